@@ -11,9 +11,15 @@ import (
 	"time"
 
 	"github.com/tbauriedel/resource-nexus-core/internal/app"
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
 	"github.com/tbauriedel/resource-nexus-core/internal/common/netutils"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
 	"github.com/tbauriedel/resource-nexus-core/internal/listener"
+	"github.com/tbauriedel/resource-nexus-core/internal/listener/routes"
 	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/provisioner"
 )
 
 func main() { //nolint:funlen,nolintlint,cyclop
@@ -48,6 +54,17 @@ func main() { //nolint:funlen,nolintlint,cyclop
 		app.Exit(nil, 1)
 	}
 
+	// 'migrate up|down|status' manages the schema of the database instead of starting resource-nexus-core
+	if flag.Arg(0) == "migrate" {
+		err = app.Migrate(conf, flag.Arg(1), os.Stdout)
+		if err != nil {
+			slog.Error(err.Error())
+			app.Exit(nil, 1)
+		}
+
+		app.Exit(nil, 0)
+	}
+
 	db, logger, logfile, err := app.Bootstrap(conf)
 	if err != nil {
 		slog.Error(err.Error())
@@ -62,6 +79,61 @@ func main() { //nolint:funlen,nolintlint,cyclop
 		}
 	}()
 
+	// record changes to the audit log. also written to the audit file, if configured
+	recorder, err := audit.NewRecorder(db, conf.Audit, logger)
+	if err != nil {
+		logger.Error(err.Error())
+		app.Exit(logfile, 1)
+	}
+
+	defer func() {
+		err = recorder.Close()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}()
+
+	//----- Provisioning -----//
+
+	// credentials of workspaces are only available if a credential key is configured
+	var cipher *credentials.Cipher
+
+	if conf.Security.CredentialKey != "" {
+		cipher, err = credentials.NewCipher(conf.Security.CredentialKey)
+		if err != nil {
+			logger.Error(err.Error())
+			app.Exit(logfile, 1)
+		}
+	}
+
+	logger.Debug("detecting installed executables")
+
+	// detect the versions of the configured executables. runs are executed with the best matching executable
+	registry := provisioner.NewRegistry(conf.Provisioner, logger)
+
+	err = registry.Probe(context.Background())
+	if err != nil {
+		logger.Warn(fmt.Sprintf("runs can't be executed. %s", err.Error()))
+	}
+
+	// hooks are executed around the phases of runs. executable hooks are isolated like the commands of the provisioner
+	hooks, err := hook.Load(conf.Provisioner.Hooks, provisioning.HookIsolator(conf.Provisioner.Isolation), logger)
+	if err != nil {
+		logger.Error(err.Error())
+		app.Exit(logfile, 1)
+	}
+
+	logger.Debug("initializing provisioning queue")
+
+	// create the queue and start the workers. queued runs of previous starts are picked up again
+	runner := provisioning.NewRunner(db, cipher, hooks, recorder, logger)
+	queue := provisioning.NewQueue(db, runner, registry, recorder, conf.Provisioner, logger)
+	queue.Start()
+
+	// enqueue drift runs for workspaces with a drift interval
+	scheduler := provisioning.NewDriftScheduler(db, queue, conf.Provisioner, logger)
+	scheduler.Start()
+
 	//----- Listener -----//
 
 	logger.Debug("initializing listener")
@@ -84,10 +156,19 @@ func main() { //nolint:funlen,nolintlint,cyclop
 			logger,
 		)),
 		listener.WithMiddleWare(listener.MiddlewareAuthentication(db, logger)), // validate user
+		listener.WithMiddleWare(listener.MiddlewareAudit(recorder)),            // record changes
 	)
 
 	// Add routes to the listener
-	l.AddRoutesToListener(db, logger)
+	l.AddRoutesToListener(routes.Routes{
+		DB:     db,
+		Logger: logger,
+		Queue:  queue,
+		Config: conf,
+
+		Executables: registry,
+		Credentials: cipher,
+	})
 
 	// Start listener in the background
 	go func() {
@@ -132,4 +213,12 @@ func main() { //nolint:funlen,nolintlint,cyclop
 	}
 
 	logger.Debug("listener stopped")
+
+	scheduler.Stop()
+
+	// wait for running runs. they are interrupted once the shutdown timeout is reached
+	queueCtx, queueCancel := context.WithTimeout(context.Background(), conf.Provisioner.ShutdownTimeout)
+	defer queueCancel()
+
+	queue.Stop(queueCtx)
 }
@@ -1,8 +1,13 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/tbauriedel/resource-nexus-core/internal/common/fileutils"
 	"github.com/tbauriedel/resource-nexus-core/internal/config"
@@ -33,17 +38,119 @@ func LoadConfig(configPath string) (config.Config, error) {
 
 	slog.Debug("starting with configuration", "config", conf.GetConfigRedacted())
 
+	// negative workers are replaced by the default, see provisioning.NewQueue
+	workers := conf.Provisioner.Workers
+	if workers < 0 {
+		workers = config.LoadDefaults().Provisioner.Workers
+	}
+
+	err = database.ValidatePool(conf.Database, workers)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("invalid database config: %w", err)
+	}
+
 	return conf, nil
 }
 
 // Bootstrap initializes the application.
 //
+// The schema of the database needs to be at the version of the embedded migrations.
 // Returns the database connection, and logger.
 // Returns an error if something went wrong.
 func Bootstrap(conf config.Config) (database.Database, *logging.Logger, *os.File, error) { //nolint:ireturn
+	db, logger, logfile, err := bootstrap(conf)
+	if err != nil {
+		return nil, nil, logfile, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// refuse to work on a schema the binary is not made for
+	err = db.CheckSchemaVersion(ctx)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, nil, logfile, err //nolint:wrapcheck
+	}
+
+	return db, logger, logfile, nil
+}
+
+// Migrate executes the migrate command against the configured database.
+//
+// Commands are 'up' (apply all pending migrations), 'down' (roll back the newest migration)
+// and 'status' (print the status of all migrations to out).
+func Migrate(conf config.Config, command string, out io.Writer) error {
+	db, logger, _, err := bootstrap(conf)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = db.Close()
+	}()
+
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		migrations, err := db.MigrateUp(ctx)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		logger.Info(fmt.Sprintf("%d migrations applied", len(migrations)))
+	case "down":
+		migration, ok, err := db.MigrateDown(ctx)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		if !ok {
+			logger.Info("no migration to roll back")
+
+			return nil
+		}
+
+		logger.Info(fmt.Sprintf("migration %04d_%s rolled back", migration.Version, migration.Name))
+	case "status":
+		status, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return printMigrationStatus(out, status)
+	default:
+		return fmt.Errorf("unknown migrate command '%s'. use 'up', 'down' or 'status'", command)
+	}
+
+	return nil
+}
+
+// printMigrationStatus prints the status of the migrations as table.
+func printMigrationStatus(out io.Writer, status []database.MigrationStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, s := range status {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+
+	return w.Flush() //nolint:wrapcheck
+}
+
+// bootstrap initializes the logger and the database connection without checking the schema.
+func bootstrap(conf config.Config) (*database.SqlDatabase, *logging.Logger, *os.File, error) {
 	var (
 		err     error
-		db      database.Database
+		db      *database.SqlDatabase
 		logger  *logging.Logger
 		logfile *os.File
 	)
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/authentication"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// ActorSystem is the actor of changes that are not done by a user. e.g. a worker that finishes a run.
+const ActorSystem = "system"
+
+// Actions of changes that are not done by api calls. Api calls use the permission of their path as action.
+const (
+	ActionRunStatus  = "provisioning:run:status"  // the status of a run changed
+	ActionRunRecover = "provisioning:run:recover" // runs of a gone worker have been recovered
+)
+
+// Recorder records changes in the audit log.
+//
+// Entries are stored inside the database and, if configured, appended to a JSON-lines file.
+// A failure to record an entry is logged, but does not fail the recorded change.
+// A nil Recorder records nothing.
+type Recorder struct {
+	db     database.Database
+	logger *logging.Logger
+
+	mu   sync.Mutex // serializes the writes to file
+	file *os.File   // nil if no file is configured
+}
+
+// NewRecorder returns a new Recorder that stores the entries inside db.
+//
+// If a file is configured, it is opened for appending and created with the permissions 0600 if it does not exist.
+func NewRecorder(db database.Database, conf config.Audit, logger *logging.Logger) (*Recorder, error) {
+	recorder := &Recorder{
+		db:     db,
+		logger: logger,
+	}
+
+	if conf.File == "" {
+		return recorder, nil
+	}
+
+	file, err := os.OpenFile(conf.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+
+	recorder.file = file
+
+	return recorder, nil
+}
+
+// Record stores the entry.
+//
+// Missing fields are taken from ctx. The actor is the authenticated user or ActorSystem if ctx holds no user.
+// The id and source ip of the request are taken from the audited request (see Request).
+// The entry is stored even if ctx is already canceled, so the changes of interrupted runs are recorded as well.
+func (r *Recorder) Record(ctx context.Context, entry database.AuditEntry) {
+	if r == nil {
+		return
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	if entry.Actor == "" {
+		entry.Actor = ActorSystem
+
+		if user, ok := authentication.UserFromContext(ctx); ok {
+			entry.Actor = user.Name
+		}
+	}
+
+	if request, ok := RequestFromContext(ctx); ok {
+		if entry.RequestID == "" {
+			entry.RequestID = request.ID
+		}
+
+		if entry.SourceIP == "" {
+			entry.SourceIP = request.SourceIP
+		}
+	}
+
+	if entry.Result == "" {
+		entry.Result = database.AuditResultSuccess
+	}
+
+	_, err := r.db.InsertAuditEntry(context.WithoutCancel(ctx), entry)
+	if err != nil {
+		r.logger.Error("failed to record audit entry", "action", entry.Action, "target", entry.Target, "error", err)
+	}
+
+	err = r.writeFile(entry)
+	if err != nil {
+		r.logger.Error("failed to write audit entry to file", "action", entry.Action, "target", entry.Target,
+			"error", err)
+	}
+}
+
+// RecordRun records a change of the status of a run. from is the status before the change. It is empty for new runs.
+//
+// Nothing is recorded if the status did not change. e.g. the phase of a running run changed.
+func (r *Recorder) RecordRun(ctx context.Context, run database.Run, from database.RunStatus) {
+	if r == nil || run.Status == from {
+		return
+	}
+
+	entry := database.AuditEntry{
+		Action: ActionRunStatus,
+		Target: Target("run", run.ID),
+		After:  Snapshot(run),
+	}
+
+	if from != "" {
+		entry.Before = Snapshot(map[string]database.RunStatus{"status": from})
+	}
+
+	r.Record(ctx, entry)
+}
+
+// Close closes the file. The database is not closed.
+func (r *Recorder) Close() error {
+	if r == nil || r.file == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close() //nolint:wrapcheck
+}
+
+// writeFile appends the entry as a single line of JSON to the file. Does nothing if no file is configured.
+func (r *Recorder) writeFile(entry database.AuditEntry) error {
+	if r.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Target returns the target of an entry for an object of the kind. e.g. 'run:42' or 'user:alice'.
+func Target(kind string, id any) string {
+	return fmt.Sprintf("%s:%v", kind, id)
+}
+
+// Snapshot returns v encoded as JSON. Returns nil if v is nil or can't be encoded.
+//
+// Snapshots must not contain secrets. e.g. use a user without its password hash.
+func Snapshot(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	snapshot, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return snapshot
+}
@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/authentication"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func newTestRecorder(t *testing.T, conf config.Audit) (*Recorder, *database.MemoryDatabase) {
+	t.Helper()
+
+	db := database.NewMemoryDatabase()
+
+	recorder, err := NewRecorder(db, conf, logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "error"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = recorder.Close()
+	})
+
+	return recorder, db
+}
+
+func listEntries(t *testing.T, db database.Database) []database.AuditEntry {
+	t.Helper()
+
+	entries, err := db.ListAuditEntries(nil, database.Page{}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries.Items
+}
+
+func TestRecord(t *testing.T) {
+	recorder, db := newTestRecorder(t, config.Audit{})
+
+	// without user and request the change is done by the system
+	recorder.Record(context.TODO(), database.AuditEntry{Action: ActionRunRecover, Target: "runs"})
+
+	ctx := authentication.ContextWithUser(context.TODO(), &authentication.User{Name: "alice"})
+	ctx = ContextWithRequest(ctx, &Request{ID: "request-1", SourceIP: "192.0.2.1"})
+
+	// the context is canceled, e.g. by a disconnected client. the entry is recorded anyway
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	recorder.Record(ctx, database.AuditEntry{
+		Action: "auth:user:delete",
+		Target: Target("user", "bob"),
+		Before: Snapshot(map[string]string{"name": "bob"}),
+		Result: database.AuditResultFailure,
+		Status: 500,
+	})
+
+	entries := listEntries(t, db)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	system := entries[0]
+	if system.Actor != ActorSystem || system.Result != database.AuditResultSuccess || system.CreatedAt.IsZero() ||
+		system.RequestID != "" {
+		t.Fatalf("unexpected system entry: %+v", system)
+	}
+
+	user := entries[1]
+	if user.Actor != "alice" || user.RequestID != "request-1" || user.SourceIP != "192.0.2.1" ||
+		user.Target != "user:bob" || user.Result != database.AuditResultFailure ||
+		string(user.Before) != `{"name":"bob"}` || user.After != nil {
+		t.Fatalf("unexpected user entry: %+v", user)
+	}
+}
+
+func TestRecordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	recorder, _ := newTestRecorder(t, config.Audit{File: path})
+
+	recorder.Record(context.TODO(), database.AuditEntry{Action: "auth:group:add", Target: "group:admins"})
+	recorder.Record(context.TODO(), database.AuditEntry{Action: "auth:group:delete", Target: "group:admins"})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected permissions 0600, got %v", info.Mode().Perm())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var actions []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry database.AuditEntry
+
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatalf("line is no valid json: %s", scanner.Text())
+		}
+
+		actions = append(actions, entry.Action)
+	}
+
+	if len(actions) != 2 || actions[0] != "auth:group:add" || actions[1] != "auth:group:delete" {
+		t.Fatalf("unexpected lines in file: %v", actions)
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	recorder, db := newTestRecorder(t, config.Audit{})
+
+	run := database.Run{ID: 42, Workspace: "vms", Status: database.RunStatusRunning, Phase: "plan"}
+
+	// only the phase changed
+	recorder.RecordRun(context.TODO(), run, database.RunStatusRunning)
+
+	run.Status = database.RunStatusApplied
+	recorder.RecordRun(context.TODO(), run, database.RunStatusRunning)
+
+	entries := listEntries(t, db)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Action != ActionRunStatus || entry.Target != "run:42" || string(entry.Before) != `{"status":"running"}` {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	var after database.Run
+
+	err := json.Unmarshal(entry.After, &after)
+	if err != nil || after.Status != database.RunStatusApplied || after.Workspace != "vms" {
+		t.Fatalf("unexpected snapshot after the change: %s", entry.After)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var recorder *Recorder
+
+	recorder.Record(context.TODO(), database.AuditEntry{Action: ActionRunRecover})
+	recorder.RecordRun(context.TODO(), database.Run{Status: database.RunStatusQueued}, "")
+
+	err := recorder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+type contextKey string
+
+const (
+	requestKey contextKey = "audit-request"
+)
+
+// Request is an api call that is audited. The listener stores it inside the context of the request.
+//
+// Handlers describe the change they have done with Describe. Calls without description are recorded without target.
+type Request struct {
+	ID       string // id of the request. Returned in the header X-Request-ID
+	SourceIP string
+
+	mu     sync.Mutex
+	target string
+	before any
+	after  any
+}
+
+// ContextWithRequest returns a copy of ctx that holds the audited request.
+func ContextWithRequest(ctx context.Context, request *Request) context.Context {
+	return context.WithValue(ctx, requestKey, request)
+}
+
+// RequestFromContext returns the audited request stored inside ctx.
+// Returns false if no request is stored.
+func RequestFromContext(ctx context.Context) (*Request, bool) {
+	request, ok := ctx.Value(requestKey).(*Request)
+
+	return request, ok && request != nil
+}
+
+// Describe sets the target of the audited request inside ctx and its snapshots before and after the change.
+//
+// before is nil for created targets, after is nil for deleted targets. The snapshots are encoded by Snapshot, so
+// they must not contain secrets. Does nothing if ctx holds no audited request.
+func Describe(ctx context.Context, target string, before any, after any) {
+	request, ok := RequestFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	request.mu.Lock()
+	defer request.mu.Unlock()
+
+	request.target = target
+	request.before = before
+	request.after = after
+}
+
+// Entry returns the entry of the request for the action, which finished with the http status.
+// Requests with a status of 400 or above are recorded as failures.
+func (r *Request) Entry(action string, status int) database.AuditEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := database.AuditResultSuccess
+	if status >= http.StatusBadRequest {
+		result = database.AuditResultFailure
+	}
+
+	return database.AuditEntry{
+		Action:    action,
+		Target:    r.target,
+		Before:    Snapshot(r.before),
+		After:     Snapshot(r.after),
+		RequestID: r.ID,
+		SourceIP:  r.SourceIP,
+		Result:    result,
+		Status:    status,
+	}
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestDescribe(t *testing.T) {
+	// does nothing without an audited request
+	Describe(context.TODO(), "user:alice", nil, nil)
+
+	request := &Request{ID: "request-1", SourceIP: "192.0.2.1"}
+	ctx := ContextWithRequest(context.TODO(), request)
+
+	stored, ok := RequestFromContext(ctx)
+	if !ok || stored != request {
+		t.Fatal("request not stored inside the context")
+	}
+
+	Describe(ctx, "group:admins", map[string]string{"name": "admins"}, map[string]string{"name": "operators"})
+
+	entry := request.Entry("auth:group:update", http.StatusOK)
+	if entry.Target != "group:admins" || entry.RequestID != "request-1" || entry.SourceIP != "192.0.2.1" ||
+		entry.Result != database.AuditResultSuccess || string(entry.Before) != `{"name":"admins"}` ||
+		string(entry.After) != `{"name":"operators"}` {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	entry = request.Entry("auth:group:update", http.StatusBadRequest)
+	if entry.Result != database.AuditResultFailure || entry.Status != http.StatusBadRequest {
+		t.Fatalf("expected a failure, got %+v", entry)
+	}
+}
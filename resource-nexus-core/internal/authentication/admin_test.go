@@ -0,0 +1,40 @@
+package authentication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestCreateAdminUser(t *testing.T) {
+	db := database.NewMemoryDatabase()
+	params := config.HashingParams{
+		Iterations:   1,
+		MemoryCost:   16 * 1024,
+		ThreadsCount: 1,
+		KeyLength:    32,
+		SaltLength:   16,
+	}
+
+	err := CreateAdminUser("secret", params, db, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := LoadUser("admin", db, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !user.IsAdmin || user.Authenticate("secret") != nil {
+		t.Fatalf("admin user should be able to authenticate: %v", user)
+	}
+
+	// the admin user can only be created once
+	err = CreateAdminUser("other", params, db, context.TODO())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -8,6 +8,12 @@ import (
 	"github.com/tbauriedel/resource-nexus-core/internal/database"
 )
 
+type contextKey string
+
+const (
+	userKey contextKey = "user"
+)
+
 type User struct {
 	ID              int
 	Name            string
@@ -87,3 +93,16 @@ func (user *User) HasPermission(permission string) bool {
 
 	return slices.Contains(user.Permissions, permission)
 }
+
+// ContextWithUser returns a copy of ctx that holds the authenticated user.
+func ContextWithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the authenticated user stored inside ctx.
+// Returns false if no user is stored.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey).(*User)
+
+	return user, ok && user != nil
+}
@@ -67,6 +67,13 @@ func TestLoadUser(t *testing.T) {
 	}
 }
 
+func TestLoadUserNotFound(t *testing.T) {
+	_, err := LoadUser("unknown", database.NewMemoryDatabase(), context.TODO())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 func TestAuthenticate(t *testing.T) {
 	// pass: foobar
 	user := User{
@@ -110,3 +117,17 @@ func TestHasPermission(t *testing.T) {
 		t.Fatal("should have permission")
 	}
 }
+
+func TestUserFromContext(t *testing.T) {
+	_, ok := UserFromContext(context.TODO())
+	if ok {
+		t.Fatal("no user should be returned from an empty context")
+	}
+
+	ctx := ContextWithUser(context.TODO(), &User{Name: "dummy"})
+
+	user, ok := UserFromContext(ctx)
+	if !ok || user.Name != "dummy" {
+		t.Fatal("stored user not returned")
+	}
+}
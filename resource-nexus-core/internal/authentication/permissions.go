@@ -1,13 +1,39 @@
 package authentication
 
+import "github.com/tbauriedel/resource-nexus-core/internal/database"
+
 // permissions return the permissions map.
 func permissions() map[string]string {
 	return map[string]string{
-		"/system/health":            "system:health:get",
-		"/auth/user/add":            "auth:user:add",
-		"/auth/group/add":           "auth:group:add",
-		"/auth/usergroup/add":       "auth:usergroup:add",
-		"/auth/grouppermission/add": "auth:grouppermission:add",
+		"/system/health":               "system:health:get",
+		"/system/audit/list":           "system:audit:get",
+		"/auth/user/list":              "auth:user:get",
+		"/auth/user/add":               "auth:user:add",
+		"/auth/user/update":            "auth:user:update",
+		"/auth/user/delete":            "auth:user:delete",
+		"/auth/group/list":             "auth:group:get",
+		"/auth/group/add":              "auth:group:add",
+		"/auth/group/update":           "auth:group:update",
+		"/auth/group/delete":           "auth:group:delete",
+		"/auth/usergroup/add":          "auth:usergroup:add",
+		"/auth/usergroup/delete":       "auth:usergroup:delete",
+		"/auth/grouppermission/add":    "auth:grouppermission:add",
+		"/auth/grouppermission/delete": "auth:grouppermission:delete",
+
+		"/provisioning/workspace/add":    "provisioning:workspace:add",
+		"/provisioning/workspace/list":   "provisioning:workspace:get",
+		"/provisioning/workspace/lock":   "provisioning:workspace:get",
+		"/provisioning/workspace/unlock": "provisioning:workspace:unlock",
+		"/provisioning/executable/list":  "provisioning:workspace:get",
+		"/provisioning/credential/add":   "provisioning:credential:add",
+		"/provisioning/credential/list":  "provisioning:credential:get",
+		"/provisioning/run/add":          "provisioning:run:add",
+		"/provisioning/run/list":         "provisioning:run:get",
+		"/provisioning/run/get":          "provisioning:run:get",
+		"/provisioning/run/events":       "provisioning:run:get",
+		"/provisioning/run/plan":         "provisioning:run:get",
+		"/provisioning/run/approve":      "provisioning:run:apply",
+		"/provisioning/run/cancel":       "provisioning:run:cancel",
 	}
 }
 
@@ -21,6 +47,30 @@ func GetPermissionForPath(url string) (string, bool) {
 	return perm, true
 }
 
+// GetPermissionsForRunOptions returns the permissions that are needed in addition to the permission of the path to
+// queue a run with the given options.
+func GetPermissionsForRunOptions(options database.RunOptions) []string {
+	var perms []string
+
+	if len(options.Targets) > 0 {
+		perms = append(perms, "provisioning:run:target")
+	}
+
+	if len(options.Replace) > 0 {
+		perms = append(perms, "provisioning:run:replace")
+	}
+
+	if options.RefreshOnly {
+		perms = append(perms, "provisioning:run:refresh")
+	}
+
+	if options.Destroy {
+		perms = append(perms, "provisioning:run:destroy")
+	}
+
+	return perms
+}
+
 // BuildPermissionString builds a permission string from category, action and resource.
 //
 // Format: category:action:resource.
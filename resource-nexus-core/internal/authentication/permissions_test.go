@@ -1,7 +1,10 @@
 package authentication
 
 import (
+	"slices"
 	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
 )
 
 func TestPermissions(t *testing.T) {
@@ -34,6 +37,17 @@ func TestGetPermissionForPath(t *testing.T) {
 	}
 }
 
+func TestGetPermissionsForRunOptions(t *testing.T) {
+	if p := GetPermissionsForRunOptions(database.RunOptions{}); len(p) != 0 {
+		t.Fatalf("no permissions expected, got %v", p)
+	}
+
+	p := GetPermissionsForRunOptions(database.RunOptions{Targets: []string{"module.web"}, Destroy: true})
+	if !slices.Equal(p, []string{"provisioning:run:target", "provisioning:run:destroy"}) {
+		t.Fatalf("wrong permissions returned: %v", p)
+	}
+}
+
 func TestBuildPermissionString(t *testing.T) {
 	actual := BuildPermissionString("security", "user", "create")
 	expected := "security:user:create"
@@ -0,0 +1,132 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operators in the order they are matched. longer operators first.
+var operators = []string{">=", "<=", "~>", "!=", ">", "<", "="} //nolint:gochecknoglobals
+
+// Constraint is a version constraint in the syntax of terraform. e.g. '>= 1.5.0, < 2.0.0' or '~> 1.9'.
+//
+// Multiple conditions are separated by comma. All conditions need to match.
+type Constraint struct {
+	conditions []condition
+	raw        string
+}
+
+// condition is a single condition of a Constraint.
+type condition struct {
+	operator  string
+	version   Version
+	precision int // number of given version parts. needed for '~>'
+}
+
+// ParseConstraint parses a version constraint. An empty constraint matches all versions, except prereleases.
+func ParseConstraint(s string) (Constraint, error) {
+	constraint := Constraint{raw: strings.TrimSpace(s)}
+
+	if constraint.raw == "" {
+		return constraint, nil
+	}
+
+	for _, part := range strings.Split(constraint.raw, ",") {
+		part = strings.TrimSpace(part)
+
+		operator := "="
+
+		for _, o := range operators {
+			if strings.HasPrefix(part, o) {
+				operator = o
+				part = strings.TrimSpace(strings.TrimPrefix(part, o))
+
+				break
+			}
+		}
+
+		version, err := Parse(part)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+
+		core, _, _ := strings.Cut(strings.TrimPrefix(part, "v"), "-")
+
+		constraint.conditions = append(constraint.conditions, condition{
+			operator:  operator,
+			version:   version,
+			precision: len(strings.Split(core, ".")),
+		})
+	}
+
+	return constraint, nil
+}
+
+// String returns the constraint as it has been parsed.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check returns true if the version matches all conditions.
+//
+// Prereleases only match if a condition refers to a prerelease of the same version.
+func (c Constraint) Check(v Version) bool {
+	if v.Prerelease != "" && !c.allowsPrerelease(v) {
+		return false
+	}
+
+	for _, cond := range c.conditions {
+		if !cond.check(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// allowsPrerelease returns true if a condition refers to a prerelease of the same version as v.
+func (c Constraint) allowsPrerelease(v Version) bool {
+	for _, cond := range c.conditions {
+		if cond.version.Prerelease != "" && cond.version.sameCore(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns true if the version matches the condition.
+func (cond condition) check(v Version) bool {
+	cmp := v.Compare(cond.version)
+
+	switch cond.operator {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "~>":
+		return cmp >= 0 && v.Compare(cond.pessimisticLimit()) < 0
+	default:
+		return false
+	}
+}
+
+// pessimisticLimit returns the exclusive upper limit of '~>'.
+//
+// Only the rightmost given version part is allowed to increase. '~> 1.2' allows 1.x, '~> 1.2.3' allows 1.2.x.
+func (cond condition) pessimisticLimit() Version {
+	switch cond.precision {
+	case 1, 2:
+		return Version{Major: cond.version.Major + 1}
+	default:
+		return Version{Major: cond.version.Major, Minor: cond.version.Minor + 1}
+	}
+}
@@ -0,0 +1,49 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{"", "1.9.5", true},
+		{"1.9.5", "1.9.5", true},
+		{"= 1.9.5", "1.9.6", false},
+		{">= 1.5.0, < 2.0.0", "1.9.5", true},
+		{">= 1.5.0, < 2.0.0", "2.0.0", false},
+		{"!= 1.9.5", "1.9.5", false},
+		{"~> 1.9", "1.12.0", true},
+		{"~> 1.9", "2.0.0", false},
+		{"~> 1.9.2", "1.9.8", true},
+		{"~> 1.9.2", "1.10.0", false},
+		{">= 1.9.0", "1.10.0-beta1", false}, // prereleases only if requested explicitly
+		{"1.10.0-beta1", "1.10.0-beta1", true},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := Parse(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Check(v) != tt.expected {
+			t.Fatalf("wrong result for %s matching %q", tt.version, tt.constraint)
+		}
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	_, err := ParseConstraint(">= one")
+	if err == nil {
+		t.Fatal("invalid constraint should return an error")
+	}
+}
@@ -0,0 +1,95 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version. e.g. 1.9.5 or 1.10.0-beta1.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
+
+// Parse parses a version in the format '[v]major[.minor[.patch]][-prerelease][+metadata]'.
+//
+// Missing minor and patch numbers are set to 0. Build metadata is ignored.
+func Parse(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	// build metadata has no precedence
+	s, _, _ = strings.Cut(s, "+")
+
+	core, prerelease, _ := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	numbers := [3]int{}
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+
+		numbers[i] = n
+	}
+
+	return Version{
+		Major:      numbers[0],
+		Minor:      numbers[1],
+		Patch:      numbers[2],
+		Prerelease: prerelease,
+	}, nil
+}
+
+// String returns the version in the format 'major.minor.patch[-prerelease]'.
+func (v Version) String() string {
+	if v.Prerelease != "" {
+		return fmt.Sprintf("%d.%d.%d-%s", v.Major, v.Minor, v.Patch, v.Prerelease)
+	}
+
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// MarshalText encodes the version as text. Used to encode the version as JSON string.
+func (v Version) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Compare returns -1 if v is lower than o, 1 if v is greater than o and 0 if both are equal.
+//
+// A prerelease is lower than the release of the same version. Prereleases are compared lexically.
+func (v Version) Compare(o Version) int {
+	for _, c := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] != c[1] {
+			if c[0] < c[1] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	default:
+		return strings.Compare(v.Prerelease, o.Prerelease)
+	}
+}
+
+// sameCore returns true if major, minor and patch of both versions are equal.
+func (v Version) sameCore(o Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
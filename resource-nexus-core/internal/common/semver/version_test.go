@@ -0,0 +1,55 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Version
+		valid    bool
+	}{
+		{"1.9.5", Version{Major: 1, Minor: 9, Patch: 5}, true},
+		{"v1.1.0", Version{Major: 1, Minor: 1}, true},
+		{"1.10", Version{Major: 1, Minor: 10}, true},
+		{"1.10.0-beta1", Version{Major: 1, Minor: 10, Prerelease: "beta1"}, true},
+		{"1.8.0+dev", Version{Major: 1, Minor: 8}, true},
+		{"1.a.0", Version{}, false},
+		{"1.2.3.4", Version{}, false},
+		{"", Version{}, false},
+	}
+
+	for _, tt := range tests {
+		v, err := Parse(tt.input)
+		if (err == nil) != tt.valid {
+			t.Fatalf("wrong parse result for %q: %v", tt.input, err)
+		}
+
+		if v != tt.expected {
+			t.Fatalf("wrong version parsed from %q: %s", tt.input, v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.9.5", "1.9.5", 0},
+		{"1.9.5", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.10.0-beta1", "1.10.0", -1},
+		{"1.10.0-beta2", "1.10.0-beta1", 1},
+	}
+
+	for _, tt := range tests {
+		a, _ := Parse(tt.a)
+		b, _ := Parse(tt.b)
+
+		if a.Compare(b) != tt.expected {
+			t.Fatalf("wrong comparison of %s and %s", tt.a, tt.b)
+		}
+	}
+}
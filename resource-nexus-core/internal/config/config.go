@@ -14,6 +14,7 @@ type Config struct {
 	Listener    Listener    `json:"listener"`
 	Security    Security    `json:"security"`
 	Provisioner Provisioner `json:"provisioner"`
+	Audit       Audit       `json:"audit"`
 }
 
 var (
@@ -29,12 +30,20 @@ func LoadDefaults() Config {
 			Level: "info",
 		},
 		Database: Database{
-			Address:  "localhost",
-			Port:     5432,
-			User:     "",
-			Password: "",
-			Name:     "resource-nexus",
-			TLSMode:  "verify-full",
+			Type:            "postgres",
+			File:            "resource-nexus.db",
+			Address:         "localhost",
+			Port:            5432,
+			User:            "",
+			Password:        "",
+			Name:            "resource-nexus",
+			TLSMode:         "verify-full",
+			ApplicationName: "resource-nexus-core",
+			ConnectTimeout:  10 * time.Second,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
 		},
 		Listener: Listener{
 			ListenAddr:                 ":4890",
@@ -57,6 +66,23 @@ func LoadDefaults() Config {
 		},
 		Provisioner: Provisioner{
 			AllowedExecutables: "/usr/local/bin/terraform",
+			Workers:            2,
+			PollInterval:       5 * time.Second,
+			MaxAttempts:        3,
+			RetryBackoff:       30 * time.Second,
+			HeartbeatInterval:  30 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+			CancelGracePeriod:  60 * time.Second,
+			DriftCheckInterval: time.Minute,
+			Timeouts: Timeouts{
+				Init:  10 * time.Minute,
+				Plan:  30 * time.Minute,
+				Apply: time.Hour,
+			},
+			Ansible: Ansible{
+				InventoryOutput: "ansible_hosts",
+				FailurePolicy:   "fail",
+			},
 		},
 	}
 }
@@ -85,6 +111,7 @@ func LoadFromJSONFile(file string) (Config, error) {
 // Sensitive testdata includes:
 //   - Database.User
 //   - Database.Password
+//   - Security.CredentialKey
 func (c Config) GetConfigRedacted() Config {
 	sanitized := c
 
@@ -96,5 +123,9 @@ func (c Config) GetConfigRedacted() Config {
 		sanitized.Database.Password = redactionPlaceholder
 	}
 
+	if c.Security.CredentialKey != "" {
+		sanitized.Security.CredentialKey = redactionPlaceholder
+	}
+
 	return sanitized
 }
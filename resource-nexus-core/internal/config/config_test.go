@@ -22,10 +22,15 @@ func TestGetConfigRedacted(t *testing.T) {
 	c := LoadDefaults()
 	c.Database.User = "foo"
 	c.Database.Password = "bar"
+	c.Security.CredentialKey = "key"
 
 	sanitized := c.GetConfigRedacted()
 
 	if sanitized.Database.Password != redactionPlaceholder || sanitized.Database.User != redactionPlaceholder {
 		t.Fatal("password and user should be redacted")
 	}
+
+	if sanitized.Security.CredentialKey != redactionPlaceholder {
+		t.Fatal("credential key should be redacted")
+	}
 }
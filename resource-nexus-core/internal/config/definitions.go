@@ -29,17 +29,36 @@ type Logger struct {
 }
 
 // Database represents the database configuration.
+// Settings of the connection pool with the value 0 are not applied.
 type Database struct {
-	Address  string `json:"address"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
-	TLSMode  string `json:"tlsmode"`
+	Type             string        `json:"type"` // 'postgres' or 'sqlite'
+	File             string        `json:"file"` // database file of SQLite
+	Address          string        `json:"address"`
+	Port             int           `json:"port"`
+	User             string        `json:"user"`
+	Password         string        `json:"password"`
+	Name             string        `json:"name"`
+	TLSMode          string        `json:"tlsmode"`
+	TLSRootCert      string        `json:"tlsRootCert"`      // CA certificate the server certificate is verified with
+	TLSCert          string        `json:"tlsCert"`          // Client certificate
+	TLSKey           string        `json:"tlsKey"`           // Private key of the client certificate
+	ApplicationName  string        `json:"applicationName"`  // Name of the connections shown by pg_stat_activity
+	ConnectTimeout   time.Duration `json:"connectTimeout"`   // Timeout of establishing a connection. Rounded up to seconds
+	StatementTimeout time.Duration `json:"statementTimeout"` // Statements running longer are aborted by the server
+	MaxOpenConns     int           `json:"maxOpenConns"`     // Maximum open connections of the pool
+	MaxIdleConns     int           `json:"maxIdleConns"`     // Maximum idle connections kept by the pool
+	ConnMaxLifetime  time.Duration `json:"connMaxLifetime"`  // Connections are closed after they were open this long
+	ConnMaxIdleTime  time.Duration `json:"connMaxIdleTime"`  // Connections are closed after they were idle this long
+}
+
+// Audit represents the configuration of the audit log. Entries are always stored inside the database.
+type Audit struct {
+	File string `json:"file"` // JSON-lines file every entry is appended to. Empty disables the file
 }
 
 type Security struct {
 	PasswordHashing HashingParams `json:"passwordHashing"`
+	CredentialKey   string        `json:"credentialKey"` // Base64 encoded 32 byte key to encrypt credentials of workspaces
 }
 
 type HashingParams struct {
@@ -52,5 +71,60 @@ type HashingParams struct {
 }
 
 type Provisioner struct {
-	AllowedExecutables string `json:"allowedExecutables"` // comma separated list of allowed executables
+	Executables        []Executable  `json:"executables"`        // Installed executables that can be used for runs
+	AllowedExecutables string        `json:"allowedExecutables"` // Deprecated: use Executables. comma separated paths
+	Workers            int           `json:"workers"`            // Number of workers that execute queued runs
+	PollInterval       time.Duration `json:"pollInterval"`       // Interval in which idle workers check for queued runs
+	MaxAttempts        int           `json:"maxAttempts"`        // Maximum attempts of a run. Only init and plan are retried
+	RetryBackoff       time.Duration `json:"retryBackoff"`       // Delay before a failed run is attempted again
+	HeartbeatInterval  time.Duration `json:"heartbeatInterval"`  // Interval in which workers mark their runs as alive
+	ShutdownTimeout    time.Duration `json:"shutdownTimeout"`    // Time to wait for running runs on shutdown
+	CancelGracePeriod  time.Duration `json:"cancelGracePeriod"`  // Time between interrupt and kill of a canceled command
+	DriftCheckInterval time.Duration `json:"driftCheckInterval"` // Interval in which workspaces are checked for due drift runs
+	Timeouts           Timeouts      `json:"timeouts"`           // Timeouts of the phases of runs
+	Isolation          Isolation     `json:"isolation"`          // Isolation of the executed commands
+	Ansible            Ansible       `json:"ansible"`            // Post-apply stage that runs ansible-playbook
+	Hooks              []Hook        `json:"hooks"`              // Hooks that are executed around the phases of runs
+}
+
+// Timeouts represents the timeouts of the phases of runs. Timeouts with the value 0 are not applied.
+// Workspaces can override each timeout.
+type Timeouts struct {
+	Init  time.Duration `json:"init"`  // Timeout of init and the phases that read the state. e.g. state pull
+	Plan  time.Duration `json:"plan"`  // Timeout of plan and the rendering of the plan
+	Apply time.Duration `json:"apply"` // Timeout of apply
+}
+
+// Hook represents a hook that is executed at a point of a run. Either path or builtin needs to be set.
+type Hook struct {
+	Name         string        `json:"name"`         // Unique name of the hook. Used for annotations and messages
+	Point        string        `json:"point"`        // Point of the run. e.g. "pre-apply"
+	Path         string        `json:"path"`         // Absolute path of an executable hook
+	Builtin      string        `json:"builtin"`      // Name of a built-in hook
+	Timeout      time.Duration `json:"timeout"`      // Timeout of an executable hook
+	IgnoreErrors bool          `json:"ignoreErrors"` // Failures of the hook are logged instead of failing the run
+}
+
+// Ansible represents the post-apply stage. It runs the playbook of a workspace against the created hosts.
+type Ansible struct {
+	ExecutablePath  string `json:"executablePath"`  // Allowed ansible-playbook executable. Empty disables the stage
+	InventoryOutput string `json:"inventoryOutput"` // Terraform output the inventory is generated from
+	FailurePolicy   string `json:"failurePolicy"`   // "fail" finishes the run as errored, "continue" as applied
+}
+
+// Isolation represents the isolation of the executed provisioning commands.
+// Settings with the value 0 are not applied.
+type Isolation struct {
+	Uid             uint32        `json:"uid"`             // Uid the commands are executed as. 0 keeps the uid
+	Gid             uint32        `json:"gid"`             // Gid the commands are executed as. 0 keeps the gid
+	RunDirectory    string        `json:"runDirectory"`    // Parent directory of the private HOME and TMPDIR of each run
+	MaxCPUTime      time.Duration `json:"maxCpuTime"`      // CPU time of each process (RLIMIT_CPU)
+	MaxAddressSpace uint64        `json:"maxAddressSpace"` // Address space of each process in bytes (RLIMIT_AS)
+	MaxOpenFiles    uint64        `json:"maxOpenFiles"`    // Open files of each process (RLIMIT_NOFILE)
+}
+
+type Executable struct {
+	Name string `json:"name"` // Unique name of the executable. e.g. "terraform-1.9"
+	Type string `json:"type"` // Type of the provisioner. "terraform" or "opentofu"
+	Path string `json:"path"` // Absolute path of the executable
 }
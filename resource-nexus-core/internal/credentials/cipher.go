@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// keySize is the size of the credential key in bytes. AES-256 is used.
+const keySize = 32
+
+var (
+	// ErrNoKey is returned if credentials are used without a configured credential key.
+	ErrNoKey = errors.New("no credential key configured")
+	// ErrDecrypt is returned if a value can't be decrypted. e.g. the credential key has changed.
+	ErrDecrypt = errors.New("failed to decrypt value")
+)
+
+// Cipher encrypts and decrypts credential values with AES-256-GCM.
+//
+// Encrypted values consist of the random nonce followed by the sealed value.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a new Cipher for the base64 encoded key.
+//
+// The key needs to be 32 bytes long. Returns ErrNoKey if key is empty.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, ErrNoKey
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode credential key: %w", err)
+	}
+
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("credential key needs to be %d bytes long. got %d bytes", keySize, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts the value. Every call uses a new random nonce.
+func (c *Cipher) Encrypt(value []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, value, nil), nil
+}
+
+// Decrypt decrypts a value encrypted with Encrypt.
+//
+// Returns ErrDecrypt if the value has been modified or was encrypted with another key.
+func (c *Cipher) Decrypt(encrypted []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(encrypted) < size {
+		return nil, ErrDecrypt
+	}
+
+	value, err := c.aead.Open(nil, encrypted[:size], encrypted[size:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return value, nil
+}
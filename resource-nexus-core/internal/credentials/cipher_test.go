@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// testKey is a valid base64 encoded credential key.
+var testKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, keySize)) //nolint:gochecknoglobals
+
+func TestNewCipher(t *testing.T) {
+	_, err := NewCipher("")
+	if !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey, got %v", err)
+	}
+
+	_, err = NewCipher(base64.StdEncoding.EncodeToString([]byte("too short")))
+	if err == nil {
+		t.Fatal("short key should be rejected")
+	}
+
+	_, err = NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCipherEncryptDecrypt(t *testing.T) {
+	c, err := NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := c.Encrypt([]byte("s3cr3t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(encrypted, []byte("s3cr3t")) {
+		t.Fatal("value is not encrypted")
+	}
+
+	again, _ := c.Encrypt([]byte("s3cr3t"))
+	if bytes.Equal(encrypted, again) {
+		t.Fatal("every encryption should use a new nonce")
+	}
+
+	value, err := c.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(value) != "s3cr3t" {
+		t.Fatalf("wrong value decrypted: %s", value)
+	}
+
+	encrypted[len(encrypted)-1] ^= 0xff
+
+	_, err = c.Decrypt(encrypted)
+	if !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt for modified value, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+// MaskPlaceholder replaces secret values.
+const MaskPlaceholder = "********"
+
+// Masker replaces secret values inside output.
+//
+// Besides the plain value, the JSON escaped form of each value is replaced, so values inside machine-readable output
+// are masked as well.
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// NewMasker returns a new Masker for the given secret values. Empty values are ignored.
+func NewMasker(secrets []string) *Masker {
+	var forms []string
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		forms = append(forms, secret)
+
+		// json escaped form without the surrounding quotes
+		encoded, err := json.Marshal(secret)
+		if err == nil {
+			if escaped := string(encoded[1 : len(encoded)-1]); escaped != secret {
+				forms = append(forms, escaped)
+			}
+		}
+	}
+
+	if len(forms) == 0 {
+		return &Masker{}
+	}
+
+	// replace the longest values first. a value could contain another value
+	slices.SortFunc(forms, func(a, b string) int {
+		return len(b) - len(a)
+	})
+
+	pairs := make([]string, 0, len(forms)*2)
+
+	for _, form := range forms {
+		pairs = append(pairs, form, MaskPlaceholder)
+	}
+
+	return &Masker{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Mask returns s with all secret values replaced by MaskPlaceholder.
+func (m *Masker) Mask(s string) string {
+	if m == nil || m.replacer == nil {
+		return s
+	}
+
+	return m.replacer.Replace(s)
+}
+
+// Reader returns a reader that masks r line by line.
+//
+// Values are only masked if they don't span multiple lines.
+func (m *Masker) Reader(r io.Reader) io.Reader {
+	if m == nil || m.replacer == nil {
+		return r
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		reader := bufio.NewReader(r)
+
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				_, werr := pw.Write([]byte(m.Mask(string(line))))
+				if werr != nil {
+					// reader has been closed. drain r, so the writing process is not blocked
+					_, _ = io.Copy(io.Discard, reader)
+
+					return
+				}
+			}
+
+			if errors.Is(err, io.EOF) {
+				_ = pw.Close()
+
+				return
+			}
+
+			if err != nil {
+				_ = pw.CloseWithError(err)
+
+				return
+			}
+		}
+	}()
+
+	return pr
+}
+
+// MaskBytes returns b with all secret values replaced by MaskPlaceholder.
+func (m *Masker) MaskBytes(b []byte) []byte {
+	if m == nil || m.replacer == nil {
+		return b
+	}
+
+	return []byte(m.Mask(string(b)))
+}
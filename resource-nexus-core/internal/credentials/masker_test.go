@@ -0,0 +1,35 @@
+package credentials
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMaskerMask(t *testing.T) {
+	m := NewMasker([]string{"s3cr3t", "", `pa"ss`})
+
+	masked := m.Mask(`token=s3cr3t {"password":"pa\"ss"} pa"ss`)
+	if masked != `token=`+MaskPlaceholder+` {"password":"`+MaskPlaceholder+`"} `+MaskPlaceholder {
+		t.Fatalf("wrong masked output: %s", masked)
+	}
+
+	// empty masker returns the input
+	if NewMasker(nil).Mask("foo") != "foo" {
+		t.Fatal("empty masker should not modify the input")
+	}
+}
+
+func TestMaskerReader(t *testing.T) {
+	m := NewMasker([]string{"s3cr3t"})
+
+	out, err := io.ReadAll(m.Reader(strings.NewReader("line s3cr3t\nother line\nlast s3cr3t")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "line " + MaskPlaceholder + "\nother line\nlast " + MaskPlaceholder
+	if string(out) != expected {
+		t.Fatalf("wrong masked output: %q", out)
+	}
+}
@@ -0,0 +1,48 @@
+package credentials
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// namePattern matches valid names of environment variables.
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedNames returns the names of environment variables that can't be set by credentials.
+// They are set by resource-nexus-core itself or change the behavior of the provisioner.
+func reservedNames() []string {
+	return []string{
+		"PATH", "HOME", "TMPDIR", "LANG",
+		"TF_IN_AUTOMATION", "TF_INPUT", "TF_DATA_DIR", "TF_WORKSPACE", "TF_CLI_CONFIG_FILE", "TF_REATTACH_PROVIDERS",
+	}
+}
+
+// reservedPrefixes returns the prefixes of environment variables that can't be set by credentials.
+func reservedPrefixes() []string {
+	return []string{"TF_CLI_ARGS", "TF_LOG", "TF_PLUGIN_", "LD_", "DYLD_"}
+}
+
+// ValidateName validates the name of a credential.
+//
+// The name is used as name of the environment variable. e.g. TF_VAR_token or PM_API_TOKEN_SECRET.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid credential name '%s'. only letters, digits and underscores are allowed", name)
+	}
+
+	upper := strings.ToUpper(name)
+
+	if slices.Contains(reservedNames(), upper) {
+		return fmt.Errorf("credential name '%s' is reserved", name)
+	}
+
+	for _, prefix := range reservedPrefixes() {
+		if strings.HasPrefix(upper, prefix) {
+			return fmt.Errorf("credential name '%s' is reserved", name)
+		}
+	}
+
+	return nil
+}
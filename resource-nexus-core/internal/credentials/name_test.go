@@ -0,0 +1,17 @@
+package credentials
+
+import "testing"
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"TF_VAR_token", "PM_API_TOKEN_SECRET", "AWS_ACCESS_KEY_ID", "_x"} {
+		if err := ValidateName(name); err != nil {
+			t.Fatalf("name should be valid: %s", err)
+		}
+	}
+
+	for _, name := range []string{"", "1ABC", "A-B", "A B", "PATH", "path", "TF_CLI_ARGS_plan", "TF_LOG", "LD_PRELOAD"} {
+		if err := ValidateName(name); err == nil {
+			t.Fatalf("name should be invalid: '%s'", name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package database
+
+// listColumns holds the columns of each table that list queries can filter and order by.
+// Columns that hold secrets (e.g. password hashes) are left out on purpose.
+var listColumns = map[string][]string{
+	TableNameWorkspaces: {
+		"id", "name", "working_directory", "executable_path", "provisioner", "required_version", "playbook",
+		"created_at", "drift_interval", "drift_status", "drift_checked_at", "drift_run_id", "init_timeout",
+		"plan_timeout", "apply_timeout",
+	},
+	TableNameCredentials: {"id", "workspace", "name", "created_at", "updated_at"},
+	TableNameRuns: {
+		"id", "workspace", "kind", "status", "phase", "message", "attempts", "created_at", "started_at", "finished_at",
+		"approved_at", "canceled_by", "post_apply_status",
+	},
+	TableNameRunEvents: {"id", "run_id", "phase", "type", "level", "message", "timestamp"},
+	TableNameUsers:     {"id", "name", "is_admin"},
+	TableNameGroups:    {"id", "name"},
+	TableNameAuditLog: {
+		"id", "created_at", "actor", "action", "target", "request_id", "source_ip", "result", "status",
+	},
+}
+
+// listSamples holds a row of each table that supports list queries. The values hold the types of the columns.
+var listSamples = map[string]map[string]any{
+	TableNameWorkspaces:  workspaceRow(Workspace{}),
+	TableNameCredentials: credentialRow(Credential{}),
+	TableNameRuns:        runRow(Run{}),
+	TableNameRunEvents:   runEventRow(RunEvent{}),
+	TableNameUsers:       userRow(User{}),
+	TableNameGroups:      groupRow(Group{}),
+	TableNameAuditLog:    auditRow(AuditEntry{}),
+}
+
+// The row functions return the columns of a row by name. The values keep the types of the columns.
+// e.g. pointers for nullable columns. The SqlDatabase uses them for the keyset of list queries,
+// the MemoryDatabase to evaluate filters.
+
+func workspaceRow(workspace Workspace) map[string]any {
+	return map[string]any{
+		"id":                workspace.ID,
+		"name":              workspace.Name,
+		"working_directory": workspace.WorkingDirectory,
+		"executable_path":   workspace.ExecutablePath,
+		"provisioner":       workspace.Provisioner,
+		"required_version":  workspace.RequiredVersion,
+		"created_at":        workspace.CreatedAt,
+		"drift_interval":    workspace.DriftInterval,
+		"drift_status":      workspace.DriftStatus,
+		"drift_checked_at":  workspace.DriftCheckedAt,
+		"drift_run_id":      workspace.DriftRunID,
+		"playbook":          workspace.Playbook,
+		"init_timeout":      workspace.InitTimeout,
+		"plan_timeout":      workspace.PlanTimeout,
+		"apply_timeout":     workspace.ApplyTimeout,
+	}
+}
+
+func workspaceLockRow(lock WorkspaceLockInfo) map[string]any {
+	return map[string]any{
+		"workspace":   lock.Workspace,
+		"run_id":      lock.RunID,
+		"holder":      lock.Holder,
+		"backend_pid": lock.BackendPID,
+		"acquired_at": lock.AcquiredAt,
+	}
+}
+
+func credentialRow(credential Credential) map[string]any {
+	return map[string]any{
+		"id":         credential.ID,
+		"workspace":  credential.Workspace,
+		"name":       credential.Name,
+		"created_at": credential.CreatedAt,
+		"updated_at": credential.UpdatedAt,
+	}
+}
+
+func runRow(run Run) map[string]any {
+	return map[string]any{
+		"id":                run.ID,
+		"workspace":         run.Workspace,
+		"kind":              run.Kind,
+		"status":            run.Status,
+		"phase":             run.Phase,
+		"message":           run.Message,
+		"attempts":          run.Attempts,
+		"created_at":        run.CreatedAt,
+		"started_at":        run.StartedAt,
+		"finished_at":       run.FinishedAt,
+		"approved_at":       run.ApprovedAt,
+		"canceled_by":       nullable(run.CanceledBy),
+		"post_apply_status": run.PostApplyStatus,
+	}
+}
+
+func runPhaseRow(phase RunPhase) map[string]any {
+	return map[string]any{
+		"run_id":      phase.RunID,
+		"phase":       phase.Phase,
+		"result":      phase.Result,
+		"exit_code":   phase.ExitCode,
+		"started_at":  phase.StartedAt,
+		"finished_at": phase.FinishedAt,
+	}
+}
+
+func runEventRow(event RunEvent) map[string]any {
+	return map[string]any{
+		"id":        event.ID,
+		"run_id":    event.RunID,
+		"phase":     event.Phase,
+		"type":      event.Type,
+		"level":     event.Level,
+		"message":   event.Message,
+		"timestamp": event.Timestamp,
+	}
+}
+
+func runAnnotationRow(annotation RunAnnotation) map[string]any {
+	return map[string]any{
+		"run_id":     annotation.RunID,
+		"hook":       annotation.Hook,
+		"name":       annotation.Name,
+		"value":      annotation.Value,
+		"created_at": annotation.CreatedAt,
+	}
+}
+
+func runArtifactRow(artifact RunArtifact) map[string]any {
+	return map[string]any{
+		"id":           artifact.ID,
+		"run_id":       artifact.RunID,
+		"name":         artifact.Name,
+		"content_type": artifact.ContentType,
+		"created_at":   artifact.CreatedAt,
+	}
+}
+
+func userRow(user User) map[string]any {
+	return map[string]any{
+		"id":            user.ID,
+		"name":          user.Name,
+		"password_hash": user.PasswordHash,
+		"is_admin":      user.IsAdmin,
+	}
+}
+
+func groupRow(group Group) map[string]any {
+	return map[string]any{
+		"id":   group.ID,
+		"name": group.Name,
+	}
+}
+
+func permissionRow(permission Permission) map[string]any {
+	return map[string]any{
+		"id":       permission.ID,
+		"category": permission.Category,
+		"resource": permission.Resource,
+		"action":   permission.Action,
+	}
+}
+
+func userGroupRow(ref UserGroupReference) map[string]any {
+	return map[string]any{
+		"user_id":  ref.UserID,
+		"group_id": ref.GroupID,
+	}
+}
+
+func groupPermissionRow(ref GroupPermissionReference) map[string]any {
+	return map[string]any{
+		"group_id":      ref.GroupID,
+		"permission_id": ref.PermissionID,
+	}
+}
+
+func auditRow(entry AuditEntry) map[string]any {
+	return map[string]any{
+		"id":         entry.ID,
+		"created_at": entry.CreatedAt,
+		"actor":      entry.Actor,
+		"action":     entry.Action,
+		"target":     entry.Target,
+		"request_id": entry.RequestID,
+		"source_ip":  entry.SourceIP,
+		"result":     entry.Result,
+		"status":     entry.Status,
+	}
+}
+
+// nullable returns nil for empty strings. Columns that are NULL in the schema are stored as empty strings.
+func nullable(value string) any {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Dialect is the SQL dialect of a database engine. The zero value is DialectPostgres.
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota
+	DialectSqlite
+)
+
+// placeholderPattern matches the '$1', '$2', ... placeholders of queries.
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`) //nolint:gochecknoglobals
+
+// ParseDialect returns the dialect of the configured database type. An empty type is PostgresSQL.
+func ParseDialect(databaseType string) (Dialect, error) {
+	switch databaseType {
+	case "", "postgres":
+		return DialectPostgres, nil
+	case "sqlite":
+		return DialectSqlite, nil
+	default:
+		return 0, fmt.Errorf("unsupported database type '%s'. use 'postgres' or 'sqlite'", databaseType)
+	}
+}
+
+// String returns the name of the dialect as used by the database type of the config.
+func (d Dialect) String() string {
+	if d == DialectSqlite {
+		return "sqlite"
+	}
+
+	return "postgres"
+}
+
+// Placeholder returns the placeholder of the argument with the given index (starting at 1).
+//
+// e.g. '$1' for PostgresSQL and '?1' for SQLite.
+func (d Dialect) Placeholder(index int) string {
+	if d == DialectSqlite {
+		return "?" + strconv.Itoa(index)
+	}
+
+	return "$" + strconv.Itoa(index)
+}
+
+// like returns the LIKE expression of the dialect for the column and placeholder.
+//
+// SQLite has no ILIKE and no default escape character. So both are emulated to behave like in PostgresSQL.
+// LIKE itself is case-sensitive with the pragma case_sensitive_like set by the connection.
+func (d Dialect) like(column string, operator Operator, placeholder string) string {
+	if d == DialectPostgres {
+		return fmt.Sprintf("%s %s %s", column, operator, placeholder)
+	}
+
+	switch operator { //nolint:exhaustive
+	case OperatorILike:
+		return fmt.Sprintf(`LOWER(%s) LIKE LOWER(%s) ESCAPE '\'`, column, placeholder)
+	case OperatorNotILike:
+		return fmt.Sprintf(`LOWER(%s) NOT LIKE LOWER(%s) ESCAPE '\'`, column, placeholder)
+	default:
+		return fmt.Sprintf(`%s %s %s ESCAPE '\'`, column, operator, placeholder)
+	}
+}
+
+// Rebind rewrites the '$1', '$2', ... placeholders of a query into the placeholders of the dialect.
+//
+// Queries are written with the placeholders of PostgresSQL.
+func (d Dialect) Rebind(query string) string {
+	if d == DialectPostgres {
+		return query
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		index, _ := strconv.Atoi(placeholder[1:])
+
+		return d.Placeholder(index)
+	})
+}
+
+// BindArgs converts the arguments of a query into values of the dialect.
+//
+// SQLite stores timestamps as text, which are compared as text. So times are converted to UTC like the timestamps
+// written by the database.
+func (d Dialect) BindArgs(args []any) []any {
+	if d == DialectPostgres {
+		return args
+	}
+
+	bound := make([]any, len(args))
+
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			arg = t.UTC()
+		}
+
+		bound[i] = arg
+	}
+
+	return bound
+}
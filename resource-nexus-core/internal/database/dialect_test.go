@@ -0,0 +1,67 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := map[string]Dialect{
+		"":         DialectPostgres,
+		"postgres": DialectPostgres,
+		"sqlite":   DialectSqlite,
+	}
+
+	for databaseType, expected := range tests {
+		dialect, err := ParseDialect(databaseType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if dialect != expected {
+			t.Fatalf("expected %s for '%s', got %s", expected, databaseType, dialect)
+		}
+	}
+
+	_, err := ParseDialect("mysql")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	query := "UPDATE runs SET status = $1 WHERE id = $10 AND status IN ($2, $1)"
+
+	if actual := DialectPostgres.Rebind(query); actual != query {
+		t.Fatalf("expected query to be unchanged, got %s", actual)
+	}
+
+	expected := "UPDATE runs SET status = ?1 WHERE id = ?10 AND status IN (?2, ?1)"
+	if actual := DialectSqlite.Rebind(query); actual != expected {
+		t.Fatalf("\nactual: %s\nexpected: %s", actual, expected)
+	}
+}
+
+func TestDialectBindArgs(t *testing.T) {
+	local := time.Date(2026, 1, 4, 15, 33, 7, 0, time.FixedZone("CET", 3600))
+	args := []any{"queued", local, 3}
+
+	bound := DialectSqlite.BindArgs(args)
+
+	if bound[0] != "queued" || bound[2] != 3 {
+		t.Fatalf("unexpected args %v", bound)
+	}
+
+	if tm, ok := bound[1].(time.Time); !ok || tm.Location() != time.UTC || !tm.Equal(local) {
+		t.Fatalf("expected time in UTC, got %v", bound[1])
+	}
+
+	// the args of the caller are not changed
+	if args[1] != local {
+		t.Fatal("args have been changed")
+	}
+
+	if bound := DialectPostgres.BindArgs(args); bound[1] != local {
+		t.Fatalf("expected time to be unchanged, got %v", bound[1])
+	}
+}
@@ -1,12 +1,64 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 )
 
+// ErrInvalidFilter is returned if a filter can't be built. The errors below wrap it, so check with errors.Is.
+var (
+	ErrInvalidFilter   = errors.New("invalid filter")
+	ErrUnknownColumn   = fmt.Errorf("%w: unknown column", ErrInvalidFilter)
+	ErrInvalidOperator = fmt.Errorf("%w: invalid operator", ErrInvalidFilter)
+	ErrInvalidValue    = fmt.Errorf("%w: invalid value", ErrInvalidFilter)
+)
+
+// Operator is the comparison operator of a Filter. The case is ignored.
+type Operator string
+
+const (
+	OperatorEqual        Operator = "="
+	OperatorNotEqual     Operator = "!="
+	OperatorLess         Operator = "<"
+	OperatorLessEqual    Operator = "<="
+	OperatorGreater      Operator = ">"
+	OperatorGreaterEqual Operator = ">="
+	OperatorLike         Operator = "LIKE"        // '%' matches any sequence of characters, '_' a single one, '\' escapes
+	OperatorNotLike      Operator = "NOT LIKE"    // see OperatorLike
+	OperatorILike        Operator = "ILIKE"       // like OperatorLike, but ignores the case
+	OperatorNotILike     Operator = "NOT ILIKE"   // see OperatorILike
+	OperatorIn           Operator = "IN"          // Value is a non-empty slice
+	OperatorNotIn        Operator = "NOT IN"      // Value is a non-empty slice
+	OperatorIsNull       Operator = "IS NULL"     // Value is ignored
+	OperatorIsNotNull    Operator = "IS NOT NULL" // Value is ignored
+	OperatorBetween      Operator = "BETWEEN"     // Value is a slice of the lower and upper bound. both are included
+)
+
+// operators holds all supported operators.
+var operators = []Operator{
+	OperatorEqual, OperatorNotEqual, OperatorLess, OperatorLessEqual, OperatorGreater, OperatorGreaterEqual,
+	OperatorLike, OperatorNotLike, OperatorILike, OperatorNotILike, OperatorIn, OperatorNotIn, OperatorIsNull,
+	OperatorIsNotNull, OperatorBetween,
+}
+
+// LogicalOperator combines the filters of a LogicalFilter. The case is ignored.
+type LogicalOperator string
+
+const (
+	LogicalAnd LogicalOperator = "AND"
+	LogicalOr  LogicalOperator = "OR"
+	LogicalNot LogicalOperator = "NOT" // negates a single filter
+)
+
+// identifier matches valid column names. Keys are interpolated into the query, so nothing else is accepted.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 type FilterExpr interface {
-	ToSQL(index int) (string, []any, int, error)
+	ToSQL(dialect Dialect, index int) (string, []any, int, error)
 }
 
 // Filter represents a simple filter for a database query.
@@ -14,51 +66,160 @@ type FilterExpr interface {
 // e.g. "name = 'dummy'" -> Filter{Key: "name", Value: "dummy", Operator: "="}.
 type Filter struct {
 	Key      string
-	Operator string
+	Operator Operator
 	Value    any
 }
 
 // LogicalFilter represents a logical filter for a database query.
 // Multiple LogicalFilter can be combined. Add them to the Filters slice.
 type LogicalFilter struct {
-	Operator string
+	Operator LogicalOperator
 	Filters  []FilterExpr
 }
 
+// check validates the key, operator and value of the filter and returns the normalized operator.
+func (f Filter) check() (Operator, error) {
+	if !identifier.MatchString(f.Key) {
+		return "", fmt.Errorf("%w '%s'", ErrUnknownColumn, f.Key)
+	}
+
+	operator := Operator(strings.ToUpper(strings.Join(strings.Fields(string(f.Operator)), " ")))
+	if !slices.Contains(operators, operator) {
+		return "", fmt.Errorf("%w '%s'", ErrInvalidOperator, f.Operator)
+	}
+
+	switch operator {
+	case OperatorIsNull, OperatorIsNotNull:
+		return operator, nil
+	case OperatorIn, OperatorNotIn, OperatorBetween:
+		values, ok := f.values()
+		if !ok || len(values) == 0 || (operator == OperatorBetween && len(values) != 2) {
+			return "", fmt.Errorf("%w for %s on column '%s': %v", ErrInvalidValue, operator, f.Key, f.Value)
+		}
+
+		if slices.Contains(values, nil) {
+			return "", fmt.Errorf("%w for %s on column '%s': NULL is not allowed", ErrInvalidValue, operator, f.Key)
+		}
+
+		return operator, nil
+	case OperatorLike, OperatorNotLike, OperatorILike, OperatorNotILike:
+		if _, ok := f.Value.(string); !ok {
+			return "", fmt.Errorf("%w for %s on column '%s': pattern must be a string", ErrInvalidValue, operator, f.Key)
+		}
+
+		return operator, nil
+	default:
+		if _, ok := f.values(); ok || f.Value == nil {
+			return "", fmt.Errorf("%w for %s on column '%s': %v", ErrInvalidValue, operator, f.Key, f.Value)
+		}
+
+		return operator, nil
+	}
+}
+
+// values returns the elements of the value if it is a slice. []byte is not treated as slice.
+func (f Filter) values() ([]any, bool) {
+	v := reflect.ValueOf(f.Value)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	values := make([]any, 0, v.Len())
+
+	for i := range v.Len() {
+		values = append(values, v.Index(i).Interface())
+	}
+
+	return values, true
+}
+
 // ToSQL builds the SQL query string for the filter and returns it along with the arguments.
 //
-// index is used to generate the argument placeholder '$1'.
+// index is used to generate the argument placeholder of the dialect. e.g. '$1' or '?1'.
+// IN and BETWEEN use one placeholder per value.
 //
-// // the returned lastIndex is the highest index used for the arguments.
-func (f Filter) ToSQL(index int) (string, []any, int, error) {
-	if f.Key == "" || f.Operator == "" {
-		return "", nil, 0, fmt.Errorf("cant build filter for query")
+//	e.g. Filter{Key: "status", Operator: "IN", Value: []string{"queued", "running"}}
+//	"status IN ($1, $2)"
+//
+// the returned lastIndex is the next free index for the arguments.
+func (f Filter) ToSQL(dialect Dialect, index int) (string, []any, int, error) {
+	operator, err := f.check()
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	switch operator {
+	case OperatorIsNull, OperatorIsNotNull:
+		return fmt.Sprintf("%s %s", f.Key, operator), nil, index, nil
+	case OperatorIn, OperatorNotIn:
+		values, _ := f.values()
+		placeholders := make([]string, 0, len(values))
+
+		for i := range values {
+			placeholders = append(placeholders, dialect.Placeholder(index+i))
+		}
+
+		return fmt.Sprintf("%s %s (%s)", f.Key, operator, strings.Join(placeholders, ", ")),
+			values, index + len(values), nil
+	case OperatorBetween:
+		values, _ := f.values()
+
+		return fmt.Sprintf("%s BETWEEN %s AND %s", f.Key, dialect.Placeholder(index), dialect.Placeholder(index+1)),
+			values, index + 2, nil
+	case OperatorLike, OperatorNotLike, OperatorILike, OperatorNotILike:
+		return dialect.like(f.Key, operator, dialect.Placeholder(index)), []any{f.Value}, index + 1, nil
+	default:
+		return fmt.Sprintf("%s %s %s", f.Key, operator, dialect.Placeholder(index)), []any{f.Value}, index + 1, nil
 	}
+}
+
+// check validates the operator of the logical filter and returns the normalized operator.
+func (f LogicalFilter) check() (LogicalOperator, error) {
+	operator := LogicalOperator(strings.ToUpper(string(f.Operator)))
+
+	switch operator {
+	case LogicalAnd, LogicalOr:
+		return operator, nil
+	case LogicalNot:
+		if len(f.Filters) != 1 {
+			return "", fmt.Errorf("%w: NOT needs exactly one filter, got %d", ErrInvalidOperator, len(f.Filters))
+		}
 
-	return fmt.Sprintf("%s %s $%d", f.Key, f.Operator, index), []any{f.Value}, index + 1, nil
+		return operator, nil
+	default:
+		return "", fmt.Errorf("%w '%s'", ErrInvalidOperator, f.Operator)
+	}
 }
 
 // ToSQL builds the SQL query string for the filter and returns it along with the arguments.
 //
-// index is used to generate the argument placeholder '$1', '$2', ...
+// index is used to generate the argument placeholders of the dialect. e.g. '$1', '$2', ... or '?1', '?2', ...
 // the provided index is used as "start number" for the index.
 //
 //	e.g. "(name = 'dummy' OR name = 'test')"
 //	LogicalFilter{
-//	 	Operator: "AND",
+//	 	Operator: "OR",
 //	  	Filters: []FilterExpr{
 //	  		Filter{Key: "name", Value: "dummy", Operator: "="},
 //	  		Filter{Key: "name", Value: "test", Operator: "="}
 //	  	}
 //	 }
 //
+//	e.g. "NOT (name = 'dummy')"
+//	LogicalFilter{Operator: "NOT", Filters: []FilterExpr{Filter{Key: "name", Value: "dummy", Operator: "="}}}
+//
 // the returned lastIndex is the highest index used for the arguments.
-func (f LogicalFilter) ToSQL(index int) (string, []any, int, error) {
+func (f LogicalFilter) ToSQL(dialect Dialect, index int) (string, []any, int, error) {
 	// no filters added. nothing to combine into logical filter expression
 	if len(f.Filters) == 0 {
 		return "", nil, 0, nil
 	}
 
+	operator, err := f.check()
+	if err != nil {
+		return "", nil, 0, err
+	}
+
 	filters := make([]string, 0, len(f.Filters)) // single filter expressions that will be combined
 
 	var arguments []any // arguments for the combined filters
@@ -68,7 +229,7 @@ func (f LogicalFilter) ToSQL(index int) (string, []any, int, error) {
 	// loop over filters and combine them into a single logical expression
 	for _, f := range f.Filters {
 		// get filter string and argument for filter
-		s, a, nextIdx, err := f.ToSQL(currentIdx)
+		s, a, nextIdx, err := f.ToSQL(dialect, currentIdx)
 		if err != nil {
 			return "", nil, 0, fmt.Errorf("cant build logical filter: %w", err)
 		}
@@ -79,23 +240,27 @@ func (f LogicalFilter) ToSQL(index int) (string, []any, int, error) {
 		currentIdx = nextIdx
 	}
 
+	if operator == LogicalNot {
+		return fmt.Sprintf("NOT (%s)", filters[0]), arguments, currentIdx, nil
+	}
+
 	// combine filters by the desired operator
-	filter := fmt.Sprintf("(%s)", strings.Join(filters, fmt.Sprintf(" %s ", f.Operator)))
+	filter := fmt.Sprintf("(%s)", strings.Join(filters, fmt.Sprintf(" %s ", operator)))
 
 	return filter, arguments, currentIdx, nil
 }
 
 // BuildWhere builds the WHERE clause for a database query from a FilterExpr.
 //
-// When no filter is given, an empty string is returned.
-func BuildWhere(filter FilterExpr) (string, []any, error) {
+// The placeholders of the arguments match the dialect. When no filter is given, an empty string is returned.
+func BuildWhere(filter FilterExpr, dialect Dialect) (string, []any, error) {
 	// no filter given. return empty string
 	if filter == nil {
 		return "", nil, nil
 	}
 
 	// get filter expression as string and arguments
-	filterString, arguments, _, err := filter.ToSQL(1)
+	filterString, arguments, _, err := filter.ToSQL(dialect, 1)
 	if err != nil {
 		return "", nil, fmt.Errorf("cant build where: %w", err)
 	}
@@ -103,3 +268,67 @@ func BuildWhere(filter FilterExpr) (string, []any, error) {
 	// build where clause and return clause and arguments
 	return fmt.Sprintf(" WHERE %s", filterString), arguments, nil
 }
+
+// allowedColumns returns the columns of the row that list queries on the table can filter and order by.
+//
+// The values of the row are kept, so the columns can be checked by type. e.g. pointers for nullable columns.
+func allowedColumns(table string, row map[string]any) map[string]any {
+	columns := map[string]any{}
+
+	for _, column := range listColumns[table] {
+		if value, ok := row[column]; ok {
+			columns[column] = value
+		}
+	}
+
+	return columns
+}
+
+// validateFilter checks that the filter is valid and only uses the allowed columns.
+func validateFilter(filter FilterExpr, columns map[string]any) error {
+	switch f := filter.(type) {
+	case nil:
+		return nil
+	case Filter:
+		return f.validate(columns)
+	case *Filter:
+		return f.validate(columns)
+	case LogicalFilter:
+		return f.validate(columns)
+	case *LogicalFilter:
+		return f.validate(columns)
+	default:
+		return fmt.Errorf("%w: unsupported filter expression %T", ErrInvalidFilter, filter)
+	}
+}
+
+// validate checks that the filter is valid and its column is allowed.
+func (f Filter) validate(columns map[string]any) error {
+	_, err := f.check()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := columns[f.Key]; !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownColumn, f.Key)
+	}
+
+	return nil
+}
+
+// validate checks that the logical filter and all of its filters are valid.
+func (f LogicalFilter) validate(columns map[string]any) error {
+	_, err := f.check()
+	if err != nil {
+		return err
+	}
+
+	for _, filter := range f.Filters {
+		err = validateFilter(filter, columns)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFilterToSQL(t *testing.T) {
@@ -12,7 +14,7 @@ func TestFilterToSQL(t *testing.T) {
 		Value:    "foobar",
 	}
 
-	f, args, _, err := filter.ToSQL(1)
+	f, args, _, err := filter.ToSQL(DialectPostgres, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,7 +45,7 @@ func TestLogicalFilterToSQL(t *testing.T) {
 		},
 	}
 
-	f, args, _, err := filter.ToSQL(1)
+	f, args, _, err := filter.ToSQL(DialectPostgres, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,7 +66,7 @@ func TestBuildWhereFilter(t *testing.T) {
 		Value:    "volvo",
 	}
 
-	s, args, err := BuildWhere(filter)
+	s, args, err := BuildWhere(filter, DialectPostgres)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -105,7 +107,7 @@ func TestBuildWhereLogicalFilter(t *testing.T) {
 		},
 	}
 
-	s, args, err := BuildWhere(filter)
+	s, args, err := BuildWhere(filter, DialectPostgres)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,3 +121,186 @@ func TestBuildWhereLogicalFilter(t *testing.T) {
 		t.Fatalf("wrong args returned: %v", args)
 	}
 }
+
+func TestBuildWhereSqlite(t *testing.T) {
+	filter := LogicalFilter{
+		Operator: "OR",
+		Filters: []FilterExpr{
+			Filter{
+				Key:      "car",
+				Operator: "=",
+				Value:    "volvo",
+			},
+			Filter{
+				Key:      "car",
+				Operator: "=",
+				Value:    "bmw",
+			},
+		},
+	}
+
+	s, args, err := BuildWhere(filter, DialectSqlite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := " WHERE (car = ?1 OR car = ?2)"
+	if s != expected {
+		t.Fatalf("\nactual: %s\nexpected: %s", s, expected)
+	}
+
+	if !reflect.DeepEqual(args, []any{"volvo", "bmw"}) {
+		t.Fatalf("wrong args returned: %v", args)
+	}
+}
+
+func TestFilterOperatorsToSQL(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		filter   Filter
+		postgres string
+		sqlite   string
+		args     []any
+	}{
+		{
+			Filter{Key: "attempts", Operator: "<=", Value: 3},
+			"attempts <= $2", "attempts <= ?2", []any{3},
+		},
+		{
+			Filter{Key: "name", Operator: "like", Value: "web%"},
+			"name LIKE $2", `name LIKE ?2 ESCAPE '\'`, []any{"web%"},
+		},
+		{
+			Filter{Key: "name", Operator: "NOT  ILIKE", Value: "WEB%"},
+			"name NOT ILIKE $2", `LOWER(name) NOT LIKE LOWER(?2) ESCAPE '\'`, []any{"WEB%"},
+		},
+		{
+			Filter{Key: "status", Operator: "IN", Value: []RunStatus{RunStatusQueued, RunStatusRunning}},
+			"status IN ($2, $3)", "status IN (?2, ?3)", []any{RunStatusQueued, RunStatusRunning},
+		},
+		{
+			Filter{Key: "id", Operator: "NOT IN", Value: []any{1}},
+			"id NOT IN ($2)", "id NOT IN (?2)", []any{1},
+		},
+		{
+			Filter{Key: "finished_at", Operator: "is null"},
+			"finished_at IS NULL", "finished_at IS NULL", nil,
+		},
+		{
+			Filter{Key: "finished_at", Operator: "IS NOT NULL", Value: "ignored"},
+			"finished_at IS NOT NULL", "finished_at IS NOT NULL", nil,
+		},
+		{
+			Filter{Key: "created_at", Operator: "BETWEEN", Value: []time.Time{since, until}},
+			"created_at BETWEEN $2 AND $3", "created_at BETWEEN ?2 AND ?3", []any{since, until},
+		},
+	}
+
+	for _, tt := range tests {
+		for dialect, expected := range map[Dialect]string{DialectPostgres: tt.postgres, DialectSqlite: tt.sqlite} {
+			f, args, next, err := tt.filter.ToSQL(dialect, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if f != expected || next != 2+len(tt.args) {
+				t.Fatalf("\nactual: %s (next index %d)\nexpected: %s", f, next, expected)
+			}
+
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Fatalf("wrong args returned for %s: %v", f, args)
+			}
+		}
+	}
+}
+
+func TestFilterToSQLInvalid(t *testing.T) {
+	tests := []struct {
+		filter FilterExpr
+		err    error
+	}{
+		{Filter{Key: "", Operator: "=", Value: "web"}, ErrUnknownColumn},
+		{Filter{Key: "name = name OR 1", Operator: "=", Value: "web"}, ErrUnknownColumn},
+		{Filter{Key: "name", Operator: "", Value: "web"}, ErrInvalidOperator},
+		{Filter{Key: "name", Operator: "= 1 OR name =", Value: "web"}, ErrInvalidOperator},
+		{Filter{Key: "name", Operator: "~", Value: "web"}, ErrInvalidOperator},
+		{Filter{Key: "name", Operator: "=", Value: nil}, ErrInvalidValue},
+		{Filter{Key: "name", Operator: "=", Value: []string{"web"}}, ErrInvalidValue},
+		{Filter{Key: "name", Operator: "LIKE", Value: 1}, ErrInvalidValue},
+		{Filter{Key: "name", Operator: "IN", Value: "web"}, ErrInvalidValue},
+		{Filter{Key: "name", Operator: "IN", Value: []any{"web", nil}}, ErrInvalidValue},
+		{Filter{Key: "id", Operator: "BETWEEN", Value: []int{1, 2, 3}}, ErrInvalidValue},
+		{LogicalFilter{Operator: "XOR", Filters: []FilterExpr{Filter{Key: "id", Operator: "=", Value: 1}}}, ErrInvalidOperator},
+		{LogicalFilter{Operator: "NOT", Filters: []FilterExpr{
+			Filter{Key: "id", Operator: "=", Value: 1},
+			Filter{Key: "id", Operator: "=", Value: 2},
+		}}, ErrInvalidOperator},
+		{LogicalFilter{Operator: "AND", Filters: []FilterExpr{Filter{Key: "id", Operator: "==", Value: 1}}}, ErrInvalidOperator},
+	}
+
+	for _, tt := range tests {
+		_, _, err := BuildWhere(tt.filter, DialectPostgres)
+		if !errors.Is(err, tt.err) || !errors.Is(err, ErrInvalidFilter) {
+			t.Fatalf("expected %v for %v, got %v", tt.err, tt.filter, err)
+		}
+	}
+}
+
+func TestBuildWhereNot(t *testing.T) {
+	filter := LogicalFilter{
+		Operator: "NOT",
+		Filters: []FilterExpr{
+			LogicalFilter{
+				Operator: "OR",
+				Filters: []FilterExpr{
+					Filter{Key: "status", Operator: "IN", Value: []string{"queued", "running"}},
+					Filter{Key: "finished_at", Operator: "IS NULL"},
+				},
+			},
+		},
+	}
+
+	s, args, err := BuildWhere(filter, DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := " WHERE NOT ((status IN ($1, $2) OR finished_at IS NULL))"
+	if s != expected {
+		t.Fatalf("\nactual: %s\nexpected: %s", s, expected)
+	}
+
+	if !reflect.DeepEqual(args, []any{"queued", "running"}) {
+		t.Fatalf("wrong args returned: %v", args)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	columns := allowedColumns(TableNameUsers, userRow(User{}))
+
+	err := validateFilter(LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+		Filter{Key: "name", Operator: "LIKE", Value: "a%"},
+		&Filter{Key: "is_admin", Operator: "=", Value: true},
+	}}, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// password hashes are not allowed to be filtered by
+	for _, filter := range []FilterExpr{
+		Filter{Key: "password_hash", Operator: "LIKE", Value: "$argon2id$%"},
+		LogicalFilter{Operator: "NOT", Filters: []FilterExpr{Filter{Key: "unknown", Operator: "IS NULL"}}},
+	} {
+		err = validateFilter(filter, columns)
+		if !errors.Is(err, ErrUnknownColumn) {
+			t.Fatalf("expected ErrUnknownColumn for %v, got %v", filter, err)
+		}
+	}
+
+	_, err = Page{OrderBy: []Order{{Column: "password_hash"}}}.orders(columns)
+	if !errors.Is(err, ErrInvalidPage) {
+		t.Fatalf("expected ErrInvalidPage, got %v", err)
+	}
+}
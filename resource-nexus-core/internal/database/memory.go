@@ -0,0 +1,459 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// errUniqueViolation is returned by the MemoryDatabase if an insert violates a unique constraint of the schema.
+	errUniqueViolation = errors.New("duplicate key value violates unique constraint")
+	// errForeignKeyViolation is returned by the MemoryDatabase if a referenced row does not exist.
+	errForeignKeyViolation = errors.New("referenced row does not exist")
+)
+
+// MemoryDatabase is a Database that keeps all data in memory.
+//
+// It mirrors the behavior of the SqlDatabase, including the unique constraints of the schema.
+// Filters are evaluated against the columns of each table, including the LIKE and ILIKE operators.
+// It is safe for concurrent use.
+// Data is lost once the process exits. Use it for tests and local development only.
+type MemoryDatabase struct {
+	mu   sync.Mutex
+	inTx bool // the database is the copy of a transaction. See WithTx
+
+	ids map[string]int // last id of each table
+
+	users            []User
+	groups           []Group
+	permissions      []Permission
+	userGroups       []UserGroupReference
+	groupPermissions []GroupPermissionReference
+	workspaces       []Workspace
+	locks            []WorkspaceLockInfo
+	runs             []memoryRun
+	runPhases        []RunPhase
+	runEvents        []RunEvent
+	runAnnotations   []RunAnnotation
+	runArtifacts     []RunArtifact
+	credentials      []Credential
+	auditLog         []AuditEntry
+}
+
+// memoryRun is a run with the columns that are only used by the queue.
+type memoryRun struct {
+	Run
+
+	claimedBy     string
+	heartbeatAt   *time.Time
+	nextAttemptAt *time.Time
+}
+
+// NewMemoryDatabase returns a new MemoryDatabase.
+//
+// Like a new schema, it only contains the permissions of the API.
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{
+		ids:         map[string]int{TableNamePermissions: len(schemaPermissions())},
+		permissions: schemaPermissions(),
+	}
+}
+
+// schemaPermissions returns the permissions that are inserted by the migrations.
+func schemaPermissions() []Permission {
+	return []Permission{
+		{ID: 1, Category: "system", Resource: "health", Action: "get"},
+		{ID: 2, Category: "auth", Resource: "user", Action: "add"},
+		{ID: 3, Category: "auth", Resource: "group", Action: "add"},
+		{ID: 4, Category: "auth", Resource: "usergroup", Action: "add"},
+		{ID: 5, Category: "auth", Resource: "grouppermission", Action: "add"},
+		{ID: 6, Category: "provisioning", Resource: "workspace", Action: "add"},
+		{ID: 7, Category: "provisioning", Resource: "workspace", Action: "get"},
+		{ID: 8, Category: "provisioning", Resource: "run", Action: "add"},
+		{ID: 9, Category: "provisioning", Resource: "run", Action: "get"},
+		{ID: 10, Category: "provisioning", Resource: "workspace", Action: "unlock"},
+		{ID: 11, Category: "provisioning", Resource: "run", Action: "apply"},
+		{ID: 12, Category: "provisioning", Resource: "run", Action: "cancel"},
+		{ID: 13, Category: "provisioning", Resource: "credential", Action: "add"},
+		{ID: 14, Category: "provisioning", Resource: "credential", Action: "get"},
+		{ID: 15, Category: "provisioning", Resource: "run", Action: "target"},
+		{ID: 16, Category: "provisioning", Resource: "run", Action: "replace"},
+		{ID: 17, Category: "provisioning", Resource: "run", Action: "refresh"},
+		{ID: 18, Category: "provisioning", Resource: "run", Action: "destroy"},
+		{ID: 19, Category: "auth", Resource: "user", Action: "update"},
+		{ID: 20, Category: "auth", Resource: "user", Action: "delete"},
+		{ID: 21, Category: "auth", Resource: "group", Action: "update"},
+		{ID: 22, Category: "auth", Resource: "group", Action: "delete"},
+		{ID: 23, Category: "auth", Resource: "usergroup", Action: "delete"},
+		{ID: 24, Category: "auth", Resource: "grouppermission", Action: "delete"},
+		{ID: 25, Category: "auth", Resource: "user", Action: "get"},
+		{ID: 26, Category: "auth", Resource: "group", Action: "get"},
+		{ID: 27, Category: "system", Resource: "audit", Action: "get"},
+	}
+}
+
+// TestConnection always succeeds.
+func (db *MemoryDatabase) TestConnection() error {
+	return nil
+}
+
+// Stats returns empty statistics. The memory database has no connection pool.
+func (db *MemoryDatabase) Stats() PoolStats {
+	return PoolStats{}
+}
+
+// Close does nothing. The data is kept.
+func (db *MemoryDatabase) Close() error {
+	return nil
+}
+
+// WithTx runs fn inside a transaction.
+//
+// fn works on a copy of the data, which replaces the data if fn returns nil. The database is locked until fn returns,
+// so transactions are serialized like in SQLite. Don't use db inside fn, it would wait for itself.
+// Workspace locks return ErrInTransaction inside fn. Calling WithTx on tx joins the running transaction.
+func (db *MemoryDatabase) WithTx(_ context.Context, fn func(tx Database) error) error {
+	if db.inTx {
+		return fn(db)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx := &MemoryDatabase{inTx: true}
+	tx.copyFrom(db)
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	db.copyFrom(tx)
+
+	return nil
+}
+
+// copyFrom replaces the data with a copy of the data of src. Needs to be called with the lock of src held.
+func (db *MemoryDatabase) copyFrom(src *MemoryDatabase) {
+	db.ids = maps.Clone(src.ids)
+	db.users = slices.Clone(src.users)
+	db.groups = slices.Clone(src.groups)
+	db.permissions = slices.Clone(src.permissions)
+	db.userGroups = slices.Clone(src.userGroups)
+	db.groupPermissions = slices.Clone(src.groupPermissions)
+	db.workspaces = slices.Clone(src.workspaces)
+	db.locks = slices.Clone(src.locks)
+	db.runs = slices.Clone(src.runs)
+	db.runPhases = slices.Clone(src.runPhases)
+	db.runEvents = slices.Clone(src.runEvents)
+	db.runAnnotations = slices.Clone(src.runAnnotations)
+	db.runArtifacts = slices.Clone(src.runArtifacts)
+	db.credentials = slices.Clone(src.credentials)
+	db.auditLog = slices.Clone(src.auditLog)
+}
+
+// nextID returns the next id of the table. Needs to be called with the lock held.
+func (db *MemoryDatabase) nextID(table string) int {
+	db.ids[table]++
+
+	return db.ids[table]
+}
+
+// memoryResult is the sql.Result of a statement executed by the MemoryDatabase.
+type memoryResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r memoryResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r memoryResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// rowFn returns the values of a row by their column names. Filters are evaluated against them.
+type rowFn[T any] func(item T) map[string]any
+
+// selectRows returns the items that match the filter. A nil filter matches all items.
+func selectRows[T any](items []T, filter FilterExpr, row rowFn[T]) ([]T, error) {
+	var result []T
+
+	for _, item := range items {
+		ok, err := matches(filter, row(item))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+// matches evaluates the filter against the columns of a row.
+//
+// Like in SQL, comparisons with NULL (nil) columns never match.
+func matches(filter FilterExpr, row map[string]any) (bool, error) {
+	switch f := filter.(type) {
+	case nil:
+		return true, nil
+	case Filter:
+		return f.matches(row)
+	case *Filter:
+		return f.matches(row)
+	case LogicalFilter:
+		return f.matches(row)
+	case *LogicalFilter:
+		return f.matches(row)
+	default:
+		return false, fmt.Errorf("unsupported filter expression: %T", filter)
+	}
+}
+
+// matches evaluates the filter against the columns of a row.
+func (f Filter) matches(row map[string]any) (bool, error) {
+	operator, err := f.check()
+	if err != nil {
+		return false, err
+	}
+
+	value, ok := row[f.Key]
+	if !ok {
+		return false, fmt.Errorf("%w '%s'", ErrUnknownColumn, f.Key)
+	}
+
+	left := normalize(value)
+
+	switch operator { //nolint:exhaustive
+	case OperatorIsNull:
+		return left == nil, nil
+	case OperatorIsNotNull:
+		return left != nil, nil
+	}
+
+	right := normalize(f.Value)
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	switch operator { //nolint:exhaustive
+	case OperatorLike, OperatorNotLike, OperatorILike, OperatorNotILike:
+		ok, err := like(left, right, operator == OperatorILike || operator == OperatorNotILike)
+		if err != nil {
+			return false, fmt.Errorf("cant evaluate filter on column '%s': %w", f.Key, err)
+		}
+
+		return ok != (operator == OperatorNotLike || operator == OperatorNotILike), nil
+	case OperatorIn, OperatorNotIn, OperatorBetween:
+		return f.matchesValues(operator, left)
+	}
+
+	c, err := compare(left, right)
+	if err != nil {
+		return false, fmt.Errorf("cant evaluate filter on column '%s': %w", f.Key, err)
+	}
+
+	switch operator { //nolint:exhaustive
+	case OperatorEqual:
+		return c == 0, nil
+	case OperatorNotEqual:
+		return c != 0, nil
+	case OperatorLess:
+		return c < 0, nil
+	case OperatorLessEqual:
+		return c <= 0, nil
+	case OperatorGreater:
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// matchesValues evaluates IN, NOT IN and BETWEEN against the normalized value of a column.
+func (f Filter) matchesValues(operator Operator, left any) (bool, error) {
+	values, _ := f.values()
+	results := make([]int, 0, len(values))
+
+	for _, value := range values {
+		c, err := compare(left, normalize(value))
+		if err != nil {
+			return false, fmt.Errorf("cant evaluate filter on column '%s': %w", f.Key, err)
+		}
+
+		results = append(results, c)
+	}
+
+	if operator == OperatorBetween {
+		return results[0] >= 0 && results[1] <= 0, nil
+	}
+
+	return slices.Contains(results, 0) != (operator == OperatorNotIn), nil
+}
+
+// matches evaluates the logical filter against the columns of a row.
+func (f LogicalFilter) matches(row map[string]any) (bool, error) {
+	if len(f.Filters) == 0 {
+		return true, nil
+	}
+
+	operator, err := f.check()
+	if err != nil {
+		return false, err
+	}
+
+	if operator == LogicalNot {
+		ok, err := matches(f.Filters[0], row)
+
+		return !ok, err
+	}
+
+	for _, filter := range f.Filters {
+		ok, err := matches(filter, row)
+		if err != nil {
+			return false, err
+		}
+
+		if ok && operator == LogicalOr {
+			return true, nil
+		}
+
+		if !ok && operator == LogicalAnd {
+			return false, nil
+		}
+	}
+
+	return operator == LogicalAnd, nil
+}
+
+// like matches the value against the pattern of a LIKE expression.
+//
+// Like in PostgresSQL, '%' matches any sequence of characters, '_' matches a single character and '\' escapes
+// the next character. If fold is true, the case is ignored (ILIKE).
+func like(value any, pattern any, fold bool) (bool, error) {
+	v, ok := value.(string)
+	p, isString := pattern.(string)
+
+	if !ok || !isString {
+		return false, fmt.Errorf("cant match %T with pattern %T", value, pattern)
+	}
+
+	var expr strings.Builder
+
+	if fold {
+		expr.WriteString("(?i)")
+	}
+
+	expr.WriteString("(?s)^")
+
+	escaped := false
+
+	for _, r := range p {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if escaped {
+		return false, fmt.Errorf("pattern '%s' must not end with the escape character", p)
+	}
+
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String()).MatchString(v), nil
+}
+
+// normalize converts a value into string, int64, float64, bool or time.Time, so values of named types
+// (e.g. RunStatus) can be compared with plain values. nil pointers are returned as nil.
+func normalize(value any) any {
+	if t, ok := value.(time.Time); ok {
+		return t
+	}
+
+	v := reflect.ValueOf(value)
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Invalid:
+		return nil
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()) //nolint:gosec
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	default:
+		return v.Interface()
+	}
+}
+
+// compare compares two normalized values. Returns an error if the values are not comparable.
+func compare(left any, right any) (int, error) {
+	switch l := left.(type) {
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	case int64:
+		switch r := right.(type) {
+		case int64:
+			return cmp.Compare(l, r), nil
+		case float64:
+			return cmp.Compare(float64(l), r), nil
+		}
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return cmp.Compare(l, r), nil
+		case int64:
+			return cmp.Compare(l, float64(r)), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			if l == r {
+				return 0, nil
+			}
+
+			return 1, nil
+		}
+	case time.Time:
+		if r, ok := right.(time.Time); ok {
+			return l.Compare(r), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cant compare %T with %T", left, right)
+}
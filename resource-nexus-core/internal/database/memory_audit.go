@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+)
+
+func (db *MemoryDatabase) ListAuditEntries(
+	filter FilterExpr, page Page, _ context.Context,
+) (PageResult[AuditEntry], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectPage(db.auditLog, TableNameAuditLog, filter, page, auditRow)
+}
+
+// InsertAuditEntry appends an entry to the audit log. Like in the schema, entries can't be changed afterward.
+func (db *MemoryDatabase) InsertAuditEntry(_ context.Context, entry AuditEntry) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entry.ID = db.nextID(TableNameAuditLog)
+	entry.Before = slices.Clone(entry.Before)
+	entry.After = slices.Clone(entry.After)
+	db.auditLog = append(db.auditLog, entry)
+
+	return memoryResult{lastInsertID: int64(entry.ID), rowsAffected: 1}, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMemoryDatabaseAuditLog(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	after := json.RawMessage(`{"name":"web"}`)
+
+	for _, target := range []string{"workspace:web", "run:1", "workspace:web"} {
+		_, err := db.InsertAuditEntry(ctx, AuditEntry{
+			CreatedAt: time.Now(),
+			Actor:     "alice",
+			Action:    "provisioning:workspace:add",
+			Target:    target,
+			After:     after,
+			Result:    AuditResultSuccess,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the stored snapshot is a copy
+	after[2] = 'x'
+
+	result, err := db.ListAuditEntries(
+		Filter{Key: "target", Operator: "=", Value: "workspace:web"},
+		Page{OrderBy: []Order{{Column: "id", Desc: true}}},
+		ctx,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 2 || result.Items[0].ID != 3 || result.Items[1].ID != 1 {
+		t.Fatalf("unexpected audit entries: %v", result)
+	}
+
+	if string(result.Items[0].After) != `{"name":"web"}` {
+		t.Fatalf("snapshot changed after the insert: %s", result.Items[0].After)
+	}
+
+	// snapshots can't be filtered
+	_, err = db.ListAuditEntries(Filter{Key: "after_snapshot", Operator: "=", Value: "{}"}, Page{}, ctx)
+	if err == nil {
+		t.Fatal("expected an invalid filter")
+	}
+}
@@ -0,0 +1,346 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+func (db *MemoryDatabase) GetUsers(filter FilterExpr, _ context.Context) ([]User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.users, filter, userRow)
+}
+
+// ListUsers returns a page of the users that match the filter. Like in the SqlDatabase, the password hashes are
+// left out.
+func (db *MemoryDatabase) ListUsers(filter FilterExpr, page Page, _ context.Context) (PageResult[User], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := selectPage(db.users, TableNameUsers, filter, page, userRow)
+	if err != nil {
+		return PageResult[User]{}, err
+	}
+
+	for i := range result.Items {
+		result.Items[i].PasswordHash = ""
+	}
+
+	return result, nil
+}
+
+func (db *MemoryDatabase) GetUser(filter FilterExpr, ctx context.Context) (User, error) {
+	users, err := db.GetUsers(filter, ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	if len(users) > 1 {
+		return User{}, fmt.Errorf("found more than one user with filter %s", filter)
+	}
+
+	if len(users) == 0 {
+		return User{}, fmt.Errorf("no user found with filter %s", filter)
+	}
+
+	return users[0], nil
+}
+
+func (db *MemoryDatabase) InsertUser(_ context.Context, user User) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if slices.ContainsFunc(db.users, func(u User) bool { return u.Name == user.Name }) {
+		return nil, fmt.Errorf("failed to insert user: %w", errUniqueViolation)
+	}
+
+	user.ID = db.nextID(TableNameUsers)
+	db.users = append(db.users, user)
+
+	return memoryResult{lastInsertID: int64(user.ID), rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) UpdateUser(_ context.Context, user User) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if slices.ContainsFunc(db.users, func(u User) bool { return u.Name == user.Name && u.ID != user.ID }) {
+		return nil, fmt.Errorf("failed to update user: %w", errUniqueViolation)
+	}
+
+	i := slices.IndexFunc(db.users, func(u User) bool { return u.ID == user.ID })
+	if i < 0 {
+		return memoryResult{}, nil
+	}
+
+	db.users[i] = user
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+// DeleteUser deletes the user. Like the foreign keys of the schema, the group memberships are deleted with it.
+func (db *MemoryDatabase) DeleteUser(_ context.Context, id int) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	n := len(db.users)
+	db.users = slices.DeleteFunc(db.users, func(u User) bool { return u.ID == id })
+
+	if len(db.users) == n {
+		return memoryResult{}, nil
+	}
+
+	db.userGroups = slices.DeleteFunc(db.userGroups, func(ref UserGroupReference) bool { return ref.UserID == id })
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+// GetUserPermissions returns the distinct permissions of all groups of the user.
+//
+// Like the SqlDatabase, only the category, resource and action of the permissions are returned.
+func (db *MemoryDatabase) GetUserPermissions(username string, _ context.Context) ([]Permission, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var permissions []Permission
+
+	for _, user := range db.users {
+		if user.Name != username {
+			continue
+		}
+
+		for _, ug := range db.userGroups {
+			if ug.UserID != user.ID {
+				continue
+			}
+
+			for _, gp := range db.groupPermissions {
+				if gp.GroupID != ug.GroupID {
+					continue
+				}
+
+				for _, p := range db.permissions {
+					permission := Permission{Category: p.Category, Resource: p.Resource, Action: p.Action}
+
+					if p.ID == gp.PermissionID && !slices.Contains(permissions, permission) {
+						permissions = append(permissions, permission)
+					}
+				}
+			}
+		}
+	}
+
+	return permissions, nil
+}
+
+func (db *MemoryDatabase) GetGroups(filter FilterExpr, _ context.Context) ([]Group, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.groups, filter, groupRow)
+}
+
+func (db *MemoryDatabase) ListGroups(filter FilterExpr, page Page, _ context.Context) (PageResult[Group], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectPage(db.groups, TableNameGroups, filter, page, groupRow)
+}
+
+func (db *MemoryDatabase) GetGroup(filter FilterExpr, ctx context.Context) (Group, error) {
+	groups, err := db.GetGroups(filter, ctx)
+	if err != nil {
+		return Group{}, err
+	}
+
+	if !isSingleElement[Group](groups) {
+		return Group{}, fmt.Errorf("not exactly 1 group has been found with the filter %s", filter)
+	}
+
+	return groups[0], nil
+}
+
+func (db *MemoryDatabase) InsertGroup(_ context.Context, group Group) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if slices.ContainsFunc(db.groups, func(g Group) bool { return g.Name == group.Name }) {
+		return nil, fmt.Errorf("failed to insert group: %w", errUniqueViolation)
+	}
+
+	group.ID = db.nextID(TableNameGroups)
+	db.groups = append(db.groups, group)
+
+	return memoryResult{lastInsertID: int64(group.ID), rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) UpdateGroup(_ context.Context, group Group) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if slices.ContainsFunc(db.groups, func(g Group) bool { return g.Name == group.Name && g.ID != group.ID }) {
+		return nil, fmt.Errorf("failed to update group: %w", errUniqueViolation)
+	}
+
+	i := slices.IndexFunc(db.groups, func(g Group) bool { return g.ID == group.ID })
+	if i < 0 {
+		return memoryResult{}, nil
+	}
+
+	db.groups[i] = group
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+// DeleteGroup deletes the group. Like the foreign keys of the schema, the memberships and permissions of the group
+// are deleted with it.
+func (db *MemoryDatabase) DeleteGroup(_ context.Context, id int) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	n := len(db.groups)
+	db.groups = slices.DeleteFunc(db.groups, func(g Group) bool { return g.ID == id })
+
+	if len(db.groups) == n {
+		return memoryResult{}, nil
+	}
+
+	db.userGroups = slices.DeleteFunc(db.userGroups, func(ref UserGroupReference) bool { return ref.GroupID == id })
+	db.groupPermissions = slices.DeleteFunc(db.groupPermissions,
+		func(ref GroupPermissionReference) bool { return ref.GroupID == id })
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) GetPermissions(filter FilterExpr, _ context.Context) ([]Permission, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.permissions, filter, permissionRow)
+}
+
+func (db *MemoryDatabase) GetPermission(filter FilterExpr, ctx context.Context) (Permission, error) {
+	permissions, err := db.GetPermissions(filter, ctx)
+	if err != nil {
+		return Permission{}, err
+	}
+
+	if !isSingleElement[Permission](permissions) {
+		return Permission{}, fmt.Errorf("not exactly 1 permission has been found with the filter %s", filter)
+	}
+
+	return permissions[0], nil
+}
+
+func (db *MemoryDatabase) GetUserGroupReferences(filter FilterExpr, _ context.Context) ([]UserGroupReference, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.userGroups, filter, userGroupRow)
+}
+
+func (db *MemoryDatabase) GetUserGroupReference(filter FilterExpr, ctx context.Context) (UserGroupReference, error) {
+	refs, err := db.GetUserGroupReferences(filter, ctx)
+	if err != nil {
+		return UserGroupReference{}, err
+	}
+
+	if !isSingleElement[UserGroupReference](refs) {
+		return UserGroupReference{},
+			fmt.Errorf("not exactly 1 user group reference has been found with the filter %s", filter)
+	}
+
+	return refs[0], nil
+}
+
+func (db *MemoryDatabase) InsertUserGroupReference(_ context.Context, group UserGroupReference) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ref := UserGroupReference{UserID: group.UserID, GroupID: group.GroupID}
+
+	if !slices.ContainsFunc(db.users, func(u User) bool { return u.ID == ref.UserID }) ||
+		!slices.ContainsFunc(db.groups, func(g Group) bool { return g.ID == ref.GroupID }) {
+		return nil, fmt.Errorf("failed to insert user group reference: %w", errForeignKeyViolation)
+	}
+
+	if slices.Contains(db.userGroups, ref) {
+		return nil, fmt.Errorf("failed to insert user group reference: %w", errUniqueViolation)
+	}
+
+	db.userGroups = append(db.userGroups, ref)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) DeleteUserGroupReference(_ context.Context, group UserGroupReference) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	n := len(db.userGroups)
+	db.userGroups = slices.DeleteFunc(db.userGroups, func(ref UserGroupReference) bool {
+		return ref.UserID == group.UserID && ref.GroupID == group.GroupID
+	})
+
+	return memoryResult{rowsAffected: int64(n - len(db.userGroups))}, nil
+}
+
+func (db *MemoryDatabase) GetGroupPermissions(filter FilterExpr, _ context.Context) ([]GroupPermissionReference, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.groupPermissions, filter, groupPermissionRow)
+}
+
+func (db *MemoryDatabase) GetGroupPermission(filter FilterExpr, ctx context.Context) (GroupPermissionReference, error) {
+	refs, err := db.GetGroupPermissions(filter, ctx)
+	if err != nil {
+		return GroupPermissionReference{}, err
+	}
+
+	if !isSingleElement[GroupPermissionReference](refs) {
+		return GroupPermissionReference{},
+			fmt.Errorf("not exactly 1 group permission reference has been found with the filter %s", filter)
+	}
+
+	return refs[0], nil
+}
+
+func (db *MemoryDatabase) InsertGroupPermission(
+	_ context.Context, groupPermission GroupPermissionReference,
+) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ref := GroupPermissionReference{GroupID: groupPermission.GroupID, PermissionID: groupPermission.PermissionID}
+
+	if !slices.ContainsFunc(db.groups, func(g Group) bool { return g.ID == ref.GroupID }) ||
+		!slices.ContainsFunc(db.permissions, func(p Permission) bool { return p.ID == ref.PermissionID }) {
+		return nil, fmt.Errorf("failed to insert group permission: %w", errForeignKeyViolation)
+	}
+
+	if slices.Contains(db.groupPermissions, ref) {
+		return nil, fmt.Errorf("failed to insert group permission: %w", errUniqueViolation)
+	}
+
+	db.groupPermissions = append(db.groupPermissions, ref)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) DeleteGroupPermission(
+	_ context.Context, groupPermission GroupPermissionReference,
+) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	n := len(db.groupPermissions)
+	db.groupPermissions = slices.DeleteFunc(db.groupPermissions, func(ref GroupPermissionReference) bool {
+		return ref.GroupID == groupPermission.GroupID && ref.PermissionID == groupPermission.PermissionID
+	})
+
+	return memoryResult{rowsAffected: int64(n - len(db.groupPermissions))}, nil
+}
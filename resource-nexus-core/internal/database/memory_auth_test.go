@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryDatabaseUserPermissions(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	_, err := db.InsertUser(ctx, User{Name: "dummy", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroup(ctx, Group{Name: "operators"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUser(Filter{Key: "name", Operator: "=", Value: "dummy"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := db.GetGroup(Filter{Key: "name", Operator: "=", Value: "operators"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertUserGroupReference(ctx, UserGroupReference{UserID: user.ID, GroupID: group.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{8, 9} {
+		_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: group.ID, PermissionID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	permissions, err := db.GetUserPermissions("dummy", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(permissions) != 2 || permissions[0].Resource != "run" || permissions[0].Action != "add" {
+		t.Fatalf("wrong permissions returned: %v", permissions)
+	}
+
+	refs, err := db.GetGroupPermissions(Filter{Key: "group_id", Operator: "=", Value: group.ID}, ctx)
+	if err != nil || len(refs) != 2 {
+		t.Fatalf("group permissions should be returned: %v (%v)", refs, err)
+	}
+}
+
+func TestMemoryDatabaseConstraints(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	_, err := db.InsertUser(ctx, User{Name: "dummy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertUser(ctx, User{Name: "dummy"})
+	if !errors.Is(err, errUniqueViolation) {
+		t.Fatalf("duplicate user should be rejected: %v", err)
+	}
+
+	_, err = db.InsertUserGroupReference(ctx, UserGroupReference{UserID: 1, GroupID: 42})
+	if !errors.Is(err, errForeignKeyViolation) {
+		t.Fatalf("reference to missing group should be rejected: %v", err)
+	}
+
+	_, err = db.InsertGroup(ctx, Group{Name: "operators"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: 1, PermissionID: 99})
+	if !errors.Is(err, errForeignKeyViolation) {
+		t.Fatalf("reference to missing permission should be rejected: %v", err)
+	}
+
+	_, err = db.GetUser(Filter{Key: "name", Operator: "=", Value: "unknown"}, ctx)
+	if err == nil {
+		t.Fatal("unknown user should not be found")
+	}
+}
+
+func TestMemoryDatabaseUpdate(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, name := range []string{"alice", "bob"} {
+		_, err := db.InsertUser(ctx, User{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := db.UpdateUser(ctx, User{ID: 1, Name: "bob"})
+	if !errors.Is(err, errUniqueViolation) {
+		t.Fatalf("rename to an existing name should be rejected: %v", err)
+	}
+
+	_, err = db.UpdateUser(ctx, User{ID: 1, Name: "carol", PasswordHash: "hash", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUser(Filter{Key: "id", Operator: "=", Value: 1}, ctx)
+	if err != nil || user.Name != "carol" || user.PasswordHash != "hash" || !user.IsAdmin {
+		t.Fatalf("user has not been updated: %v (%v)", user, err)
+	}
+
+	result, err := db.UpdateGroup(ctx, Group{ID: 42, Name: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows != 0 {
+		t.Fatal("unknown group should not be updated")
+	}
+}
+
+func TestMemoryDatabaseDeleteCascade(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, name := range []string{"alice", "bob"} {
+		_, err := db.InsertUser(ctx, User{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := db.InsertGroup(ctx, Group{Name: "operators"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []UserGroupReference{{UserID: 1, GroupID: 1}, {UserID: 2, GroupID: 1}} {
+		_, err = db.InsertUserGroupReference(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []int{8, 9} {
+		_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: 1, PermissionID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := db.DeleteGroupPermission(ctx, GroupPermissionReference{GroupID: 1, PermissionID: 8})
+	if rows, _ := result.RowsAffected(); err != nil || rows != 1 {
+		t.Fatalf("group permission should be deleted: %v", err)
+	}
+
+	result, err = db.DeleteUserGroupReference(ctx, UserGroupReference{UserID: 2, GroupID: 1})
+	if rows, _ := result.RowsAffected(); err != nil || rows != 1 {
+		t.Fatalf("user group reference should be deleted: %v", err)
+	}
+
+	// the memberships of a deleted user are deleted with it
+	_, err = db.DeleteUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs, _ := db.GetUserGroupReferences(nil, ctx)
+	if len(refs) != 0 {
+		t.Fatalf("memberships of the deleted user should be deleted: %v", refs)
+	}
+
+	_, err = db.InsertUserGroupReference(ctx, UserGroupReference{UserID: 2, GroupID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the memberships and permissions of a deleted group are deleted with it
+	_, err = db.DeleteGroup(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs, _ = db.GetUserGroupReferences(nil, ctx)
+	grants, _ := db.GetGroupPermissions(nil, ctx)
+
+	if len(refs) != 0 || len(grants) != 0 {
+		t.Fatalf("references of the deleted group should be deleted: %v %v", refs, grants)
+	}
+
+	permissions, err := db.GetUserPermissions("bob", ctx)
+	if err != nil || len(permissions) != 0 {
+		t.Fatalf("bob should have no permissions left: %v (%v)", permissions, err)
+	}
+}
+
+func TestMemoryDatabaseListUsers(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, user := range []User{{Name: "alice", PasswordHash: "hash", IsAdmin: true}, {Name: "bob", PasswordHash: "hash"}} {
+		_, err := db.InsertUser(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := db.ListUsers(Filter{Key: "is_admin", Operator: "=", Value: false}, Page{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 1 || result.Items[0].Name != "bob" || result.Items[0].PasswordHash != "" {
+		t.Fatalf("wrong users returned: %v", result)
+	}
+
+	// the stored hash is kept
+	user, err := db.GetUser(Filter{Key: "name", Operator: "=", Value: "bob"}, ctx)
+	if err != nil || user.PasswordHash != "hash" {
+		t.Fatalf("password hash has been changed: %v %v", user, err)
+	}
+}
@@ -0,0 +1,434 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// memoryRunRow returns the row of the run. The columns that are only used by the queue are left out.
+func memoryRunRow(run memoryRun) map[string]any {
+	return runRow(run.Run)
+}
+
+// isActive returns true if the run is not final and blocks new runs of its workspace.
+func (r memoryRun) isActive() bool {
+	return r.Status == RunStatusQueued || r.Status == RunStatusRunning || r.Status == RunStatusPlanned
+}
+
+// updateRuns applies update to all runs that match where. Needs to be called with the lock held.
+// Returns the number of updated runs.
+func (db *MemoryDatabase) updateRuns(where func(run memoryRun) bool, update func(run *memoryRun)) int64 {
+	var affected int64
+
+	for i := range db.runs {
+		if where(db.runs[i]) {
+			update(&db.runs[i])
+			affected++
+		}
+	}
+
+	return affected
+}
+
+// GetRuns returns all runs based on the filter.
+func (db *MemoryDatabase) GetRuns(filter FilterExpr, _ context.Context) ([]Run, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	selected, err := selectRows(db.runs, filter, memoryRunRow)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+
+	for _, run := range selected {
+		runs = append(runs, run.Run)
+	}
+
+	return runs, nil
+}
+
+// ListRuns returns a page of the runs that match the filter.
+func (db *MemoryDatabase) ListRuns(filter FilterExpr, page Page, _ context.Context) (PageResult[Run], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	selected, err := selectPage(db.runs, TableNameRuns, filter, page, memoryRunRow)
+	if err != nil {
+		return PageResult[Run]{}, err
+	}
+
+	result := PageResult[Run]{Total: selected.Total, NextCursor: selected.NextCursor}
+
+	for _, run := range selected.Items {
+		result.Items = append(result.Items, run.Run)
+	}
+
+	return result, nil
+}
+
+// GetRun returns a single run based on the filter.
+func (db *MemoryDatabase) GetRun(filter FilterExpr, ctx context.Context) (Run, error) {
+	runs, err := db.GetRuns(filter, ctx)
+	if err != nil {
+		return Run{}, err
+	}
+
+	if !isSingleElement[Run](runs) {
+		return Run{}, fmt.Errorf("not exactly 1 run has been found with the filter %s", filter)
+	}
+
+	return runs[0], nil
+}
+
+// InsertRun inserts a new run and returns its id.
+//
+// Returns ErrActiveRun if the workspace already has an active run. Runs without kind are inserted as RunKindDeploy.
+func (db *MemoryDatabase) InsertRun(_ context.Context, run Run) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	inserted := memoryRun{Run: Run{
+		Workspace: run.Workspace,
+		Kind:      run.Kind,
+		Status:    run.Status,
+		Phase:     run.Phase,
+		Message:   run.Message,
+		Options: RunOptions{
+			Targets:     slices.Clone(run.Options.Targets),
+			Replace:     slices.Clone(run.Options.Replace),
+			RefreshOnly: run.Options.RefreshOnly,
+			Destroy:     run.Options.Destroy,
+		},
+		CreatedAt: time.Now(),
+	}}
+
+	if inserted.Kind == "" {
+		inserted.Kind = RunKindDeploy
+	}
+
+	if inserted.isActive() && slices.ContainsFunc(db.runs, func(r memoryRun) bool {
+		return r.Workspace == run.Workspace && r.isActive()
+	}) {
+		return 0, ErrActiveRun
+	}
+
+	inserted.ID = db.nextID(TableNameRuns)
+	db.runs = append(db.runs, inserted)
+
+	return inserted.ID, nil
+}
+
+// UpdateRun updates the lifecycle fields (status, phase, message and timestamps) of the given run.
+func (db *MemoryDatabase) UpdateRun(_ context.Context, run Run) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	affected := db.updateRuns(
+		func(r memoryRun) bool { return r.ID == run.ID },
+		func(r *memoryRun) {
+			r.Status = run.Status
+			r.Phase = run.Phase
+			r.Message = run.Message
+			r.StartedAt = run.StartedAt
+			r.FinishedAt = run.FinishedAt
+			r.PostApplyStatus = run.PostApplyStatus
+
+			// like the SqlDatabase, a canceled run can't become queued or planned again
+			if r.CanceledBy != "" && (run.Status == RunStatusQueued || run.Status == RunStatusPlanned) {
+				now := time.Now()
+
+				r.Status = RunStatusCanceled
+				r.Message = "canceled by " + r.CanceledBy
+				r.FinishedAt = &now
+			}
+		},
+	)
+
+	return memoryResult{rowsAffected: affected}, nil
+}
+
+// ApproveRun approves the saved plan of a planned run and puts the run back into the queue to apply the plan.
+//
+// Only runs with the status planned are approved. Check the affected rows of the result.
+func (db *MemoryDatabase) ApproveRun(_ context.Context, id int) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+
+	affected := db.updateRuns(
+		func(r memoryRun) bool { return r.ID == id && r.Status == RunStatusPlanned && r.CanceledBy == "" },
+		func(r *memoryRun) {
+			r.Status = RunStatusQueued
+			r.ApprovedAt = &now
+			r.Message = ""
+			r.Attempts = 0
+			r.nextAttemptAt = nil
+		},
+	)
+
+	return memoryResult{rowsAffected: affected}, nil
+}
+
+// ClaimRun claims the oldest queued run for the given worker and marks it as running.
+//
+// Runs with a next attempt in the future are skipped. Returns false if no run is waiting for execution.
+func (db *MemoryDatabase) ClaimRun(_ context.Context, worker string) (Run, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+
+	for i := range db.runs {
+		run := &db.runs[i]
+
+		if run.Status != RunStatusQueued || (run.nextAttemptAt != nil && run.nextAttemptAt.After(now)) {
+			continue
+		}
+
+		run.Status = RunStatusRunning
+		run.Attempts++
+		run.claimedBy = worker
+		run.heartbeatAt = &now
+
+		return run.Run, true, nil
+	}
+
+	return Run{}, false, nil
+}
+
+// HeartbeatRun marks the claimed run as still being executed by the given worker.
+//
+// Returns true if the cancellation of the run has been requested.
+func (db *MemoryDatabase) HeartbeatRun(_ context.Context, id int, worker string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+
+	for i := range db.runs {
+		if db.runs[i].ID == id && db.runs[i].claimedBy == worker {
+			db.runs[i].heartbeatAt = &now
+
+			return db.runs[i].CanceledBy != "", nil
+		}
+	}
+
+	// the run is not claimed by the worker anymore
+	return false, nil
+}
+
+// CancelRun cancels the run on behalf of the given user.
+//
+// Queued and planned runs are canceled immediately. For running runs, the cancellation is requested.
+// Returns the status of the run after the cancellation. Returns false if the run can't be canceled.
+func (db *MemoryDatabase) CancelRun(_ context.Context, id int, canceledBy string) (RunStatus, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i := range db.runs {
+		run := &db.runs[i]
+
+		if run.ID != id || !run.isActive() || run.CanceledBy != "" {
+			continue
+		}
+
+		run.CanceledBy = canceledBy
+
+		if run.Status != RunStatusRunning {
+			now := time.Now()
+
+			run.Status = RunStatusCanceled
+			run.Message = fmt.Sprintf("canceled by %s", canceledBy)
+			run.FinishedAt = &now
+		}
+
+		return run.Status, true, nil
+	}
+
+	return "", false, nil
+}
+
+// RequeueRun puts the run back into the queue. The run will not be claimed before nextAttempt.
+// Runs whose cancellation has been requested are not put back into the queue.
+// If countAttempt is false, the attempt counted by ClaimRun is taken back.
+func (db *MemoryDatabase) RequeueRun(
+	_ context.Context, id int, nextAttempt time.Time, message string, countAttempt bool,
+) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	affected := db.updateRuns(
+		func(r memoryRun) bool { return r.ID == id && r.CanceledBy == "" },
+		func(r *memoryRun) {
+			r.Status = RunStatusQueued
+			r.Phase = ""
+			r.Message = message
+			r.nextAttemptAt = &nextAttempt
+			r.claimedBy = ""
+			r.heartbeatAt = nil
+			r.FinishedAt = nil
+
+			if !countAttempt {
+				r.Attempts--
+			}
+		},
+	)
+
+	return memoryResult{rowsAffected: affected}, nil
+}
+
+// RecoverStaleRuns recovers running runs whose worker has not sent a heartbeat since staleBefore.
+//
+// Like the SqlDatabase, runs that have not reached the apply phase are put back into the queue. Runs that were
+// interrupted during apply or the post-apply stage are marked as errored. Runs whose cancellation has been requested
+// are marked as canceled.
+func (db *MemoryDatabase) RecoverStaleRuns(_ context.Context, staleBefore time.Time) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+
+	return db.updateRuns(
+		func(r memoryRun) bool {
+			return r.Status == RunStatusRunning && r.heartbeatAt != nil && r.heartbeatAt.Before(staleBefore)
+		},
+		func(r *memoryRun) {
+			r.claimedBy = ""
+			r.heartbeatAt = nil
+
+			switch {
+			case r.CanceledBy != "":
+				r.Status = RunStatusCanceled
+				r.Message = "run was canceled. the worker was gone before the interruption was confirmed"
+				r.FinishedAt = &now
+			case slices.Contains([]string{"apply", "output", "ansible-playbook"}, r.Phase):
+				r.Status = RunStatusErrored
+				r.Message = "run was interrupted during or after apply. check the state of the workspace"
+				r.FinishedAt = &now
+			default:
+				r.Status = RunStatusQueued
+				r.Message = "run was interrupted. requeued"
+				r.FinishedAt = nil
+				r.Phase = ""
+			}
+		},
+	), nil
+}
+
+func (db *MemoryDatabase) GetRunPhases(filter FilterExpr, _ context.Context) ([]RunPhase, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.runPhases, filter, runPhaseRow)
+}
+
+func (db *MemoryDatabase) InsertRunPhase(_ context.Context, phase RunPhase) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.runPhases = append(db.runPhases, phase)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) GetRunEvents(filter FilterExpr, _ context.Context) ([]RunEvent, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.runEvents, filter, runEventRow)
+}
+
+// ListRunEvents returns a page of the run events that match the filter.
+func (db *MemoryDatabase) ListRunEvents(filter FilterExpr, page Page, _ context.Context) (PageResult[RunEvent], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectPage(db.runEvents, TableNameRunEvents, filter, page, runEventRow)
+}
+
+func (db *MemoryDatabase) InsertRunEvent(_ context.Context, event RunEvent) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	event.ID = db.nextID(TableNameRunEvents)
+	db.runEvents = append(db.runEvents, event)
+
+	return memoryResult{lastInsertID: int64(event.ID), rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) GetRunAnnotations(filter FilterExpr, _ context.Context) ([]RunAnnotation, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.runAnnotations, filter, runAnnotationRow)
+}
+
+// InsertRunAnnotation inserts the annotation. An existing annotation of the run with the same name is replaced.
+func (db *MemoryDatabase) InsertRunAnnotation(_ context.Context, annotation RunAnnotation) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	annotation.CreatedAt = time.Now()
+
+	for i, a := range db.runAnnotations {
+		if a.RunID == annotation.RunID && a.Name == annotation.Name {
+			db.runAnnotations[i] = annotation
+
+			return memoryResult{rowsAffected: 1}, nil
+		}
+	}
+
+	db.runAnnotations = append(db.runAnnotations, annotation)
+
+	return memoryResult{rowsAffected: 1}, nil
+}
+
+func (db *MemoryDatabase) GetRunArtifacts(filter FilterExpr, _ context.Context) ([]RunArtifact, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.runArtifacts, filter, runArtifactRow)
+}
+
+func (db *MemoryDatabase) GetRunArtifact(filter FilterExpr, ctx context.Context) (RunArtifact, error) {
+	artifacts, err := db.GetRunArtifacts(filter, ctx)
+	if err != nil {
+		return RunArtifact{}, err
+	}
+
+	if !isSingleElement[RunArtifact](artifacts) {
+		return RunArtifact{}, fmt.Errorf("not exactly 1 run artifact has been found with the filter %s", filter)
+	}
+
+	return artifacts[0], nil
+}
+
+// InsertRunArtifact inserts the artifact. An existing artifact of the run with the same name is replaced.
+func (db *MemoryDatabase) InsertRunArtifact(_ context.Context, artifact RunArtifact) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	artifact.Content = slices.Clone(artifact.Content)
+	artifact.CreatedAt = time.Now()
+
+	for i, a := range db.runArtifacts {
+		if a.RunID == artifact.RunID && a.Name == artifact.Name {
+			artifact.ID = a.ID
+			db.runArtifacts[i] = artifact
+
+			return memoryResult{lastInsertID: int64(artifact.ID), rowsAffected: 1}, nil
+		}
+	}
+
+	artifact.ID = db.nextID(TableNameRunArtifacts)
+
+	db.runArtifacts = append(db.runArtifacts, artifact)
+
+	return memoryResult{lastInsertID: int64(artifact.ID), rowsAffected: 1}, nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryDatabaseRunLifecycle(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	id, err := db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if !errors.Is(err, ErrActiveRun) {
+		t.Fatalf("second active run should be rejected: %v", err)
+	}
+
+	run, ok, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil || !ok {
+		t.Fatalf("queued run should be claimed: %v", err)
+	}
+
+	if run.ID != id || run.Status != RunStatusRunning || run.Attempts != 1 || run.Kind != RunKindDeploy {
+		t.Fatalf("wrong run claimed: %v", run)
+	}
+
+	_, ok, _ = db.ClaimRun(ctx, "worker-1")
+	if ok {
+		t.Fatal("running run should not be claimed again")
+	}
+
+	run.Status = RunStatusPlanned
+	run.Phase = "show"
+
+	_, err = db.UpdateRun(ctx, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.ApproveRun(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Fatal("planned run should be approved")
+	}
+
+	run, ok, _ = db.ClaimRun(ctx, "worker-1")
+	if !ok || run.ApprovedAt == nil || run.Attempts != 1 {
+		t.Fatalf("approved run should be claimed: %v", run)
+	}
+
+	status, ok, err := db.CancelRun(ctx, id, "dummy")
+	if err != nil || !ok || status != RunStatusRunning {
+		t.Fatalf("cancellation of running run should be requested. got %s (%v)", status, err)
+	}
+
+	canceled, _ := db.HeartbeatRun(ctx, id, "worker-1")
+	if !canceled {
+		t.Fatal("heartbeat should report the requested cancellation")
+	}
+
+	_, ok, _ = db.CancelRun(ctx, id, "dummy")
+	if ok {
+		t.Fatal("run should not be canceled twice")
+	}
+}
+
+func TestMemoryDatabaseRequeueAndRecover(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, workspace := range []string{"web", "db"} {
+		_, err := db.InsertRun(ctx, Run{Workspace: workspace, Status: RunStatusQueued})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	web, _, _ := db.ClaimRun(ctx, "worker-0")
+
+	_, err := db.RequeueRun(ctx, web.ID, time.Now().Add(time.Hour), "retrying", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbRun, ok, _ := db.ClaimRun(ctx, "worker-0")
+	if !ok || dbRun.Workspace != "db" {
+		t.Fatalf("runs with a future attempt should be skipped: %v", dbRun)
+	}
+
+	dbRun.Phase = "apply"
+
+	_, err = db.UpdateRun(ctx, dbRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := db.RecoverStaleRuns(ctx, time.Now().Add(time.Minute))
+	if err != nil || recovered != 1 {
+		t.Fatalf("stale run should be recovered: %d (%v)", recovered, err)
+	}
+
+	dbRun, err = db.GetRun(Filter{Key: "id", Operator: "=", Value: dbRun.ID}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dbRun.Status != RunStatusErrored || dbRun.FinishedAt == nil {
+		t.Fatalf("run interrupted during apply should be errored: %v", dbRun)
+	}
+
+	active, err := db.GetRuns(ActiveRunFilter("web"), ctx)
+	if err != nil || len(active) != 1 || active[0].Message != "retrying" {
+		t.Fatalf("requeued run should be active: %v (%v)", active, err)
+	}
+}
+
+func TestMemoryDatabaseRunRecords(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+	filter := Filter{Key: "run_id", Operator: "=", Value: 1}
+
+	_, err := db.InsertRunPhase(ctx, RunPhase{RunID: 1, Phase: "plan", Result: "success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertRunEvent(ctx, RunEvent{RunID: 1, Phase: "plan", Type: "version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"CHG-1", "CHG-2"} {
+		_, err = db.InsertRunAnnotation(ctx, RunAnnotation{RunID: 1, Hook: "cmdb", Name: "ticket", Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, content := range []string{"old plan", "plan"} {
+		_, err = db.InsertRunArtifact(ctx, RunArtifact{RunID: 1, Name: "plan", Content: []byte(content)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	phases, _ := db.GetRunPhases(filter, ctx)
+	events, _ := db.GetRunEvents(filter, ctx)
+	annotations, _ := db.GetRunAnnotations(filter, ctx)
+
+	if len(phases) != 1 || len(events) != 1 || events[0].ID != 1 {
+		t.Fatalf("phases and events should be stored: %v %v", phases, events)
+	}
+
+	if len(annotations) != 1 || annotations[0].Value != "CHG-2" {
+		t.Fatalf("annotation should be replaced: %v", annotations)
+	}
+
+	artifact, err := db.GetRunArtifact(LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+		filter,
+		Filter{Key: "name", Operator: "=", Value: "plan"},
+	}}, ctx)
+	if err != nil || string(artifact.Content) != "plan" {
+		t.Fatalf("artifact should be replaced: %v (%v)", artifact, err)
+	}
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	now := time.Now()
+	row := map[string]any{
+		"name":        "web",
+		"status":      RunStatusQueued,
+		"attempts":    2,
+		"created_at":  now,
+		"finished_at": (*time.Time)(nil),
+	}
+
+	tests := []struct {
+		filter FilterExpr
+		match  bool
+	}{
+		{nil, true},
+		{Filter{Key: "name", Operator: "=", Value: "web"}, true},
+		{&Filter{Key: "name", Operator: "!=", Value: "web"}, false},
+		{Filter{Key: "status", Operator: "=", Value: "queued"}, true},
+		{Filter{Key: "attempts", Operator: ">=", Value: 2}, true},
+		{Filter{Key: "attempts", Operator: "<", Value: int64(2)}, false},
+		{Filter{Key: "created_at", Operator: "<=", Value: now.Add(time.Second)}, true},
+		{Filter{Key: "finished_at", Operator: "=", Value: now}, false}, // NULL never matches
+		{Filter{Key: "name", Operator: "LIKE", Value: "w%"}, true},
+		{Filter{Key: "name", Operator: "like", Value: "w_b"}, true},
+		{Filter{Key: "name", Operator: "LIKE", Value: "W%"}, false},
+		{Filter{Key: "name", Operator: "ILIKE", Value: "W%"}, true},
+		{Filter{Key: "name", Operator: "NOT LIKE", Value: "%eb"}, false},
+		{Filter{Key: "name", Operator: "NOT ILIKE", Value: "db%"}, true},
+		{Filter{Key: "name", Operator: "LIKE", Value: `we\_`}, false}, // escaped wildcard
+		{Filter{Key: "finished_at", Operator: "LIKE", Value: "%"}, false},
+		{Filter{Key: "status", Operator: "IN", Value: []RunStatus{RunStatusRunning, RunStatusQueued}}, true},
+		{Filter{Key: "attempts", Operator: "not in", Value: []int{1, 2}}, false},
+		{Filter{Key: "attempts", Operator: "BETWEEN", Value: []int{2, 3}}, true},
+		{Filter{Key: "created_at", Operator: "BETWEEN", Value: []time.Time{now.Add(time.Second), now.Add(time.Hour)}}, false},
+		{Filter{Key: "finished_at", Operator: "IS NULL"}, true},
+		{Filter{Key: "created_at", Operator: "IS NULL"}, false},
+		{Filter{Key: "finished_at", Operator: "IS NOT NULL"}, false},
+		{Filter{Key: "finished_at", Operator: "IN", Value: []any{now}}, false},
+		{LogicalFilter{Operator: "NOT", Filters: []FilterExpr{Filter{Key: "name", Operator: "=", Value: "db"}}}, true},
+		{LogicalFilter{}, true},
+		{LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+			Filter{Key: "name", Operator: "=", Value: "web"},
+			Filter{Key: "attempts", Operator: ">", Value: 5},
+		}}, false},
+		{LogicalFilter{Operator: "or", Filters: []FilterExpr{
+			Filter{Key: "name", Operator: "=", Value: "db"},
+			&LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+				Filter{Key: "status", Operator: "=", Value: RunStatusQueued},
+			}},
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		match, err := matches(tt.filter, row)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if match != tt.match {
+			t.Fatalf("wrong result for filter %v: %t", tt.filter, match)
+		}
+	}
+}
+
+func TestMatchesInvalid(t *testing.T) {
+	row := map[string]any{"name": "web", "attempts": 2}
+
+	for _, filter := range []FilterExpr{
+		Filter{Key: "unknown", Operator: "=", Value: "web"},
+		Filter{Key: "name", Operator: "~", Value: "web"},
+		Filter{Key: "attempts", Operator: "LIKE", Value: "2"},
+		Filter{Key: "name", Operator: "LIKE", Value: `web\`},
+		Filter{Key: "name", Operator: "=", Value: 1},
+		Filter{Key: "", Operator: "=", Value: "web"},
+		LogicalFilter{Operator: "XOR", Filters: []FilterExpr{Filter{Key: "name", Operator: "=", Value: "web"}}},
+		LogicalFilter{Operator: "NOT", Filters: []FilterExpr{
+			Filter{Key: "name", Operator: "=", Value: "web"},
+			Filter{Key: "name", Operator: "=", Value: "db"},
+		}},
+		Filter{Key: "name", Operator: "IN", Value: []string{}},
+		Filter{Key: "attempts", Operator: "BETWEEN", Value: []int{1}},
+		Filter{Key: "name", Operator: "=", Value: []string{"web"}},
+	} {
+		_, err := matches(filter, row)
+		if err == nil {
+			t.Fatalf("filter should be invalid: %v", filter)
+		}
+	}
+}
+
+func TestNewMemoryDatabase(t *testing.T) {
+	var db Database = NewMemoryDatabase()
+
+	if db.TestConnection() != nil || db.Close() != nil {
+		t.Fatal("memory database should always be connected")
+	}
+
+	permission, err := db.GetPermission(LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+		Filter{Key: "resource", Operator: "=", Value: "run"},
+		Filter{Key: "action", Operator: "=", Value: "apply"},
+	}}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if permission.ID != 11 {
+		t.Fatalf("permissions of the schema should exist. got %v", permission)
+	}
+}
+
+func TestMemoryDatabaseConcurrency(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	var wg sync.WaitGroup
+
+	for i := range 20 {
+		wg.Go(func() {
+			_, err := db.InsertUser(ctx, User{Name: fmt.Sprintf("user-%d", i)})
+			if err != nil {
+				t.Error(err)
+			}
+
+			_, err = db.GetUsers(Filter{Key: "name", Operator: "LIKE", Value: "user-%"}, ctx)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	users, err := db.GetUsers(nil, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[int]bool)
+	for _, user := range users {
+		ids[user.ID] = true
+	}
+
+	if len(users) != 20 || len(ids) != 20 {
+		t.Fatalf("expected 20 users with distinct ids, got %v", users)
+	}
+}
+
+func TestMemoryDatabaseWithTx(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+	errFailed := errors.New("failed")
+
+	err := db.WithTx(ctx, func(tx Database) error {
+		_, err := tx.InsertGroup(ctx, Group{Name: "operators"})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.LockWorkspace(ctx, Workspace{ID: 1, Name: "web"}, 1, "host-1")
+		if !errors.Is(err, ErrInTransaction) {
+			t.Fatalf("expected ErrInTransaction, got %v", err)
+		}
+
+		return tx.WithTx(ctx, func(tx Database) error {
+			_, err := tx.InsertGroup(ctx, Group{Name: "admins"})
+
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.WithTx(ctx, func(tx Database) error {
+		_, err := tx.InsertGroup(ctx, Group{Name: "auditors"})
+		if err != nil {
+			return err
+		}
+
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	groups, err := db.GetGroups(nil, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 || groups[0].Name != "operators" || groups[1].Name != "admins" {
+		t.Fatalf("expected only the committed groups, got %v", groups)
+	}
+}
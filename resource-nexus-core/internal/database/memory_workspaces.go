@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+func (db *MemoryDatabase) GetWorkspaces(filter FilterExpr, _ context.Context) ([]Workspace, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.workspaces, filter, workspaceRow)
+}
+
+func (db *MemoryDatabase) ListWorkspaces(
+	filter FilterExpr, page Page, _ context.Context,
+) (PageResult[Workspace], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectPage(db.workspaces, TableNameWorkspaces, filter, page, workspaceRow)
+}
+
+func (db *MemoryDatabase) GetWorkspace(filter FilterExpr, ctx context.Context) (Workspace, error) {
+	workspaces, err := db.GetWorkspaces(filter, ctx)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	if !isSingleElement[Workspace](workspaces) {
+		return Workspace{}, fmt.Errorf("not exactly 1 workspace has been found with the filter %s", filter)
+	}
+
+	return workspaces[0], nil
+}
+
+func (db *MemoryDatabase) InsertWorkspace(_ context.Context, workspace Workspace) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if slices.ContainsFunc(db.workspaces, func(w Workspace) bool { return w.Name == workspace.Name }) {
+		return nil, fmt.Errorf("failed to insert workspace: %w", errUniqueViolation)
+	}
+
+	// only the inserted columns are taken over. everything else gets the defaults of the schema
+	workspace = Workspace{
+		ID:               db.nextID(TableNameWorkspaces),
+		Name:             workspace.Name,
+		WorkingDirectory: workspace.WorkingDirectory,
+		ExecutablePath:   workspace.ExecutablePath,
+		Provisioner:      workspace.Provisioner,
+		RequiredVersion:  workspace.RequiredVersion,
+		Playbook:         workspace.Playbook,
+		DriftInterval:    workspace.DriftInterval,
+		InitTimeout:      workspace.InitTimeout,
+		PlanTimeout:      workspace.PlanTimeout,
+		ApplyTimeout:     workspace.ApplyTimeout,
+		CreatedAt:        time.Now(),
+	}
+
+	db.workspaces = append(db.workspaces, workspace)
+
+	return memoryResult{lastInsertID: int64(workspace.ID), rowsAffected: 1}, nil
+}
+
+// GetDriftDueWorkspaces returns the workspaces with enabled drift detection whose last detection is older than their
+// drift interval. Workspaces that have never been checked are returned first.
+func (db *MemoryDatabase) GetDriftDueWorkspaces(_ context.Context) ([]Workspace, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+
+	var due []Workspace
+
+	for _, workspace := range db.workspaces {
+		if workspace.DriftInterval <= 0 {
+			continue
+		}
+
+		interval := time.Duration(workspace.DriftInterval) * time.Second
+
+		if workspace.DriftCheckedAt == nil || !workspace.DriftCheckedAt.Add(interval).After(now) {
+			due = append(due, workspace)
+		}
+	}
+
+	slices.SortStableFunc(due, func(a, b Workspace) int {
+		switch {
+		case a.DriftCheckedAt == nil && b.DriftCheckedAt == nil:
+			return 0
+		case a.DriftCheckedAt == nil:
+			return -1
+		case b.DriftCheckedAt == nil:
+			return 1
+		default:
+			return a.DriftCheckedAt.Compare(*b.DriftCheckedAt)
+		}
+	})
+
+	return due, nil
+}
+
+// UpdateWorkspaceDrift stores the result of a drift detection of the workspace.
+func (db *MemoryDatabase) UpdateWorkspaceDrift(
+	_ context.Context, workspace string, status DriftStatus, runID int,
+) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var affected int64
+
+	for i := range db.workspaces {
+		if db.workspaces[i].Name != workspace {
+			continue
+		}
+
+		now := time.Now()
+
+		db.workspaces[i].DriftStatus = status
+		db.workspaces[i].DriftCheckedAt = &now
+		db.workspaces[i].DriftRunID = &runID
+		affected++
+	}
+
+	return memoryResult{rowsAffected: affected}, nil
+}
+
+// memoryLock is a WorkspaceLock of the MemoryDatabase. It is only valid inside the current process.
+type memoryLock struct {
+	db        *MemoryDatabase
+	workspace string
+	runID     int
+}
+
+// LockWorkspace locks the workspace for the given run.
+//
+// Returns a *WorkspaceLockedError if the workspace is already locked and ErrInTransaction inside a transaction.
+func (db *MemoryDatabase) LockWorkspace(
+	_ context.Context, workspace Workspace, runID int, holder string,
+) (WorkspaceLock, error) {
+	if db.inTx {
+		return nil, ErrInTransaction
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, lock := range db.locks {
+		if lock.Workspace == workspace.Name {
+			return nil, &WorkspaceLockedError{Workspace: workspace.Name, RunID: lock.RunID}
+		}
+	}
+
+	db.locks = append(db.locks, WorkspaceLockInfo{
+		Workspace:  workspace.Name,
+		RunID:      runID,
+		Holder:     holder,
+		BackendPID: os.Getpid(),
+		AcquiredAt: time.Now(),
+		Held:       true,
+	})
+
+	return &memoryLock{db: db, workspace: workspace.Name, runID: runID}, nil
+}
+
+// Release releases the lock. A lock that has been removed in the meantime is ignored.
+func (l *memoryLock) Release(_ context.Context) error {
+	l.db.mu.Lock()
+	defer l.db.mu.Unlock()
+
+	l.db.locks = slices.DeleteFunc(l.db.locks, func(lock WorkspaceLockInfo) bool {
+		return lock.Workspace == l.workspace && lock.RunID == l.runID
+	})
+
+	return nil
+}
+
+func (db *MemoryDatabase) GetWorkspaceLocks(filter FilterExpr, _ context.Context) ([]WorkspaceLockInfo, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.locks, filter, workspaceLockRow)
+}
+
+// ForceUnlockWorkspace removes the lock of the workspace, regardless of the holder.
+func (db *MemoryDatabase) ForceUnlockWorkspace(_ context.Context, workspace string) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	before := len(db.locks)
+
+	db.locks = slices.DeleteFunc(db.locks, func(lock WorkspaceLockInfo) bool {
+		return lock.Workspace == workspace
+	})
+
+	return memoryResult{rowsAffected: int64(before - len(db.locks))}, nil
+}
+
+func (db *MemoryDatabase) GetCredentials(filter FilterExpr, _ context.Context) ([]Credential, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectRows(db.credentials, filter, credentialRow)
+}
+
+func (db *MemoryDatabase) ListCredentials(
+	filter FilterExpr, page Page, _ context.Context,
+) (PageResult[Credential], error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return selectPage(db.credentials, TableNameCredentials, filter, page, credentialRow)
+}
+
+// InsertCredential inserts the credential. The value of an existing credential with the same name is replaced.
+func (db *MemoryDatabase) InsertCredential(_ context.Context, credential Credential) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	value := slices.Clone(credential.Value)
+
+	for i, c := range db.credentials {
+		if c.Workspace == credential.Workspace && c.Name == credential.Name {
+			db.credentials[i].Value = value
+			db.credentials[i].UpdatedAt = now
+
+			return memoryResult{rowsAffected: 1}, nil
+		}
+	}
+
+	credential = Credential{
+		ID:        db.nextID(TableNameCredentials),
+		Workspace: credential.Workspace,
+		Name:      credential.Name,
+		Value:     value,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	db.credentials = append(db.credentials, credential)
+
+	return memoryResult{lastInsertID: int64(credential.ID), rowsAffected: 1}, nil
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryDatabaseWorkspaces(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, workspace := range []Workspace{
+		{Name: "web", WorkingDirectory: "/srv/web", DriftInterval: 60},
+		{Name: "db", WorkingDirectory: "/srv/db"},
+	} {
+		_, err := db.InsertWorkspace(ctx, workspace)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := db.InsertWorkspace(ctx, Workspace{Name: "web"})
+	if !errors.Is(err, errUniqueViolation) {
+		t.Fatalf("duplicate workspace should be rejected: %v", err)
+	}
+
+	due, err := db.GetDriftDueWorkspaces(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(due) != 1 || due[0].Name != "web" {
+		t.Fatalf("only workspaces with drift interval should be due: %v", due)
+	}
+
+	_, err = db.UpdateWorkspaceDrift(ctx, "web", DriftStatusDrifted, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err = db.GetDriftDueWorkspaces(ctx)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("checked workspace should not be due: %v (%v)", due, err)
+	}
+
+	workspace, err := db.GetWorkspace(Filter{Key: "name", Operator: "=", Value: "web"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if workspace.DriftStatus != DriftStatusDrifted || workspace.DriftRunID == nil || *workspace.DriftRunID != 3 {
+		t.Fatalf("drift should be stored: %v", workspace)
+	}
+}
+
+func TestMemoryDatabaseLockWorkspace(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+	workspace := Workspace{ID: 1, Name: "web"}
+
+	lock, err := db.LockWorkspace(ctx, workspace, 1, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.LockWorkspace(ctx, workspace, 2, "worker-1")
+
+	var locked *WorkspaceLockedError
+	if !errors.As(err, &locked) || locked.RunID != 1 {
+		t.Fatalf("locked workspace should return the holding run: %v", err)
+	}
+
+	locks, err := db.GetWorkspaceLocks(Filter{Key: "workspace", Operator: "=", Value: "web"}, ctx)
+	if err != nil || len(locks) != 1 || !locks[0].Held {
+		t.Fatalf("lock should be listed: %v (%v)", locks, err)
+	}
+
+	err = lock.Release(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.LockWorkspace(ctx, workspace, 2, "worker-1")
+	if err != nil {
+		t.Fatalf("released workspace should be lockable: %v", err)
+	}
+
+	result, err := db.ForceUnlockWorkspace(ctx, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Fatalf("lock should be removed. affected: %d", affected)
+	}
+}
+
+func TestMemoryDatabaseCredentials(t *testing.T) {
+	db := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for _, value := range []string{"first", "second"} {
+		_, err := db.InsertCredential(ctx, Credential{Workspace: "web", Name: "TF_VAR_token", Value: []byte(value)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := db.GetCredentials(Filter{Key: "workspace", Operator: "=", Value: "web"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 1 || string(stored[0].Value) != "second" {
+		t.Fatalf("existing credential should be replaced: %v", stored)
+	}
+}
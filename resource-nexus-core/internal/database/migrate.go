@@ -0,0 +1,456 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	TableNameSchemaMigrations string = "schema_migrations"
+
+	// pqUndefinedTable is the error code of PostgresSQL if a table does not exist.
+	pqUndefinedTable pq.ErrorCode = "42P01"
+
+	// migrationLockKey is the key of the PostgresSQL advisory lock held while migrations are applied or rolled back.
+	migrationLockKey int = 4891
+
+	// baselineVersion is the version of the migration that matches contrib/database/schema/schema-psql.sql.
+	// Databases created from this file before migrations existed start at this version.
+	baselineVersion int = 1
+)
+
+// baselineTables are the tables created by the baseline migration and contrib/database/schema/schema-psql.sql.
+var baselineTables = []string{ //nolint:gochecknoglobals
+	TableNameUsers, TableNameGroups, TableNamePermissions, TableNameUserGroups, TableNameGroupPermissions,
+}
+
+// migrationFiles contains the migrations of the schema. Each dialect has its own directory.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// migrationPattern matches the file names of migrations. e.g. '0001_initial.up.sql'.
+var migrationPattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`) //nolint:gochecknoglobals
+
+// ErrSchemaVersion is returned if the schema version of the database does not match the version of the binary.
+var ErrSchemaVersion = errors.New("schema version mismatch")
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // statements to apply the migration
+	Down    string // statements to roll back the migration
+}
+
+// MigrationStatus is the status of a migration inside the database.
+type MigrationStatus struct {
+	Migration
+
+	AppliedAt *time.Time // nil if the migration has not been applied
+}
+
+// Migrations returns the migrations of the dialect embedded into the binary, ordered by their version.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations/"+dialect.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	return loadMigrations(sub)
+}
+
+// SchemaVersion returns the version of the newest migration of the dialect embedded into the binary.
+func SchemaVersion(dialect Dialect) (int, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// loadMigrations reads the migrations inside the root of fsys.
+//
+// Each migration consists of an up and a down file. e.g. '0001_initial.up.sql' and '0001_initial.down.sql'.
+// Returns an error if a file does not match the pattern, or one of the files of a migration is missing.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			return nil, fmt.Errorf("invalid migration file '%s'", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration '%s': %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by '%s' and '%s'", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+// CurrentSchemaVersion returns the version of the newest migration applied to the database.
+//
+// Returns 0 if no migration has been applied yet.
+func (db *SqlDatabase) CurrentSchemaVersion(ctx context.Context) (int, error) {
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", TableNameSchemaMigrations)
+
+	var version int
+
+	err := db.database.QueryRowContext(ctx, query).Scan(&version)
+	if isUndefinedTable(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to query schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CheckSchemaVersion returns an error wrapping ErrSchemaVersion if the schema of the database
+// is not at the version of the newest migration embedded into the binary.
+func (db *SqlDatabase) CheckSchemaVersion(ctx context.Context) error {
+	expected, err := SchemaVersion(db.dialect)
+	if err != nil {
+		return err
+	}
+
+	current, err := db.CurrentSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < expected {
+		return fmt.Errorf("%w: database schema is at version %d, expected %d. run 'migrate up' first",
+			ErrSchemaVersion, current, expected)
+	}
+
+	if current > expected {
+		return fmt.Errorf("%w: database schema is at version %d, which is newer than the supported version %d",
+			ErrSchemaVersion, current, expected)
+	}
+
+	return nil
+}
+
+// MigrationStatus returns the status of all embedded migrations, ordered by their version.
+func (db *SqlDatabase) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+
+	for _, migration := range migrations {
+		s := MigrationStatus{Migration: migration}
+
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// MigrateUp applies all pending migrations in the order of their versions.
+//
+// Each migration is applied inside its own transaction. Returns the applied migrations.
+// Concurrent calls on PostgresSQL are serialized, so each migration is applied once.
+// A PostgresSQL schema created from contrib/database/schema/schema-psql.sql is recorded at the baseline version first.
+func (db *SqlDatabase) MigrateUp(ctx context.Context) ([]Migration, error) {
+	unlock, err := db.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	status, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err = db.baseline(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []Migration
+
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			continue
+		}
+
+		db.logger.Info(fmt.Sprintf("applying migration %04d_%s", s.Version, s.Name))
+
+		insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES ($1, $2, now())",
+			TableNameSchemaMigrations)
+
+		err = db.migrate(ctx, s.Up, insert, s.Version, s.Name)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to apply migration %04d_%s: %w", s.Version, s.Name, err)
+		}
+
+		migrated = append(migrated, s.Migration)
+	}
+
+	return migrated, nil
+}
+
+// MigrateDown rolls back the newest applied migration.
+//
+// Returns false if no migration has been applied.
+func (db *SqlDatabase) MigrateDown(ctx context.Context) (Migration, bool, error) {
+	unlock, err := db.lockMigrations(ctx)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	defer unlock()
+
+	status, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return Migration{}, false, err
+	}
+
+	for _, s := range slices.Backward(status) {
+		if s.AppliedAt == nil {
+			continue
+		}
+
+		db.logger.Info(fmt.Sprintf("rolling back migration %04d_%s", s.Version, s.Name))
+
+		remove := fmt.Sprintf("DELETE FROM %s WHERE version = $1", TableNameSchemaMigrations)
+
+		err = db.migrate(ctx, s.Down, remove, s.Version)
+		if err != nil {
+			return Migration{}, false, fmt.Errorf("failed to roll back migration %04d_%s: %w", s.Version, s.Name, err)
+		}
+
+		return s.Migration, true, nil
+	}
+
+	return Migration{}, false, nil
+}
+
+// lockMigrations acquires the PostgresSQL advisory lock of the migrations. It waits until the lock is free.
+//
+// The returned function releases the lock. SQLite has no advisory locks. Nothing is locked.
+func (db *SqlDatabase) lockMigrations(ctx context.Context) (func(), error) {
+	if db.dialect == DialectSqlite {
+		return func() {}, nil
+	}
+
+	// session-level advisory locks are bound to a connection. it is reserved until the lock is released
+	conn, err := db.database.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection for migration lock: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	return func() {
+		_, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		if err != nil {
+			db.logger.Warn("failed to unlock migrations", "error", err)
+		}
+
+		_ = conn.Close()
+	}, nil
+}
+
+// baseline records the baseline migration as applied, if the schema has been created before migrations existed.
+//
+// This is the case if no migration has been applied, but all tables of the baseline migration exist. The statements
+// of the migration are not executed. The permissions of contrib/database/schema/schema-psql.sql were inserted with
+// explicit ids, so their sequence is moved behind them. Returns an error if only some of the tables exist.
+// Only PostgresSQL databases have been created from the file. The status of other databases is returned unchanged.
+func (db *SqlDatabase) baseline(ctx context.Context, status []MigrationStatus) ([]MigrationStatus, error) {
+	if db.dialect != DialectPostgres || len(status) == 0 || status[0].Version != baselineVersion {
+		return status, nil
+	}
+
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			return status, nil
+		}
+	}
+
+	var existing int
+
+	err := db.database.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ANY($1)",
+		pq.Array(baselineTables),
+	).Scan(&existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query existing tables: %w", err)
+	}
+
+	if existing == 0 {
+		return status, nil
+	}
+
+	if existing != len(baselineTables) {
+		return nil, fmt.Errorf("%d of %d tables of migration %04d_%s already exist. the schema can't be migrated",
+			existing, len(baselineTables), status[0].Version, status[0].Name)
+	}
+
+	db.logger.Info(fmt.Sprintf("existing schema found. recording migration %04d_%s as applied",
+		status[0].Version, status[0].Name))
+
+	sequence := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), (SELECT MAX(id) FROM %[1]s))",
+		TableNamePermissions)
+	insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES ($1, $2, now())",
+		TableNameSchemaMigrations)
+
+	err = db.migrate(ctx, sequence, insert, status[0].Version, status[0].Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record migration %04d_%s: %w", status[0].Version, status[0].Name, err)
+	}
+
+	now := time.Now()
+	status[0].AppliedAt = &now
+
+	return status, nil
+}
+
+// appliedMigrations returns the time each applied migration has been applied at by version.
+//
+// The schema_migrations table is created if it does not exist.
+func (db *SqlDatabase) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	appliedAtType := "TIMESTAMPTZ"
+	if db.dialect == DialectSqlite {
+		appliedAtType = "TIMESTAMP"
+	}
+
+	_, err := db.database.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name VARCHAR(256) NOT NULL, applied_at %s NOT NULL)",
+		TableNameSchemaMigrations,
+		appliedAtType,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", TableNameSchemaMigrations, err)
+	}
+
+	rows, closeRows, err := db.Select( //nolint:sqlclosecheck
+		fmt.Sprintf("SELECT version, applied_at FROM %s", TableNameSchemaMigrations), nil, ctx,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer closeRows()
+
+	applied := make(map[int]time.Time)
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+
+		applied[version] = appliedAt
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+
+	return applied, nil
+}
+
+// migrate executes the statements of a migration and the bookkeeping query inside a single transaction.
+func (db *SqlDatabase) migrate(ctx context.Context, statements string, bookkeeping string, args ...any) error {
+	tx, err := db.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	// statements without arguments are sent as a single query. this allows multiple statements per migration
+	_, err = tx.ExecContext(ctx, statements)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	_, err = tx.ExecContext(ctx, db.dialect.Rebind(bookkeeping), args...)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return tx.Commit() //nolint:wrapcheck
+}
@@ -0,0 +1,453 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func newMigrateTestDatabase(t *testing.T) (*SqlDatabase, sqlmock.Sqlmock) {
+	t.Helper()
+
+	d, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	return &SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}, mock
+}
+
+// expectMigrationLock adds the expectations of acquiring the advisory lock of the migrations.
+func expectMigrationLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectMigrationUnlock adds the expectations of releasing the advisory lock of the migrations.
+func expectMigrationUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(migrationLockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial" {
+		t.Fatalf("unexpected migrations: %v", migrations)
+	}
+
+	// permissions are inserted without ids
+	if !strings.Contains(migrations[0].Up, "INSERT INTO permissions (category, resource, action)") {
+		t.Fatal("initial migration does not insert the permissions")
+	}
+
+	version, err := SchemaVersion(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != migrations[len(migrations)-1].Version {
+		t.Fatalf("expected schema version %d, got %d", migrations[len(migrations)-1].Version, version)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(fstest.MapFS{
+		"0002_runs.up.sql":      {Data: []byte("CREATE TABLE runs ();")},
+		"0002_runs.down.sql":    {Data: []byte("DROP TABLE runs;")},
+		"0001_initial.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"0001_initial.down.sql": {Data: []byte("DROP TABLE users;")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Migration{
+		{Version: 1, Name: "initial", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"},
+		{Version: 2, Name: "runs", Up: "CREATE TABLE runs ();", Down: "DROP TABLE runs;"},
+	}
+
+	if len(migrations) != len(expected) {
+		t.Fatalf("expected %d migrations, got %d", len(expected), len(migrations))
+	}
+
+	for i := range expected {
+		if migrations[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], migrations[i])
+		}
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"0001_initial.up.sql": {Data: []byte("CREATE TABLE users ();")},
+		},
+		"invalid name": {
+			"initial.up.sql": {Data: []byte("CREATE TABLE users ();")},
+		},
+		"duplicate version": {
+			"0001_initial.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+			"0001_initial.down.sql": {Data: []byte("DROP TABLE users;")},
+			"0001_runs.up.sql":      {Data: []byte("CREATE TABLE runs ();")},
+			"0001_runs.down.sql":    {Data: []byte("DROP TABLE runs;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadMigrations(fsys)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestCurrentSchemaVersion(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnError(&pq.Error{Code: pqUndefinedTable})
+
+	version, err := db.CurrentSchemaVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != 3 {
+		t.Fatalf("expected version 3, got %d", version)
+	}
+
+	// a database without schema_migrations has no migrations applied
+	version, err = db.CurrentSchemaVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != 0 {
+		t.Fatalf("expected version 0, got %d", version)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	expected, err := SchemaVersion(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		version  int
+		mismatch bool
+	}{
+		"current": {version: expected},
+		"behind":  {version: expected - 1, mismatch: true},
+		"ahead":   {version: expected + 1, mismatch: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, mock := newMigrateTestDatabase(t)
+
+			mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(test.version))
+
+			err := db.CheckSchemaVersion(context.TODO())
+			if errors.Is(err, ErrSchemaVersion) != test.mismatch {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	appliedAt := time.Date(2026, 1, 4, 14, 33, 7, 0, time.UTC)
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	status, err := db.MigrationStatus(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) == 0 || status[0].AppliedAt == nil || !status[0].AppliedAt.Equal(appliedAt) {
+		t.Fatalf("unexpected status: %v", status)
+	}
+
+	for _, s := range status[1:] {
+		if s.AppliedAt != nil {
+			t.Fatalf("expected migration %d to be pending", s.Version)
+		}
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	migrations, err := Migrations(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	for _, migration := range migrations {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations \(version, name, applied_at\) VALUES \(\$1, \$2, now\(\)\)`).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	expectMigrationUnlock(mock)
+
+	migrated, err := db.MigrateUp(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrated) != len(migrations) {
+		t.Fatalf("expected %d applied migrations, got %d", len(migrations), len(migrated))
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUpFailure(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE`).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectMigrationUnlock(mock)
+
+	migrated, err := db.MigrateUp(context.TODO())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(migrated) != 0 {
+		t.Fatalf("expected no applied migrations, got %d", len(migrated))
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createdTables returns the names of the tables created by the statements.
+func createdTables(statements string) []string {
+	var tables []string
+
+	for _, match := range regexp.MustCompile(`CREATE TABLE (\w+)`).FindAllStringSubmatch(statements, -1) {
+		tables = append(tables, match[1])
+	}
+
+	slices.Sort(tables)
+
+	return tables
+}
+
+func TestBaselineTables(t *testing.T) {
+	// testdata/schema-psql.sql is the released contrib/database/schema/schema-psql.sql
+	released, err := os.ReadFile("testdata/schema-psql.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := Migrations(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := createdTables(string(released))
+	tables := slices.Sorted(slices.Values(baselineTables))
+
+	if !slices.Equal(tables, expected) {
+		t.Fatalf("baseline tables %v do not match the released schema %v", tables, expected)
+	}
+
+	if initial := createdTables(migrations[0].Up); !slices.Equal(initial, expected) {
+		t.Fatalf("initial migration creates %v, the released schema %v", initial, expected)
+	}
+}
+
+func TestMigrateUpBaseline(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	migrations, err := Migrations(DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	released, err := os.ReadFile("testdata/schema-psql.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the schema has been created from contrib/database/schema/schema-psql.sql. no migration is recorded
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.tables`).
+		WithArgs(pq.Array(baselineTables)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(createdTables(string(released)))))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT setval\(pg_get_serial_sequence\('permissions', 'id'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(baselineVersion, "initial").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	for _, migration := range migrations[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations`).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	expectMigrationUnlock(mock)
+
+	migrated, err := db.MigrateUp(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tables of the provisioning did not exist in the released schema
+	if len(migrated) != len(migrations)-1 || migrated[0].Name != "provisioning" {
+		t.Fatalf("expected the migrations after the baseline to be applied, got %v", migrated)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateUpPartialBaseline(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM information_schema.tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	expectMigrationUnlock(mock)
+
+	_, err := db.MigrateUp(context.TODO())
+	if err == nil || !strings.Contains(err.Error(), "already exist") {
+		t.Fatalf("expected an error for the partial schema, got %v", err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateDown(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE IF EXISTS group_permissions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectMigrationUnlock(mock)
+
+	migration, ok, err := db.MigrateDown(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || migration.Version != 1 {
+		t.Fatalf("expected migration 1 to be rolled back, got %v", migration)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateDownNothingApplied(t *testing.T) {
+	db, mock := newMigrateTestDatabase(t)
+
+	expectMigrationLock(mock)
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	expectMigrationUnlock(mock)
+
+	_, ok, err := db.MigrateDown(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("expected nothing to be rolled back")
+	}
+}
@@ -0,0 +1,122 @@
+INSERT INTO permissions (category, resource, action)
+VALUES
+    ('provisioning', 'workspace', 'add'),
+    ('provisioning', 'workspace', 'get'),
+    ('provisioning', 'run', 'add'),
+    ('provisioning', 'run', 'get'),
+    ('provisioning', 'workspace', 'unlock'),
+    ('provisioning', 'run', 'apply'),
+    ('provisioning', 'run', 'cancel'),
+    ('provisioning', 'credential', 'add'),
+    ('provisioning', 'credential', 'get'),
+    ('provisioning', 'run', 'target'),
+    ('provisioning', 'run', 'replace'),
+    ('provisioning', 'run', 'refresh'),
+    ('provisioning', 'run', 'destroy');
+
+CREATE TABLE workspaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(256) NOT NULL UNIQUE,
+    working_directory VARCHAR(4096) NOT NULL,
+    executable_path VARCHAR(4096) NOT NULL DEFAULT '',
+    provisioner VARCHAR(32) NOT NULL DEFAULT '',
+    required_version VARCHAR(256) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    drift_interval INTEGER NOT NULL DEFAULT 0,
+    drift_status VARCHAR(32) NOT NULL DEFAULT '',
+    drift_checked_at TIMESTAMPTZ,
+    drift_run_id INTEGER,
+    playbook VARCHAR(4096) NOT NULL DEFAULT '',
+    init_timeout INTEGER NOT NULL DEFAULT 0,
+    plan_timeout INTEGER NOT NULL DEFAULT 0,
+    apply_timeout INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE runs (
+    id SERIAL PRIMARY KEY,
+    workspace VARCHAR(256) NOT NULL REFERENCES workspaces(name) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL DEFAULT 'deploy',
+    status VARCHAR(32) NOT NULL DEFAULT 'queued',
+    phase VARCHAR(32) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    claimed_by VARCHAR(256),
+    heartbeat_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    approved_at TIMESTAMPTZ,
+    canceled_by VARCHAR(256),
+    post_apply_status VARCHAR(32) NOT NULL DEFAULT '',
+    options JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX runs_workspace_idx ON runs (workspace);
+CREATE INDEX runs_status_idx ON runs (status);
+
+-- only one active run per workspace
+CREATE UNIQUE INDEX runs_active_workspace_idx ON runs (workspace) WHERE status IN ('queued', 'running', 'planned');
+
+CREATE TABLE workspace_locks (
+    workspace   VARCHAR(256) PRIMARY KEY REFERENCES workspaces(name) ON DELETE CASCADE,
+    run_id      INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    holder      VARCHAR(256) NOT NULL,
+    backend_pid INTEGER NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE run_phases (
+    run_id      INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    phase       VARCHAR(32) NOT NULL,
+    result      VARCHAR(32) NOT NULL,
+    exit_code   INTEGER NOT NULL,
+    stderr      TEXT NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX run_phases_run_id_idx ON run_phases (run_id);
+
+CREATE TABLE run_events (
+    id        SERIAL PRIMARY KEY,
+    run_id    INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    phase     VARCHAR(32) NOT NULL,
+    type      VARCHAR(64) NOT NULL,
+    level     VARCHAR(16) NOT NULL,
+    message   TEXT NOT NULL,
+    payload   TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX run_events_run_id_idx ON run_events (run_id);
+
+CREATE TABLE run_annotations (
+    id         SERIAL PRIMARY KEY,
+    run_id     INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    hook       VARCHAR(256) NOT NULL,
+    name       VARCHAR(256) NOT NULL,
+    value      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (run_id, name)
+);
+
+CREATE TABLE run_artifacts (
+    id           SERIAL PRIMARY KEY,
+    run_id       INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+    name         VARCHAR(64) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    content      BYTEA NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (run_id, name)
+);
+
+CREATE TABLE credentials (
+    id         SERIAL PRIMARY KEY,
+    workspace  VARCHAR(256) NOT NULL REFERENCES workspaces(name) ON DELETE CASCADE,
+    name       VARCHAR(256) NOT NULL,
+    value      BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (workspace, name)
+);
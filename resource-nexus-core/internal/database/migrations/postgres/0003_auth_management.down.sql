@@ -0,0 +1,10 @@
+DELETE FROM permissions
+WHERE category = 'auth'
+  AND (resource, action) IN (
+    ('user', 'update'),
+    ('user', 'delete'),
+    ('group', 'update'),
+    ('group', 'delete'),
+    ('usergroup', 'delete'),
+    ('grouppermission', 'delete')
+  );
@@ -0,0 +1,8 @@
+INSERT INTO permissions (category, resource, action)
+VALUES
+    ('auth', 'user', 'update'),
+    ('auth', 'user', 'delete'),
+    ('auth', 'group', 'update'),
+    ('auth', 'group', 'delete'),
+    ('auth', 'usergroup', 'delete'),
+    ('auth', 'grouppermission', 'delete');
@@ -0,0 +1,6 @@
+DELETE FROM permissions
+WHERE category = 'auth'
+  AND (resource, action) IN (
+    ('user', 'get'),
+    ('group', 'get')
+  );
@@ -0,0 +1,7 @@
+DELETE FROM permissions
+WHERE category = 'system'
+  AND resource = 'audit'
+  AND action = 'get';
+
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only();
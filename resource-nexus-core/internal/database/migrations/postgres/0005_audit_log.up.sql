@@ -0,0 +1,35 @@
+CREATE TABLE audit_log (
+    id              SERIAL PRIMARY KEY,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    actor           VARCHAR(256) NOT NULL,
+    action          TEXT NOT NULL,
+    target          TEXT NOT NULL DEFAULT '',
+    before_snapshot TEXT,
+    after_snapshot  TEXT,
+    request_id      VARCHAR(64) NOT NULL DEFAULT '',
+    source_ip       VARCHAR(64) NOT NULL DEFAULT '',
+    result          VARCHAR(16) NOT NULL,
+    status          INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_target_idx ON audit_log (target);
+
+-- the audit log is append-only. entries can't be changed or removed
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+INSERT INTO permissions (category, resource, action)
+VALUES
+    ('system', 'audit', 'get');
@@ -0,0 +1,26 @@
+DELETE FROM permissions
+WHERE category = 'provisioning'
+  AND (resource, action) IN (
+    ('workspace', 'add'),
+    ('workspace', 'get'),
+    ('run', 'add'),
+    ('run', 'get'),
+    ('workspace', 'unlock'),
+    ('run', 'apply'),
+    ('run', 'cancel'),
+    ('credential', 'add'),
+    ('credential', 'get'),
+    ('run', 'target'),
+    ('run', 'replace'),
+    ('run', 'refresh'),
+    ('run', 'destroy')
+  );
+
+DROP TABLE IF EXISTS credentials;
+DROP TABLE IF EXISTS run_artifacts;
+DROP TABLE IF EXISTS run_annotations;
+DROP TABLE IF EXISTS run_events;
+DROP TABLE IF EXISTS run_phases;
+DROP TABLE IF EXISTS workspace_locks;
+DROP TABLE IF EXISTS runs;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,4 @@
+INSERT INTO permissions (category, resource, action)
+VALUES
+    ('auth', 'user', 'get'),
+    ('auth', 'group', 'get');
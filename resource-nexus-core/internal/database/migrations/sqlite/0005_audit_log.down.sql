@@ -0,0 +1,6 @@
+DELETE FROM permissions
+WHERE category = 'system'
+  AND resource = 'audit'
+  AND action = 'get';
+
+DROP TABLE audit_log;
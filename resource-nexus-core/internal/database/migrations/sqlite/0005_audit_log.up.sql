@@ -0,0 +1,33 @@
+CREATE TABLE audit_log (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at      TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    actor           VARCHAR(256) NOT NULL,
+    action          TEXT NOT NULL,
+    target          TEXT NOT NULL DEFAULT '',
+    before_snapshot TEXT,
+    after_snapshot  TEXT,
+    request_id      VARCHAR(64) NOT NULL DEFAULT '',
+    source_ip       VARCHAR(64) NOT NULL DEFAULT '',
+    result          VARCHAR(16) NOT NULL,
+    status          INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_target_idx ON audit_log (target);
+
+-- the audit log is append-only. entries can't be changed or removed
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+INSERT INTO permissions (category, resource, action)
+VALUES
+    ('system', 'audit', 'get');
@@ -1,5 +1,12 @@
 package database
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type User struct {
 	ID           int    `json:"id"`
 	Name         string `json:"name"`
@@ -32,3 +39,228 @@ type GroupPermissionReference struct {
 	GroupID      int    `json:"group_id"`
 	PermissionID int    `json:"permission_id"`
 }
+
+// RunStatus is the lifecycle status of a provisioning run.
+type RunStatus string
+
+const (
+	RunStatusQueued    RunStatus = "queued"
+	RunStatusRunning   RunStatus = "running"
+	RunStatusPlanned   RunStatus = "planned"
+	RunStatusApplied   RunStatus = "applied"
+	RunStatusErrored   RunStatus = "errored"
+	RunStatusCanceled  RunStatus = "canceled"
+	RunStatusTimedOut  RunStatus = "timed_out"
+	RunStatusStale     RunStatus = "stale"     // the state changed between plan and apply. the saved plan has been rejected
+	RunStatusCompleted RunStatus = "completed" // a run without apply stage has finished. e.g. a drift detection
+	RunStatusVetoed    RunStatus = "vetoed"    // a hook has vetoed the run
+)
+
+// RunKind is the kind of a provisioning run.
+type RunKind string
+
+const (
+	RunKindDeploy RunKind = "deploy" // plan, wait for approval and apply the saved plan
+	RunKindDrift  RunKind = "drift"  // refresh-only plan to detect changes made outside of terraform. never applies
+)
+
+// DriftStatus is the result of the last drift detection of a workspace.
+type DriftStatus string
+
+const (
+	DriftStatusUnknown DriftStatus = ""        // drift has not been checked yet
+	DriftStatusInSync  DriftStatus = "in_sync" // the infrastructure matches the state
+	DriftStatusDrifted DriftStatus = "drifted" // the infrastructure has been changed outside of terraform
+	DriftStatusFailed  DriftStatus = "failed"  // the last drift detection has failed
+)
+
+// PostApplyStatus is the status of the post-apply stage of a run.
+type PostApplyStatus string
+
+const (
+	PostApplyStatusNone      PostApplyStatus = ""          // the workspace has no post-apply stage or it has not been reached
+	PostApplyStatusSkipped   PostApplyStatus = "skipped"   // the terraform outputs contain no hosts
+	PostApplyStatusSucceeded PostApplyStatus = "succeeded" // the playbook has finished successfully
+	PostApplyStatusFailed    PostApplyStatus = "failed"    // the inventory could not be generated or the playbook failed
+)
+
+// IsFinal returns true if the run has reached a status that will not change anymore.
+func (s RunStatus) IsFinal() bool {
+	switch s {
+	case RunStatusApplied, RunStatusErrored, RunStatusCanceled, RunStatusTimedOut, RunStatusStale, RunStatusCompleted,
+		RunStatusVetoed:
+		return true
+	default:
+		return false
+	}
+}
+
+type Workspace struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	WorkingDirectory string    `json:"working_directory"`
+	ExecutablePath   string    `json:"executable_path"`  // pinned executable. empty to resolve it by provisioner and version
+	Provisioner      string    `json:"provisioner"`      // type of the provisioner. e.g. terraform. empty for any
+	RequiredVersion  string    `json:"required_version"` // version constraint. e.g. ">= 1.5.0, < 2.0.0"
+	Playbook         string    `json:"playbook"`         // ansible playbook of the post-apply stage. empty to skip it
+	CreatedAt        time.Time `json:"created_at"`
+
+	DriftInterval  int         `json:"drift_interval"`   // seconds between drift detections. 0 disables them
+	DriftStatus    DriftStatus `json:"drift_status"`     // result of the last drift detection
+	DriftCheckedAt *time.Time  `json:"drift_checked_at"` // time of the last drift detection
+	DriftRunID     *int        `json:"drift_run_id"`     // run of the last drift detection
+
+	InitTimeout  int `json:"init_timeout"`  // seconds. overrides the configured init timeout. 0 to keep it
+	PlanTimeout  int `json:"plan_timeout"`  // seconds. overrides the configured plan timeout. 0 to keep it
+	ApplyTimeout int `json:"apply_timeout"` // seconds. overrides the configured apply timeout. 0 to keep it
+}
+
+type WorkspaceLockInfo struct {
+	Workspace  string    `json:"workspace"`
+	RunID      int       `json:"run_id"`
+	Holder     string    `json:"holder"`
+	BackendPID int       `json:"backend_pid"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Held       bool      `json:"held"` // false if the holder is gone, but the lock record has not been cleaned up
+}
+
+type Run struct {
+	ID         int        `json:"id"`
+	Workspace  string     `json:"workspace"`
+	Kind       RunKind    `json:"kind"`
+	Status     RunStatus  `json:"status"`
+	Phase      string     `json:"phase"`
+	Message    string     `json:"message"`
+	Attempts   int        `json:"attempts"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	ApprovedAt *time.Time `json:"approved_at"` // set once the saved plan has been approved
+	CanceledBy string     `json:"canceled_by"` // name of the user that canceled the run
+	Options    RunOptions `json:"options"`     // change what is planned. only used by deploy runs
+
+	PostApplyStatus PostApplyStatus `json:"post_apply_status"` // status of the post-apply stage
+
+	// StoredStatus is the status of the run as last written by the provisioner. It is not stored.
+	// It is the previous status if a change of the status is recorded in the audit log.
+	StoredStatus RunStatus `json:"-"`
+}
+
+// RunOptions change what is planned by a run. The zero RunOptions plan all changes of the configuration.
+type RunOptions struct {
+	Targets     []string `json:"targets,omitempty"`      // only these addresses are planned (-target)
+	Replace     []string `json:"replace,omitempty"`      // these resource instances are recreated (-replace)
+	RefreshOnly bool     `json:"refresh_only,omitempty"` // only the state is updated to the infrastructure (-refresh-only)
+	Destroy     bool     `json:"destroy,omitempty"`      // the resources are deleted (-destroy)
+}
+
+// Value implements driver.Valuer. The options are stored as JSON.
+func (o RunOptions) Value() (driver.Value, error) {
+	value, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode run options: %w", err)
+	}
+
+	return string(value), nil
+}
+
+// Scan implements sql.Scanner. NULL is scanned as the zero RunOptions.
+func (o *RunOptions) Scan(src any) error {
+	var value []byte
+
+	switch v := src.(type) {
+	case nil:
+		*o = RunOptions{}
+
+		return nil
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	default:
+		return fmt.Errorf("cant scan %T into run options", src)
+	}
+
+	options := RunOptions{}
+
+	err := json.Unmarshal(value, &options)
+	if err != nil {
+		return fmt.Errorf("failed to decode run options: %w", err)
+	}
+
+	*o = options
+
+	return nil
+}
+
+type RunPhase struct {
+	RunID      int       `json:"run_id"`
+	Phase      string    `json:"phase"`
+	Result     string    `json:"result"`
+	ExitCode   int       `json:"exit_code"`
+	Stderr     string    `json:"stderr"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+type RunEvent struct {
+	ID        int       `json:"id"`
+	RunID     int       `json:"run_id"`
+	Phase     string    `json:"phase"`
+	Type      string    `json:"type"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Payload   string    `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RunAnnotation is a value that a hook added to a run. e.g. the id of the run inside a CMDB.
+type RunAnnotation struct {
+	RunID     int       `json:"run_id"`
+	Hook      string    `json:"hook"` // name of the hook that added the annotation
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RunArtifact struct {
+	ID          int       `json:"id"`
+	RunID       int       `json:"run_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Content     []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Credential is a secret that is injected as environment variable into the commands of a workspace.
+type Credential struct {
+	ID        int       `json:"id"`
+	Workspace string    `json:"workspace"`
+	Name      string    `json:"name"` // name of the environment variable. e.g. TF_VAR_token
+	Value     []byte    `json:"-"`    // encrypted value. never returned
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditResult is the result of an audited change.
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success"
+	AuditResultFailure AuditResult = "failure"
+)
+
+// AuditEntry records a change done by an api call or by a worker. Entries are never updated or deleted.
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`      // name of the user. "system" for changes of workers and schedulers
+	Action    string          `json:"action"`     // e.g. the permission of the api call. e.g. provisioning:run:add
+	Target    string          `json:"target"`     // changed object. e.g. run:42. empty if unknown
+	Before    json.RawMessage `json:"before"`     // snapshot of the target before the change. null if unknown or created
+	After     json.RawMessage `json:"after"`      // snapshot of the target after the change. null if unknown or deleted
+	RequestID string          `json:"request_id"` // empty for changes that are not caused by a request
+	SourceIP  string          `json:"source_ip"`
+	Result    AuditResult     `json:"result"`
+	Status    int             `json:"status"` // http status of the api call. 0 for changes of workers
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidPage is returned if a page can't be selected. e.g. if it orders by an unknown column or the cursor is
+// malformed.
+var ErrInvalidPage = errors.New("invalid page")
+
+// Order orders the rows of a list query by a column.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Page selects a window of the rows of a list query.
+//
+// The rows are ordered by OrderBy. The id is always appended as the last column, so the order is stable.
+// A page starts at Offset, or after the row the Cursor points to (keyset pagination). Cursors stay valid if rows are
+// inserted or deleted in the meantime. The cursor of the next page is returned by PageResult.NextCursor.
+//
+// The zero value selects all rows ordered by their id.
+type Page struct {
+	Limit   int // maximum number of rows. 0 selects all rows
+	Offset  int // number of rows to skip
+	OrderBy []Order
+	Cursor  string // NextCursor of the previous page. Needs the same order as the previous page
+}
+
+// PageResult is a page of rows of a list query.
+type PageResult[T any] struct {
+	Items      []T
+	Total      int    // number of rows that match the filter, regardless of the page
+	NextCursor string // cursor of the next page. empty if there are no more rows
+}
+
+// ParseOrder parses a comma separated list of columns. Columns prefixed with '-' are ordered descending.
+//
+// e.g. '-created_at,name' -> []Order{{Column: "created_at", Desc: true}, {Column: "name"}}.
+func ParseOrder(order string) []Order {
+	var orders []Order
+
+	for column := range strings.SplitSeq(order, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(column, "-")
+		orders = append(orders, Order{Column: strings.TrimPrefix(column, "-"), Desc: desc})
+	}
+
+	return orders
+}
+
+// orders returns the order of the page, including the id as last column.
+//
+// sample holds the columns of the table. Only columns that can't be NULL are accepted, because NULL can't be compared
+// with the cursor.
+func (p Page) orders(sample map[string]any) ([]Order, error) {
+	orders := slices.Clone(p.OrderBy)
+
+	if !slices.ContainsFunc(orders, func(o Order) bool { return o.Column == "id" }) {
+		orders = append(orders, Order{Column: "id"})
+	}
+
+	for _, o := range orders {
+		value, ok := sample[o.Column]
+		if !ok {
+			return nil, fmt.Errorf("%w: column '%s' does not exist", ErrInvalidPage, o.Column)
+		}
+
+		if value == nil || reflect.TypeOf(value).Kind() == reflect.Pointer {
+			return nil, fmt.Errorf("%w: column '%s' can be NULL and can't be ordered by", ErrInvalidPage, o.Column)
+		}
+	}
+
+	if p.Limit < 0 || p.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidPage)
+	}
+
+	return orders, nil
+}
+
+// keyset returns the filter that selects the rows after the row the cursor points to.
+//
+//	e.g. for the order 'created_at DESC, id ASC':
+//	(created_at < $1) OR (created_at = $1 AND id > $2)
+//
+// Returns nil if the page has no cursor.
+func (p Page) keyset(orders []Order, sample map[string]any) (FilterExpr, error) {
+	if p.Cursor == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	values, err := decodeCursor(p.Cursor, orders, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	after := LogicalFilter{Operator: "OR"}
+
+	for i, o := range orders {
+		operator := OperatorGreater
+		if o.Desc {
+			operator = OperatorLess
+		}
+
+		and := LogicalFilter{Operator: "AND"}
+
+		for j := range i {
+			and.Filters = append(and.Filters, Filter{Key: orders[j].Column, Operator: "=", Value: values[j]})
+		}
+
+		and.Filters = append(and.Filters, Filter{Key: o.Column, Operator: operator, Value: values[i]})
+		after.Filters = append(after.Filters, and)
+	}
+
+	return after, nil
+}
+
+// orderBy returns the ORDER BY clause of the orders. The columns are checked by Page.orders already.
+func orderBy(orders []Order) string {
+	columns := make([]string, 0, len(orders))
+
+	for _, o := range orders {
+		direction := "ASC"
+		if o.Desc {
+			direction = "DESC"
+		}
+
+		columns = append(columns, o.Column+" "+direction)
+	}
+
+	return " ORDER BY " + strings.Join(columns, ", ")
+}
+
+// pageFilter combines the filter of the query with the keyset of the page.
+func pageFilter(filter FilterExpr, keyset FilterExpr) FilterExpr {
+	if keyset == nil {
+		return filter
+	}
+
+	if filter == nil {
+		return keyset
+	}
+
+	return LogicalFilter{Operator: "AND", Filters: []FilterExpr{filter, keyset}}
+}
+
+// encodeCursor returns the cursor that points to the row.
+func encodeCursor(orders []Order, row map[string]any) (string, error) {
+	values := make([]any, 0, len(orders))
+
+	for _, o := range orders {
+		values = append(values, row[o.Column])
+	}
+
+	j, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(j), nil
+}
+
+// decodeCursor returns the values of the ordered columns of the row the cursor points to.
+//
+// Each value is decoded into the type of the column inside sample. e.g. timestamps into time.Time.
+func decodeCursor(cursor string, orders []Order, sample map[string]any) ([]any, error) {
+	j, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+
+	var raw []json.RawMessage
+
+	err = json.Unmarshal(j, &raw)
+	if err != nil || len(raw) != len(orders) {
+		return nil, fmt.Errorf("%w: cursor does not match the order", ErrInvalidPage)
+	}
+
+	values := make([]any, 0, len(orders))
+
+	for i, o := range orders {
+		value := reflect.New(reflect.TypeOf(sample[o.Column]))
+
+		err = json.Unmarshal(raw[i], value.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%w: cursor does not match the order", ErrInvalidPage)
+		}
+
+		values = append(values, value.Elem().Interface())
+	}
+
+	return values, nil
+}
+
+// sortRows sorts the rows by the orders. Used by the MemoryDatabase.
+func sortRows[T any](items []T, orders []Order, row rowFn[T]) {
+	slices.SortStableFunc(items, func(a, b T) int {
+		left, right := row(a), row(b)
+
+		for _, o := range orders {
+			c, _ := compare(normalize(left[o.Column]), normalize(right[o.Column]))
+			if o.Desc {
+				c = -c
+			}
+
+			if c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	})
+}
+
+// selectPage selects a page of the items of the table that match the filter. Used by the MemoryDatabase.
+//
+// The filter and the order may only use the allowed columns of the table, see listColumns.
+func selectPage[T any](
+	items []T, table string, filter FilterExpr, page Page, row rowFn[T],
+) (PageResult[T], error) {
+	var zero T
+
+	sample := allowedColumns(table, row(zero))
+
+	err := validateFilter(filter, sample)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	orders, err := page.orders(sample)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	keyset, err := page.keyset(orders, sample)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	selected, err := selectRows(items, filter, row)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	total := len(selected)
+
+	selected, err = selectRows(selected, keyset, row)
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	sortRows(selected, orders, row)
+
+	selected = selected[min(page.Offset, len(selected)):]
+
+	return pageResult(selected, total, page, orders, row)
+}
+
+// pageResult returns the result of a page. items may contain one more row than the limit, which indicates that
+// there is a next page.
+func pageResult[T any](items []T, total int, page Page, orders []Order, row rowFn[T]) (PageResult[T], error) {
+	result := PageResult[T]{Items: items, Total: total}
+
+	if page.Limit == 0 || len(items) <= page.Limit {
+		return result, nil
+	}
+
+	result.Items = items[:page.Limit]
+
+	cursor, err := encodeCursor(orders, row(result.Items[page.Limit-1]))
+	if err != nil {
+		return PageResult[T]{}, err
+	}
+
+	result.NextCursor = cursor
+
+	return result, nil
+}
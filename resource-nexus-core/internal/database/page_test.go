@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOrder(t *testing.T) {
+	tests := map[string][]Order{
+		"":                      nil,
+		"name":                  {{Column: "name"}},
+		"-created_at, name":     {{Column: "created_at", Desc: true}, {Column: "name"}},
+		",-id,":                 {{Column: "id", Desc: true}},
+		"workspace,-created_at": {{Column: "workspace"}, {Column: "created_at", Desc: true}},
+	}
+
+	for order, expected := range tests {
+		actual := ParseOrder(order)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("expected %v for '%s', got %v", expected, order, actual)
+		}
+	}
+}
+
+func TestPageOrders(t *testing.T) {
+	sample := workspaceRow(Workspace{})
+
+	orders, err := Page{OrderBy: []Order{{Column: "name", Desc: true}}}.orders(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(orders, []Order{{Column: "name", Desc: true}, {Column: "id"}}) {
+		t.Fatalf("expected id to be appended, got %v", orders)
+	}
+
+	for _, page := range []Page{
+		{OrderBy: []Order{{Column: "unknown"}}},
+		{OrderBy: []Order{{Column: "name; DROP TABLE workspaces"}}},
+		{OrderBy: []Order{{Column: "drift_checked_at"}}}, // nullable
+		{Limit: -1},
+		{Offset: -1},
+	} {
+		_, err = page.orders(sample)
+		if !errors.Is(err, ErrInvalidPage) {
+			t.Fatalf("expected ErrInvalidPage for %v, got %v", page, err)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	createdAt := time.Date(2026, 1, 4, 14, 33, 8, 102100000, time.UTC)
+	orders := []Order{{Column: "created_at", Desc: true}, {Column: "name"}, {Column: "id"}}
+	sample := workspaceRow(Workspace{})
+
+	cursor, err := encodeCursor(orders, workspaceRow(Workspace{ID: 7, Name: "web", CreatedAt: createdAt}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := decodeCursor(cursor, orders, sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(values, []any{createdAt, "web", 7}) {
+		t.Fatalf("cursor not decoded correctly: %v", values)
+	}
+
+	keyset, err := Page{Cursor: cursor}.keyset(orders, sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	where, args, err := BuildWhere(keyset, DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := " WHERE ((created_at < $1) OR (created_at = $2 AND name > $3) OR " +
+		"(created_at = $4 AND name = $5 AND id > $6))"
+	if where != expected || len(args) != 6 {
+		t.Fatalf("unexpected keyset %s %v", where, args)
+	}
+
+	// cursors of another order and malformed cursors are rejected
+	for _, cursor := range []string{"%%%", "bm90IGpzb24", cursor[:len(cursor)-4]} {
+		_, err = decodeCursor(cursor, orders, sample)
+		if !errors.Is(err, ErrInvalidPage) {
+			t.Fatalf("expected ErrInvalidPage for %s, got %v", cursor, err)
+		}
+	}
+
+	_, err = decodeCursor(cursor, orders[1:], sample)
+	if !errors.Is(err, ErrInvalidPage) {
+		t.Fatalf("expected ErrInvalidPage, got %v", err)
+	}
+}
+
+func TestSelectPage(t *testing.T) {
+	var workspaces []Workspace
+
+	for i, name := range []string{"web", "db", "cache", "proxy", "dns"} {
+		workspaces = append(workspaces, Workspace{ID: i + 1, Name: name, DriftInterval: i % 2})
+	}
+
+	page := Page{Limit: 2, OrderBy: []Order{{Column: "drift_interval", Desc: true}, {Column: "name"}}}
+
+	var names []string
+
+	for {
+		result, err := selectPage(workspaces, TableNameWorkspaces, nil, page, workspaceRow)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Total != 5 {
+			t.Fatalf("expected 5 workspaces in total, got %d", result.Total)
+		}
+
+		for _, workspace := range result.Items {
+			names = append(names, workspace.Name)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+
+		page.Cursor = result.NextCursor
+	}
+
+	if !reflect.DeepEqual(names, []string{"db", "proxy", "cache", "dns", "web"}) {
+		t.Fatalf("wrong order of workspaces: %v", names)
+	}
+
+	result, err := selectPage(workspaces, TableNameWorkspaces, Filter{Key: "drift_interval", Operator: "=", Value: 0},
+		Page{Offset: 1, OrderBy: []Order{{Column: "id", Desc: true}}}, workspaceRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 3 || len(result.Items) != 2 || result.Items[0].Name != "cache" || result.NextCursor != "" {
+		t.Fatalf("wrong page selected: %v", result)
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+)
+
+// poolHeadroom is the number of connections left for other queries besides the workspace locks of the workers.
+// e.g. updates and heartbeats of the runs and api requests.
+const poolHeadroom = 4
+
+// PoolStats represents the statistics of the connection pool of a database.
+type PoolStats struct {
+	MaxOpenConnections int   `json:"maxOpenConnections"` // 0 means unlimited
+	OpenConnections    int   `json:"openConnections"`    // Connections in use and idle
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`      // Total number of waits for a connection of the exhausted pool
+	WaitDurationMs     int64 `json:"waitDurationMs"` // Total time waited for connections
+	MaxIdleClosed      int64 `json:"maxIdleClosed"`  // Connections closed because of maxIdleConns
+	MaxIdleTimeClosed  int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64 `json:"maxLifetimeClosed"`
+}
+
+// configurePool applies the settings of the connection pool of the config. Settings with the value 0 are not applied,
+// so the defaults of database/sql are kept.
+func configurePool(db *sql.DB, conf config.Database) {
+	if conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+
+	if conf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+
+	if conf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+
+	if conf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+	}
+}
+
+// ValidatePool returns an error if the workspace locks of the workers can exhaust the connection pool.
+//
+// Each running run reserves a PostgresSQL connection for the advisory lock of its workspace until it is finished. The
+// queries of the runs need further connections of the pool. If maxOpenConns does not leave poolHeadroom connections
+// besides the locks, the runs wait for connections that are never released. Unlimited pools and SQLite, which has no
+// advisory locks, are always valid.
+func ValidatePool(conf config.Database, workers int) error {
+	dialect, err := ParseDialect(conf.Type)
+	if err != nil {
+		return err
+	}
+
+	if dialect != DialectPostgres || conf.MaxOpenConns <= 0 || conf.MaxOpenConns >= workers+poolHeadroom {
+		return nil
+	}
+
+	return fmt.Errorf("maxOpenConns (%d) has to be at least the number of workers (%d) plus %d, or 0 for unlimited. "+
+		"each running run holds a connection for the lock of its workspace", conf.MaxOpenConns, workers, poolHeadroom)
+}
+
+// Stats returns the statistics of the connection pool.
+func (db *SqlDatabase) Stats() PoolStats {
+	stats := db.database.Stats()
+
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
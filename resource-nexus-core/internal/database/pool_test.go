@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+)
+
+func TestConfigurePool(t *testing.T) {
+	d, _, _ := sqlmock.New()
+	defer d.Close()
+
+	configurePool(d, config.Database{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Minute})
+
+	db := SqlDatabase{database: d}
+
+	stats := db.Stats()
+	if stats.MaxOpenConnections != 4 {
+		t.Fatalf("expected 4 max open connections, got %v", stats)
+	}
+
+	// settings with the value 0 keep the defaults
+	configurePool(d, config.Database{})
+
+	if db.Stats().MaxOpenConnections != 4 {
+		t.Fatalf("expected the pool to be unchanged, got %v", db.Stats())
+	}
+}
+
+func TestValidatePool(t *testing.T) {
+	tests := []struct {
+		conf    config.Database
+		workers int
+		valid   bool
+	}{
+		{config.Database{MaxOpenConns: 10}, 2, true},
+		{config.Database{MaxOpenConns: 10}, 6, true},
+		{config.Database{MaxOpenConns: 10}, 7, false},
+		{config.Database{Type: "postgres", MaxOpenConns: 2}, 2, false},
+		{config.Database{MaxOpenConns: 0}, 100, true},
+		{config.Database{Type: "sqlite", MaxOpenConns: 1}, 8, true},
+		{config.Database{Type: "mysql"}, 2, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePool(tt.conf, tt.workers)
+		if (err == nil) != tt.valid {
+			t.Fatalf("unexpected result for %+v with %d workers: %v", tt.conf, tt.workers, err)
+		}
+	}
+}
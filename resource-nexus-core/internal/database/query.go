@@ -0,0 +1,265 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxQueryDepth is the maximum number of nested 'or' and 'and' groups of a filter query.
+const maxQueryDepth = 4
+
+// pageParams are the query parameters of a list request that select the page and the fields. They are no filters.
+var pageParams = []string{"limit", "offset", "order", "cursor", "fields"}
+
+// ErrInvalidFields is returned if the fields of a list request contain a column that can't be selected.
+var ErrInvalidFields = errors.New("invalid fields")
+
+// queryOperators maps the operators of the filter query language to the operators of a Filter.
+var queryOperators = map[string]Operator{
+	"eq":      OperatorEqual,
+	"ne":      OperatorNotEqual,
+	"lt":      OperatorLess,
+	"le":      OperatorLessEqual,
+	"gt":      OperatorGreater,
+	"ge":      OperatorGreaterEqual,
+	"like":    OperatorLike,
+	"ilike":   OperatorILike,
+	"in":      OperatorIn,
+	"between": OperatorBetween,
+	"is":      OperatorIsNull,
+}
+
+// ParseFilterQuery parses the query parameters of a list request on the table into a filter.
+//
+// Each parameter filters a column by '[not:]operator:value'. Values without operator are compared with '='.
+//
+//	name=web                        -> name = 'web'
+//	name=ilike:web%                 -> name ILIKE 'web%'
+//	created_at=gt:2026-01-01        -> created_at > '2026-01-01T00:00:00Z'
+//	status=in:queued,running        -> status IN ('queued', 'running')
+//	attempts=between:1,3            -> attempts BETWEEN 1 AND 3
+//	finished_at=not:is:null         -> NOT (finished_at IS NULL)
+//	name=eq:like:web                -> name = 'like:web'
+//
+// All parameters are combined with AND. The value of an 'or' parameter is an url encoded query, whose parameters are
+// combined with OR. 'and' does the same with AND, so groups can be nested.
+//
+//	status=applied&or=workspace%3Dweb%26workspace%3Ddb -> status = 'applied' AND (workspace = 'web' OR workspace = 'db')
+//
+// Only the allowed columns of the table can be filtered by (see listColumns). The values are converted into the type of
+// the column. Timestamps are given as RFC 3339 or as date. The parameters of the page (e.g. limit) are skipped.
+//
+// Returns nil if the query contains no filters.
+func ParseFilterQuery(query url.Values, table string) (FilterExpr, error) {
+	columns := allowedColumns(table, listSamples[table])
+
+	return parseFilterQuery(query, columns, LogicalAnd, 0)
+}
+
+// ParseFields parses the 'fields' query parameter of a list request on the table. It is a comma separated list of the
+// fields the returned items are reduced to. e.g. 'id,status'.
+//
+// Only the allowed columns of the table can be selected (see listColumns). Returns nil if the parameter is missing,
+// so all fields are returned.
+func ParseFields(query url.Values, table string) ([]string, error) {
+	value := query.Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+
+	for field := range strings.SplitSeq(value, ",") {
+		field = strings.TrimSpace(field)
+
+		if !slices.Contains(listColumns[table], field) {
+			return nil, fmt.Errorf("%w: '%s' can't be selected", ErrInvalidFields, field)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// parseFilterQuery parses the parameters of the query and combines them with the operator.
+func parseFilterQuery(query url.Values, columns map[string]any, operator LogicalOperator, depth int) (FilterExpr, error) {
+	if depth > maxQueryDepth {
+		return nil, fmt.Errorf("%w: groups are nested deeper than %d levels", ErrInvalidFilter, maxQueryDepth)
+	}
+
+	// the order of the parameters is kept stable, so the same query results in the same statement
+	keys := make([]string, 0, len(query))
+
+	for key := range query {
+		if depth == 0 && slices.Contains(pageParams, key) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	var filters []FilterExpr
+
+	for _, key := range keys {
+		for _, value := range query[key] {
+			var (
+				filter FilterExpr
+				err    error
+			)
+
+			switch key {
+			case "or", "and":
+				filter, err = parseFilterGroup(value, columns, LogicalOperator(strings.ToUpper(key)), depth)
+			default:
+				filter, err = parseCondition(key, value, columns)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if filter != nil {
+				filters = append(filters, filter)
+			}
+		}
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, nil //nolint:nilnil
+	case 1:
+		return filters[0], nil
+	default:
+		return LogicalFilter{Operator: operator, Filters: filters}, nil
+	}
+}
+
+// parseFilterGroup parses the url encoded query of an 'or' or 'and' parameter.
+func parseFilterGroup(value string, columns map[string]any, operator LogicalOperator, depth int) (FilterExpr, error) {
+	group, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed %s group '%s'", ErrInvalidFilter, strings.ToLower(string(operator)), value)
+	}
+
+	return parseFilterQuery(group, columns, operator, depth+1)
+}
+
+// parseCondition parses the value of a single query parameter, which filters the column key.
+func parseCondition(key string, value string, columns map[string]any) (FilterExpr, error) {
+	sample, ok := columns[key]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownColumn, key)
+	}
+
+	value, negate := strings.CutPrefix(value, "not:")
+	filter := Filter{Key: key, Operator: OperatorEqual}
+
+	if name, rest, ok := strings.Cut(value, ":"); ok {
+		if operator, known := queryOperators[name]; known {
+			filter.Operator = operator
+			value = rest
+		}
+	}
+
+	switch filter.Operator { //nolint:exhaustive
+	case OperatorIsNull:
+		if value != "null" {
+			return nil, fmt.Errorf("%w for column '%s': 'is' only supports 'null'", ErrInvalidValue, key)
+		}
+	case OperatorLike, OperatorILike:
+		if t := reflect.TypeOf(sample); t != nil && t.Kind() != reflect.String {
+			return nil, fmt.Errorf("%w for column '%s': patterns only match text columns", ErrInvalidValue, key)
+		}
+
+		filter.Value = value
+	case OperatorIn, OperatorBetween:
+		var values []any
+
+		for text := range strings.SplitSeq(value, ",") {
+			v, err := coerce(key, text, sample)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, v)
+		}
+
+		filter.Value = values
+	default:
+		v, err := coerce(key, value, sample)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.Value = v
+	}
+
+	_, err := filter.check()
+	if err != nil {
+		return nil, err
+	}
+
+	if negate {
+		return LogicalFilter{Operator: LogicalNot, Filters: []FilterExpr{filter}}, nil
+	}
+
+	return filter, nil
+}
+
+// coerce converts the text of a query parameter into the type of the sample value of the column.
+func coerce(key string, text string, sample any) (any, error) {
+	t := reflect.TypeOf(sample)
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// nullable text columns have no typed sample
+	if t == nil {
+		return text, nil
+	}
+
+	if t == reflect.TypeFor[time.Time]() {
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			parsed, err := time.Parse(layout, text)
+			if err == nil {
+				return parsed, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w for column '%s': '%s' is no timestamp", ErrInvalidValue, key, text)
+	}
+
+	var (
+		value any
+		err   error
+	)
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return text, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err = strconv.Atoi(text)
+	case reflect.Bool:
+		value, err = strconv.ParseBool(text)
+	case reflect.Float32, reflect.Float64:
+		value, err = strconv.ParseFloat(text, 64)
+	default:
+		return nil, fmt.Errorf("%w for column '%s': column can't be filtered by", ErrInvalidValue, key)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w for column '%s': '%s' is no %s", ErrInvalidValue, key, text, t.Kind())
+	}
+
+	return value, nil
+}
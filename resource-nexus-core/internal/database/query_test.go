@@ -0,0 +1,145 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFilterQuery(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 1, 4, 14, 33, 8, 0, time.FixedZone("", 3600))
+
+	tests := []struct {
+		query    string
+		expected FilterExpr
+	}{
+		{"", nil},
+		{"limit=10&order=-created_at&cursor=abc&fields=id", nil},
+		{"workspace=web", Filter{Key: "workspace", Operator: "=", Value: "web"}},
+		{"message=failed:%20timeout", Filter{Key: "message", Operator: "=", Value: "failed: timeout"}},
+		{"workspace=eq:like:web", Filter{Key: "workspace", Operator: "=", Value: "like:web"}},
+		{"workspace=ilike:web%25", Filter{Key: "workspace", Operator: "ILIKE", Value: "web%"}},
+		{"attempts=ge:2", Filter{Key: "attempts", Operator: ">=", Value: 2}},
+		{"created_at=gt:2026-01-01", Filter{Key: "created_at", Operator: ">", Value: since}},
+		{"finished_at=le:2026-01-04T14:33:08%2B01:00", Filter{Key: "finished_at", Operator: "<=", Value: until}},
+		{"canceled_by=ne:alice", Filter{Key: "canceled_by", Operator: "!=", Value: "alice"}},
+		{"status=in:queued,running", Filter{Key: "status", Operator: "IN", Value: []any{"queued", "running"}}},
+		{"attempts=between:1,3", Filter{Key: "attempts", Operator: "BETWEEN", Value: []any{1, 3}}},
+		{"finished_at=is:null", Filter{Key: "finished_at", Operator: "IS NULL"}},
+		{"finished_at=not:is:null", LogicalFilter{Operator: "NOT", Filters: []FilterExpr{
+			Filter{Key: "finished_at", Operator: "IS NULL"},
+		}}},
+		{"workspace=web&attempts=lt:3&attempts=gt:0", LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+			Filter{Key: "attempts", Operator: "<", Value: 3},
+			Filter{Key: "attempts", Operator: ">", Value: 0},
+			Filter{Key: "workspace", Operator: "=", Value: "web"},
+		}}},
+		{"status=applied&or=" + url.QueryEscape("workspace=web&workspace=like:db%25"),
+			LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+				LogicalFilter{Operator: "OR", Filters: []FilterExpr{
+					Filter{Key: "workspace", Operator: "=", Value: "web"},
+					Filter{Key: "workspace", Operator: "LIKE", Value: "db%"},
+				}},
+				Filter{Key: "status", Operator: "=", Value: "applied"},
+			}},
+		},
+		{"or=" + url.QueryEscape("kind=drift&and="+url.QueryEscape("kind=deploy&status=applied")),
+			LogicalFilter{Operator: "OR", Filters: []FilterExpr{
+				LogicalFilter{Operator: "AND", Filters: []FilterExpr{
+					Filter{Key: "kind", Operator: "=", Value: "deploy"},
+					Filter{Key: "status", Operator: "=", Value: "applied"},
+				}},
+				Filter{Key: "kind", Operator: "=", Value: "drift"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter, err := ParseFilterQuery(query, TableNameRuns)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.query, err)
+		}
+
+		if !reflect.DeepEqual(filter, tt.expected) {
+			t.Fatalf("wrong filter for %s\nactual: %#v\nexpected: %#v", tt.query, filter, tt.expected)
+		}
+	}
+}
+
+func TestParseFilterQueryInvalid(t *testing.T) {
+	tests := []struct {
+		table string
+		query string
+		err   error
+	}{
+		{TableNameRuns, "unknown=web", ErrUnknownColumn},
+		{TableNameUsers, "password_hash=like:$argon2id$%25", ErrUnknownColumn},
+		{TableNameRuns, "or=" + url.QueryEscape("limit=10"), ErrUnknownColumn},
+		{TableNameRuns, "attempts=gt:many", ErrInvalidValue},
+		{TableNameRuns, "created_at=gt:yesterday", ErrInvalidValue},
+		{TableNameRuns, "attempts=like:1%25", ErrInvalidValue},
+		{TableNameRuns, "attempts=between:1", ErrInvalidValue},
+		{TableNameRuns, "finished_at=is:empty", ErrInvalidValue},
+		{TableNameUsers, "is_admin=maybe", ErrInvalidValue},
+		{TableNameRuns, "or=%25", ErrInvalidFilter},
+		{TableNameRuns, "or=" + url.QueryEscape("or="+url.QueryEscape("or="+url.QueryEscape("or="+
+			url.QueryEscape("or="+url.QueryEscape("workspace=web"))))), ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ParseFilterQuery(query, tt.table)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("expected %v for %s, got %v", tt.err, tt.query, err)
+		}
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", nil},
+		{"limit=10", nil},
+		{"fields=id,%20status", []string{"id", "status"}},
+	}
+
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+
+		fields, err := ParseFields(query, TableNameRuns)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(fields, tt.expected) {
+			t.Fatalf("expected %v for %s, got %v", tt.expected, tt.query, fields)
+		}
+	}
+
+	// columns that are not allowed can't be selected. e.g. the options of runs or the password hashes of users
+	for table, query := range map[string]string{
+		TableNameRuns:  "fields=id,options",
+		TableNameUsers: "fields=password_hash",
+	} {
+		values, _ := url.ParseQuery(query)
+
+		_, err := ParseFields(values, table)
+		if !errors.Is(err, ErrInvalidFields) {
+			t.Fatalf("expected ErrInvalidFields for %s, got %v", query, err)
+		}
+	}
+}
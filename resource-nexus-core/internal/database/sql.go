@@ -11,19 +11,27 @@ import (
 //
 // Returns selected rows and a function to close the rows.
 func (db *SqlDatabase) Select(query string, filter FilterExpr, ctx context.Context) (*sql.Rows, func(), error) {
+	return db.selectClauses(query, filter, "", ctx)
+}
+
+// selectClauses executes a query like Select. The clauses (e.g. ORDER BY) are appended after the WHERE clause.
+func (db *SqlDatabase) selectClauses(
+	query string, filter FilterExpr, clauses string, ctx context.Context,
+) (*sql.Rows, func(), error) {
 	// build where clause. returns empty string if no filter is given
-	where, args, err := BuildWhere(filter)
+	where, args, err := BuildWhere(filter, db.dialect)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// append where clause to query
-	query += where
+	query = db.dialect.Rebind(query) + where + clauses
+	args = db.dialect.BindArgs(args)
 
 	db.logger.Debug("query database", "query", query, "args", args)
 
 	// query database
-	rows, err := db.database.QueryContext(ctx, query, args...)
+	rows, err := db.querier().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, func() {
 			err = rows.Close()
@@ -41,8 +49,41 @@ func (db *SqlDatabase) Select(query string, filter FilterExpr, ctx context.Conte
 	}, nil
 }
 
+// Insert executes an insert statement against the database.
 func (db *SqlDatabase) Insert(query string, ctx context.Context, args ...any) (sql.Result, error) {
+	query = db.dialect.Rebind(query)
+	args = db.dialect.BindArgs(args)
+
+	db.logger.Debug("exec database", "query", query, "args", args)
+
+	return db.querier().ExecContext(ctx, query, args...) //nolint:wrapcheck
+}
+
+// InsertReturningID executes an insert statement and returns the id of the new row.
+//
+// The query needs to end with 'RETURNING id'. The pq driver does not support sql.Result.LastInsertId.
+func (db *SqlDatabase) InsertReturningID(query string, ctx context.Context, args ...any) (int, error) {
+	query = db.dialect.Rebind(query)
+	args = db.dialect.BindArgs(args)
+
+	db.logger.Debug("exec database", "query", query, "args", args)
+
+	var id int
+
+	err := db.querier().QueryRowContext(ctx, query, args...).Scan(&id)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return id, nil
+}
+
+// Update executes an update or delete statement against the database.
+func (db *SqlDatabase) Update(query string, ctx context.Context, args ...any) (sql.Result, error) {
+	query = db.dialect.Rebind(query)
+	args = db.dialect.BindArgs(args)
+
 	db.logger.Debug("exec database", "query", query, "args", args)
 
-	return db.database.ExecContext(ctx, query, args...) //nolint:wrapcheck
+	return db.querier().ExecContext(ctx, query, args...) //nolint:wrapcheck
 }
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	TableNameAuditLog string = "audit_log"
+)
+
+// auditColumns are the columns of the audit log in the order they are scanned by scanAuditEntry.
+const auditColumns = "id, created_at, actor, action, target, before_snapshot, after_snapshot, request_id, source_ip, " +
+	"result, status"
+
+// ListAuditEntries returns a page of the audit entries that match the filter.
+func (db *SqlDatabase) ListAuditEntries(
+	filter FilterExpr, page Page, ctx context.Context,
+) (PageResult[AuditEntry], error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", auditColumns, TableNameAuditLog)
+
+	return getPage(db, query, TableNameAuditLog, filter, page, ctx, auditRow, scanAuditEntry)
+}
+
+// InsertAuditEntry appends an entry to the audit log. Empty snapshots are stored as NULL.
+func (db *SqlDatabase) InsertAuditEntry(ctx context.Context, entry AuditEntry) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (created_at, actor, action, target, before_snapshot, after_snapshot, request_id, source_ip, "+
+			"result, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		TableNameAuditLog,
+	)
+
+	result, err := db.Insert(query, ctx,
+		entry.CreatedAt,
+		entry.Actor,
+		entry.Action,
+		entry.Target,
+		nullable(string(entry.Before)),
+		nullable(string(entry.After)),
+		entry.RequestID,
+		entry.SourceIP,
+		entry.Result,
+		entry.Status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+
+	return result, nil
+}
+
+// scanAuditEntry scans a row of auditColumns.
+func scanAuditEntry(rows *sql.Rows) (AuditEntry, error) {
+	var (
+		entry         AuditEntry
+		before, after sql.NullString
+	)
+
+	err := rows.Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.Actor,
+		&entry.Action,
+		&entry.Target,
+		&before,
+		&after,
+		&entry.RequestID,
+		&entry.SourceIP,
+		&entry.Result,
+		&entry.Status,
+	)
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("failed to scan audit entry: %w", err)
+	}
+
+	if before.Valid {
+		entry.Before = json.RawMessage(before.String)
+	}
+
+	if after.Valid {
+		entry.After = json.RawMessage(after.String)
+	}
+
+	return entry, nil
+}
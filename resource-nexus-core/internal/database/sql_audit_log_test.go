@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestListAuditEntries(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM audit_log WHERE target = \$1`).
+		WithArgs("run:1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rows := sqlmock.NewRows([]string{
+		"id", "created_at", "actor", "action", "target", "before_snapshot", "after_snapshot", "request_id",
+		"source_ip", "result", "status",
+	}).AddRow(1, time.Now(), "alice", "provisioning:run:cancel", "run:1", nil, `{"status":"canceled"}`, "request-1",
+		"192.0.2.1", "success", 200)
+
+	mock.ExpectQuery(`SELECT id, created_at, actor, action, target, before_snapshot, after_snapshot, request_id, ` +
+		`source_ip, result, status FROM audit_log WHERE target = \$1 ORDER BY created_at DESC, id ASC`).
+		WithArgs("run:1").
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	result, err := db.ListAuditEntries(
+		Filter{Key: "target", Operator: "=", Value: "run:1"},
+		Page{OrderBy: []Order{{Column: "created_at", Desc: true}}},
+		context.TODO(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 1 || len(result.Items) != 1 {
+		t.Fatalf("wrong audit entries returned: %v", result)
+	}
+
+	entry := result.Items[0]
+	if entry.Actor != "alice" || entry.Before != nil || string(entry.After) != `{"status":"canceled"}` ||
+		entry.Result != AuditResultSuccess || entry.Status != 200 {
+		t.Fatalf("wrong audit entry returned: %+v", entry)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertAuditEntry(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	createdAt := time.Now().UTC()
+
+	mock.ExpectExec(`INSERT INTO audit_log \(created_at, actor, action, target, before_snapshot, after_snapshot, `+
+		`request_id, source_ip, result, status\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10\)`).
+		WithArgs(createdAt, "alice", "auth:user:delete", "user:bob", `{"name":"bob"}`, nil, "request-1", "192.0.2.1",
+			AuditResultSuccess, 200).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertAuditEntry(context.TODO(), AuditEntry{
+		CreatedAt: createdAt,
+		Actor:     "alice",
+		Action:    "auth:user:delete",
+		Target:    "user:bob",
+		Before:    json.RawMessage(`{"name":"bob"}`),
+		RequestID: "request-1",
+		SourceIP:  "192.0.2.1",
+		Result:    AuditResultSuccess,
+		Status:    200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
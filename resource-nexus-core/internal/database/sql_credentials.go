@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameCredentials string = "credentials"
+)
+
+// GetCredentials returns all credentials, including their encrypted values, from the database based on the filter.
+func (db *SqlDatabase) GetCredentials(filter FilterExpr, ctx context.Context) ([]Credential, error) {
+	query := fmt.Sprintf(
+		"SELECT id, workspace, name, value, created_at, updated_at FROM %s",
+		TableNameCredentials,
+	)
+
+	return getReferences(db, query, filter, ctx, scanCredential)
+}
+
+// ListCredentials returns a page of the credentials that match the filter.
+func (db *SqlDatabase) ListCredentials(
+	filter FilterExpr, page Page, ctx context.Context,
+) (PageResult[Credential], error) {
+	query := fmt.Sprintf(
+		"SELECT id, workspace, name, value, created_at, updated_at FROM %s",
+		TableNameCredentials,
+	)
+
+	return getPage(db, query, TableNameCredentials, filter, page, ctx, credentialRow, scanCredential)
+}
+
+// scanCredential scans the selected columns of a credential.
+func scanCredential(rows *sql.Rows) (Credential, error) {
+	var credential Credential
+
+	err := rows.Scan(
+		&credential.ID,
+		&credential.Workspace,
+		&credential.Name,
+		&credential.Value,
+		&credential.CreatedAt,
+		&credential.UpdatedAt,
+	)
+	if err != nil {
+		return Credential{}, fmt.Errorf("failed to scan credential: %w", err)
+	}
+
+	return credential, nil
+}
+
+// InsertCredential inserts a new credential into the database.
+//
+// The value needs to be encrypted already. An existing credential with the same name is replaced, so credentials
+// can be rotated.
+func (db *SqlDatabase) InsertCredential(ctx context.Context, credential Credential) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (workspace, name, value) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (workspace, name) DO UPDATE SET value = EXCLUDED.value, updated_at = now()",
+		TableNameCredentials,
+	)
+
+	result, err := db.Insert(query, ctx, credential.Workspace, credential.Name, credential.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert credential: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestGetCredentials(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}).
+		AddRow(1, "web", "TF_VAR_token", []byte{0x01, 0x02}, time.Now(), time.Now())
+
+	mock.ExpectQuery(`SELECT id, workspace, name, value, created_at, updated_at FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	credentials, err := db.GetCredentials(Filter{Key: "workspace", Operator: "=", Value: "web"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(credentials) != 1 || credentials[0].Name != "TF_VAR_token" || len(credentials[0].Value) != 2 {
+		t.Fatal("wrong credentials returned")
+	}
+}
+
+func TestInsertCredential(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`INSERT INTO credentials \(workspace, name, value\) VALUES \(\$1, \$2, \$3\) ON CONFLICT`).
+		WithArgs("web", "TF_VAR_token", []byte{0x01, 0x02}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertCredential(context.TODO(), Credential{
+		Workspace: "web",
+		Name:      "TF_VAR_token",
+		Value:     []byte{0x01, 0x02},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
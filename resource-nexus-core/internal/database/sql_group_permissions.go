@@ -59,3 +59,17 @@ func (db *SqlDatabase) InsertGroupPermission(
 
 	return result, nil
 }
+
+// DeleteGroupPermission removes the permission from the group.
+func (db *SqlDatabase) DeleteGroupPermission(
+	ctx context.Context, groupPermission GroupPermissionReference,
+) (sql.Result, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE group_id = $1 AND permission_id = $2", TableNameGroupPermissions)
+
+	result, err := db.Update(query, ctx, groupPermission.GroupID, groupPermission.PermissionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete group permission: %w", err)
+	}
+
+	return result, nil
+}
@@ -98,3 +98,30 @@ func TestInsertGroupPermission(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDeleteGroupPermission(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`DELETE FROM group_permissions WHERE group_id = \$1 AND permission_id = \$2`).
+		WithArgs(14, 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.DeleteGroupPermission(context.TODO(), GroupPermissionReference{GroupID: 14, PermissionID: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -43,6 +43,21 @@ func (db *SqlDatabase) GetGroups(filter FilterExpr, ctx context.Context) ([]Grou
 	return groups, nil
 }
 
+// ListGroups returns a page of the groups that match the filter.
+func (db *SqlDatabase) ListGroups(filter FilterExpr, page Page, ctx context.Context) (PageResult[Group], error) {
+	query := fmt.Sprintf("SELECT id, name FROM %s", TableNameGroups)
+
+	return getPage(db, query, TableNameGroups, filter, page, ctx, groupRow,
+		func(rows *sql.Rows) (Group, error) {
+			var group Group
+
+			err := rows.Scan(&group.ID, &group.Name)
+
+			return group, err //nolint:wrapcheck
+		},
+	)
+}
+
 // GetGroup returns a single group from the database based on the filter.
 func (db *SqlDatabase) GetGroup(filter FilterExpr, ctx context.Context) (Group, error) {
 	groups, err := db.GetGroups(filter, ctx)
@@ -72,3 +87,27 @@ func (db *SqlDatabase) InsertGroup(ctx context.Context, group Group) (sql.Result
 
 	return result, nil
 }
+
+// UpdateGroup updates the name of the group with the id of the given group.
+func (db *SqlDatabase) UpdateGroup(ctx context.Context, group Group) (sql.Result, error) {
+	query := fmt.Sprintf("UPDATE %s SET name = $1 WHERE id = $2", TableNameGroups)
+
+	result, err := db.Update(query, ctx, group.Name, group.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeleteGroup deletes the group with the given id. The memberships and permissions of the group are deleted with it.
+func (db *SqlDatabase) DeleteGroup(ctx context.Context, id int) (sql.Result, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", TableNameGroups)
+
+	result, err := db.Update(query, ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	return result, nil
+}
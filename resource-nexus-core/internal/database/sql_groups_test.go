@@ -42,6 +42,35 @@ func TestGetGroups(t *testing.T) {
 	}
 }
 
+func TestListGroups(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM groups`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	mock.ExpectQuery(`SELECT id, name FROM groups ORDER BY id ASC LIMIT 3 OFFSET 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "operators").AddRow(3, "viewers"))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	result, err := db.ListGroups(nil, Page{Limit: 2, Offset: 1}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 3 || len(result.Items) != 2 || result.NextCursor != "" {
+		t.Fatalf("wrong groups returned: %v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
 func TestGetGroup(t *testing.T) {
 	d, mock, _ := sqlmock.New()
 	defer d.Close()
@@ -100,3 +129,57 @@ func TestInsertGroup(t *testing.T) {
 		t.Fatal("wrong number of rows affected")
 	}
 }
+
+func TestUpdateGroup(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`UPDATE groups SET name = \$1 WHERE id = \$2`).
+		WithArgs("dummy", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.UpdateGroup(context.TODO(), Group{ID: 2, Name: "dummy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`DELETE FROM groups WHERE id = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.DeleteGroup(context.TODO(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
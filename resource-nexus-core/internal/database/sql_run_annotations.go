@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameRunAnnotations string = "run_annotations"
+)
+
+// GetRunAnnotations returns all run annotations from the database based on the filter.
+func (db *SqlDatabase) GetRunAnnotations(filter FilterExpr, ctx context.Context) ([]RunAnnotation, error) {
+	query := fmt.Sprintf("SELECT run_id, hook, name, value, created_at FROM %s", TableNameRunAnnotations)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (RunAnnotation, error) {
+			var annotation RunAnnotation
+
+			err := rows.Scan(
+				&annotation.RunID,
+				&annotation.Hook,
+				&annotation.Name,
+				&annotation.Value,
+				&annotation.CreatedAt,
+			)
+			if err != nil {
+				return RunAnnotation{}, fmt.Errorf("failed to scan run annotation: %w", err)
+			}
+
+			return annotation, nil
+		},
+	)
+}
+
+// InsertRunAnnotation inserts an annotation of a run into the database.
+//
+// An existing annotation of the run with the same name is replaced.
+func (db *SqlDatabase) InsertRunAnnotation(ctx context.Context, annotation RunAnnotation) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (run_id, hook, name, value) VALUES ($1, $2, $3, $4) "+
+			"ON CONFLICT (run_id, name) DO UPDATE SET hook = EXCLUDED.hook, value = EXCLUDED.value, created_at = now()",
+		TableNameRunAnnotations,
+	)
+
+	result, err := db.Insert(query, ctx, annotation.RunID, annotation.Hook, annotation.Name, annotation.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert run annotation: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestGetRunAnnotations(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := sqlmock.NewRows([]string{"run_id", "hook", "name", "value", "created_at"}).
+		AddRow(1, "cmdb", "cmdb_id", "CI0042", time.Now())
+
+	mock.ExpectQuery(`SELECT run_id, hook, name, value, created_at FROM run_annotations WHERE run_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	annotations, err := db.GetRunAnnotations(Filter{Key: "run_id", Operator: "=", Value: 1}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(annotations) != 1 || annotations[0].Value != "CI0042" {
+		t.Fatal("wrong run annotations returned")
+	}
+}
+
+func TestInsertRunAnnotation(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`INSERT INTO run_annotations \(run_id, hook, name, value\)(.+)ON CONFLICT \(run_id, name\)`).
+		WithArgs(1, "cmdb", "cmdb_id", "CI0042").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertRunAnnotation(context.TODO(), RunAnnotation{RunID: 1, Hook: "cmdb", Name: "cmdb_id", Value: "CI0042"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameRunArtifacts string = "run_artifacts"
+)
+
+// GetRunArtifacts returns all run artifacts, including their content, from the database based on the filter.
+func (db *SqlDatabase) GetRunArtifacts(filter FilterExpr, ctx context.Context) ([]RunArtifact, error) {
+	query := fmt.Sprintf(
+		"SELECT id, run_id, name, content_type, content, created_at FROM %s",
+		TableNameRunArtifacts,
+	)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (RunArtifact, error) {
+			var artifact RunArtifact
+
+			err := rows.Scan(
+				&artifact.ID,
+				&artifact.RunID,
+				&artifact.Name,
+				&artifact.ContentType,
+				&artifact.Content,
+				&artifact.CreatedAt,
+			)
+			if err != nil {
+				return RunArtifact{}, fmt.Errorf("failed to scan run artifact: %w", err)
+			}
+
+			return artifact, nil
+		},
+	)
+}
+
+// GetRunArtifact returns a single run artifact from the database based on the filter.
+func (db *SqlDatabase) GetRunArtifact(filter FilterExpr, ctx context.Context) (RunArtifact, error) {
+	artifacts, err := db.GetRunArtifacts(filter, ctx)
+	if err != nil {
+		return RunArtifact{}, err
+	}
+
+	if !isSingleElement[RunArtifact](artifacts) {
+		return RunArtifact{}, fmt.Errorf("not exactly 1 run artifact has been found with the filter %s", filter)
+	}
+
+	return artifacts[0], nil
+}
+
+// InsertRunArtifact inserts a run artifact into the database.
+//
+// An existing artifact of the run with the same name is replaced. e.g. the plan of a retried run.
+func (db *SqlDatabase) InsertRunArtifact(ctx context.Context, artifact RunArtifact) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (run_id, name, content_type, content) VALUES ($1, $2, $3, $4) "+
+			"ON CONFLICT (run_id, name) DO UPDATE SET "+
+			"content_type = EXCLUDED.content_type, content = EXCLUDED.content, created_at = now()",
+		TableNameRunArtifacts,
+	)
+
+	result, err := db.Insert(query, ctx, artifact.RunID, artifact.Name, artifact.ContentType, artifact.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert run artifact: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestGetRunArtifact(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "run_id", "name", "content_type", "content", "created_at"}).
+		AddRow(1, 42, "plan.json", "application/json", []byte(`{"format_version":"1.2"}`), time.Now())
+
+	mock.ExpectQuery(`SELECT id, run_id, name, content_type, content, created_at FROM run_artifacts WHERE`).
+		WithArgs(42, "plan.json").
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	artifact, err := db.GetRunArtifact(LogicalFilter{
+		Operator: "AND",
+		Filters: []FilterExpr{
+			Filter{Key: "run_id", Operator: "=", Value: 42},
+			Filter{Key: "name", Operator: "=", Value: "plan.json"},
+		},
+	}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(artifact.Content) != `{"format_version":"1.2"}` {
+		t.Fatalf("wrong content returned: %s", artifact.Content)
+	}
+}
+
+func TestInsertRunArtifact(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`INSERT INTO run_artifacts \(run_id, name, content_type, content\)(.+)ON CONFLICT \(run_id, name\)`).
+		WithArgs(42, "plan", "application/octet-stream", []byte{0x50, 0x4b}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertRunArtifact(context.TODO(), RunArtifact{
+		RunID:       42,
+		Name:        "plan",
+		ContentType: "application/octet-stream",
+		Content:     []byte{0x50, 0x4b},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
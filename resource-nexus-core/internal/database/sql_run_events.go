@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameRunEvents string = "run_events"
+)
+
+// runEventColumns are the columns selected for a run event. See scanRunEvent.
+const runEventColumns = "id, run_id, phase, type, level, message, payload, timestamp"
+
+// GetRunEvents returns all run events from the database based on the filter.
+func (db *SqlDatabase) GetRunEvents(filter FilterExpr, ctx context.Context) ([]RunEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", runEventColumns, TableNameRunEvents)
+
+	return getReferences(db, query, filter, ctx, scanRunEvent)
+}
+
+// ListRunEvents returns a page of the run events that match the filter.
+func (db *SqlDatabase) ListRunEvents(
+	filter FilterExpr, page Page, ctx context.Context,
+) (PageResult[RunEvent], error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", runEventColumns, TableNameRunEvents)
+
+	return getPage(db, query, TableNameRunEvents, filter, page, ctx, runEventRow, scanRunEvent)
+}
+
+// scanRunEvent scans a row of the runEventColumns into a RunEvent.
+func scanRunEvent(rows *sql.Rows) (RunEvent, error) {
+	var event RunEvent
+
+	err := rows.Scan(
+		&event.ID,
+		&event.RunID,
+		&event.Phase,
+		&event.Type,
+		&event.Level,
+		&event.Message,
+		&event.Payload,
+		&event.Timestamp,
+	)
+	if err != nil {
+		return RunEvent{}, fmt.Errorf("failed to scan run event: %w", err)
+	}
+
+	return event, nil
+}
+
+// InsertRunEvent inserts a new run event into the database.
+func (db *SqlDatabase) InsertRunEvent(ctx context.Context, event RunEvent) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (run_id, phase, type, level, message, payload, timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		TableNameRunEvents,
+	)
+
+	result, err := db.Insert(query, ctx,
+		event.RunID,
+		event.Phase,
+		event.Type,
+		event.Level,
+		event.Message,
+		event.Payload,
+		event.Timestamp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert run event: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestGetRunEvents(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "run_id", "phase", "type", "level", "message", "payload", "timestamp"}).
+		AddRow(1, 7, "plan", "version", "info", "Terraform 1.9.5", "{}", time.Now())
+
+	mock.ExpectQuery(`SELECT id, run_id, phase, type, level, message, payload, timestamp FROM run_events`).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	events, err := db.GetRunEvents(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].RunID != 7 {
+		t.Fatal("wrong run events returned")
+	}
+}
+
+func TestInsertRunEvent(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	now := time.Now()
+
+	mock.ExpectExec(`INSERT INTO run_events \(run_id, phase, type, level, message, payload, timestamp\)`).
+		WithArgs(7, "plan", "log", "info", "foo", "{}", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertRunEvent(context.TODO(), RunEvent{
+		RunID:     7,
+		Phase:     "plan",
+		Type:      "log",
+		Level:     "info",
+		Message:   "foo",
+		Payload:   "{}",
+		Timestamp: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameRunPhases string = "run_phases"
+)
+
+// GetRunPhases returns all run phases from the database based on the filter.
+func (db *SqlDatabase) GetRunPhases(filter FilterExpr, ctx context.Context) ([]RunPhase, error) {
+	query := fmt.Sprintf(
+		"SELECT run_id, phase, result, exit_code, stderr, started_at, finished_at FROM %s",
+		TableNameRunPhases,
+	)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (RunPhase, error) {
+			var phase RunPhase
+
+			err := rows.Scan(
+				&phase.RunID,
+				&phase.Phase,
+				&phase.Result,
+				&phase.ExitCode,
+				&phase.Stderr,
+				&phase.StartedAt,
+				&phase.FinishedAt,
+			)
+			if err != nil {
+				return RunPhase{}, fmt.Errorf("failed to scan run phase: %w", err)
+			}
+
+			return phase, nil
+		},
+	)
+}
+
+// InsertRunPhase inserts the result of an executed run phase into the database.
+func (db *SqlDatabase) InsertRunPhase(ctx context.Context, phase RunPhase) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (run_id, phase, result, exit_code, stderr, started_at, finished_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		TableNameRunPhases,
+	)
+
+	result, err := db.Insert(query, ctx,
+		phase.RunID,
+		phase.Phase,
+		phase.Result,
+		phase.ExitCode,
+		phase.Stderr,
+		phase.StartedAt,
+		phase.FinishedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert run phase: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestGetRunPhases(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	now := time.Now()
+
+	rows := sqlmock.NewRows([]string{"run_id", "phase", "result", "exit_code", "stderr", "started_at", "finished_at"}).
+		AddRow(1, "init", "success", 0, "", now, now).
+		AddRow(1, "plan", "failed", 1, "boom", now, now)
+
+	mock.ExpectQuery(`SELECT run_id, phase, result, exit_code, stderr, started_at, finished_at FROM run_phases WHERE run_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	phases, err := db.GetRunPhases(Filter{Key: "run_id", Operator: "=", Value: 1}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(phases) != 2 || phases[1].Stderr != "boom" {
+		t.Fatal("wrong run phases returned")
+	}
+}
+
+func TestInsertRunPhase(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	now := time.Now()
+
+	mock.ExpectExec(`INSERT INTO run_phases \(run_id, phase, result, exit_code, stderr, started_at, finished_at\)`).
+		WithArgs(1, "plan", "success", 0, "", now, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertRunPhase(context.TODO(), RunPhase{
+		RunID:      1,
+		Phase:      "plan",
+		Result:     "success",
+		StartedAt:  now,
+		FinishedAt: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,368 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	TableNameRuns string = "runs"
+
+	// pqUniqueViolation is the PostgresSQL error code of a violated unique constraint.
+	pqUniqueViolation pq.ErrorCode = "23505"
+
+	// runColumns are the selected columns of a run. The order matches scanRun.
+	runColumns string = "id, workspace, kind, status, phase, message, attempts, created_at, started_at, finished_at, " +
+		"approved_at, COALESCE(canceled_by, ''), post_apply_status, options"
+)
+
+// ErrActiveRun is returned if a run is inserted for a workspace that already has an active run.
+var ErrActiveRun = errors.New("workspace already has an active run")
+
+// ActiveRunFilter returns a filter that matches the active (not final) runs of the workspace.
+func ActiveRunFilter(workspace string) FilterExpr {
+	return LogicalFilter{
+		Operator: "AND",
+		Filters: []FilterExpr{
+			Filter{Key: "workspace", Operator: "=", Value: workspace},
+			LogicalFilter{
+				Operator: "OR",
+				Filters: []FilterExpr{
+					Filter{Key: "status", Operator: "=", Value: RunStatusQueued},
+					Filter{Key: "status", Operator: "=", Value: RunStatusRunning},
+					Filter{Key: "status", Operator: "=", Value: RunStatusPlanned},
+				},
+			},
+		},
+	}
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRun scans the columns defined in runColumns into a Run.
+func scanRun(row rowScanner) (Run, error) {
+	var run Run
+
+	err := row.Scan(
+		&run.ID,
+		&run.Workspace,
+		&run.Kind,
+		&run.Status,
+		&run.Phase,
+		&run.Message,
+		&run.Attempts,
+		&run.CreatedAt,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.ApprovedAt,
+		&run.CanceledBy,
+		&run.PostApplyStatus,
+		&run.Options,
+	)
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to scan run: %w", err)
+	}
+
+	return run, nil
+}
+
+// GetRuns returns all runs from the database based on the filter.
+func (db *SqlDatabase) GetRuns(filter FilterExpr, ctx context.Context) ([]Run, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", runColumns, TableNameRuns)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (Run, error) {
+			return scanRun(rows)
+		},
+	)
+}
+
+// ListRuns returns a page of the runs that match the filter.
+func (db *SqlDatabase) ListRuns(filter FilterExpr, page Page, ctx context.Context) (PageResult[Run], error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", runColumns, TableNameRuns)
+
+	return getPage(db, query, TableNameRuns, filter, page, ctx, runRow,
+		func(rows *sql.Rows) (Run, error) {
+			return scanRun(rows)
+		},
+	)
+}
+
+// GetRun returns a single run from the database based on the filter.
+func (db *SqlDatabase) GetRun(filter FilterExpr, ctx context.Context) (Run, error) {
+	runs, err := db.GetRuns(filter, ctx)
+	if err != nil {
+		return Run{}, err
+	}
+
+	if !isSingleElement[Run](runs) {
+		return Run{}, fmt.Errorf("not exactly 1 run has been found with the filter %s", filter)
+	}
+
+	return runs[0], nil
+}
+
+// InsertRun inserts a new run into the database and returns the id of the new run.
+//
+// Only one active run per workspace is allowed. Returns ErrActiveRun if the workspace already has one.
+// Runs without kind are inserted as RunKindDeploy.
+func (db *SqlDatabase) InsertRun(ctx context.Context, run Run) (int, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (workspace, kind, status, phase, message, options) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		TableNameRuns,
+	)
+
+	if run.Kind == "" {
+		run.Kind = RunKindDeploy
+	}
+
+	id, err := db.InsertReturningID(query, ctx, run.Workspace, run.Kind, run.Status, run.Phase, run.Message, run.Options)
+	if isUniqueViolation(err) {
+		return 0, ErrActiveRun
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert run: %w", err)
+	}
+
+	return id, nil
+}
+
+// UpdateRun updates the lifecycle fields (status, phase, message and timestamps) of the given run.
+//
+// If the cancellation of the run has been requested, a queued or planned status is stored as canceled. e.g. the
+// plan has finished before the interrupt of the cancellation arrived. A canceled plan must never be approved.
+// Running runs keep their status until they are interrupted.
+func (db *SqlDatabase) UpdateRun(ctx context.Context, run Run) (sql.Result, error) {
+	canceled := "canceled_by IS NOT NULL AND $1 IN ($8, $9)"
+
+	query := fmt.Sprintf(
+		"UPDATE %[1]s SET status = CASE WHEN %[2]s THEN $10 ELSE $1 END, phase = $2, "+
+			"message = CASE WHEN %[2]s THEN 'canceled by ' || canceled_by ELSE $3 END, started_at = $4, "+
+			"finished_at = CASE WHEN %[2]s THEN now() ELSE $5 END, post_apply_status = $6 WHERE id = $7",
+		TableNameRuns, canceled,
+	)
+
+	result, err := db.Update(query, ctx,
+		run.Status, run.Phase, run.Message, run.StartedAt, run.FinishedAt, run.PostApplyStatus, run.ID,
+		RunStatusQueued, RunStatusPlanned, RunStatusCanceled,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update run: %w", err)
+	}
+
+	return result, nil
+}
+
+// ApproveRun approves the saved plan of a planned run and puts the run back into the queue to apply the plan.
+//
+// Only runs with the status planned, whose cancellation has not been requested, are approved. Check the affected rows
+// of the result.
+func (db *SqlDatabase) ApproveRun(ctx context.Context, id int) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET status = $1, approved_at = now(), message = '', attempts = 0, next_attempt_at = NULL "+
+			"WHERE id = $2 AND status = $3 AND canceled_by IS NULL",
+		TableNameRuns,
+	)
+
+	result, err := db.Update(query, ctx, RunStatusQueued, id, RunStatusPlanned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve run: %w", err)
+	}
+
+	return result, nil
+}
+
+// ClaimRun claims the oldest queued run for the given worker and marks it as running.
+//
+// Rows are locked with 'FOR UPDATE SKIP LOCKED', so concurrent workers (also of other instances) never claim
+// the same run. SQLite can't lock rows, but executes writes one after another. So the claim is atomic as well.
+// Runs with a next attempt in the future are skipped.
+// Returns false if no run is waiting for execution.
+func (db *SqlDatabase) ClaimRun(ctx context.Context, worker string) (Run, bool, error) {
+	skipLocked := "FOR UPDATE SKIP LOCKED"
+	if db.dialect == DialectSqlite {
+		skipLocked = ""
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %[1]s SET status = $1, attempts = attempts + 1, claimed_by = $2, heartbeat_at = now()
+		WHERE id = (
+			SELECT id FROM %[1]s
+			WHERE status = $3 AND (next_attempt_at IS NULL OR next_attempt_at <= now())
+			ORDER BY id
+			LIMIT 1
+			%[3]s
+		)
+		RETURNING %[2]s`,
+		TableNameRuns,
+		runColumns,
+		skipLocked,
+	)
+
+	query = db.dialect.Rebind(query)
+
+	db.logger.Debug("claim run from database", "query", query, "worker", worker)
+
+	run, err := scanRun(db.querier().QueryRowContext(ctx, query, RunStatusRunning, worker, RunStatusQueued))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Run{}, false, nil
+	}
+
+	if err != nil {
+		return Run{}, false, fmt.Errorf("failed to claim run: %w", err)
+	}
+
+	return run, true, nil
+}
+
+// HeartbeatRun marks the claimed run as still being executed by the given worker.
+//
+// Returns true if the cancellation of the run has been requested.
+func (db *SqlDatabase) HeartbeatRun(ctx context.Context, id int, worker string) (bool, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET heartbeat_at = now() WHERE id = $1 AND claimed_by = $2 RETURNING canceled_by IS NOT NULL",
+		TableNameRuns,
+	)
+
+	query = db.dialect.Rebind(query)
+
+	db.logger.Debug("exec database", "query", query, "args", []any{id, worker})
+
+	var canceled bool
+
+	err := db.querier().QueryRowContext(ctx, query, id, worker).Scan(&canceled)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the run is not claimed by the worker anymore
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to update run heartbeat: %w", err)
+	}
+
+	return canceled, nil
+}
+
+// CancelRun cancels the run on behalf of the given user.
+//
+// Queued and planned runs are canceled immediately. For running runs, the cancellation is requested.
+// The worker executing the run interrupts it on its next heartbeat.
+// Returns the status of the run after the cancellation. Returns false if the run can't be canceled, because it
+// does not exist, is already final or its cancellation has already been requested.
+func (db *SqlDatabase) CancelRun(ctx context.Context, id int, canceledBy string) (RunStatus, bool, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s SET
+			canceled_by = $1,
+			status = CASE WHEN status = $2 THEN status ELSE $3 END,
+			message = CASE WHEN status = $2 THEN message ELSE $4 END,
+			finished_at = CASE WHEN status = $2 THEN finished_at ELSE now() END
+		WHERE id = $5 AND status IN ($6, $2, $7) AND canceled_by IS NULL
+		RETURNING status`,
+		TableNameRuns,
+	)
+
+	query = db.dialect.Rebind(query)
+
+	args := []any{
+		canceledBy,
+		RunStatusRunning,
+		RunStatusCanceled,
+		fmt.Sprintf("canceled by %s", canceledBy),
+		id,
+		RunStatusQueued,
+		RunStatusPlanned,
+	}
+
+	db.logger.Debug("exec database", "query", query, "args", args)
+
+	var status RunStatus
+
+	err := db.querier().QueryRowContext(ctx, query, args...).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("failed to cancel run: %w", err)
+	}
+
+	return status, true, nil
+}
+
+// RequeueRun puts the run back into the queue. The run will not be claimed before nextAttempt.
+// Runs whose cancellation has been requested are not put back into the queue.
+//
+// If countAttempt is false, the attempt counted by ClaimRun is taken back. e.g. the run has not been executed, because
+// the workspace is locked by another run.
+func (db *SqlDatabase) RequeueRun(
+	ctx context.Context, id int, nextAttempt time.Time, message string, countAttempt bool,
+) (sql.Result, error) {
+	attempts := "attempts"
+	if !countAttempt {
+		attempts = "attempts - 1"
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET status = $1, phase = '', message = $2, next_attempt_at = $3, attempts = %s, "+
+			"claimed_by = NULL, heartbeat_at = NULL, finished_at = NULL WHERE id = $4 AND canceled_by IS NULL",
+		TableNameRuns, attempts,
+	)
+
+	result, err := db.Update(query, ctx, RunStatusQueued, message, nextAttempt, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue run: %w", err)
+	}
+
+	return result, nil
+}
+
+// RecoverStaleRuns recovers running runs whose worker has not sent a heartbeat since staleBefore.
+//
+// The worker that executed these runs is gone (e.g. the instance crashed or was killed).
+// Runs that have not reached the apply phase are safe to execute again and are put back into the queue.
+// Runs that were interrupted during apply or the post-apply stage are marked as errored, because infrastructure may have
+// been changed.
+// Runs whose cancellation has been requested are marked as canceled.
+func (db *SqlDatabase) RecoverStaleRuns(ctx context.Context, staleBefore time.Time) (int64, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s SET
+			status = CASE WHEN canceled_by IS NOT NULL THEN $8 WHEN phase IN ($1, $10, $11) THEN $2 ELSE $3 END,
+			message = CASE WHEN canceled_by IS NOT NULL THEN $9 WHEN phase IN ($1, $10, $11) THEN $4 ELSE $5 END,
+			finished_at = CASE WHEN canceled_by IS NOT NULL OR phase IN ($1, $10, $11) THEN now() ELSE NULL END,
+			phase = CASE WHEN canceled_by IS NOT NULL OR phase IN ($1, $10, $11) THEN phase ELSE '' END,
+			claimed_by = NULL,
+			heartbeat_at = NULL
+		WHERE status = $6 AND heartbeat_at < $7`,
+		TableNameRuns,
+	)
+
+	result, err := db.Update(query, ctx,
+		"apply",
+		RunStatusErrored,
+		RunStatusQueued,
+		"run was interrupted during or after apply. check the state of the workspace",
+		"run was interrupted. requeued",
+		RunStatusRunning,
+		staleBefore,
+		RunStatusCanceled,
+		"run was canceled. the worker was gone before the interruption was confirmed",
+		"output",
+		"ansible-playbook",
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recover stale runs: %w", err)
+	}
+
+	recovered, _ := result.RowsAffected()
+
+	return recovered, nil
+}
@@ -0,0 +1,353 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// newRunRows returns the rows of the columns defined in runColumns.
+func newRunRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "workspace", "kind", "status", "phase", "message", "attempts", "created_at", "started_at", "finished_at",
+		"approved_at", "canceled_by", "post_apply_status", "options",
+	})
+}
+
+func TestGetRuns(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	now := time.Now()
+
+	rows := newRunRows().
+		AddRow(1, "dummy", "deploy", "queued", "", "", 0, now, nil, nil, nil, "", "", []byte(`{"replace":["null_resource.web[1]"]}`)).
+		AddRow(2, "dummy", "deploy", "applied", "apply", "", 1, now, now, now, nil, "", "", []byte(`{}`))
+
+	mock.ExpectQuery(`SELECT id, workspace, (.+), COALESCE\(canceled_by, ''\), post_apply_status, options FROM runs`).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	runs, err := db.GetRuns(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatal("wrong number of runs returned")
+	}
+
+	if runs[0].StartedAt != nil || runs[1].FinishedAt == nil {
+		t.Fatal("nullable timestamps not scanned correctly")
+	}
+
+	if runs[1].Status != RunStatusApplied {
+		t.Fatalf("wrong status returned: %s", runs[1].Status)
+	}
+
+	if len(runs[0].Options.Replace) != 1 || runs[0].Options.Replace[0] != "null_resource.web[1]" || runs[1].Options.Destroy {
+		t.Fatalf("options not scanned correctly: %+v", runs[0].Options)
+	}
+}
+
+func TestGetRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := newRunRows().
+		AddRow(4, "dummy", "deploy", "queued", "", "", 0, time.Now(), nil, nil, nil, "", "", []byte(`{}`))
+
+	mock.ExpectQuery(`SELECT (.+) FROM runs WHERE id = \$1`).
+		WithArgs(4).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	run, err := db.GetRun(Filter{Key: "id", Operator: "=", Value: 4}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.ID != 4 {
+		t.Fatal("wrong run returned")
+	}
+}
+
+func TestInsertRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`INSERT INTO runs \(workspace, kind, status, phase, message, options\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\) RETURNING id`).
+		WithArgs("dummy", RunKindDeploy, RunStatusQueued, "", "", `{"targets":["module.web"],"destroy":true}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	id, err := db.InsertRun(context.TODO(), Run{
+		Workspace: "dummy",
+		Status:    RunStatusQueued,
+		Options:   RunOptions{Targets: []string{"module.web"}, Destroy: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 12 {
+		t.Fatalf("wrong id returned: %d", id)
+	}
+}
+
+func TestInsertRunActive(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`INSERT INTO runs`).
+		WillReturnError(&pq.Error{Code: pqUniqueViolation})
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertRun(context.TODO(), Run{Workspace: "dummy", Status: RunStatusQueued})
+	if !errors.Is(err, ErrActiveRun) {
+		t.Fatalf("expected ErrActiveRun, got %v", err)
+	}
+}
+
+func TestUpdateRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	now := time.Now()
+	run := Run{
+		ID:        3,
+		Status:    RunStatusRunning,
+		Phase:     "plan",
+		StartedAt: &now,
+	}
+
+	mock.ExpectExec(`UPDATE runs SET status = CASE WHEN canceled_by IS NOT NULL AND \$1 IN \(\$8, \$9\) THEN \$10 `+
+		`ELSE \$1 END, phase = \$2, .* WHERE id = \$7`).
+		WithArgs(RunStatusRunning, "plan", "", &now, nil, PostApplyStatusNone, 3,
+			RunStatusQueued, RunStatusPlanned, RunStatusCanceled).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.UpdateRun(context.TODO(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestApproveRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`UPDATE runs SET status = \$1, approved_at = now\(\)(.+) WHERE id = \$2 AND status = \$3`).
+		WithArgs(RunStatusQueued, 7, RunStatusPlanned).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	result, err := db.ApproveRun(context.TODO(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if approved, _ := result.RowsAffected(); approved != 1 {
+		t.Fatal("run not approved")
+	}
+}
+
+func TestClaimRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := newRunRows().
+		AddRow(5, "dummy", "deploy", "running", "", "", 1, time.Now(), nil, nil, nil, "", "", []byte(`{}`))
+
+	mock.ExpectQuery(`UPDATE runs SET status = \$1, attempts = attempts \+ 1(.+)FOR UPDATE SKIP LOCKED(.+)RETURNING`).
+		WithArgs(RunStatusRunning, "worker-1", RunStatusQueued).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	run, ok, err := db.ClaimRun(context.TODO(), "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || run.ID != 5 || run.Attempts != 1 {
+		t.Fatal("wrong run claimed")
+	}
+}
+
+func TestClaimRunEmptyQueue(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`UPDATE runs SET status`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, ok, err := db.ClaimRun(context.TODO(), "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("no run should be claimed from an empty queue")
+	}
+}
+
+func TestRequeueRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	next := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE runs SET status = \$1, phase = '', message = \$2, next_attempt_at = \$3, `+
+		`attempts = attempts,`).
+		WithArgs(RunStatusQueued, "retry", next, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET status = \$1, phase = '', message = \$2, next_attempt_at = \$3, `+
+		`attempts = attempts - 1,`).
+		WithArgs(RunStatusQueued, "locked", next, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.RequeueRun(context.TODO(), 5, next, "retry", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.RequeueRun(context.TODO(), 5, next, "locked", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverStaleRuns(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	staleBefore := time.Now().Add(-time.Minute)
+
+	mock.ExpectExec(`UPDATE runs SET(.+)WHERE status = \$6 AND heartbeat_at < \$7`).
+		WithArgs("apply", RunStatusErrored, RunStatusQueued, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			RunStatusRunning, staleBefore, RunStatusCanceled, sqlmock.AnyArg(), "output", "ansible-playbook").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	recovered, err := db.RecoverStaleRuns(context.TODO(), staleBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recovered != 2 {
+		t.Fatalf("wrong number of recovered runs: %d", recovered)
+	}
+}
+
+func TestHeartbeatRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`UPDATE runs SET heartbeat_at = now\(\) WHERE id = \$1 AND claimed_by = \$2 RETURNING`).
+		WithArgs(5, "worker-0").
+		WillReturnRows(sqlmock.NewRows([]string{"canceled"}).AddRow(true))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	canceled, err := db.HeartbeatRun(context.TODO(), 5, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !canceled {
+		t.Fatal("requested cancellation not returned")
+	}
+}
+
+func TestCancelRun(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`UPDATE runs SET(.+)WHERE id = \$5 AND status IN \(\$6, \$2, \$7\) AND canceled_by IS NULL`).
+		WithArgs("dummy", RunStatusRunning, RunStatusCanceled, "canceled by dummy", 5, RunStatusQueued,
+			RunStatusPlanned).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(RunStatusRunning))
+	mock.ExpectQuery(`UPDATE runs SET`).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	status, ok, err := db.CancelRun(context.TODO(), 5, "dummy")
+	if err != nil || !ok {
+		t.Fatal("run should be canceled")
+	}
+
+	if status != RunStatusRunning {
+		t.Fatalf("running run should stay running until it is interrupted. got %s", status)
+	}
+
+	// already canceled or final
+	_, ok, err = db.CancelRun(context.TODO(), 5, "dummy")
+	if err != nil || ok {
+		t.Fatal("run should not be canceled twice")
+	}
+}
@@ -57,3 +57,15 @@ func (db *SqlDatabase) InsertUserGroupReference(ctx context.Context, group UserG
 
 	return result, nil
 }
+
+// DeleteUserGroupReference removes the user from the group.
+func (db *SqlDatabase) DeleteUserGroupReference(ctx context.Context, group UserGroupReference) (sql.Result, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND group_id = $2", TableNameUserGroups)
+
+	result, err := db.Update(query, ctx, group.UserID, group.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete user group reference: %w", err)
+	}
+
+	return result, nil
+}
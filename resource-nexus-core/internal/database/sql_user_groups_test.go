@@ -87,3 +87,30 @@ func TestInsertUserGroupReference(t *testing.T) {
 		t.Fatal("wrong number of rows affected")
 	}
 }
+
+func TestDeleteUserGroupReference(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`DELETE FROM user_groups WHERE user_id = \$1 AND group_id = \$2`).
+		WithArgs(7, 14).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.DeleteUserGroupReference(context.TODO(), UserGroupReference{UserID: 7, GroupID: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
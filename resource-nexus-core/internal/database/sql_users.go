@@ -42,6 +42,21 @@ func (db *SqlDatabase) GetUsers(filter FilterExpr, ctx context.Context) ([]User,
 	return users, nil
 }
 
+// ListUsers returns a page of the users that match the filter. The password hashes are not selected.
+func (db *SqlDatabase) ListUsers(filter FilterExpr, page Page, ctx context.Context) (PageResult[User], error) {
+	query := fmt.Sprintf("SELECT id, name, is_admin FROM %s", TableNameUsers)
+
+	return getPage(db, query, TableNameUsers, filter, page, ctx, userRow,
+		func(rows *sql.Rows) (User, error) {
+			var user User
+
+			err := rows.Scan(&user.ID, &user.Name, &user.IsAdmin)
+
+			return user, err //nolint:wrapcheck
+		},
+	)
+}
+
 // GetUser returns a single user from the database.
 func (db *SqlDatabase) GetUser(filter FilterExpr, ctx context.Context) (User, error) {
 	users, err := db.GetUsers(filter, ctx)
@@ -100,17 +115,18 @@ func (db *SqlDatabase) GetUserPermissions(username string, ctx context.Context)
 	}
 
 	// build where clause. is empty if no filter is given
-	where, args, err := BuildWhere(filter)
+	where, args, err := BuildWhere(filter, db.dialect)
 	if err != nil {
 		return nil, err
 	}
 
 	// append where clause to query
-	query += where
+	query = db.dialect.Rebind(query) + where
+	args = db.dialect.BindArgs(args)
 
 	db.logger.Debug("query user permissions from database", "query", query, "args", args)
 
-	rows, err := db.database.QueryContext(ctx, query, args...)
+	rows, err := db.querier().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user permissions. query: %s: %w", query, err)
 	}
@@ -142,3 +158,27 @@ func (db *SqlDatabase) GetUserPermissions(username string, ctx context.Context)
 
 	return permissions, nil
 }
+
+// UpdateUser updates the name, password hash and admin flag of the user with the id of the given user.
+func (db *SqlDatabase) UpdateUser(ctx context.Context, user User) (sql.Result, error) {
+	query := fmt.Sprintf("UPDATE %s SET name = $1, password_hash = $2, is_admin = $3 WHERE id = $4", TableNameUsers)
+
+	result, err := db.Update(query, ctx, user.Name, user.PasswordHash, user.IsAdmin, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeleteUser deletes the user with the given id. The group memberships of the user are deleted with it.
+func (db *SqlDatabase) DeleteUser(ctx context.Context, id int) (sql.Result, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", TableNameUsers)
+
+	result, err := db.Update(query, ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return result, nil
+}
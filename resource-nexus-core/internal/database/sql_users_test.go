@@ -45,6 +45,42 @@ func TestGetUsers(t *testing.T) {
 	}
 }
 
+func TestListUsers(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE name ILIKE \$1`).
+		WithArgs("a%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectQuery(`SELECT id, name, is_admin FROM users WHERE name ILIKE \$1 ORDER BY name DESC, id ASC`).
+		WithArgs("a%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_admin"}).AddRow(1, "alice", true))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	result, err := db.ListUsers(
+		Filter{Key: "name", Operator: "ILIKE", Value: "a%"},
+		Page{OrderBy: []Order{{Column: "name", Desc: true}}},
+		context.TODO(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 1 || len(result.Items) != 1 || result.Items[0].Name != "alice" || !result.Items[0].IsAdmin {
+		t.Fatalf("wrong users returned: %v", result)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	d, mock, _ := sqlmock.New()
 	defer d.Close()
@@ -145,3 +181,57 @@ func TestGetUserPermissions(t *testing.T) {
 		t.Fatal("wrong permissions returned")
 	}
 }
+
+func TestUpdateUser(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`UPDATE users SET name = \$1, password_hash = \$2, is_admin = \$3 WHERE id = \$4`).
+		WithArgs("dummy", "foobar", true, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.UpdateUser(context.TODO(), User{ID: 3, Name: "dummy", PasswordHash: "foobar", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	res, err := db.DeleteUser(context.TODO(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows != 1 {
+		t.Fatal("wrong number of rows affected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+const (
+	TableNameWorkspaceLocks string = "workspace_locks"
+
+	// advisoryLockNamespace is the first key of the two-key advisory locks used for workspaces.
+	// The second key is the id of the workspace.
+	advisoryLockNamespace int = 4890
+)
+
+// ErrWorkspaceLocked is returned if a workspace is already locked by another run.
+var ErrWorkspaceLocked = errors.New("workspace is locked")
+
+// WorkspaceLock is a lock on a workspace. The lock is held until Release is called.
+type WorkspaceLock interface {
+	Release(ctx context.Context) error
+}
+
+// WorkspaceLockedError is returned if a workspace is already locked. It holds the run that holds the lock.
+type WorkspaceLockedError struct {
+	Workspace string
+	RunID     int
+}
+
+func (e *WorkspaceLockedError) Error() string {
+	return fmt.Sprintf("workspace '%s' is locked by run %d", e.Workspace, e.RunID)
+}
+
+func (e *WorkspaceLockedError) Unwrap() error {
+	return ErrWorkspaceLocked
+}
+
+// advisoryLock is a WorkspaceLock backed by a session-level PostgresSQL advisory lock.
+//
+// Session-level advisory locks are bound to a database connection.
+// The connection is reserved until the lock is released. If the instance dies, the connection is closed and
+// PostgresSQL releases the lock automatically.
+type advisoryLock struct {
+	db        *SqlDatabase
+	conn      *sql.Conn
+	workspace Workspace
+	runID     int
+}
+
+// LockWorkspace locks the workspace for the given run.
+//
+// The lock is a PostgresSQL advisory lock, so it works across multiple instances.
+// SQLite has no advisory locks. The lock is held inside this instance instead. See lockWorkspaceLocal.
+// The lock holder is recorded inside the workspace_locks table, so it can be inspected over the API.
+// Returns a *WorkspaceLockedError if the workspace is already locked and ErrInTransaction inside a transaction.
+func (db *SqlDatabase) LockWorkspace(
+	ctx context.Context, workspace Workspace, runID int, holder string,
+) (WorkspaceLock, error) {
+	if db.tx != nil {
+		return nil, ErrInTransaction
+	}
+
+	if db.dialect == DialectSqlite {
+		return db.lockWorkspaceLocal(ctx, workspace, runID, holder)
+	}
+
+	conn, err := db.database.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection for workspace lock: %w", err)
+	}
+
+	var (
+		locked bool
+		pid    int
+	)
+
+	db.logger.Debug("lock workspace", "workspace", workspace.Name, "run", runID)
+
+	err = conn.QueryRowContext(ctx,
+		"SELECT pg_try_advisory_lock($1, $2), pg_backend_pid()",
+		advisoryLockNamespace, workspace.ID,
+	).Scan(&locked, &pid)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("failed to lock workspace: %w", err)
+	}
+
+	if !locked {
+		_ = conn.Close()
+
+		lockedErr := &WorkspaceLockedError{Workspace: workspace.Name}
+
+		info, err := db.GetWorkspaceLocks(Filter{Key: "workspace", Operator: "=", Value: workspace.Name}, ctx)
+		if err == nil && len(info) == 1 {
+			lockedErr.RunID = info[0].RunID
+		}
+
+		return nil, lockedErr
+	}
+
+	// record the lock holder. a record of a gone holder is replaced
+	_, err = conn.ExecContext(ctx, recordWorkspaceLockQuery(), workspace.Name, runID, holder, pid)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("failed to record workspace lock: %w", err),
+			unlockAdvisory(context.WithoutCancel(ctx), conn, workspace),
+		)
+	}
+
+	return &advisoryLock{
+		db:        db,
+		conn:      conn,
+		workspace: workspace,
+		runID:     runID,
+	}, nil
+}
+
+// Release removes the lock record and releases the advisory lock.
+//
+// The advisory lock is released even if the record can't be removed. A record without a held lock is replaced by the
+// next holder. The reserved connection is returned to the pool. See unlockAdvisory.
+func (l *advisoryLock) Release(ctx context.Context) error {
+	l.db.logger.Debug("unlock workspace", "workspace", l.workspace.Name, "run", l.runID)
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE workspace = $1 AND run_id = $2", TableNameWorkspaceLocks)
+
+	_, err := l.conn.ExecContext(ctx, query, l.workspace.Name, l.runID)
+	if err != nil {
+		err = fmt.Errorf("failed to remove workspace lock record: %w", err)
+	}
+
+	return errors.Join(err, unlockAdvisory(ctx, l.conn, l.workspace))
+}
+
+// unlockAdvisory releases the advisory lock of the workspace and returns the reserved connection to the pool.
+//
+// If the lock can't be released, the connection is discarded instead. Otherwise, the session would stay inside the
+// pool with the lock held. PostgresSQL releases the lock once the session is closed.
+func unlockAdvisory(ctx context.Context, conn *sql.Conn, workspace Workspace) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, $2)", advisoryLockNamespace, workspace.ID)
+	if err != nil {
+		// a bad connection is closed instead of returned to the pool
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = conn.Close()
+
+		return fmt.Errorf("failed to release workspace lock: %w", err)
+	}
+
+	_ = conn.Close()
+
+	return nil
+}
+
+// recordWorkspaceLockQuery returns the query to record the holder of a workspace lock.
+// An existing record of the workspace is replaced.
+func recordWorkspaceLockQuery() string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (workspace, run_id, holder, backend_pid, acquired_at) VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (workspace) DO UPDATE SET
+			run_id = EXCLUDED.run_id, holder = EXCLUDED.holder,
+			backend_pid = EXCLUDED.backend_pid, acquired_at = EXCLUDED.acquired_at`,
+		TableNameWorkspaceLocks,
+	)
+}
+
+// GetWorkspaceLocks returns all workspace locks from the database based on the filter.
+//
+// Held is false if the session of the holder does not exist anymore.
+func (db *SqlDatabase) GetWorkspaceLocks(filter FilterExpr, ctx context.Context) ([]WorkspaceLockInfo, error) {
+	if db.dialect == DialectSqlite {
+		return db.getWorkspaceLocksLocal(filter, ctx)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT workspace, run_id, holder, backend_pid, acquired_at,
+			EXISTS (
+				SELECT 1 FROM pg_locks
+				WHERE locktype = 'advisory' AND granted AND pid = backend_pid AND classid = %d
+			) AS held
+		FROM %s`,
+		advisoryLockNamespace,
+		TableNameWorkspaceLocks,
+	)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (WorkspaceLockInfo, error) {
+			var lock WorkspaceLockInfo
+
+			err := rows.Scan(&lock.Workspace, &lock.RunID, &lock.Holder, &lock.BackendPID, &lock.AcquiredAt, &lock.Held)
+			if err != nil {
+				return WorkspaceLockInfo{}, fmt.Errorf("failed to scan workspace lock: %w", err)
+			}
+
+			return lock, nil
+		},
+	)
+}
+
+// ForceUnlockWorkspace releases the lock of the workspace, regardless of the holder.
+//
+// Advisory locks can only be released by the session that holds them. So the session of the holder is terminated.
+// The executing process of the run is not stopped by that. Use it only for locks of gone holders or finished runs.
+func (db *SqlDatabase) ForceUnlockWorkspace(ctx context.Context, workspace string) (sql.Result, error) {
+	if db.dialect == DialectSqlite {
+		return db.forceUnlockWorkspaceLocal(ctx, workspace)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT pg_terminate_backend(backend_pid) FROM %s WHERE workspace = $1 AND backend_pid <> pg_backend_pid()",
+		TableNameWorkspaceLocks,
+	)
+
+	_, err := db.Update(query, ctx, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to terminate lock holder: %w", err)
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE workspace = $1", TableNameWorkspaceLocks)
+
+	result, err := db.Update(query, ctx, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove workspace lock record: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestLockWorkspace(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1, \$2\), pg_backend_pid\(\)`).
+		WithArgs(advisoryLockNamespace, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"locked", "pid"}).AddRow(true, 815))
+	mock.ExpectExec(`INSERT INTO workspace_locks`).
+		WithArgs("web", 7, "worker-0", 815).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM workspace_locks WHERE workspace = \$1 AND run_id = \$2`).
+		WithArgs("web", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1, \$2\)`).
+		WithArgs(advisoryLockNamespace, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	lock, err := db.LockWorkspace(context.TODO(), Workspace{ID: 3, Name: "web"}, 7, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lock.Release(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockWorkspaceRecordFailed(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked", "pid"}).AddRow(true, 815))
+	mock.ExpectExec(`INSERT INTO workspace_locks`).
+		WillReturnError(errors.New("dummy"))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1, \$2\)`).
+		WithArgs(advisoryLockNamespace, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	// the lock is released before the connection is returned to the pool
+	_, err := db.LockWorkspace(context.TODO(), Workspace{ID: 3, Name: "web"}, 7, "worker-0")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseWorkspaceLockFailed(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked", "pid"}).AddRow(true, 815))
+	mock.ExpectExec(`INSERT INTO workspace_locks`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM workspace_locks`).
+		WillReturnError(errors.New("dummy"))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1, \$2\)`).
+		WithArgs(advisoryLockNamespace, 3).
+		WillReturnError(errors.New("dummy"))
+	// the connection still holds the lock. it is closed instead of returned to the pool
+	mock.ExpectClose()
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	lock, err := db.LockWorkspace(context.TODO(), Workspace{ID: 3, Name: "web"}, 7, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lock.Release(context.TODO())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockWorkspaceLocked(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock`).
+		WillReturnRows(sqlmock.NewRows([]string{"locked", "pid"}).AddRow(false, 815))
+	mock.ExpectQuery(`SELECT (.+) FROM workspace_locks WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"workspace", "run_id", "holder", "backend_pid", "acquired_at", "held",
+		}).AddRow("web", 5, "worker-1", 900, time.Now(), true))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.LockWorkspace(context.TODO(), Workspace{ID: 3, Name: "web"}, 7, "worker-0")
+	if !errors.Is(err, ErrWorkspaceLocked) {
+		t.Fatalf("expected ErrWorkspaceLocked, got %v", err)
+	}
+
+	var lockedErr *WorkspaceLockedError
+	if !errors.As(err, &lockedErr) || lockedErr.RunID != 5 {
+		t.Fatalf("blocking run not returned: %v", err)
+	}
+}
+
+func TestForceUnlockWorkspace(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`SELECT pg_terminate_backend\(backend_pid\) FROM workspace_locks WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM workspace_locks WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	result, err := db.ForceUnlockWorkspace(context.TODO(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed, _ := result.RowsAffected(); removed != 1 {
+		t.Fatal("lock record not removed")
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	TableNameWorkspaces string = "workspaces"
+
+	// workspaceColumns are the selected columns of a workspace. The order matches scanWorkspace.
+	workspaceColumns string = "id, name, working_directory, executable_path, provisioner, required_version, created_at, " +
+		"drift_interval, drift_status, drift_checked_at, drift_run_id, playbook, init_timeout, plan_timeout, apply_timeout"
+)
+
+// scanWorkspace scans the columns defined in workspaceColumns into a Workspace.
+func scanWorkspace(row rowScanner) (Workspace, error) {
+	var workspace Workspace
+
+	err := row.Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.WorkingDirectory,
+		&workspace.ExecutablePath,
+		&workspace.Provisioner,
+		&workspace.RequiredVersion,
+		&workspace.CreatedAt,
+		&workspace.DriftInterval,
+		&workspace.DriftStatus,
+		&workspace.DriftCheckedAt,
+		&workspace.DriftRunID,
+		&workspace.Playbook,
+		&workspace.InitTimeout,
+		&workspace.PlanTimeout,
+		&workspace.ApplyTimeout,
+	)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+
+	return workspace, nil
+}
+
+// GetWorkspaces returns all workspaces from the database based on the filter.
+func (db *SqlDatabase) GetWorkspaces(filter FilterExpr, ctx context.Context) ([]Workspace, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", workspaceColumns, TableNameWorkspaces)
+
+	return getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (Workspace, error) {
+			return scanWorkspace(rows)
+		},
+	)
+}
+
+// ListWorkspaces returns a page of the workspaces that match the filter.
+func (db *SqlDatabase) ListWorkspaces(filter FilterExpr, page Page, ctx context.Context) (PageResult[Workspace], error) {
+	query := fmt.Sprintf("SELECT %s FROM %s", workspaceColumns, TableNameWorkspaces)
+
+	return getPage(db, query, TableNameWorkspaces, filter, page, ctx, workspaceRow,
+		func(rows *sql.Rows) (Workspace, error) {
+			return scanWorkspace(rows)
+		},
+	)
+}
+
+// GetWorkspace returns a single workspace from the database based on the filter.
+func (db *SqlDatabase) GetWorkspace(filter FilterExpr, ctx context.Context) (Workspace, error) {
+	workspaces, err := db.GetWorkspaces(filter, ctx)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	if !isSingleElement[Workspace](workspaces) {
+		return Workspace{}, fmt.Errorf("not exactly 1 workspace has been found with the filter %s", filter)
+	}
+
+	return workspaces[0], nil
+}
+
+// InsertWorkspace inserts a new workspace into the database.
+func (db *SqlDatabase) InsertWorkspace(ctx context.Context, workspace Workspace) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (name, working_directory, executable_path, provisioner, required_version, drift_interval, "+
+			"playbook, init_timeout, plan_timeout, apply_timeout) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		TableNameWorkspaces,
+	)
+
+	result, err := db.Insert(query, ctx,
+		workspace.Name,
+		workspace.WorkingDirectory,
+		workspace.ExecutablePath,
+		workspace.Provisioner,
+		workspace.RequiredVersion,
+		workspace.DriftInterval,
+		workspace.Playbook,
+		workspace.InitTimeout,
+		workspace.PlanTimeout,
+		workspace.ApplyTimeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert workspace: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetDriftDueWorkspaces returns all workspaces whose next drift detection is due.
+//
+// Drift detection is due if it is enabled for the workspace and the last detection is older than the drift interval
+// of the workspace. Workspaces that have never been checked are due immediately.
+func (db *SqlDatabase) GetDriftDueWorkspaces(ctx context.Context) ([]Workspace, error) {
+	due := "drift_checked_at + drift_interval * interval '1 second' <= now()"
+	if db.dialect == DialectSqlite {
+		// SQLite has no intervals. julianday returns the time in days
+		due = "julianday(drift_checked_at) + drift_interval / 86400.0 <= julianday(now())"
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE drift_interval > 0 AND (drift_checked_at IS NULL OR %s) "+
+			"ORDER BY drift_checked_at NULLS FIRST",
+		workspaceColumns,
+		TableNameWorkspaces,
+		due,
+	)
+
+	return getReferences(db, query, nil, ctx,
+		func(rows *sql.Rows) (Workspace, error) {
+			return scanWorkspace(rows)
+		},
+	)
+}
+
+// UpdateWorkspaceDrift stores the result of a drift detection of the workspace.
+func (db *SqlDatabase) UpdateWorkspaceDrift(
+	ctx context.Context, workspace string, status DriftStatus, runID int,
+) (sql.Result, error) {
+	query := fmt.Sprintf(
+		"UPDATE %s SET drift_status = $1, drift_checked_at = now(), drift_run_id = $2 WHERE name = $3",
+		TableNameWorkspaces,
+	)
+
+	result, err := db.Update(query, ctx, status, runID, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update drift of workspace: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// newWorkspaceRows returns the rows of the columns defined in workspaceColumns.
+func newWorkspaceRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "name", "working_directory", "executable_path", "provisioner", "required_version", "created_at",
+		"drift_interval", "drift_status", "drift_checked_at", "drift_run_id", "playbook", "init_timeout", "plan_timeout",
+		"apply_timeout",
+	})
+}
+
+func TestGetWorkspaces(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := newWorkspaceRows().
+		AddRow(1, "web", "/var/lib/resource-nexus/web", "/usr/local/bin/terraform", "terraform", "~> 1.9", time.Now(),
+			3600, "drifted", time.Now(), 17, "site.yml", 0, 0, 7200).
+		AddRow(2, "db", "/var/lib/resource-nexus/db", "/usr/local/bin/tofu", "opentofu", "", time.Now(),
+			0, "", nil, nil, "", 0, 0, 0)
+
+	mock.ExpectQuery(
+		"SELECT id, name, working_directory, executable_path, provisioner, required_version, created_at, " +
+			"drift_interval, drift_status, drift_checked_at, drift_run_id, playbook, init_timeout, plan_timeout, " +
+			"apply_timeout FROM workspaces",
+	).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	workspaces, err := db.GetWorkspaces(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workspaces) != 2 || workspaces[1].ExecutablePath != "/usr/local/bin/tofu" {
+		t.Fatal("wrong workspaces returned")
+	}
+
+	if workspaces[0].RequiredVersion != "~> 1.9" || workspaces[1].Provisioner != "opentofu" {
+		t.Fatal("provisioner requirements not scanned correctly")
+	}
+
+	if workspaces[0].DriftStatus != DriftStatusDrifted || *workspaces[0].DriftRunID != 17 || workspaces[1].DriftCheckedAt != nil {
+		t.Fatal("drift not scanned correctly")
+	}
+
+	if workspaces[0].ApplyTimeout != 7200 || workspaces[0].InitTimeout != 0 {
+		t.Fatal("timeouts not scanned correctly")
+	}
+}
+
+func TestListWorkspaces(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM workspaces WHERE provisioner = \$1`).
+		WithArgs("terraform").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	rows := newWorkspaceRows().
+		AddRow(1, "web", "/var/lib/resource-nexus/web", "", "terraform", "", time.Now(),
+			0, "", nil, nil, "", 0, 0, 0).
+		AddRow(2, "db", "/var/lib/resource-nexus/db", "", "terraform", "", time.Now(),
+			0, "", nil, nil, "", 0, 0, 0)
+
+	mock.ExpectQuery(`SELECT (.+) FROM workspaces WHERE \(provisioner = \$1 AND \(\(name > \$2\) OR `+
+		`\(name = \$3 AND id > \$4\)\)\) ORDER BY name ASC, id ASC LIMIT 2 OFFSET 1`).
+		WithArgs("terraform", "cache", "cache", 3).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	cursor, err := encodeCursor([]Order{{Column: "name"}, {Column: "id"}}, workspaceRow(Workspace{ID: 3, Name: "cache"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.ListWorkspaces(
+		Filter{Key: "provisioner", Operator: "=", Value: "terraform"},
+		Page{Limit: 1, Offset: 1, OrderBy: []Order{{Column: "name"}}, Cursor: cursor},
+		context.TODO(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 3 || len(result.Items) != 1 || result.Items[0].Name != "web" {
+		t.Fatalf("wrong page returned: %v", result)
+	}
+
+	next, err := encodeCursor([]Order{{Column: "name"}, {Column: "id"}}, workspaceRow(Workspace{ID: 1, Name: "web"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.NextCursor != next {
+		t.Fatalf("expected next cursor %s, got %s", next, result.NextCursor)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetWorkspace(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := newWorkspaceRows().
+		AddRow(1, "web", "/var/lib/resource-nexus/web", "/usr/local/bin/terraform", "terraform", "~> 1.9", time.Now(),
+			0, "", nil, nil, "", 0, 0, 0)
+
+	mock.ExpectQuery(`SELECT (.+) FROM workspaces WHERE name = \$1`).
+		WithArgs("web").
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	workspace, err := db.GetWorkspace(Filter{Key: "name", Operator: "=", Value: "web"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if workspace.Name != "web" {
+		t.Fatal("wrong workspace returned")
+	}
+}
+
+func TestInsertWorkspace(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(
+		`INSERT INTO workspaces \(name, working_directory, executable_path, provisioner, required_version, drift_interval, `+
+			`playbook, init_timeout, plan_timeout, apply_timeout\)`,
+	).
+		WithArgs("web", "/var/lib/resource-nexus/web", "/usr/local/bin/terraform", "", "", 0, "", 0, 0, 3600).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.InsertWorkspace(context.TODO(), Workspace{
+		Name:             "web",
+		WorkingDirectory: "/var/lib/resource-nexus/web",
+		ExecutablePath:   "/usr/local/bin/terraform",
+		ApplyTimeout:     3600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDriftDueWorkspaces(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	rows := newWorkspaceRows().
+		AddRow(1, "web", "/var/lib/resource-nexus/web", "", "", "", time.Now(), 3600, "", nil, nil, "", 0, 0, 0)
+
+	mock.ExpectQuery(`SELECT (.+) FROM workspaces WHERE drift_interval > 0 AND \(drift_checked_at IS NULL OR`).
+		WillReturnRows(rows)
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	workspaces, err := db.GetDriftDueWorkspaces(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(workspaces) != 1 || workspaces[0].DriftInterval != 3600 {
+		t.Fatal("wrong workspaces returned")
+	}
+}
+
+func TestUpdateWorkspaceDrift(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectExec(`UPDATE workspaces SET drift_status = \$1, drift_checked_at = now\(\), drift_run_id = \$2 WHERE name = \$3`).
+		WithArgs(DriftStatusDrifted, 17, "web").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	_, err := db.UpdateWorkspaceDrift(context.TODO(), "web", DriftStatusDrifted, 17)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// sqliteTimeFormat is the format timestamps are stored with inside SQLite. Like the time format 'sqlite' of
+	// the driver. All timestamps are stored in UTC, so they can be compared as text.
+	sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+	// sqliteBusyTimeout is the time in milliseconds a connection waits for the lock of another connection.
+	sqliteBusyTimeout = 5000
+)
+
+// registerSqliteFunctions registers the functions of PostgresSQL that are used by queries, but are unknown to SQLite.
+//
+// - now(): the current time in UTC. Formatted like the timestamps written by the driver.
+var registerSqliteFunctions = sync.OnceFunc(func() { //nolint:gochecknoglobals
+	sqlite.MustRegisterScalarFunction("now", 0,
+		func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+			return time.Now().UTC().Format(sqliteTimeFormat), nil
+		},
+	)
+})
+
+// getSqliteDsn returns the DSN of the SQLite database file.
+//
+// Foreign keys are enforced and the WAL journal allows reading while a run is written. LIKE is case-sensitive like in
+// PostgresSQL.
+// Transactions take the write lock immediately, so concurrent transactions wait for each other instead of failing.
+func getSqliteDsn(conf config.Database) string {
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeout))
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "case_sensitive_like(1)")
+	query.Set("_time_format", "sqlite")
+	query.Set("_txlock", "immediate")
+
+	return "file:" + conf.File + "?" + query.Encode()
+}
+
+// isUniqueViolation returns true if the error is a violated unique constraint of PostgresSQL or SQLite.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqUniqueViolation
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+
+	return false
+}
+
+// isUndefinedTable returns true if the error is caused by a table that does not exist in PostgresSQL or SQLite.
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqUndefinedTable
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return strings.Contains(sqliteErr.Error(), "no such table")
+	}
+
+	return false
+}
@@ -0,0 +1,800 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// newSqliteTestDatabase returns a migrated SqlDatabase backed by a SQLite file inside a temporary directory.
+func newSqliteTestDatabase(t *testing.T) *SqlDatabase {
+	t.Helper()
+
+	db, err := NewDatabase(
+		config.Database{Type: "sqlite", File: filepath.Join(t.TempDir(), "resource-nexus.db")},
+		logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	err = db.TestConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.MigrateUp(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestGetSqliteDsn(t *testing.T) {
+	dsn := getSqliteDsn(config.Database{File: "/var/lib/resource-nexus/resource-nexus.db"})
+
+	if !strings.HasPrefix(dsn, "file:/var/lib/resource-nexus/resource-nexus.db?") {
+		t.Fatalf("unexpected dsn %s", dsn)
+	}
+
+	for _, param := range []string{
+		"_pragma=foreign_keys%281%29", "_pragma=journal_mode%28WAL%29",
+		"_pragma=case_sensitive_like%281%29", "_time_format=sqlite", "_txlock=immediate",
+	} {
+		if !strings.Contains(dsn, param) {
+			t.Fatalf("expected %s inside dsn %s", param, dsn)
+		}
+	}
+}
+
+func TestSqliteMigrations(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+
+	err := db.CheckSchemaVersion(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the permissions of the migrations match the permissions of the MemoryDatabase
+	permissions, err := db.GetPermissions(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := schemaPermissions()
+	if len(permissions) != len(expected) {
+		t.Fatalf("expected %d permissions, got %d", len(expected), len(permissions))
+	}
+
+	for i := range expected {
+		if permissions[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected[i], permissions[i])
+		}
+	}
+
+	migration, ok, err := db.MigrateDown(context.TODO())
+	if err != nil || !ok {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+
+	err = db.CheckSchemaVersion(context.TODO())
+	if !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("expected schema version mismatch, got %v", err)
+	}
+
+	// the permissions of the rolled back migration are removed
+	if migration.Name == "auth_management" {
+		permissions, err = db.GetPermissions(nil, context.TODO())
+		if err != nil || len(permissions) != 18 {
+			t.Fatalf("expected the permissions of the initial schema, got %d (%v)", len(permissions), err)
+		}
+	}
+
+	for ok {
+		_, ok, err = db.MigrateDown(context.TODO())
+		if err != nil {
+			t.Fatalf("failed to roll back: %v", err)
+		}
+	}
+
+	_, err = db.GetPermissions(nil, context.TODO())
+	if err == nil {
+		t.Fatal("expected the tables to be dropped")
+	}
+}
+
+func TestSqliteUsers(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertUser(ctx, User{Name: "alice", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertUser(ctx, User{Name: "alice", PasswordHash: "hash"})
+	if !isUniqueViolation(err) {
+		t.Fatalf("expected unique violation, got %v", err)
+	}
+
+	_, err = db.InsertGroup(ctx, Group{Name: "operators"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertUserGroupReference(ctx, UserGroupReference{UserID: 1, GroupID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: 1, PermissionID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// foreign keys are enforced
+	_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: 2, PermissionID: 7})
+	if err == nil {
+		t.Fatal("expected foreign key violation")
+	}
+
+	permissions, err := db.GetUserPermissions("alice", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Permission{Category: "provisioning", Resource: "workspace", Action: "get"}
+	if len(permissions) != 1 || permissions[0] != expected {
+		t.Fatalf("unexpected permissions %v", permissions)
+	}
+
+	_, err = db.UpdateUser(ctx, User{ID: 1, Name: "bob", PasswordHash: "other", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUser(Filter{Key: "name", Operator: "=", Value: "bob"}, ctx)
+	if err != nil || user.PasswordHash != "other" || !user.IsAdmin {
+		t.Fatalf("user has not been updated: %v (%v)", user, err)
+	}
+}
+
+func TestSqliteWithTx(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertUser(ctx, User{Name: "alice", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the group is rolled back, because the reference violates the foreign key of the group
+	err = db.WithTx(ctx, func(tx Database) error {
+		_, err := tx.InsertGroup(ctx, Group{Name: "operators"})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.InsertUserGroupReference(ctx, UserGroupReference{UserID: 1, GroupID: 2})
+
+		return err
+	})
+	if err == nil {
+		t.Fatal("expected foreign key violation")
+	}
+
+	groups, err := db.GetGroups(nil, ctx)
+	if err != nil || len(groups) != 0 {
+		t.Fatalf("expected the group to be rolled back, got %v (%v)", groups, err)
+	}
+
+	err = db.WithTx(ctx, func(tx Database) error {
+		_, err := tx.InsertGroup(ctx, Group{Name: "operators"})
+		if err != nil {
+			return err
+		}
+
+		group, err := tx.GetGroup(Filter{Key: "name", Operator: "=", Value: "operators"}, ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.InsertUserGroupReference(ctx, UserGroupReference{UserID: 1, GroupID: group.ID})
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	references, err := db.GetUserGroupReferences(nil, ctx)
+	if err != nil || len(references) != 1 {
+		t.Fatalf("expected the reference to be committed, got %v (%v)", references, err)
+	}
+}
+
+func TestSqliteDeleteCascade(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	for _, name := range []string{"alice", "bob"} {
+		_, err := db.InsertUser(ctx, User{Name: name, PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := db.InsertGroup(ctx, Group{Name: "operators"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []UserGroupReference{{UserID: 1, GroupID: 1}, {UserID: 2, GroupID: 1}} {
+		_, err = db.InsertUserGroupReference(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = db.InsertGroupPermission(ctx, GroupPermissionReference{GroupID: 1, PermissionID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the memberships of a deleted user are deleted with it
+	_, err = db.DeleteUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := db.GetUserGroupReferences(nil, ctx)
+	if err != nil || len(refs) != 1 || refs[0].UserID != 2 {
+		t.Fatalf("expected only the membership of bob, got %v (%v)", refs, err)
+	}
+
+	// the memberships and permissions of a deleted group are deleted with it
+	_, err = db.DeleteGroup(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err = db.GetUserGroupReferences(nil, ctx)
+	if err != nil || len(refs) != 0 {
+		t.Fatalf("expected no memberships, got %v (%v)", refs, err)
+	}
+
+	grants, err := db.GetGroupPermissions(nil, ctx)
+	if err != nil || len(grants) != 0 {
+		t.Fatalf("expected no group permissions, got %v (%v)", grants, err)
+	}
+}
+
+func TestSqliteRuns(t *testing.T) { //nolint:cyclop
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertWorkspace(ctx, Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.InsertRun(ctx, Run{
+		Workspace: "web",
+		Status:    RunStatusQueued,
+		Options:   RunOptions{Targets: []string{"proxmox_vm_qemu.web"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if !errors.Is(err, ErrActiveRun) {
+		t.Fatalf("expected ErrActiveRun, got %v", err)
+	}
+
+	run, ok, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil || !ok {
+		t.Fatalf("failed to claim run: %v", err)
+	}
+
+	if run.ID != id || run.Status != RunStatusRunning || run.Attempts != 1 || run.Options.Targets[0] != "proxmox_vm_qemu.web" {
+		t.Fatalf("unexpected run %v", run)
+	}
+
+	if time.Since(run.CreatedAt) > time.Minute || time.Since(run.CreatedAt) < 0 {
+		t.Fatalf("unexpected creation time %s", run.CreatedAt)
+	}
+
+	_, ok, err = db.ClaimRun(ctx, "worker-1")
+	if err != nil || ok {
+		t.Fatalf("expected no run to be claimed: %v", err)
+	}
+
+	status, ok, err := db.CancelRun(ctx, id, "alice")
+	if err != nil || !ok || status != RunStatusRunning {
+		t.Fatalf("unexpected cancellation %s %t %v", status, ok, err)
+	}
+
+	canceled, err := db.HeartbeatRun(ctx, id, "worker-0")
+	if err != nil || !canceled {
+		t.Fatalf("expected the cancellation to be requested: %v", err)
+	}
+
+	// the heartbeat has just been sent. the run is not stale yet
+	recovered, err := db.RecoverStaleRuns(ctx, time.Now().Add(-time.Minute))
+	if err != nil || recovered != 0 {
+		t.Fatalf("expected no recovered runs, got %d: %v", recovered, err)
+	}
+
+	recovered, err = db.RecoverStaleRuns(ctx, time.Now().Add(time.Minute))
+	if err != nil || recovered != 1 {
+		t.Fatalf("expected 1 recovered run, got %d: %v", recovered, err)
+	}
+
+	run, err = db.GetRun(Filter{Key: "id", Operator: "=", Value: id}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != RunStatusCanceled || run.CanceledBy != "alice" || run.FinishedAt == nil {
+		t.Fatalf("unexpected run %v", run)
+	}
+}
+
+func TestSqliteUpdateCanceledRun(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertWorkspace(ctx, Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, _, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = db.CancelRun(ctx, id, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the plan finished after the cancellation has been requested
+	run.Status = RunStatusPlanned
+	run.Message = "plan is waiting for approval"
+
+	_, err = db.UpdateRun(ctx, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err = db.GetRun(Filter{Key: "id", Operator: "=", Value: id}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != RunStatusCanceled || run.Message != "canceled by alice" || run.FinishedAt == nil {
+		t.Fatalf("canceled run should not be planned. got %v", run)
+	}
+
+	result, err := db.ApproveRun(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected != 0 {
+		t.Fatal("canceled run should not be approved")
+	}
+}
+
+func TestSqliteListRuns(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	for _, name := range []string{"web", "db"} {
+		_, err := db.InsertWorkspace(ctx, Workspace{Name: name, WorkingDirectory: "/tmp/" + name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// finished runs don't block new runs of the workspace
+	for range 3 {
+		for _, workspace := range []string{"web", "db"} {
+			_, err := db.InsertRun(ctx, Run{Workspace: workspace, Status: RunStatusApplied})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	page := Page{Limit: 2, OrderBy: []Order{{Column: "workspace"}, {Column: "id", Desc: true}}}
+
+	var ids []int
+
+	for {
+		result, err := db.ListRuns(nil, page, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Total != 6 || len(result.Items) != 2 {
+			t.Fatalf("unexpected page %v", result)
+		}
+
+		for _, run := range result.Items {
+			ids = append(ids, run.ID)
+		}
+
+		if result.NextCursor == "" {
+			break
+		}
+
+		page.Cursor = result.NextCursor
+	}
+
+	if !slices.Equal(ids, []int{6, 4, 2, 5, 3, 1}) {
+		t.Fatalf("wrong order of runs: %v", ids)
+	}
+
+	result, err := db.ListRuns(Filter{Key: "workspace", Operator: "=", Value: "web"},
+		Page{Offset: 2, OrderBy: []Order{{Column: "created_at"}}}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 3 || len(result.Items) != 1 || result.Items[0].ID != 5 {
+		t.Fatalf("unexpected page %v", result)
+	}
+}
+
+// TestSqliteFilters checks that the filters behave like in PostgresSQL and the MemoryDatabase.
+func TestSqliteFilters(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	memory := NewMemoryDatabase()
+	ctx := context.TODO()
+
+	for i, name := range []string{"web", "Web-2", "db_1", "db-2"} {
+		workspace := Workspace{Name: name, WorkingDirectory: "/tmp/" + name, DriftInterval: i * 60}
+
+		for _, d := range []Database{db, memory} {
+			_, err := d.InsertWorkspace(ctx, workspace)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		filter   FilterExpr
+		expected []string
+	}{
+		{Filter{Key: "name", Operator: "LIKE", Value: "web%"}, []string{"web"}},
+		{Filter{Key: "name", Operator: "ILIKE", Value: "web%"}, []string{"web", "Web-2"}},
+		{Filter{Key: "name", Operator: "NOT ILIKE", Value: "WEB%"}, []string{"db_1", "db-2"}},
+		{Filter{Key: "name", Operator: "LIKE", Value: `db\_%`}, []string{"db_1"}},
+		{Filter{Key: "name", Operator: "IN", Value: []string{"web", "db-2", "unknown"}}, []string{"web", "db-2"}},
+		{Filter{Key: "drift_interval", Operator: "BETWEEN", Value: []int{60, 120}}, []string{"Web-2", "db_1"}},
+		{Filter{Key: "drift_checked_at", Operator: "IS NULL"}, []string{"web", "Web-2", "db_1", "db-2"}},
+		{LogicalFilter{Operator: "NOT", Filters: []FilterExpr{
+			Filter{Key: "drift_interval", Operator: ">", Value: 60},
+		}}, []string{"web", "Web-2"}},
+	}
+
+	for _, tt := range tests {
+		for _, d := range []Database{db, memory} {
+			result, err := d.ListWorkspaces(tt.filter, Page{}, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+
+			for _, workspace := range result.Items {
+				names = append(names, workspace.Name)
+			}
+
+			if !slices.Equal(names, tt.expected) {
+				t.Fatalf("expected %v for %v inside %T, got %v", tt.expected, tt.filter, d, names)
+			}
+		}
+	}
+}
+
+func TestSqliteRequeueRun(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertWorkspace(ctx, Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// local times are compared with the times written by the database
+	_, err = db.RequeueRun(ctx, id, time.Now().In(time.FixedZone("CET", 3600)).Add(time.Hour), "retry", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil || ok {
+		t.Fatalf("expected the next attempt to be in the future: %v", err)
+	}
+
+	_, err = db.RequeueRun(ctx, id, time.Now().Add(-time.Second), "retry", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, ok, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil || !ok || run.Attempts != 1 {
+		t.Fatalf("expected the run to be claimed: %v", err)
+	}
+
+	// a run that waited for the lock of the workspace has not been attempted
+	_, err = db.RequeueRun(ctx, id, time.Now().Add(-time.Second), "locked", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, ok, err = db.ClaimRun(ctx, "worker-0")
+	if err != nil || !ok || run.Attempts != 1 {
+		t.Fatalf("expected the attempt of the locked run not to be counted: %+v (%v)", run, err)
+	}
+}
+
+func TestSqliteDriftDueWorkspaces(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	for _, workspace := range []Workspace{
+		{Name: "web", WorkingDirectory: "/tmp/web", DriftInterval: 3600},
+		{Name: "db", WorkingDirectory: "/tmp/db"},
+	} {
+		_, err := db.InsertWorkspace(ctx, workspace)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := db.GetDriftDueWorkspaces(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(due) != 1 || due[0].Name != "web" {
+		t.Fatalf("expected web to be due, got %v", due)
+	}
+
+	_, err = db.UpdateWorkspaceDrift(ctx, "web", DriftStatusInSync, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err = db.GetDriftDueWorkspaces(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(due) != 0 {
+		t.Fatalf("expected no workspace to be due, got %v", due)
+	}
+}
+
+func TestSqliteWorkspaceLocks(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	workspace := Workspace{ID: 1, Name: "web", WorkingDirectory: "/tmp/web"}
+
+	_, err := db.InsertWorkspace(ctx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		_, err = db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusCompleted})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lock, err := db.LockWorkspace(ctx, workspace, 1, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lockedErr *WorkspaceLockedError
+
+	_, err = db.LockWorkspace(ctx, workspace, 2, "worker-1")
+	if !errors.As(err, &lockedErr) || lockedErr.RunID != 1 {
+		t.Fatalf("expected the workspace to be locked by run 1, got %v", err)
+	}
+
+	locks, err := db.GetWorkspaceLocks(Filter{Key: "workspace", Operator: "=", Value: "web"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locks) != 1 || !locks[0].Held || locks[0].Holder != "worker-0" {
+		t.Fatalf("unexpected locks %v", locks)
+	}
+
+	_, err = db.ForceUnlockWorkspace(ctx, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock2, err := db.LockWorkspace(ctx, workspace, 2, "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// releasing the force unlocked lock does not release the new lock
+	err = lock.Release(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.LockWorkspace(ctx, workspace, 1, "worker-0")
+	if !errors.As(err, &lockedErr) || lockedErr.RunID != 2 {
+		t.Fatalf("expected the workspace to be locked by run 2, got %v", err)
+	}
+
+	err = lock2.Release(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locks, err = db.GetWorkspaceLocks(nil, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(locks) != 0 {
+		t.Fatalf("expected no locks, got %v", locks)
+	}
+}
+
+func TestSqliteRunRecords(t *testing.T) { //nolint:cyclop
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	_, err := db.InsertWorkspace(ctx, Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := db.InsertRun(ctx, Run{Workspace: "web", Status: RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	_, err = db.InsertRunPhase(ctx, RunPhase{RunID: id, Phase: "plan", Result: "success", StartedAt: now, FinishedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertRunEvent(ctx, RunEvent{RunID: id, Phase: "plan", Type: "log", Level: "info", Timestamp: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"CHG-1", "CHG-2"} {
+		_, err = db.InsertRunAnnotation(ctx, RunAnnotation{RunID: id, Hook: "cmdb", Name: "change", Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, content := range []string{`{"old":true}`, `{}`} {
+		_, err = db.InsertRunArtifact(ctx, RunArtifact{RunID: id, Name: "plan", ContentType: "application/json",
+			Content: []byte(content)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = db.InsertCredential(ctx, Credential{Workspace: "web", Name: "TF_VAR_token", Value: []byte{0x01}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := Filter{Key: "run_id", Operator: "=", Value: id}
+
+	phases, err := db.GetRunPhases(filter, ctx)
+	if err != nil || len(phases) != 1 || !phases[0].StartedAt.Equal(now) {
+		t.Fatalf("unexpected phases %v: %v", phases, err)
+	}
+
+	events, err := db.GetRunEvents(filter, ctx)
+	if err != nil || len(events) != 1 || !events[0].Timestamp.Equal(now) {
+		t.Fatalf("unexpected events %v: %v", events, err)
+	}
+
+	// annotations with the same name are replaced
+	annotations, err := db.GetRunAnnotations(filter, ctx)
+	if err != nil || len(annotations) != 1 || annotations[0].Value != "CHG-2" {
+		t.Fatalf("unexpected annotations %v: %v", annotations, err)
+	}
+
+	// artifacts with the same name are replaced
+	artifact, err := db.GetRunArtifact(filter, ctx)
+	if err != nil || string(artifact.Content) != `{}` {
+		t.Fatalf("unexpected artifact %v: %v", artifact, err)
+	}
+
+	credentials, err := db.GetCredentials(Filter{Key: "workspace", Operator: "=", Value: "web"}, ctx)
+	if err != nil || len(credentials) != 1 || credentials[0].Value[0] != 0x01 {
+		t.Fatalf("unexpected credentials %v: %v", credentials, err)
+	}
+}
+
+func TestSqliteAuditLog(t *testing.T) {
+	db := newSqliteTestDatabase(t)
+	ctx := context.TODO()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	_, err := db.InsertAuditEntry(ctx, AuditEntry{
+		CreatedAt: now,
+		Actor:     "alice",
+		Action:    "auth:user:delete",
+		Target:    "user:bob",
+		Before:    []byte(`{"name":"bob"}`),
+		RequestID: "request-1",
+		SourceIP:  "192.0.2.1",
+		Result:    AuditResultSuccess,
+		Status:    200,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.ListAuditEntries(Filter{Key: "actor", Operator: "=", Value: "alice"}, Page{}, ctx)
+	if err != nil || result.Total != 1 {
+		t.Fatalf("unexpected audit entries %v: %v", result, err)
+	}
+
+	entry := result.Items[0]
+	if !entry.CreatedAt.Equal(now) || string(entry.Before) != `{"name":"bob"}` || entry.After != nil ||
+		entry.Status != 200 {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+
+	// the audit log is append-only
+	for _, query := range []string{
+		"UPDATE audit_log SET actor = 'mallory'",
+		"DELETE FROM audit_log",
+	} {
+		_, err = db.database.ExecContext(ctx, query)
+		if err == nil || !strings.Contains(err.Error(), "append-only") {
+			t.Fatalf("expected '%s' to be rejected, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// localLocks are the workspace locks held by this instance.
+//
+// SQLite has no advisory locks. The database file is used by a single instance, so the locks are held in memory.
+// A lock record of a previous process (e.g. a crashed instance) is not held by anyone and gets replaced.
+type localLocks struct {
+	mu   sync.Mutex
+	held map[string]int // id of the run holding the lock by workspace
+}
+
+// localLock is a WorkspaceLock held by this instance.
+type localLock struct {
+	db        *SqlDatabase
+	workspace Workspace
+	runID     int
+}
+
+func newLocalLocks() *localLocks {
+	return &localLocks{held: make(map[string]int)}
+}
+
+// lockWorkspaceLocal locks the workspace for the given run inside this instance and records the lock holder.
+//
+// Returns a *WorkspaceLockedError if the workspace is already locked.
+func (db *SqlDatabase) lockWorkspaceLocal(
+	ctx context.Context, workspace Workspace, runID int, holder string,
+) (WorkspaceLock, error) {
+	db.locks.mu.Lock()
+	defer db.locks.mu.Unlock()
+
+	db.logger.Debug("lock workspace", "workspace", workspace.Name, "run", runID)
+
+	if lockedBy, ok := db.locks.held[workspace.Name]; ok {
+		return nil, &WorkspaceLockedError{Workspace: workspace.Name, RunID: lockedBy}
+	}
+
+	// record the lock holder. a record of a gone holder is replaced
+	_, err := db.Insert(recordWorkspaceLockQuery(), ctx, workspace.Name, runID, holder, os.Getpid())
+	if err != nil {
+		return nil, fmt.Errorf("failed to record workspace lock: %w", err)
+	}
+
+	db.locks.held[workspace.Name] = runID
+
+	return &localLock{db: db, workspace: workspace, runID: runID}, nil
+}
+
+// Release removes the lock record and releases the lock.
+// A lock that has been force unlocked in the meantime is ignored.
+func (l *localLock) Release(ctx context.Context) error {
+	l.db.locks.mu.Lock()
+	defer l.db.locks.mu.Unlock()
+
+	l.db.logger.Debug("unlock workspace", "workspace", l.workspace.Name, "run", l.runID)
+
+	if l.db.locks.held[l.workspace.Name] == l.runID {
+		delete(l.db.locks.held, l.workspace.Name)
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE workspace = $1 AND run_id = $2", TableNameWorkspaceLocks)
+
+	_, err := l.db.Update(query, ctx, l.workspace.Name, l.runID)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace lock record: %w", err)
+	}
+
+	return nil
+}
+
+// getWorkspaceLocksLocal returns all workspace locks from the database based on the filter.
+//
+// Held is false if the lock is not held by this instance.
+func (db *SqlDatabase) getWorkspaceLocksLocal(filter FilterExpr, ctx context.Context) ([]WorkspaceLockInfo, error) {
+	query := fmt.Sprintf("SELECT workspace, run_id, holder, backend_pid, acquired_at FROM %s", TableNameWorkspaceLocks)
+
+	locks, err := getReferences(db, query, filter, ctx,
+		func(rows *sql.Rows) (WorkspaceLockInfo, error) {
+			var lock WorkspaceLockInfo
+
+			err := rows.Scan(&lock.Workspace, &lock.RunID, &lock.Holder, &lock.BackendPID, &lock.AcquiredAt)
+			if err != nil {
+				return WorkspaceLockInfo{}, fmt.Errorf("failed to scan workspace lock: %w", err)
+			}
+
+			return lock, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	db.locks.mu.Lock()
+	defer db.locks.mu.Unlock()
+
+	for i, lock := range locks {
+		runID, ok := db.locks.held[lock.Workspace]
+		locks[i].Held = ok && runID == lock.RunID && lock.BackendPID == os.Getpid()
+	}
+
+	return locks, nil
+}
+
+// forceUnlockWorkspaceLocal releases the lock of the workspace, regardless of the holder.
+//
+// The executing process of the run is not stopped by that. Use it only for locks of gone holders.
+func (db *SqlDatabase) forceUnlockWorkspaceLocal(ctx context.Context, workspace string) (sql.Result, error) {
+	db.locks.mu.Lock()
+	defer db.locks.mu.Unlock()
+
+	delete(db.locks.held, workspace)
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE workspace = $1", TableNameWorkspaceLocks)
+
+	result, err := db.Update(query, ctx, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove workspace lock record: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrInTransaction is returned by operations that can't run inside a transaction. e.g. workspace locks, which are held
+// longer than the transaction.
+var ErrInTransaction = errors.New("not supported inside a transaction")
+
+// querier runs the queries of a SqlDatabase. It is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// querier returns the transaction if the database is used inside WithTx. Otherwise, the connection pool is returned.
+func (db *SqlDatabase) querier() querier {
+	if db.tx != nil {
+		return db.tx
+	}
+
+	return db.database
+}
+
+// WithTx runs fn inside a transaction.
+//
+// All operations of tx run inside the transaction. It is committed if fn returns nil and rolled back if fn returns an
+// error or panics. The error of fn is returned as it is. Calling WithTx on tx joins the running transaction.
+//
+// Don't use db inside fn. SQLite allows a single writing transaction only, so fn would wait for itself.
+// Workspace locks are held longer than a transaction and return ErrInTransaction inside fn.
+func (db *SqlDatabase) WithTx(ctx context.Context, fn func(tx Database) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+
+	sqlTx, err := db.database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	done := false
+
+	defer func() {
+		if done {
+			return
+		}
+
+		err := sqlTx.Rollback()
+		if err != nil {
+			db.logger.Error("failed to roll back transaction", "error", err)
+		}
+	}()
+
+	tx := *db
+	tx.tx = sqlTx
+
+	err = fn(&tx)
+	if err != nil {
+		return err
+	}
+
+	done = true
+
+	err = sqlTx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestWithTx(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO groups \(name\)`).
+		WithArgs("operators").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO user_groups \(user_id, group_id\)`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	err := db.WithTx(context.TODO(), func(tx Database) error {
+		_, err := tx.InsertGroup(context.TODO(), Group{Name: "operators"})
+		if err != nil {
+			return err
+		}
+
+		// nested transactions join the outer one
+		return tx.WithTx(context.TODO(), func(tx Database) error {
+			_, err := tx.InsertUserGroupReference(context.TODO(), UserGroupReference{UserID: 1, GroupID: 1})
+
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	errFailed := errors.New("failed")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO groups \(name\)`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	err := db.WithTx(context.TODO(), func(tx Database) error {
+		_, err := tx.InsertGroup(context.TODO(), Group{Name: "operators"})
+		if err != nil {
+			return err
+		}
+
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	// a panic rolls back as well
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to be passed on")
+			}
+		}()
+
+		_ = db.WithTx(context.TODO(), func(_ Database) error {
+			panic("failed")
+		})
+	}()
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithTxUnsupported(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	db := SqlDatabase{
+		database: d,
+		logger:   logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := db.WithTx(context.TODO(), func(tx Database) error {
+		_, err := tx.LockWorkspace(context.TODO(), Workspace{ID: 1, Name: "web"}, 1, "host-1")
+		if !errors.Is(err, ErrInTransaction) {
+			t.Fatalf("expected ErrInTransaction, got %v", err)
+		}
+
+		return tx.Close()
+	})
+	if !errors.Is(err, ErrInTransaction) {
+		t.Fatalf("expected ErrInTransaction, got %v", err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
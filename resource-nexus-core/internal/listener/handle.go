@@ -4,9 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/tbauriedel/resource-nexus-core/internal/database"
 	"github.com/tbauriedel/resource-nexus-core/internal/listener/routes"
-	"github.com/tbauriedel/resource-nexus-core/internal/logging"
 )
 
 // AddRoute adds a new route to the listener.
@@ -25,13 +23,8 @@ func (l *Listener) AddRoute(method, url string, handler http.HandlerFunc) {
 
 // AddRoutesToListener adds all routes to the listener.
 //
-// Routes are defined in the 'routes' package.
-func (l *Listener) AddRoutesToListener(db database.Database, logger *logging.Logger) {
-	r := routes.Routes{
-		DB:     db,
-		Logger: logger,
-	}
-
+// Routes are defined in the 'routes' package. r holds the dependencies that are used by the route handlers.
+func (l *Listener) AddRoutesToListener(r routes.Routes) {
 	for _, route := range r.Get() {
 		l.AddRoute(route.Method, route.Path, route.HandlerFunc)
 	}
@@ -1,18 +1,26 @@
 package listener
 
 import (
-	"context"
+	"crypto/rand"
 	"fmt"
 	"net"
 	"net/http"
+	"regexp"
 	"sync"
 
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
 	"github.com/tbauriedel/resource-nexus-core/internal/authentication"
 	"github.com/tbauriedel/resource-nexus-core/internal/database"
 	"github.com/tbauriedel/resource-nexus-core/internal/logging"
 	"golang.org/x/time/rate"
 )
 
+// headerRequestID is the header that holds the id of a request. An id sent by the client is kept.
+const headerRequestID = "X-Request-ID"
+
+// requestIDPattern matches the ids of requests that are accepted from clients.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`) //nolint:gochecknoglobals
+
 // Option for Listener.
 type Option func(*Listener)
 
@@ -27,12 +35,6 @@ type userRateLimiter struct {
 	rateLimitBucketSize int
 }
 
-type contextKey string
-
-const (
-	storedUserKey contextKey = "storedUser"
-)
-
 // WithMiddleWare adds middleware to the listener.
 func WithMiddleWare(m Middleware) Option {
 	return func(l *Listener) {
@@ -115,7 +117,7 @@ func MiddlewareAuthentication(db database.Database, logger *logging.Logger) Midd
 			logger.Debug(fmt.Sprintf("authentication for user '%s' successful", username))
 
 			// store the user inside the request context
-			ctx := context.WithValue(r.Context(), storedUserKey, storedUser)
+			ctx := authentication.ContextWithUser(r.Context(), storedUser)
 
 			// hand over to the next handler
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -128,7 +130,7 @@ func MiddlewareAuthorization(logger *logging.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// get stored user from request context. Is saved inside the MiddlewareAuthentication()
-			storedUser, ok := r.Context().Value(storedUserKey).(*authentication.User)
+			storedUser, ok := authentication.UserFromContext(r.Context())
 			if !ok {
 				logger.Warn("authorization failed: no stored user found in request context")
 				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
@@ -161,6 +163,93 @@ func MiddlewareAuthorization(logger *logging.Logger) Middleware {
 	}
 }
 
+// MiddlewareAudit wraps the http.Handler with audit middleware.
+//
+// Each request gets an id, which is returned in the header X-Request-ID. A valid id sent by the client is kept, so
+// requests can be correlated with other systems.
+// Api calls that change something (all methods except GET, HEAD and OPTIONS) are recorded with the recorder. Also
+// rejected and failed calls. The action is the permission of the path. Handlers describe the changed target with
+// audit.Describe.
+// Needs to be added after the MiddlewareAuthentication, so the authenticated user is recorded as actor. Calls that
+// fail the authentication have no actor and are only logged by the MiddlewareAuthentication.
+func MiddlewareAudit(recorder *audit.Recorder) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request := &audit.Request{
+				ID:       requestID(r),
+				SourceIP: sourceIP(r),
+			}
+
+			w.Header().Set(headerRequestID, request.ID)
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+			// hand over to the next handler
+			next.ServeHTTP(sw, r.WithContext(audit.ContextWithRequest(r.Context(), request)))
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return
+			}
+
+			action, ok := authentication.GetPermissionForPath(r.URL.Path)
+			if !ok {
+				action = r.Method + " " + r.URL.Path
+			}
+
+			recorder.Record(r.Context(), request.Entry(action, sw.status))
+		})
+	}
+}
+
+// requestID returns the id sent by the client or a new random id.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(headerRequestID)
+	if requestIDPattern.MatchString(id) {
+		return id
+	}
+
+	return rand.Text()
+}
+
+// sourceIP returns the ip of the client. Headers of proxies (e.g. X-Forwarded-For) are not trusted.
+func sourceIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// statusWriter is a http.ResponseWriter that remembers the status of the response.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. Used by http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // MiddlewareGlobalRateLimiter wraps the http.Handler with global rate limiting middleware.
 //
 // Rate limiting is token-based. Each request consumes a token.
@@ -1,10 +1,17 @@
 package listener
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/authentication"
 	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
 	"github.com/tbauriedel/resource-nexus-core/internal/logging"
 )
 
@@ -30,3 +37,234 @@ func TestWithMiddleWare(t *testing.T) {
 		t.Fatal("applied middlewares should be equal to one")
 	}
 }
+
+// newAuthTestDatabase returns a MemoryDatabase with the user 'dummy' that is allowed to get runs.
+func newAuthTestDatabase(t *testing.T) *database.MemoryDatabase {
+	t.Helper()
+
+	db := database.NewMemoryDatabase()
+	ctx := context.TODO()
+
+	hash := authentication.HashPasswordString("secret", config.HashingParams{
+		Iterations:   1,
+		MemoryCost:   16 * 1024,
+		ThreadsCount: 1,
+		KeyLength:    32,
+		SaltLength:   16,
+	})
+
+	_, err := db.InsertUser(ctx, database.User{Name: "dummy", PasswordHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroup(ctx, database.Group{Name: "viewers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUser(database.Filter{Key: "name", Operator: "=", Value: "dummy"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := db.GetGroup(database.Filter{Key: "name", Operator: "=", Value: "viewers"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permission, err := db.GetPermission(database.LogicalFilter{Operator: "AND", Filters: []database.FilterExpr{
+		database.Filter{Key: "resource", Operator: "=", Value: "run"},
+		database.Filter{Key: "action", Operator: "=", Value: "get"},
+	}}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertUserGroupReference(ctx, database.UserGroupReference{UserID: user.ID, GroupID: group.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroupPermission(ctx,
+		database.GroupPermissionReference{GroupID: group.ID, PermissionID: permission.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestMiddlewareAuthentication(t *testing.T) {
+	log := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := newAuthTestDatabase(t)
+
+	handler := MiddlewareAuthentication(db, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := authentication.UserFromContext(r.Context())
+		if !ok || user.Name != "dummy" || !slices.Contains(user.Permissions, "provisioning:run:get") {
+			t.Errorf("authenticated user not stored inside the request context: %v", user)
+		}
+	}))
+
+	tests := map[string]struct {
+		username string
+		password string
+		status   int
+	}{
+		"valid":          {username: "dummy", password: "secret", status: http.StatusOK},
+		"wrong password": {username: "dummy", password: "wrong", status: http.StatusUnauthorized},
+		"unknown user":   {username: "unknown", password: "secret", status: http.StatusUnauthorized},
+		"no credentials": {status: http.StatusUnauthorized},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/provisioning/run/list", nil)
+			if test.username != "" {
+				r.SetBasicAuth(test.username, test.password)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAuthorization(t *testing.T) {
+	log := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := newAuthTestDatabase(t)
+
+	handler := MiddlewareAuthentication(db, log)(MiddlewareAuthorization(log)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	))
+
+	tests := map[string]int{
+		"/provisioning/run/list": http.StatusOK,
+		"/provisioning/run/add":  http.StatusForbidden,
+		"/unknown":               http.StatusForbidden,
+	}
+
+	for path, status := range tests {
+		t.Run(path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.SetBasicAuth("dummy", "secret")
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != status {
+				t.Fatalf("expected status %d, got %d", status, w.Code)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAudit(t *testing.T) {
+	log := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "error"})
+	db := newAuthTestDatabase(t)
+
+	recorder, err := audit.NewRecorder(db, config.Audit{}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := MiddlewareAuthentication(db, log)(MiddlewareAudit(recorder)(MiddlewareAuthorization(log)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit.Describe(r.Context(), audit.Target("run", 1), nil, map[string]string{"status": "canceled"})
+		}),
+	)))
+
+	tests := map[string]struct {
+		method    string
+		path      string
+		requestID string
+		recorded  bool
+		result    database.AuditResult
+		status    int
+		target    string
+	}{
+		"get is not recorded": {method: http.MethodGet, path: "/provisioning/run/list"},
+		"allowed change": {
+			method: http.MethodPost, path: "/provisioning/run/cancel", requestID: "client-id.1",
+			recorded: true, result: database.AuditResultSuccess, status: http.StatusOK, target: "run:1",
+		},
+		"forbidden change": {
+			method: http.MethodPost, path: "/provisioning/run/add", requestID: "invalid id",
+			recorded: true, result: database.AuditResultFailure, status: http.StatusForbidden,
+		},
+	}
+
+	// the user is allowed to cancel runs
+	group, err := db.GetGroup(database.Filter{Key: "name", Operator: "=", Value: "viewers"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permission, err := db.GetPermission(database.LogicalFilter{Operator: "AND", Filters: []database.FilterExpr{
+		database.Filter{Key: "resource", Operator: "=", Value: "run"},
+		database.Filter{Key: "action", Operator: "=", Value: "cancel"},
+	}}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.InsertGroupPermission(context.TODO(),
+		database.GroupPermissionReference{GroupID: group.ID, PermissionID: permission.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			r.RemoteAddr = "192.0.2.1:1234"
+			r.SetBasicAuth("dummy", "secret")
+
+			if test.requestID != "" {
+				r.Header.Set(headerRequestID, test.requestID)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			id := w.Header().Get(headerRequestID)
+			if !requestIDPattern.MatchString(id) {
+				t.Fatalf("expected a valid request id, got '%s'", id)
+			}
+
+			if test.requestID == "client-id.1" && id != test.requestID {
+				t.Fatalf("expected the request id of the client, got '%s'", id)
+			}
+
+			entries, err := db.ListAuditEntries(database.Filter{Key: "request_id", Operator: "=", Value: id},
+				database.Page{}, context.TODO())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.recorded {
+				if entries.Total != 0 {
+					t.Fatalf("expected no entry, got %v", entries.Items)
+				}
+
+				return
+			}
+
+			if entries.Total != 1 {
+				t.Fatalf("expected one entry, got %v", entries.Items)
+			}
+
+			entry := entries.Items[0]
+
+			action, _ := authentication.GetPermissionForPath(test.path)
+			if entry.Actor != "dummy" || entry.Action != action || entry.SourceIP != "192.0.2.1" ||
+				entry.Result != test.result || entry.Status != test.status || entry.Target != test.target {
+				t.Fatalf("unexpected entry: %+v", entry)
+			}
+		})
+	}
+}
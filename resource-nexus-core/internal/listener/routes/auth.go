@@ -3,12 +3,80 @@ package routes
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
 	"github.com/tbauriedel/resource-nexus-core/internal/database"
 )
 
+// UserUpdateRequest is the request body to update a user. Only the given fields are changed.
+type UserUpdateRequest struct {
+	Name         string  `json:"name"`
+	NewName      *string `json:"new_name"`
+	PasswordHash *string `json:"password_hash"`
+	IsAdmin      *bool   `json:"is_admin"`
+}
+
+// UserResponse is a user as it is returned by the api. The password hash is never returned.
+type UserResponse struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	IsAdmin bool   `json:"is_admin"`
+}
+
+// GroupUpdateRequest is the request body to rename a group.
+type GroupUpdateRequest struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name"`
+}
+
+// UserList returns the users without their password hashes. The list can be filtered, paginated and ordered, see
+// parseList.
+func (routes *Routes) UserList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameUsers)
+	if err != nil {
+		writeListError(w, err, "users", routes.Logger)
+
+		return
+	}
+
+	users, err := routes.DB.ListUsers(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "users", routes.Logger)
+
+		return
+	}
+
+	response := database.PageResult[UserResponse]{Total: users.Total, NextCursor: users.NextCursor}
+
+	for _, user := range users.Items {
+		response.Items = append(response.Items, UserResponse{ID: user.ID, Name: user.Name, IsAdmin: user.IsAdmin})
+	}
+
+	writePage(w, r, response, fields, routes.Logger)
+}
+
+// GroupList returns the groups. The list can be filtered, paginated and ordered, see parseList.
+func (routes *Routes) GroupList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameGroups)
+	if err != nil {
+		writeListError(w, err, "groups", routes.Logger)
+
+		return
+	}
+
+	groups, err := routes.DB.ListGroups(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "groups", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, groups, fields, routes.Logger)
+}
+
 func (routes *Routes) UserAdd(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -22,6 +90,9 @@ func (routes *Routes) UserAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.Describe(r.Context(), audit.Target("user", user.Name), nil,
+		UserResponse{Name: user.Name, IsAdmin: user.IsAdmin})
+
 	err = addEntity(
 		w, r,
 		user,
@@ -52,6 +123,8 @@ func (routes *Routes) GroupAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.Describe(r.Context(), audit.Target("group", group.Name), nil, group)
+
 	err = addEntity(
 		w, r,
 		group,
@@ -79,191 +152,507 @@ func (routes *Routes) AddUserToGroup(w http.ResponseWriter, r *http.Request) {
 			http.StatusBadRequest,
 		)
 		routes.Logger.Error("failed to decode user group reference from body", "error", err)
+
+		return
 	}
 
-	// get user by name
-	user, err := routes.DB.GetUser(database.Filter{
-		Key:      "name",
-		Operator: "=",
-		Value:    userGroupRef.Username,
-	}, r.Context())
+	audit.Describe(r.Context(), audit.Target("group", userGroupRef.GroupName), nil,
+		map[string]string{"user": userGroupRef.Username})
+
+	// the checks and the insert run inside a transaction. user and group can't be changed in between
+	err = routes.DB.WithTx(r.Context(), func(tx database.Database) error {
+		// get user by name
+		user, err := tx.GetUser(database.Filter{
+			Key:      "name",
+			Operator: "=",
+			Value:    userGroupRef.Username,
+		}, r.Context())
+		if err != nil {
+			return &requestError{status: http.StatusBadRequest, message: "user not found", err: err}
+		}
+
+		userGroupRef.UserID = user.ID
+
+		// get group by name
+		group, err := tx.GetGroup(database.Filter{
+			Key:      "name",
+			Operator: "=",
+			Value:    userGroupRef.GroupName,
+		}, r.Context())
+		if err != nil {
+			return &requestError{status: http.StatusBadRequest, message: "group not found", err: err}
+		}
+
+		userGroupRef.GroupID = group.ID
+
+		// check if user is already in group
+		_, err = tx.GetUserGroupReference(database.LogicalFilter{
+			Operator: "AND",
+			Filters: []database.FilterExpr{
+				database.Filter{
+					Key:      "group_id",
+					Operator: "=",
+					Value:    group.ID,
+				},
+				database.Filter{
+					Key:      "user_id",
+					Operator: "=",
+					Value:    user.ID,
+				},
+			},
+		}, r.Context())
+		if err == nil {
+			return &requestError{status: http.StatusBadRequest, message: "user already in group"}
+		}
+
+		_, err = tx.InsertUserGroupReference(r.Context(), userGroupRef)
+		if err != nil {
+			return &requestError{
+				status:  http.StatusInternalServerError,
+				message: "failed to insert user group reference",
+				err:     err,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, err, "failed to add user to group", routes.Logger)
+
+		return
+	}
+
+	_, _ = w.Write([]byte(BuildResponseMessage("user group reference added")))
+}
+
+func (routes *Routes) AddPermissionToGroup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	
+	// decode permission group from json body
+	permissionGroup, err := decodeJson[database.GroupPermissionReference](r)
 	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("user not found"),
+			BuildResponseMessage("invalid json"),
 			http.StatusBadRequest,
 		)
-		routes.Logger.Error("failed to get user", "error", err)
+		routes.Logger.Error("failed to decode permission group from body", "error", err)
 
 		return
 	}
 
-	userGroupRef.UserID = user.ID
+	audit.Describe(r.Context(), audit.Target("group", permissionGroup.GroupName), nil,
+		map[string]string{"permission": permissionGroup.Permission})
+
+	// the checks and the insert run inside a transaction. group and permission can't be changed in between
+	err = routes.DB.WithTx(r.Context(), func(tx database.Database) error {
+		// check if group exists. get group by name
+		group, err := tx.GetGroup(database.Filter{
+			Key:      "name",
+			Operator: "=",
+			Value:    permissionGroup.GroupName,
+		}, r.Context())
+		if err != nil {
+			return &requestError{status: http.StatusBadRequest, message: "group not found", err: err}
+		}
+
+		permissionGroup.GroupID = group.ID
+
+		// check if permission exists
+		permission, err := getPermission(tx, permissionGroup.Permission, r.Context())
+		if err != nil {
+			return &requestError{status: http.StatusBadRequest, message: "permission not found", err: err}
+		}
+
+		permissionGroup.PermissionID = permission.ID
+
+		// check if group already has permission
+		_, err = tx.GetGroupPermission(database.LogicalFilter{
+			Operator: "AND",
+			Filters: []database.FilterExpr{
+				database.Filter{
+					Key:      "group_id",
+					Operator: "=",
+					Value:    group.ID,
+				},
+				database.Filter{
+					Key:      "permission_id",
+					Operator: "=",
+					Value:    permission.ID,
+				},
+			},
+		}, r.Context())
+		if err == nil {
+			return &requestError{status: http.StatusBadRequest, message: "permission already assigned to group"}
+		}
+
+		// add permission to group
+		_, err = tx.InsertGroupPermission(r.Context(), permissionGroup)
+		if err != nil {
+			return &requestError{
+				status:  http.StatusInternalServerError,
+				message: "failed to insert permission group reference",
+				err:     err,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, err, "failed to add permission to group", routes.Logger)
 
-	// get group by name
-	group, err := routes.DB.GetGroup(database.Filter{
-		Key:      "name",
-		Operator: "=",
-		Value:    userGroupRef.GroupName,
-	}, r.Context())
+		return
+	}
+
+	_, _ = w.Write([]byte(BuildResponseMessage("permission group reference added")))
+}
+
+// UserUpdate renames a user, changes its password hash or admin flag.
+func (routes *Routes) UserUpdate(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[UserUpdateRequest](r)
 	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("group not found"),
+			BuildResponseMessage("invalid json"),
 			http.StatusBadRequest,
 		)
-		routes.Logger.Error("failed to get group", "error", err)
+		routes.Logger.Error("failed to decode user update request from body", "error", err)
 
 		return
 	}
 
-	userGroupRef.GroupID = group.ID
+	user, err := routes.DB.GetUser(database.Filter{Key: "name", Operator: "=", Value: request.Name}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("user not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get user", "error", err)
 
-	// check if user is already in group
-	_, err = routes.DB.GetUserGroupReference(database.LogicalFilter{
-		Operator: "AND",
-		Filters: []database.FilterExpr{
-			database.Filter{
-				Key:      "group_id",
-				Operator: "=",
-				Value:    group.ID,
-			},
-			database.Filter{
-				Key:      "user_id",
-				Operator: "=",
-				Value:    user.ID,
-			},
-		},
-	}, r.Context())
-	if err == nil {
+		return
+	}
+
+	before := UserResponse{ID: user.ID, Name: user.Name, IsAdmin: user.IsAdmin}
+	audit.Describe(r.Context(), audit.Target("user", request.Name), before, nil)
+
+	if request.NewName != nil && *request.NewName != user.Name {
+		_, err = routes.DB.GetUser(database.Filter{Key: "name", Operator: "=", Value: *request.NewName}, r.Context())
+		if err == nil || *request.NewName == "" {
+			http.Error(w,
+				BuildResponseMessage("invalid name or user with the same name already exists"),
+				http.StatusBadRequest,
+			)
+
+			return
+		}
+
+		user.Name = *request.NewName
+	}
+
+	if request.PasswordHash != nil {
+		user.PasswordHash = *request.PasswordHash
+	}
+
+	if request.IsAdmin != nil {
+		user.IsAdmin = *request.IsAdmin
+	}
+
+	// the password hash is never recorded. only the fact that it changed
+	after := map[string]any{
+		"id":               user.ID,
+		"name":             user.Name,
+		"is_admin":         user.IsAdmin,
+		"password_changed": request.PasswordHash != nil,
+	}
+	audit.Describe(r.Context(), audit.Target("user", request.Name), before, after)
+
+	_, err = routes.DB.UpdateUser(r.Context(), user)
+	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("user already in group"),
-			http.StatusBadRequest,
+			BuildResponseMessage("failed to update user"),
+			http.StatusInternalServerError,
 		)
-		routes.Logger.Error("user already in group", "error", err)
+		routes.Logger.Error("failed to update user", "error", err)
 
 		return
 	}
 
-	_, err = routes.DB.InsertUserGroupReference(r.Context(), userGroupRef)
+	routes.Logger.Info("user updated", "user", request.Name)
+
+	_, _ = w.Write([]byte(BuildResponseMessage("user updated")))
+}
+
+// UserDelete deletes the user given by the query parameter 'name'. The user is removed from all groups.
+func (routes *Routes) UserDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	user, err := routes.DB.GetUser(database.Filter{Key: "name", Operator: "=", Value: name}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("user not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get user", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("user", name), UserResponse{ID: user.ID, Name: user.Name,
+		IsAdmin: user.IsAdmin}, nil)
+
+	_, err = routes.DB.DeleteUser(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("failed to insert user group reference"),
+			BuildResponseMessage("failed to delete user"),
 			http.StatusInternalServerError,
 		)
-		routes.Logger.Error("failed to insert user group reference", "error", err)
+		routes.Logger.Error("failed to delete user", "error", err)
+
+		return
 	}
 
-	_, _ = w.Write([]byte(BuildResponseMessage("user group reference added")))
+	routes.Logger.Info("user deleted", "user", name)
+
+	_, _ = w.Write([]byte(BuildResponseMessage("user deleted")))
 }
 
-func (routes *Routes) AddPermissionToGroup(w http.ResponseWriter, r *http.Request) {
+// GroupUpdate renames a group.
+func (routes *Routes) GroupUpdate(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	
-	// decode permission group from json body
-	permissionGroup, err := decodeJson[database.GroupPermissionReference](r)
+
+	request, err := decodeJson[GroupUpdateRequest](r)
 	if err != nil {
 		http.Error(w,
 			BuildResponseMessage("invalid json"),
 			http.StatusBadRequest,
 		)
-		routes.Logger.Error("failed to decode permission group from body", "error", err)
+		routes.Logger.Error("failed to decode group update request from body", "error", err)
+
+		return
+	}
+
+	group, err := routes.DB.GetGroup(database.Filter{Key: "name", Operator: "=", Value: request.Name}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("group not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get group", "error", err)
+
+		return
+	}
+
+	_, err = routes.DB.GetGroup(database.Filter{Key: "name", Operator: "=", Value: request.NewName}, r.Context())
+	if err == nil || request.NewName == "" {
+		http.Error(w,
+			BuildResponseMessage("invalid name or group with the same name already exists"),
+			http.StatusBadRequest,
+		)
+
+		return
+	}
+
+	before := group
+	group.Name = request.NewName
+
+	audit.Describe(r.Context(), audit.Target("group", request.Name), before, group)
+
+	_, err = routes.DB.UpdateGroup(r.Context(), group)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to update group"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to update group", "error", err)
+
+		return
+	}
+
+	_, _ = w.Write([]byte(BuildResponseMessage("group updated")))
+}
+
+// GroupDelete deletes the group given by the query parameter 'name'.
+// The members of the group lose the permissions granted by it.
+func (routes *Routes) GroupDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	group, err := routes.DB.GetGroup(database.Filter{Key: "name", Operator: "=", Value: name}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("group not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get group", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("group", name), group, nil)
+
+	_, err = routes.DB.DeleteGroup(r.Context(), group.ID)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to delete group"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to delete group", "error", err)
+
+		return
+	}
+
+	routes.Logger.Info("group deleted", "group", name)
+
+	_, _ = w.Write([]byte(BuildResponseMessage("group deleted")))
+}
+
+// RemoveUserFromGroup removes a user from a group based on the query parameters 'username' and 'group_name'.
+func (routes *Routes) RemoveUserFromGroup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	audit.Describe(r.Context(), audit.Target("group", query.Get("group_name")),
+		map[string]string{"user": query.Get("username")}, nil)
+
+	user, err := routes.DB.GetUser(database.Filter{
+		Key:      "name",
+		Operator: "=",
+		Value:    query.Get("username"),
+	}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("user not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get user", "error", err)
 
 		return
 	}
 
-	// check if group exists. get group by name
 	group, err := routes.DB.GetGroup(database.Filter{
 		Key:      "name",
 		Operator: "=",
-		Value:    permissionGroup.GroupName,
+		Value:    query.Get("group_name"),
 	}, r.Context())
 	if err != nil {
 		http.Error(w,
 			BuildResponseMessage("group not found"),
-			http.StatusBadRequest,
+			http.StatusNotFound,
 		)
 		routes.Logger.Error("failed to get group", "error", err)
 
 		return
 	}
 
-	permissionGroup.GroupID = group.ID
-
-	// check if permission exists
-	// split permission string into category, resource and action
-	splitted := strings.Split(permissionGroup.Permission, ":")
+	result, err := routes.DB.DeleteUserGroupReference(r.Context(),
+		database.UserGroupReference{UserID: user.ID, GroupID: group.ID})
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to delete user group reference"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to delete user group reference", "error", err)
 
-	// prepare filter
-	filter := database.LogicalFilter{
-		Operator: "AND",
-		Filters: []database.FilterExpr{
-			database.Filter{
-				Key:      "category",
-				Operator: "=",
-				Value:    splitted[0],
-			},
-			database.Filter{
-				Key:      "resource",
-				Operator: "=",
-				Value:    splitted[1],
-			},
-			database.Filter{
-				Key:      "action",
-				Operator: "=",
-				Value:    splitted[2],
-			},
-		},
+		return
 	}
 
-	permission, err := routes.DB.GetPermission(filter, r.Context())
-	if err != nil {
+	if removed, _ := result.RowsAffected(); removed == 0 {
 		http.Error(w,
-			BuildResponseMessage("permission not found"),
-			http.StatusBadRequest,
+			BuildResponseMessage("user is not in group"),
+			http.StatusNotFound,
 		)
-		routes.Logger.Error("failed to get permission", "error", err)
 
 		return
 	}
 
-	permissionGroup.PermissionID = permission.ID
+	_, _ = w.Write([]byte(BuildResponseMessage("user group reference removed")))
+}
 
-	// check if group already has permission
-	_, err = routes.DB.GetGroupPermission(database.LogicalFilter{
-		Operator: "AND",
-		Filters: []database.FilterExpr{
-			database.Filter{
-				Key:      "group_id",
-				Operator: "=",
-				Value:    group.ID,
-			},
-			database.Filter{
-				Key:      "permission_id",
-				Operator: "=",
-				Value:    permission.ID,
-			},
-		},
+// RemovePermissionFromGroup removes a permission from a group based on the query parameters 'group_name' and
+// 'permission'.
+func (routes *Routes) RemovePermissionFromGroup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	audit.Describe(r.Context(), audit.Target("group", query.Get("group_name")),
+		map[string]string{"permission": query.Get("permission")}, nil)
+
+	group, err := routes.DB.GetGroup(database.Filter{
+		Key:      "name",
+		Operator: "=",
+		Value:    query.Get("group_name"),
 	}, r.Context())
-	if err == nil {
+	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("permission already assigned to group"),
-			http.StatusBadRequest,
+			BuildResponseMessage("group not found"),
+			http.StatusNotFound,
+		)
+		routes.Logger.Error("failed to get group", "error", err)
+
+		return
+	}
+
+	permission, err := getPermission(routes.DB, query.Get("permission"), r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("permission not found"),
+			http.StatusNotFound,
 		)
-		routes.Logger.Error("permission already assigned to group", "error", err)
+		routes.Logger.Error("failed to get permission", "error", err)
 
 		return
 	}
 
-	// add permission to group
-	_, err = routes.DB.InsertGroupPermission(r.Context(), permissionGroup)
+	result, err := routes.DB.DeleteGroupPermission(r.Context(),
+		database.GroupPermissionReference{GroupID: group.ID, PermissionID: permission.ID})
 	if err != nil {
 		http.Error(w,
-			BuildResponseMessage("failed to insert permission group reference"),
+			BuildResponseMessage("failed to delete permission group reference"),
 			http.StatusInternalServerError,
 		)
-		routes.Logger.Error("failed to insert permission group reference", "error", err)
+		routes.Logger.Error("failed to delete permission group reference", "error", err)
 
 		return
 	}
 
-	_, _ = w.Write([]byte(BuildResponseMessage("permission group reference added")))
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		http.Error(w,
+			BuildResponseMessage("permission not assigned to group"),
+			http.StatusNotFound,
+		)
+
+		return
+	}
+
+	_, _ = w.Write([]byte(BuildResponseMessage("permission group reference removed")))
+}
+
+// getPermission returns the permission of a permission string in the format 'category:resource:action'.
+func getPermission(db database.Database, permission string, ctx context.Context) (database.Permission, error) {
+	// split permission string into category, resource and action
+	splitted := strings.Split(permission, ":")
+	if len(splitted) != 3 { //nolint:mnd
+		return database.Permission{}, fmt.Errorf("invalid permission '%s'", permission)
+	}
+
+	// prepare filter
+	filter := database.LogicalFilter{
+		Operator: "AND",
+		Filters: []database.FilterExpr{
+			database.Filter{
+				Key:      "category",
+				Operator: "=",
+				Value:    splitted[0],
+			},
+			database.Filter{
+				Key:      "resource",
+				Operator: "=",
+				Value:    splitted[1],
+			},
+			database.Filter{
+				Key:      "action",
+				Operator: "=",
+				Value:    splitted[2],
+			},
+		},
+	}
+
+	return db.GetPermission(filter, ctx) //nolint:wrapcheck
 }
@@ -0,0 +1,416 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func newTestRoutes() *Routes {
+	return &Routes{
+		DB:     database.NewMemoryDatabase(),
+		Logger: logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}),
+	}
+}
+
+// serve sends the request with the json body to the handler and returns the response.
+func serve(handler http.HandlerFunc, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler(w, r)
+
+	return w
+}
+
+func TestUserAdd(t *testing.T) {
+	routes := newTestRoutes()
+
+	w := serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "dummy", "password_hash": "hash"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	user, err := routes.DB.GetUser(database.Filter{Key: "name", Operator: "=", Value: "dummy"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.PasswordHash != "hash" || user.IsAdmin {
+		t.Fatalf("wrong user stored: %v", user)
+	}
+
+	// names are unique
+	w = serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "dummy", "password_hash": "other"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": `)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUserList(t *testing.T) {
+	routes := newTestRoutes()
+
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "alice", "password_hash": "hash", "is_admin": true}`)
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "bob", "password_hash": "hash"}`)
+
+	w := serve(routes.UserList, http.MethodGet, "/auth/user/list?name=like:b%25", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if w.Body.String() != `[{"id":2,"name":"bob","is_admin":false}]` {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+
+	// password hashes can't be searched
+	w = serve(routes.UserList, http.MethodGet, "/auth/user/list?password_hash=like:h%25", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGroupList(t *testing.T) {
+	routes := newTestRoutes()
+
+	for _, name := range []string{"operators", "viewers", "admins"} {
+		serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "`+name+`"}`)
+	}
+
+	w := serve(routes.GroupList, http.MethodGet, "/auth/group/list?name=not:eq:viewers&order=name", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	if w.Body.String() != `[{"id":3,"name":"admins"},{"id":1,"name":"operators"}]` {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+}
+
+func TestGroupAdd(t *testing.T) {
+	routes := newTestRoutes()
+
+	w := serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "operators"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	_, err := routes.DB.GetGroup(database.Filter{Key: "name", Operator: "=", Value: "operators"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "operators"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestAddUserToGroup(t *testing.T) {
+	routes := newTestRoutes()
+
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "dummy", "password_hash": "hash"}`)
+	serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "operators"}`)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"username": "dummy", "group_name": "operators"}`, http.StatusOK},
+		{`{"username": "dummy", "group_name": "operators"}`, http.StatusBadRequest}, // already in group
+		{`{"username": "unknown", "group_name": "operators"}`, http.StatusBadRequest},
+		{`{"username": "dummy", "group_name": "unknown"}`, http.StatusBadRequest},
+		{`{"username": `, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		w := serve(routes.AddUserToGroup, http.MethodPost, "/auth/usergroup/add", test.body)
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.body, w.Code)
+		}
+
+		// a single response is written
+		if strings.Count(w.Body.String(), `"message"`) > 1 {
+			t.Fatalf("expected a single response for %s, got %s", test.body, w.Body.String())
+		}
+	}
+
+	refs, err := routes.DB.GetUserGroupReferences(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refs) != 1 {
+		t.Fatalf("expected 1 user group reference, got %v", refs)
+	}
+}
+
+func TestAddPermissionToGroup(t *testing.T) {
+	routes := newTestRoutes()
+
+	serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "operators"}`)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"group_name": "operators", "permission": "provisioning:run:add"}`, http.StatusOK},
+		{`{"group_name": "operators", "permission": "provisioning:run:add"}`, http.StatusBadRequest}, // already assigned
+		{`{"group_name": "operators", "permission": "provisioning:run:delete"}`, http.StatusBadRequest},
+		{`{"group_name": "unknown", "permission": "provisioning:run:add"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		w := serve(routes.AddPermissionToGroup, http.MethodPost, "/auth/grouppermission/add", test.body)
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.body, w.Code)
+		}
+	}
+
+	refs, err := routes.DB.GetGroupPermissions(nil, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refs) != 1 || refs[0].PermissionID != 8 {
+		t.Fatalf("expected the permission provisioning:run:add to be assigned, got %v", refs)
+	}
+}
+
+// newTestRoutesWithMember returns routes with the user 'dummy' that is member of the group 'operators'.
+// The group grants the permission provisioning:run:add.
+func newTestRoutesWithMember(t *testing.T) *Routes {
+	t.Helper()
+
+	routes := newTestRoutes()
+
+	for handler, request := range map[string]struct {
+		handler http.HandlerFunc
+		body    string
+	}{
+		"user":  {routes.UserAdd, `{"name": "dummy", "password_hash": "hash"}`},
+		"group": {routes.GroupAdd, `{"name": "operators"}`},
+	} {
+		w := serve(request.handler, http.MethodPost, "/auth/"+handler+"/add", request.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("failed to add %s: %s", handler, w.Body.String())
+		}
+	}
+
+	w := serve(routes.AddUserToGroup, http.MethodPost, "/auth/usergroup/add",
+		`{"username": "dummy", "group_name": "operators"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to add user to group: %s", w.Body.String())
+	}
+
+	w = serve(routes.AddPermissionToGroup, http.MethodPost, "/auth/grouppermission/add",
+		`{"group_name": "operators", "permission": "provisioning:run:add"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to add permission to group: %s", w.Body.String())
+	}
+
+	return routes
+}
+
+func TestUserUpdate(t *testing.T) {
+	routes := newTestRoutes()
+
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "dummy", "password_hash": "hash"}`)
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "other", "password_hash": "hash"}`)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"name": "dummy", "new_name": "hercules", "is_admin": true}`, http.StatusOK},
+		{`{"name": "hercules", "password_hash": "new-hash"}`, http.StatusOK},
+		{`{"name": "hercules", "new_name": "other"}`, http.StatusBadRequest}, // name already exists
+		{`{"name": "hercules", "new_name": ""}`, http.StatusBadRequest},
+		{`{"name": "dummy", "is_admin": false}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := serve(routes.UserUpdate, http.MethodPatch, "/auth/user/update", test.body)
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.body, w.Code)
+		}
+	}
+
+	user, err := routes.DB.GetUser(database.Filter{Key: "name", Operator: "=", Value: "hercules"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fields that are not given are kept
+	if user.PasswordHash != "new-hash" || !user.IsAdmin {
+		t.Fatalf("wrong user stored: %v", user)
+	}
+}
+
+func TestUserUpdateAudit(t *testing.T) {
+	routes := newTestRoutes()
+
+	serve(routes.UserAdd, http.MethodPost, "/auth/user/add", `{"name": "dummy", "password_hash": "hash"}`)
+
+	request := &audit.Request{ID: "request-1"}
+
+	r := httptest.NewRequest(http.MethodPatch, "/auth/user/update",
+		strings.NewReader(`{"name": "dummy", "password_hash": "new-hash"}`))
+	r = r.WithContext(audit.ContextWithRequest(r.Context(), request))
+
+	w := httptest.NewRecorder()
+	routes.UserUpdate(w, r)
+
+	entry := request.Entry("auth:user:update", w.Code)
+	if entry.Target != "user:dummy" || entry.Before == nil || entry.After == nil {
+		t.Fatalf("change not described: %+v", entry)
+	}
+
+	// password hashes are never recorded
+	for _, snapshot := range []string{string(entry.Before), string(entry.After)} {
+		if strings.Contains(snapshot, "hash") {
+			t.Fatalf("snapshot contains the password hash: %s", snapshot)
+		}
+	}
+
+	if !strings.Contains(string(entry.After), `"password_changed":true`) {
+		t.Fatalf("changed password not recorded: %s", entry.After)
+	}
+}
+
+func TestUserDelete(t *testing.T) {
+	routes := newTestRoutesWithMember(t)
+
+	w := serve(routes.UserDelete, http.MethodDelete, "/auth/user/delete?name=dummy", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// the user is removed from its groups
+	refs, err := routes.DB.GetUserGroupReferences(nil, context.TODO())
+	if err != nil || len(refs) != 0 {
+		t.Fatalf("expected no user group references, got %v (%v)", refs, err)
+	}
+
+	w = serve(routes.UserDelete, http.MethodDelete, "/auth/user/delete?name=dummy", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGroupUpdate(t *testing.T) {
+	routes := newTestRoutesWithMember(t)
+
+	serve(routes.GroupAdd, http.MethodPost, "/auth/group/add", `{"name": "viewers"}`)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"name": "operators", "new_name": "viewers"}`, http.StatusBadRequest}, // name already exists
+		{`{"name": "operators", "new_name": ""}`, http.StatusBadRequest},
+		{`{"name": "unknown", "new_name": "admins"}`, http.StatusNotFound},
+		{`{"name": "operators", "new_name": "deployers"}`, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := serve(routes.GroupUpdate, http.MethodPatch, "/auth/group/update", test.body)
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.body, w.Code)
+		}
+	}
+
+	// members and permissions are kept
+	permissions, err := routes.DB.GetUserPermissions("dummy", context.TODO())
+	if err != nil || len(permissions) != 1 {
+		t.Fatalf("expected the permissions of the renamed group, got %v (%v)", permissions, err)
+	}
+}
+
+func TestGroupDelete(t *testing.T) {
+	routes := newTestRoutesWithMember(t)
+
+	w := serve(routes.GroupDelete, http.MethodDelete, "/auth/group/delete?name=operators", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// the members lose the permissions of the group
+	permissions, err := routes.DB.GetUserPermissions("dummy", context.TODO())
+	if err != nil || len(permissions) != 0 {
+		t.Fatalf("expected no permissions, got %v (%v)", permissions, err)
+	}
+
+	grants, err := routes.DB.GetGroupPermissions(nil, context.TODO())
+	if err != nil || len(grants) != 0 {
+		t.Fatalf("expected no group permissions, got %v (%v)", grants, err)
+	}
+
+	w = serve(routes.GroupDelete, http.MethodDelete, "/auth/group/delete?name=operators", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRemoveUserFromGroup(t *testing.T) {
+	routes := newTestRoutesWithMember(t)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/auth/usergroup/delete?username=unknown&group_name=operators", http.StatusNotFound},
+		{"/auth/usergroup/delete?username=dummy&group_name=unknown", http.StatusNotFound},
+		{"/auth/usergroup/delete?username=dummy&group_name=operators", http.StatusOK},
+		{"/auth/usergroup/delete?username=dummy&group_name=operators", http.StatusNotFound}, // not in group anymore
+	}
+
+	for _, test := range tests {
+		w := serve(routes.RemoveUserFromGroup, http.MethodDelete, test.target, "")
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.target, w.Code)
+		}
+	}
+
+	permissions, err := routes.DB.GetUserPermissions("dummy", context.TODO())
+	if err != nil || len(permissions) != 0 {
+		t.Fatalf("expected no permissions, got %v (%v)", permissions, err)
+	}
+}
+
+func TestRemovePermissionFromGroup(t *testing.T) {
+	routes := newTestRoutesWithMember(t)
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/auth/grouppermission/delete?group_name=unknown&permission=provisioning:run:add", http.StatusNotFound},
+		{"/auth/grouppermission/delete?group_name=operators&permission=provisioning", http.StatusNotFound},
+		{"/auth/grouppermission/delete?group_name=operators&permission=provisioning:run:add", http.StatusOK},
+		{"/auth/grouppermission/delete?group_name=operators&permission=provisioning:run:add", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := serve(routes.RemovePermissionFromGroup, http.MethodDelete, test.target, "")
+		if w.Code != test.status {
+			t.Fatalf("expected status %d for %s, got %d", test.status, test.target, w.Code)
+		}
+	}
+
+	permissions, err := routes.DB.GetUserPermissions("dummy", context.TODO())
+	if err != nil || len(permissions) != 0 {
+		t.Fatalf("expected no permissions, got %v (%v)", permissions, err)
+	}
+}
@@ -0,0 +1,786 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/authentication"
+	"github.com/tbauriedel/resource-nexus-core/internal/common/semver"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/provisioner"
+)
+
+// CredentialRequest is the request body to store a credential.
+type CredentialRequest struct {
+	Workspace string `json:"workspace"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+}
+
+// RunRequest is the request body to queue a new run.
+type RunRequest struct {
+	Workspace string `json:"workspace"`
+
+	database.RunOptions
+}
+
+// RunResponse is the response for a queued run.
+type RunResponse struct {
+	Message string `json:"message"`
+	ID      int    `json:"id"`
+}
+
+// RunConflictResponse is the response if a run can't be queued, because the workspace has an active run.
+type RunConflictResponse struct {
+	Message string `json:"message"`
+	RunID   int    `json:"run_id"`
+}
+
+// ApproveRequest is the request body to approve the saved plan of a run.
+type ApproveRequest struct {
+	ID int `json:"id"`
+}
+
+// CancelRequest is the request body to cancel a run.
+type CancelRequest struct {
+	ID int `json:"id"`
+}
+
+// UnlockRequest is the request body to force-unlock a workspace.
+type UnlockRequest struct {
+	Workspace string `json:"workspace"`
+}
+
+// WorkspaceLockResponse is the response for the lock state of a workspace.
+type WorkspaceLockResponse struct {
+	Workspace  string                      `json:"workspace"`
+	Locked     bool                        `json:"locked"`
+	Lock       *database.WorkspaceLockInfo `json:"lock,omitempty"`
+	AgeSeconds int64                       `json:"age_seconds,omitempty"`
+}
+
+// RunDetails is the response for a single run, including the results of the executed phases.
+type RunDetails struct {
+	Run         database.Run             `json:"run"`
+	Phases      []database.RunPhase      `json:"phases"`
+	Annotations []database.RunAnnotation `json:"annotations"` // values added by hooks
+}
+
+// WorkspaceAdd adds a new workspace to the database.
+func (routes *Routes) WorkspaceAdd(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	workspace, err := decodeJson[database.Workspace](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode workspace from body", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("workspace", workspace.Name), nil, workspace)
+
+	if workspace.Name == "" || !filepath.IsAbs(workspace.WorkingDirectory) {
+		http.Error(w,
+			BuildResponseMessage("name and absolute working_directory are required"),
+			http.StatusBadRequest,
+		)
+
+		return
+	}
+
+	err = validateWorkspace(workspace, routes.Config.Provisioner)
+	if err != nil {
+		// the error contains user input. it has to be encoded
+		writeJson(w, http.StatusBadRequest, map[string]string{"message": err.Error()}, routes.Logger)
+		routes.Logger.Error("failed to add workspace", "error", err)
+
+		return
+	}
+
+	err = addEntity(
+		w, r,
+		workspace,
+		database.Filter{Key: "name", Operator: "=", Value: workspace.Name},
+		func(filter database.FilterExpr, ctx context.Context) (any, error) {
+			return routes.DB.GetWorkspace(filter, ctx)
+		},
+		func(ctx context.Context, entity any) (sql.Result, error) {
+			return routes.DB.InsertWorkspace(ctx, workspace)
+		},
+	)
+	if err != nil {
+		routes.Logger.Error("failed to add workspace", "error", err)
+	}
+}
+
+// validateWorkspace validates the provisioner requirements of the workspace.
+//
+// The executable path is optional. If set, it needs to be one of the configured executables.
+func validateWorkspace(workspace database.Workspace, conf config.Provisioner) error {
+	if workspace.ExecutablePath != "" {
+		bp := provisioning.BaseProvisioner{
+			ProvisionerConfig: conf,
+			ExecutablePath:    workspace.ExecutablePath,
+			WorkingDirectory:  workspace.WorkingDirectory,
+		}
+
+		if bp.Validate() != nil {
+			return errors.New("executable is not allowed")
+		}
+	}
+
+	if workspace.Provisioner != "" && !provisioner.IsKnown(workspace.Provisioner) {
+		return errors.New("unknown provisioner")
+	}
+
+	_, err := semver.ParseConstraint(workspace.RequiredVersion)
+	if err != nil {
+		return errors.New("invalid required_version")
+	}
+
+	if workspace.DriftInterval < 0 {
+		return errors.New("drift_interval must not be negative")
+	}
+
+	if workspace.InitTimeout < 0 || workspace.PlanTimeout < 0 || workspace.ApplyTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+
+	if workspace.Playbook != "" {
+		if conf.Ansible.ExecutablePath == "" {
+			return errors.New("post-apply stage is not configured")
+		}
+
+		err = provisioning.ValidatePlaybook(workspace.Playbook)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// WorkspaceList returns the workspaces. The list can be filtered, paginated and ordered, see parseList.
+func (routes *Routes) WorkspaceList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameWorkspaces)
+	if err != nil {
+		writeListError(w, err, "workspaces", routes.Logger)
+
+		return
+	}
+
+	workspaces, err := routes.DB.ListWorkspaces(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "workspaces", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, workspaces, fields, routes.Logger)
+}
+
+// ExecutableList returns all installed executables and their detected versions.
+func (routes *Routes) ExecutableList(w http.ResponseWriter, _ *http.Request) {
+	executables := []provisioning.Executable{}
+
+	if routes.Executables != nil {
+		executables = append(executables, routes.Executables.Executables()...)
+	}
+
+	writeJson(w, http.StatusOK, executables, routes.Logger)
+}
+
+// WorkspaceLock returns the lock state of a workspace. The workspace is selected by the query parameter 'name'.
+func (routes *Routes) WorkspaceLock(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	locks, err := routes.DB.GetWorkspaceLocks(
+		database.Filter{Key: "workspace", Operator: "=", Value: name},
+		r.Context(),
+	)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load workspace lock"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load workspace lock", "error", err)
+
+		return
+	}
+
+	response := WorkspaceLockResponse{Workspace: name}
+
+	if len(locks) == 1 {
+		response.Locked = locks[0].Held
+		response.Lock = &locks[0]
+		response.AgeSeconds = int64(time.Since(locks[0].AcquiredAt).Seconds())
+	}
+
+	writeJson(w, http.StatusOK, response, routes.Logger)
+}
+
+// WorkspaceUnlock force-unlocks a workspace.
+//
+// The session of the lock holder is terminated. A held lock of a run that is not final is not unlocked,
+// because the run may still be executed. It has to be canceled first.
+func (routes *Routes) WorkspaceUnlock(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[UnlockRequest](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode unlock request from body", "error", err)
+
+		return
+	}
+
+	runID, active, err := routes.activeLockHolder(r.Context(), request.Workspace)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load workspace lock"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load workspace lock", "error", err)
+
+		return
+	}
+
+	if active {
+		writeJson(w, http.StatusConflict, RunConflictResponse{
+			Message: "workspace is locked by an active run. cancel the run first",
+			RunID:   runID,
+		}, routes.Logger)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("workspace", request.Workspace), map[string]bool{"locked": true},
+		map[string]bool{"locked": false})
+
+	result, err := routes.DB.ForceUnlockWorkspace(r.Context(), request.Workspace)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to unlock workspace"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to unlock workspace", "error", err)
+
+		return
+	}
+
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		http.Error(w,
+			BuildResponseMessage("workspace is not locked"),
+			http.StatusNotFound,
+		)
+
+		return
+	}
+
+	routes.Logger.Warn("workspace force-unlocked", "workspace", request.Workspace)
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "workspace unlocked"}, routes.Logger)
+}
+
+// activeLockHolder returns the run that holds the lock of the workspace, if the lock is held and the run is not final.
+// Unlocking the workspace would allow another run to be executed on the workspace at the same time.
+func (routes *Routes) activeLockHolder(ctx context.Context, workspace string) (int, bool, error) {
+	locks, err := routes.DB.GetWorkspaceLocks(database.Filter{Key: "workspace", Operator: "=", Value: workspace}, ctx)
+	if err != nil || len(locks) != 1 || !locks[0].Held {
+		return 0, false, err //nolint:wrapcheck
+	}
+
+	runs, err := routes.DB.GetRuns(database.Filter{Key: "id", Operator: "=", Value: locks[0].RunID}, ctx)
+	if err != nil || len(runs) != 1 {
+		return 0, false, err //nolint:wrapcheck
+	}
+
+	return runs[0].ID, !runs[0].Status.IsFinal(), nil
+}
+
+// CredentialAdd stores an encrypted credential of a workspace.
+//
+// An existing credential with the same name is replaced. The value is never returned.
+func (routes *Routes) CredentialAdd(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[CredentialRequest](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode credential from body", "error", err)
+
+		return
+	}
+
+	// the value is never recorded
+	audit.Describe(r.Context(), audit.Target("workspace", request.Workspace), nil,
+		map[string]string{"credential": request.Name})
+
+	if routes.Credentials == nil {
+		http.Error(w,
+			BuildResponseMessage("no credential key configured"),
+			http.StatusServiceUnavailable,
+		)
+
+		return
+	}
+
+	err = credentials.ValidateName(request.Name)
+	if err != nil || request.Value == "" {
+		http.Error(w,
+			BuildResponseMessage("valid name and value are required"),
+			http.StatusBadRequest,
+		)
+
+		return
+	}
+
+	_, err = routes.DB.GetWorkspace(database.Filter{Key: "name", Operator: "=", Value: request.Workspace}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("workspace not found"),
+			http.StatusNotFound,
+		)
+
+		return
+	}
+
+	encrypted, err := routes.Credentials.Encrypt([]byte(request.Value))
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to encrypt credential"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to encrypt credential", "error", err)
+
+		return
+	}
+
+	_, err = routes.DB.InsertCredential(r.Context(), database.Credential{
+		Workspace: request.Workspace,
+		Name:      request.Name,
+		Value:     encrypted,
+	})
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to store credential"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to store credential", "error", err)
+
+		return
+	}
+
+	routes.Logger.Info("credential stored", "workspace", request.Workspace, "name", request.Name)
+
+	writeJson(w, http.StatusOK, map[string]string{"message": "credential stored"}, routes.Logger)
+}
+
+// CredentialList returns the credentials without their values. e.g. of a workspace with the query parameter
+// 'workspace'. The list can be filtered, paginated and ordered, see parseList.
+func (routes *Routes) CredentialList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameCredentials)
+	if err != nil {
+		writeListError(w, err, "credentials", routes.Logger)
+
+		return
+	}
+
+	stored, err := routes.DB.ListCredentials(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "credentials", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, stored, fields, routes.Logger)
+}
+
+// RunAdd queues a new run for a workspace.
+//
+// The run is executed in the background. The response contains the id of the queued run.
+func (routes *Routes) RunAdd(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[RunRequest](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode run request from body", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("workspace", request.Workspace), nil, request.RunOptions)
+
+	err = provisioning.ValidateRunOptions(request.RunOptions)
+	if err != nil {
+		// the error contains user input. it has to be encoded
+		writeJson(w, http.StatusBadRequest, map[string]string{"message": err.Error()}, routes.Logger)
+
+		return
+	}
+
+	user, ok := authentication.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	// each run option needs its own permission
+	for _, perm := range authentication.GetPermissionsForRunOptions(request.RunOptions) {
+		if !user.HasPermission(perm) {
+			http.Error(w,
+				BuildResponseMessage("permission '"+perm+"' is required for the run options"),
+				http.StatusForbidden,
+			)
+			routes.Logger.Warn("authorization failed: permission not allowed", "user", user.Name, "permission", perm)
+
+			return
+		}
+	}
+
+	workspace, err := routes.DB.GetWorkspace(database.Filter{
+		Key:      "name",
+		Operator: "=",
+		Value:    request.Workspace,
+	}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("workspace not found"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to get workspace", "error", err)
+
+		return
+	}
+
+	id, err := routes.Queue.Enqueue(r.Context(), workspace, database.RunKindDeploy, request.RunOptions)
+
+	if errors.Is(err, provisioning.ErrNoExecutable) {
+		http.Error(w,
+			BuildResponseMessage("no installed executable matches the workspace"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to add run", "error", err)
+
+		return
+	}
+
+	var conflict *provisioning.RunConflictError
+	if errors.As(err, &conflict) {
+		writeJson(w, http.StatusConflict, RunConflictResponse{
+			Message: "workspace already has an active run",
+			RunID:   conflict.RunID,
+		}, routes.Logger)
+
+		return
+	}
+
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to queue run"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to queue run", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("run", id), nil, request)
+
+	writeJson(w, http.StatusAccepted, RunResponse{Message: "run queued", ID: id}, routes.Logger)
+}
+
+// RunList returns the runs. e.g. of a workspace with the query parameter 'workspace'.
+// The list can be filtered, paginated and ordered, see parseList.
+func (routes *Routes) RunList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameRuns)
+	if err != nil {
+		writeListError(w, err, "runs", routes.Logger)
+
+		return
+	}
+
+	runs, err := routes.DB.ListRuns(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "runs", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, runs, fields, routes.Logger)
+}
+
+// RunGet returns a single run including the results of its phases. The run is selected by the query parameter 'id'.
+func (routes *Routes) RunGet(w http.ResponseWriter, r *http.Request) {
+	run, ok := routes.loadRun(w, r)
+	if !ok {
+		return
+	}
+
+	phases, err := routes.DB.GetRunPhases(database.Filter{Key: "run_id", Operator: "=", Value: run.ID}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load run phases"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load run phases", "error", err)
+
+		return
+	}
+
+	annotations, err := routes.DB.GetRunAnnotations(
+		database.Filter{Key: "run_id", Operator: "=", Value: run.ID}, r.Context(),
+	)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load run annotations"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load run annotations", "error", err)
+
+		return
+	}
+
+	writeJson(w, http.StatusOK, RunDetails{Run: run, Phases: phases, Annotations: annotations}, routes.Logger)
+}
+
+// RunEvents returns the events of a single run. The run is selected by the query parameter 'id'.
+// The events can be paginated and ordered, see parsePage. 'fields' reduces the events to the given fields.
+func (routes *Routes) RunEvents(w http.ResponseWriter, r *http.Request) {
+	run, ok := routes.loadRun(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		writeListError(w, err, "run events", routes.Logger)
+
+		return
+	}
+
+	fields, err := database.ParseFields(r.URL.Query(), database.TableNameRunEvents)
+	if err != nil {
+		writeListError(w, err, "run events", routes.Logger)
+
+		return
+	}
+
+	events, err := routes.DB.ListRunEvents(
+		database.Filter{Key: "run_id", Operator: "=", Value: run.ID}, page, r.Context(),
+	)
+	if err != nil {
+		writeListError(w, err, "run events", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, events, fields, routes.Logger)
+}
+
+// RunPlan returns the saved plan of a run, rendered by `show -json`. The run is selected by the query parameter 'id'.
+//
+// The rendered plan is exactly the plan that is applied once the run is approved.
+func (routes *Routes) RunPlan(w http.ResponseWriter, r *http.Request) {
+	run, ok := routes.loadRun(w, r)
+	if !ok {
+		return
+	}
+
+	artifacts, err := routes.DB.GetRunArtifacts(database.LogicalFilter{
+		Operator: "AND",
+		Filters: []database.FilterExpr{
+			database.Filter{Key: "run_id", Operator: "=", Value: run.ID},
+			database.Filter{Key: "name", Operator: "=", Value: provisioning.ArtifactPlanJson},
+		},
+	}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load plan"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load plan", "error", err)
+
+		return
+	}
+
+	if len(artifacts) == 0 {
+		http.Error(w,
+			BuildResponseMessage("run has no saved plan"),
+			http.StatusNotFound,
+		)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(artifacts[0].Content)
+	if err != nil {
+		routes.Logger.Error("failed to write response", "error", err)
+	}
+}
+
+// RunApprove approves the saved plan of a planned run. The run is put back into the queue to apply the plan.
+//
+// Only runs with the status planned can be approved.
+func (routes *Routes) RunApprove(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[ApproveRequest](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode approve request from body", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("run", request.ID),
+		map[string]database.RunStatus{"status": database.RunStatusPlanned},
+		map[string]database.RunStatus{"status": database.RunStatusQueued})
+
+	approved, err := routes.Queue.Approve(r.Context(), request.ID)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to approve run"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to approve run", "error", err)
+
+		return
+	}
+
+	if !approved {
+		http.Error(w,
+			BuildResponseMessage("run is not waiting for approval"),
+			http.StatusConflict,
+		)
+
+		return
+	}
+
+	writeJson(w, http.StatusAccepted, RunResponse{Message: "run approved", ID: request.ID}, routes.Logger)
+}
+
+// RunCancel cancels a queued, planned or running run.
+//
+// Queued and planned runs are canceled immediately. Running runs are interrupted. The response is sent with
+// '202 Accepted' in this case, because the run is canceled once the command has exited.
+func (routes *Routes) RunCancel(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request, err := decodeJson[CancelRequest](r)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid json"),
+			http.StatusBadRequest,
+		)
+		routes.Logger.Error("failed to decode cancel request from body", "error", err)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("run", request.ID), nil, nil)
+
+	user, ok := authentication.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		return
+	}
+
+	status, ok, err := routes.Queue.Cancel(r.Context(), request.ID, user.Name)
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to cancel run"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to cancel run", "error", err)
+
+		return
+	}
+
+	if !ok {
+		http.Error(w,
+			BuildResponseMessage("run can't be canceled"),
+			http.StatusConflict,
+		)
+
+		return
+	}
+
+	audit.Describe(r.Context(), audit.Target("run", request.ID), nil, map[string]database.RunStatus{"status": status})
+
+	if status == database.RunStatusRunning {
+		writeJson(w, http.StatusAccepted, RunResponse{Message: "run is interrupted", ID: request.ID}, routes.Logger)
+
+		return
+	}
+
+	writeJson(w, http.StatusOK, RunResponse{Message: "run canceled", ID: request.ID}, routes.Logger)
+}
+
+// loadRun loads the run selected by the query parameter 'id'.
+//
+// If the run can't be loaded, an error is written to the client and false is returned.
+func (routes *Routes) loadRun(w http.ResponseWriter, r *http.Request) (database.Run, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("invalid run id"),
+			http.StatusBadRequest,
+		)
+
+		return database.Run{}, false
+	}
+
+	runs, err := routes.DB.GetRuns(database.Filter{Key: "id", Operator: "=", Value: id}, r.Context())
+	if err != nil {
+		http.Error(w,
+			BuildResponseMessage("failed to load run"),
+			http.StatusInternalServerError,
+		)
+		routes.Logger.Error("failed to load run", "error", err)
+
+		return database.Run{}, false
+	}
+
+	if len(runs) == 0 {
+		http.Error(w,
+			BuildResponseMessage("run not found"),
+			http.StatusNotFound,
+		)
+
+		return database.Run{}, false
+	}
+
+	return runs[0], true
+}
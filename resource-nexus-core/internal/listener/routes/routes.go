@@ -4,16 +4,29 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
 
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
 	"github.com/tbauriedel/resource-nexus-core/internal/database"
 	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/provisioner"
 )
 
 type Routes struct {
 	DB     database.Database
 	Logger *logging.Logger
+	Queue  *provisioning.Queue
+	Config config.Config
+
+	Executables *provisioner.Registry
+	Credentials *credentials.Cipher // nil if no credential key is configured
 }
 
 type Route struct {
@@ -29,26 +42,141 @@ func (routes *Routes) Get() []Route {
 			Path:        "/system/health",
 			HandlerFunc: routes.Health,
 		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/system/audit/list",
+			HandlerFunc: routes.AuditList,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/auth/user/list",
+			HandlerFunc: routes.UserList,
+		},
 		{
 			Method:      http.MethodPost,
 			Path:        "/auth/user/add",
 			HandlerFunc: routes.UserAdd,
 		},
+		{
+			Method:      http.MethodPatch,
+			Path:        "/auth/user/update",
+			HandlerFunc: routes.UserUpdate,
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/auth/user/delete",
+			HandlerFunc: routes.UserDelete,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/auth/group/list",
+			HandlerFunc: routes.GroupList,
+		},
 		{
 			Method:      http.MethodPost,
 			Path:        "/auth/group/add",
 			HandlerFunc: routes.GroupAdd,
 		},
+		{
+			Method:      http.MethodPatch,
+			Path:        "/auth/group/update",
+			HandlerFunc: routes.GroupUpdate,
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/auth/group/delete",
+			HandlerFunc: routes.GroupDelete,
+		},
 		{
 			Method:      http.MethodPost,
 			Path:        "/auth/usergroup/add",
 			HandlerFunc: routes.AddUserToGroup,
 		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/auth/usergroup/delete",
+			HandlerFunc: routes.RemoveUserFromGroup,
+		},
 		{
 			Method:      http.MethodPost,
 			Path:        "/auth/grouppermission/add",
 			HandlerFunc: routes.AddPermissionToGroup,
 		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/auth/grouppermission/delete",
+			HandlerFunc: routes.RemovePermissionFromGroup,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/workspace/add",
+			HandlerFunc: routes.WorkspaceAdd,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/workspace/list",
+			HandlerFunc: routes.WorkspaceList,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/workspace/lock",
+			HandlerFunc: routes.WorkspaceLock,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/workspace/unlock",
+			HandlerFunc: routes.WorkspaceUnlock,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/executable/list",
+			HandlerFunc: routes.ExecutableList,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/credential/add",
+			HandlerFunc: routes.CredentialAdd,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/credential/list",
+			HandlerFunc: routes.CredentialList,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/run/add",
+			HandlerFunc: routes.RunAdd,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/run/list",
+			HandlerFunc: routes.RunList,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/run/get",
+			HandlerFunc: routes.RunGet,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/run/events",
+			HandlerFunc: routes.RunEvents,
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/provisioning/run/plan",
+			HandlerFunc: routes.RunPlan,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/run/approve",
+			HandlerFunc: routes.RunApprove,
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/provisioning/run/cancel",
+			HandlerFunc: routes.RunCancel,
+		},
 	}
 }
 
@@ -57,6 +185,196 @@ func BuildResponseMessage(message string) string {
 	return fmt.Sprintf("{\"message\":\"%s\"}", message)
 }
 
+// writeJson writes v as json response with the given http status code.
+func writeJson(w http.ResponseWriter, status int, v any, logger *logging.Logger) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		logger.Error("failed to marshal response", "error", err)
+		http.Error(w, BuildResponseMessage(http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+
+		return
+	}
+
+	// Set json header and http code
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err = w.Write(j)
+	if err != nil {
+		logger.Error("failed to write response", "error", err)
+	}
+}
+
+// parsePage parses the pagination query parameters of a list request.
+//
+// 'limit' and 'offset' select a window of rows, 'order' is a comma separated list of columns (prefixed with '-' for
+// descending order) and 'cursor' is the next cursor of the previous page.
+func parsePage(r *http.Request) (database.Page, error) {
+	query := r.URL.Query()
+	page := database.Page{
+		OrderBy: database.ParseOrder(query.Get("order")),
+		Cursor:  query.Get("cursor"),
+	}
+
+	for key, target := range map[string]*int{"limit": &page.Limit, "offset": &page.Offset} {
+		value := query.Get(key)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return database.Page{}, fmt.Errorf("%w: %s must be a number", database.ErrInvalidPage, key)
+		}
+
+		*target = n
+	}
+
+	return page, nil
+}
+
+// parseList parses the filter, the page and the fields of a list request on the table. See database.ParseFilterQuery,
+// parsePage and database.ParseFields.
+func parseList(r *http.Request, table string) (database.FilterExpr, database.Page, []string, error) {
+	page, err := parsePage(r)
+	if err != nil {
+		return nil, database.Page{}, nil, err
+	}
+
+	filter, err := database.ParseFilterQuery(r.URL.Query(), table)
+	if err != nil {
+		return nil, database.Page{}, nil, err //nolint:wrapcheck
+	}
+
+	fields, err := database.ParseFields(r.URL.Query(), table)
+	if err != nil {
+		return nil, database.Page{}, nil, err //nolint:wrapcheck
+	}
+
+	return filter, page, fields, nil
+}
+
+// writeListError writes the error of a list request. Invalid pages, filters and fields are rejected with
+// 400 Bad Request.
+func writeListError(w http.ResponseWriter, err error, entity string, logger *logging.Logger) {
+	if errors.Is(err, database.ErrInvalidPage) || errors.Is(err, database.ErrInvalidFilter) ||
+		errors.Is(err, database.ErrInvalidFields) {
+		// the message may contain the requested columns, so it is encoded properly
+		writeJson(w, http.StatusBadRequest, map[string]string{"message": err.Error()}, logger)
+
+		return
+	}
+
+	http.Error(w,
+		BuildResponseMessage("failed to load "+entity),
+		http.StatusInternalServerError,
+	)
+	logger.Error("failed to load "+entity, "error", err)
+}
+
+// writePage writes the items of a page as json response. If fields are given, each item is reduced to them.
+//
+// The total count is set as header 'X-Total-Count'. If there are more rows, the cursor of the next page is set as header
+// 'X-Next-Cursor' and the url of the next page as 'Link' header with rel="next".
+func writePage[T any](
+	w http.ResponseWriter, r *http.Request, result database.PageResult[T], fields []string, logger *logging.Logger,
+) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(result.Total))
+
+	if result.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+
+		// the cursor already skips the rows of the previous pages
+		query.Del("offset")
+		query.Set("cursor", result.NextCursor)
+		next.RawQuery = query.Encode()
+
+		w.Header().Set("X-Next-Cursor", result.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+
+	items := result.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	if len(fields) == 0 {
+		writeJson(w, http.StatusOK, items, logger)
+
+		return
+	}
+
+	projected, err := project(items, fields)
+	if err != nil {
+		logger.Error("failed to select fields", "error", err)
+		http.Error(w, BuildResponseMessage(http.StatusText(http.StatusInternalServerError)), http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJson(w, http.StatusOK, projected, logger)
+}
+
+// project returns the json objects of the items, reduced to the given fields.
+//
+// The fields are the json keys of the items. They match the columns of the tables, see database.ParseFields.
+func project[T any](items []T, fields []string) ([]map[string]json.RawMessage, error) {
+	j, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode items: %w", err)
+	}
+
+	var objects []map[string]json.RawMessage
+
+	err = json.Unmarshal(j, &objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode items: %w", err)
+	}
+
+	for _, object := range objects {
+		maps.DeleteFunc(object, func(key string, _ json.RawMessage) bool {
+			return !slices.Contains(fields, key)
+		})
+	}
+
+	return objects, nil
+}
+
+// requestError is an error of a request. It is sent to the client with the http status and message.
+// e.g. returned inside a transaction to roll it back.
+type requestError struct {
+	status  int
+	message string
+	err     error // cause of the error. may be nil
+}
+
+func (e *requestError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// writeRequestError writes the error to the client and logs it.
+//
+// A *requestError is sent with its status and message. Other errors are sent as 500 Internal Server Error with the
+// given message.
+func writeRequestError(w http.ResponseWriter, err error, message string, logger *logging.Logger) {
+	var reqErr *requestError
+	if !errors.As(err, &reqErr) {
+		reqErr = &requestError{status: http.StatusInternalServerError, message: message, err: err}
+	}
+
+	http.Error(w, BuildResponseMessage(reqErr.message), reqErr.status)
+	logger.Error(reqErr.message, "error", reqErr.err)
+}
+
 // decodeJson decodes a json request body into a struct.
 //
 // Make sure to provide a valid type when calling this function!
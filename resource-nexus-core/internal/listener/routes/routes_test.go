@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestParsePage(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/provisioning/run/list?limit=20&offset=40&order=-created_at,id", nil)
+
+	page, err := parsePage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := database.Page{
+		Limit:   20,
+		Offset:  40,
+		OrderBy: []database.Order{{Column: "created_at", Desc: true}, {Column: "id"}},
+	}
+	if !reflect.DeepEqual(page, expected) {
+		t.Fatalf("expected %v, got %v", expected, page)
+	}
+
+	for _, target := range []string{"/provisioning/run/list?limit=ten", "/provisioning/run/list?offset=1.5"} {
+		_, err = parsePage(httptest.NewRequest(http.MethodGet, target, nil))
+		if !errors.Is(err, database.ErrInvalidPage) {
+			t.Fatalf("expected ErrInvalidPage for %s, got %v", target, err)
+		}
+	}
+}
+
+func TestWorkspaceListPage(t *testing.T) {
+	routes := newTestRoutes()
+
+	for _, name := range []string{"web", "db", "cache"} {
+		_, err := routes.DB.InsertWorkspace(context.TODO(), database.Workspace{Name: name, WorkingDirectory: "/tmp/" + name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+
+	target := "/provisioning/workspace/list?limit=2&order=-name"
+
+	for target != "" {
+		w := serve(routes.WorkspaceList, http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if w.Header().Get("X-Total-Count") != "3" {
+			t.Fatalf("expected total count 3, got %s", w.Header().Get("X-Total-Count"))
+		}
+
+		var workspaces []database.Workspace
+
+		err := json.Unmarshal(w.Body.Bytes(), &workspaces)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, workspace := range workspaces {
+			names = append(names, workspace.Name)
+		}
+
+		target = ""
+
+		if cursor := w.Header().Get("X-Next-Cursor"); cursor != "" {
+			target = "/provisioning/workspace/list?cursor=" + cursor + "&limit=2&order=-name"
+
+			if w.Header().Get("Link") != "<"+target+`>; rel="next"` {
+				t.Fatalf("unexpected link header %s", w.Header().Get("Link"))
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(names, []string{"web", "db", "cache"}) {
+		t.Fatalf("wrong order of workspaces: %v", names)
+	}
+
+	for _, target := range []string{
+		"/provisioning/workspace/list?order=password",
+		"/provisioning/workspace/list?cursor=invalid",
+		"/provisioning/workspace/list?limit=-1",
+	} {
+		w := serve(routes.WorkspaceList, http.MethodGet, target, "")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusBadRequest, target, w.Code)
+		}
+	}
+
+	// filters and pages are combined
+	w := serve(routes.WorkspaceList, http.MethodGet, "/provisioning/workspace/list?name=in:web,db&order=name&limit=1", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "2" || w.Header().Get("X-Next-Cursor") == "" {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve(routes.WorkspaceList, http.MethodGet, "/provisioning/workspace/list?drift_interval=gt:soon", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// empty pages are returned as empty list
+	w = serve(routes.RunList, http.MethodGet, "/provisioning/run/list?workspace=web", "")
+	if w.Code != http.StatusOK || w.Body.String() != "[]" || w.Header().Get("X-Total-Count") != "0" {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRunAddInvalidOptions(t *testing.T) {
+	routes := newTestRoutes()
+
+	// the invalid address is part of the message. the response has to stay valid json
+	w := serve(routes.RunAdd, http.MethodPost, "/provisioning/run/add",
+		`{"workspace": "web", "targets": ["null_resource.\"web\""]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	var response map[string]string
+
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("response is not valid json: %v: %s", err, w.Body.String())
+	}
+
+	if response["message"] != `invalid target address 'null_resource."web"'` {
+		t.Fatalf("unexpected message %s", response["message"])
+	}
+}
+
+func TestRunEventsPage(t *testing.T) {
+	routes := newTestRoutes()
+	ctx := context.TODO()
+
+	_, err := routes.DB.InsertWorkspace(ctx, database.Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := routes.DB.InsertRun(ctx, database.Run{Workspace: "web", Status: database.RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"init", "plan", "show"} {
+		_, err = routes.DB.InsertRunEvent(ctx, database.RunEvent{RunID: id, Type: "log", Message: message})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serve(routes.RunEvents, http.MethodGet, "/provisioning/run/events?id=1&limit=2&fields=id,message", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "3" || w.Header().Get("X-Next-Cursor") == "" {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	var events []map[string]any
+
+	err = json.Unmarshal(w.Body.Bytes(), &events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]any{{"id": 1.0, "message": "init"}, {"id": 2.0, "message": "plan"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("expected %v, got %v", expected, events)
+	}
+
+	for _, target := range []string{
+		"/provisioning/run/events?id=1&fields=payload",
+		"/provisioning/run/events?id=1&order=payload",
+	} {
+		w = serve(routes.RunEvents, http.MethodGet, target, "")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusBadRequest, target, w.Code)
+		}
+	}
+}
+
+func TestListFields(t *testing.T) {
+	routes := newTestRoutes()
+
+	_, err := routes.DB.InsertUser(context.TODO(), database.User{Name: "alice", PasswordHash: "hash", IsAdmin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(routes.UserList, http.MethodGet, "/auth/user/list?fields=name", "")
+	if w.Code != http.StatusOK || w.Body.String() != `[{"name":"alice"}]` {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	// only the allowed columns can be selected
+	w = serve(routes.UserList, http.MethodGet, "/auth/user/list?fields=name,password_hash", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestWorkspaceUnlockActiveRun(t *testing.T) {
+	routes := newTestRoutes()
+	ctx := context.TODO()
+
+	_, err := routes.DB.InsertWorkspace(ctx, database.Workspace{Name: "web", WorkingDirectory: "/tmp/web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspace, err := routes.DB.GetWorkspace(database.Filter{Key: "name", Operator: "=", Value: "web"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := routes.DB.InsertRun(ctx, database.Run{Workspace: "web", Status: database.RunStatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = routes.DB.LockWorkspace(ctx, workspace, id, "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the run may still be executed
+	w := serve(routes.WorkspaceUnlock, http.MethodPost, "/provisioning/workspace/unlock", `{"workspace": "web"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	run, err := routes.DB.GetRun(database.Filter{Key: "id", Operator: "=", Value: id}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock of a finished run is left behind. e.g. the holder is gone
+	run.Status = database.RunStatusErrored
+
+	_, err = routes.DB.UpdateRun(ctx, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(routes.WorkspaceUnlock, http.MethodPost, "/provisioning/workspace/unlock", `{"workspace": "web"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
@@ -4,13 +4,15 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
 	"github.com/tbauriedel/resource-nexus-core/internal/version"
 )
 
 type SystemHealth struct {
-	DatabaseStatus        bool   `json:"databaseStatus"`
-	DatabaseStatusMessage string `json:"databaseStatusMessage"`
-	Version               string `json:"version"`
+	DatabaseStatus        bool               `json:"databaseStatus"`
+	DatabaseStatusMessage string             `json:"databaseStatusMessage"`
+	DatabasePool          database.PoolStats `json:"databasePool"`
+	Version               string             `json:"version"`
 }
 
 func (routes *Routes) Health(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +31,8 @@ func (routes *Routes) Health(w http.ResponseWriter, r *http.Request) {
 		health.DatabaseStatusMessage = err.Error()
 	}
 
+	health.DatabasePool = routes.DB.Stats()
+
 	// Build json response
 	j, err := json.Marshal(health)
 	if err != nil {
@@ -51,3 +55,23 @@ func (routes *Routes) Health(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// AuditList returns the entries of the audit log. The list can be filtered, paginated and ordered, see parseList.
+// e.g. the changes of a target with the query parameter 'target'.
+func (routes *Routes) AuditList(w http.ResponseWriter, r *http.Request) {
+	filter, page, fields, err := parseList(r, database.TableNameAuditLog)
+	if err != nil {
+		writeListError(w, err, "audit entries", routes.Logger)
+
+		return
+	}
+
+	entries, err := routes.DB.ListAuditEntries(filter, page, r.Context())
+	if err != nil {
+		writeListError(w, err, "audit entries", routes.Logger)
+
+		return
+	}
+
+	writePage(w, r, entries, fields, routes.Logger)
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestHealth(t *testing.T) {
+	routes := newTestRoutes()
+
+	w := serve(routes.Health, http.MethodGet, "/system/health", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var health map[string]any
+
+	err := json.Unmarshal(w.Body.Bytes(), &health)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if health["databaseStatus"] != true {
+		t.Fatalf("expected the database to be healthy, got %v", health)
+	}
+
+	if _, ok := health["databasePool"].(map[string]any)["openConnections"]; !ok {
+		t.Fatalf("expected the statistics of the connection pool, got %v", health)
+	}
+}
+
+func TestAuditList(t *testing.T) {
+	routes := newTestRoutes()
+
+	for _, target := range []string{"run:1", "run:2", "run:1"} {
+		_, err := routes.DB.InsertAuditEntry(context.TODO(), database.AuditEntry{
+			CreatedAt: time.Now(),
+			Actor:     "alice",
+			Action:    "provisioning:run:cancel",
+			Target:    target,
+			Result:    database.AuditResultSuccess,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serve(routes.AuditList, http.MethodGet, "/system/audit/list?target=run:1&order=-id", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var entries []database.AuditEntry
+
+	err := json.Unmarshal(w.Body.Bytes(), &entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Header().Get("X-Total-Count") != "2" || len(entries) != 2 || entries[0].ID != 3 || entries[1].ID != 1 {
+		t.Fatalf("wrong audit entries returned: %v", entries)
+	}
+
+	// snapshots can't be filtered
+	w = serve(routes.AuditList, http.MethodGet, "/system/audit/list?after_snapshot=x", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SubCommandAnsiblePlaybook is the phase of the post-apply stage. It is not a subcommand of the provisioner.
+const SubCommandAnsiblePlaybook SubCommand = "ansible-playbook"
+
+// Failure policies of the post-apply stage.
+const (
+	FailurePolicyFail     = "fail"     // the run is finished as errored
+	FailurePolicyContinue = "continue" // the run is finished as applied. the failure is only recorded
+)
+
+// ErrNoHosts is returned if the terraform outputs contain no hosts for the inventory.
+var ErrNoHosts = errors.New("no hosts found in terraform outputs")
+
+// groupNamePattern matches the group names that are accepted by ansible.
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// output is a single output printed by `output -json`.
+type output struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// inventoryGroup is a group of the generated inventory.
+type inventoryGroup struct {
+	Hosts    map[string]struct{}       `json:"hosts,omitempty"`
+	Children map[string]inventoryGroup `json:"children,omitempty"`
+}
+
+// BuildInventory returns an ansible inventory generated from the outputs printed by `output -json`.
+//
+// The hosts are read from the output with the given name. Its value is either a list of hosts or a map of group names
+// to lists of hosts. The inventory is returned as JSON, which is read by the YAML inventory plugin of ansible.
+// Returns ErrNoHosts if the output does not exist or contains no hosts.
+func BuildInventory(outputs []byte, name string) ([]byte, error) {
+	var decoded map[string]output
+
+	err := json.Unmarshal(outputs, &decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse terraform outputs: %w", err)
+	}
+
+	hosts, ok := decoded[name]
+	if !ok {
+		return nil, fmt.Errorf("%w. output '%s' does not exist", ErrNoHosts, name)
+	}
+
+	all := inventoryGroup{Hosts: make(map[string]struct{})}
+
+	var list []string
+
+	var groups map[string][]string
+
+	switch {
+	case json.Unmarshal(hosts.Value, &list) == nil:
+		err = addHosts(all.Hosts, list)
+	case json.Unmarshal(hosts.Value, &groups) == nil:
+		all.Children = make(map[string]inventoryGroup, len(groups))
+
+		for group, members := range groups {
+			if !groupNamePattern.MatchString(group) {
+				return nil, fmt.Errorf("invalid group name in output '%s': %s", name, group)
+			}
+
+			children := inventoryGroup{Hosts: make(map[string]struct{})}
+
+			err = addHosts(children.Hosts, members)
+			if err != nil {
+				break
+			}
+
+			all.Children[group] = children
+		}
+	default:
+		return nil, fmt.Errorf("output '%s' needs to be a list of hosts or a map of groups to hosts", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid hosts in output '%s': %w", name, err)
+	}
+
+	if len(all.Hosts) == 0 && len(all.Children) == 0 {
+		return nil, fmt.Errorf("%w. output '%s' is empty", ErrNoHosts, name)
+	}
+
+	inventory, err := json.Marshal(map[string]inventoryGroup{"all": all})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// addHosts adds the hosts to the given set. Hosts need to be a single word.
+func addHosts(set map[string]struct{}, hosts []string) error {
+	for _, host := range hosts {
+		if host == "" || strings.ContainsFunc(host, func(r rune) bool { return r <= ' ' }) {
+			return fmt.Errorf("invalid host: '%s'", host)
+		}
+
+		set[host] = struct{}{}
+	}
+
+	return nil
+}
+
+// ValidatePlaybook returns an error if the playbook is not a YAML file inside the working directory.
+func ValidatePlaybook(playbook string) error {
+	if !filepath.IsLocal(playbook) {
+		return fmt.Errorf("playbook needs to be a relative path inside the working directory: %s", playbook)
+	}
+
+	switch filepath.Ext(playbook) {
+	case ".yml", ".yaml":
+		return nil
+	default:
+		return fmt.Errorf("playbook needs to be a YAML file: %s", playbook)
+	}
+}
+
+// inventoryFilePath returns the path of the generated inventory of the run inside the working directory.
+func inventoryFilePath(workingDirectory string, runID int) string {
+	return filepath.Join(workingDirectory, fmt.Sprintf(".resource-nexus-run-%d.inventory.json", runID))
+}
+
+// GetCommandAnsiblePlaybook returns the command for `ansible-playbook -i <inventory> <playbook>`.
+//
+// The configured ansible-playbook executable is used. The playbook of the workspace is executed against the
+// generated inventory.
+func (bp *BaseProvisioner) GetCommandAnsiblePlaybook(ctx context.Context, inventory string) (*Command, error) {
+	executable := bp.ProvisionerConfig.Ansible.ExecutablePath
+	if executable == "" || !filepath.IsAbs(executable) {
+		return nil, fmt.Errorf("invalid ansible-playbook executable: '%s'. contact your administrator for help", executable)
+	}
+
+	err := ValidatePlaybook(bp.Playbook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provisioner settings: %w", err)
+	}
+
+	cmd := buildCommand(
+		bp.WorkingDirectory,
+		executable,
+		"",
+		[]string{"-i", inventory, bp.Playbook},
+		bp.Environment,
+		bp.RunDirectory,
+		ctx,
+	)
+
+	return bp.isolate(cmd)
+}
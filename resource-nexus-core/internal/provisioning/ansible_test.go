@@ -0,0 +1,109 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+)
+
+func TestBuildInventory(t *testing.T) {
+	inventory, err := BuildInventory(
+		[]byte(`{"ansible_hosts":{"sensitive":false,"value":["10.0.0.1","10.0.0.2"]},"other":{"value":1}}`),
+		"ansible_hosts",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(inventory) != `{"all":{"hosts":{"10.0.0.1":{},"10.0.0.2":{}}}}` {
+		t.Fatalf("wrong inventory: %s", inventory)
+	}
+
+	// groups are added as children of all
+	inventory, err = BuildInventory(
+		[]byte(`{"ansible_hosts":{"value":{"web":["10.0.0.1"],"db":["10.0.0.2"]}}}`),
+		"ansible_hosts",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]inventoryGroup
+
+	err = json.Unmarshal(inventory, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := decoded["all"].Children["db"].Hosts["10.0.0.2"]; !ok {
+		t.Fatalf("group should be part of the inventory: %s", inventory)
+	}
+}
+
+func TestBuildInventoryInvalid(t *testing.T) {
+	tests := []struct {
+		outputs string
+		noHosts bool
+	}{
+		{`{}`, true},
+		{`{"ansible_hosts":{"value":[]}}`, true},
+		{`{"ansible_hosts":{"value":null}}`, true},
+		{`{"ansible_hosts":{"value":"10.0.0.1"}}`, false},
+		{`{"ansible_hosts":{"value":["10.0.0.1 ansible_user=root"]}}`, false},
+		{`{"ansible_hosts":{"value":{"web-servers":["10.0.0.1"]}}}`, false},
+		{`not json`, false},
+	}
+
+	for _, tt := range tests {
+		_, err := BuildInventory([]byte(tt.outputs), "ansible_hosts")
+		if err == nil {
+			t.Fatalf("invalid outputs should return an error: %s", tt.outputs)
+		}
+
+		if errors.Is(err, ErrNoHosts) != tt.noHosts {
+			t.Fatalf("wrong error for %s: %v", tt.outputs, err)
+		}
+	}
+}
+
+func TestValidatePlaybook(t *testing.T) {
+	for _, playbook := range []string{"site.yml", "ansible/site.yaml"} {
+		if ValidatePlaybook(playbook) != nil {
+			t.Fatalf("playbook should be valid: %s", playbook)
+		}
+	}
+
+	for _, playbook := range []string{"", "/etc/site.yml", "../site.yml", "site.sh"} {
+		if ValidatePlaybook(playbook) == nil {
+			t.Fatalf("playbook should be invalid: %s", playbook)
+		}
+	}
+}
+
+func TestGetCommandAnsiblePlaybook(t *testing.T) {
+	bp := BaseProvisioner{
+		ProvisionerConfig: config.Provisioner{Ansible: config.Ansible{ExecutablePath: "/usr/bin/ansible-playbook"}},
+		WorkingDirectory:  "/dummy/dir",
+		Playbook:          "site.yml",
+	}
+
+	cmd, err := bp.GetCommandAnsiblePlaybook(context.TODO(), "/dummy/dir/inventory.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.String() != "/usr/bin/ansible-playbook -i /dummy/dir/inventory.json site.yml" {
+		t.Fatalf("wrong command: %s", cmd.String())
+	}
+
+	// the stage is disabled without executable
+	bp.ProvisionerConfig.Ansible.ExecutablePath = ""
+
+	_, err = bp.GetCommandAnsiblePlaybook(context.TODO(), "/dummy/dir/inventory.json")
+	if err == nil {
+		t.Fatal("command should not be built without configured executable")
+	}
+}
@@ -0,0 +1,206 @@
+package provisioning
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// flagKind defines how a flag is passed.
+type flagKind int
+
+const (
+	flagBool  flagKind = iota // '-flag' or '-flag=true|false'
+	flagValue                 // '-flag=value'. the value needs to be part of the same argument
+	flagPath                  // '-flag=path'. the path needs to be relative and inside the working directory
+)
+
+// unlimited is used as maxPositional for subcommands with any number of positional arguments.
+const unlimited = -1
+
+// subCommandSpec describes the allowed command line of a subcommand.
+type subCommandSpec struct {
+	jsonFlag        string              // flag for machine-readable output. empty if not supported
+	flags           map[string]flagKind // allowed flags
+	maxPositional   int                 // maximum number of positional arguments. unlimited for no limit
+	positionalPaths bool                // positional arguments are paths. checked like the values of flagPath
+}
+
+// Flags that are shared by multiple subcommands.
+var (
+	planFlags = map[string]flagKind{ //nolint:gochecknoglobals
+		"-input":             flagBool,
+		"-lock":              flagBool,
+		"-lock-timeout":      flagValue,
+		"-refresh":           flagBool,
+		"-refresh-only":      flagBool,
+		"-destroy":           flagBool,
+		"-target":            flagValue,
+		"-replace":           flagValue,
+		"-parallelism":       flagValue,
+		"-compact-warnings":  flagBool,
+		"-detailed-exitcode": flagBool,
+	}
+	stateFlags = map[string]flagKind{ //nolint:gochecknoglobals
+		"-lock":         flagBool,
+		"-lock-timeout": flagValue,
+		"-dry-run":      flagBool,
+	}
+)
+
+// subCommandSpecs returns the specs of all supported subcommands.
+//
+// Arguments of a subcommand are only passed to the provisioner if they are allowed by the spec.
+// Flags that read files from or write files to arbitrary paths (e.g. '-var-file', '-state', '-backup') are not
+// allowed. Paths that are accepted (e.g. '-out', '-lockfile' and saved plan files) need to be relative and inside the
+// working directory.
+func subCommandSpecs() map[SubCommand]subCommandSpec {
+	return map[SubCommand]subCommandSpec{
+		SubCommandInit: {
+			jsonFlag: "--json",
+			flags: map[string]flagKind{
+				"-input":        flagBool,
+				"-upgrade":      flagBool,
+				"-reconfigure":  flagBool,
+				"-lock":         flagBool,
+				"-lock-timeout": flagValue,
+				"-lockfile":     flagPath,
+			},
+		},
+		SubCommandPlan: {
+			jsonFlag: "--json",
+			flags:    withFlags(planFlags, map[string]flagKind{"-out": flagPath}),
+		},
+		SubCommandApply: {
+			jsonFlag:        "--json",
+			flags:           withFlags(planFlags, map[string]flagKind{"-auto-approve": flagBool}),
+			maxPositional:   1, // saved plan file
+			positionalPaths: true,
+		},
+		SubCommandDestroy: {
+			jsonFlag: "--json",
+			flags: map[string]flagKind{
+				"-input":        flagBool,
+				"-auto-approve": flagBool,
+				"-lock":         flagBool,
+				"-lock-timeout": flagValue,
+				"-target":       flagValue,
+				"-parallelism":  flagValue,
+			},
+		},
+		SubCommandValidate: {
+			jsonFlag: "-json",
+			flags:    map[string]flagKind{"-no-tests": flagBool},
+		},
+		SubCommandShow: {
+			jsonFlag:        "-json",
+			maxPositional:   1, // saved plan file
+			positionalPaths: true,
+		},
+		SubCommandOutput: {
+			jsonFlag:      "-json",
+			maxPositional: 1, // name of the output
+		},
+		SubCommandStateList: {
+			flags:         map[string]flagKind{"-id": flagValue},
+			maxPositional: unlimited, // addresses
+		},
+		SubCommandStateShow: {
+			maxPositional: 1, // address
+		},
+		SubCommandStateRm: {
+			flags:         stateFlags,
+			maxPositional: unlimited, // addresses
+		},
+		SubCommandStateMv: {
+			flags:         stateFlags,
+			maxPositional: 2, // source and destination address
+		},
+		SubCommandStatePull: {},
+		SubCommandImport: {
+			flags: map[string]flagKind{
+				"-input":        flagBool,
+				"-lock":         flagBool,
+				"-lock-timeout": flagValue,
+				"-parallelism":  flagValue,
+			},
+			maxPositional: 2, // address and id
+		},
+		SubCommandForceUnlock: {
+			flags:         map[string]flagKind{"-force": flagBool},
+			maxPositional: 1, // lock id
+		},
+		SubCommandProvidersLock: {
+			flags:         map[string]flagKind{"-platform": flagValue},
+			maxPositional: unlimited, // providers
+		},
+	}
+}
+
+// withFlags returns a new map containing the flags of base and additional.
+func withFlags(base map[string]flagKind, additional map[string]flagKind) map[string]flagKind {
+	flags := make(map[string]flagKind, len(base)+len(additional))
+
+	for name, kind := range base {
+		flags[name] = kind
+	}
+
+	for name, kind := range additional {
+		flags[name] = kind
+	}
+
+	return flags
+}
+
+// validateArgs validates the arguments against the spec.
+//
+// Returns an error if a flag is not allowed, a flag is passed in the wrong format, flags are placed after positional
+// arguments, too many positional arguments are passed or a path leaves the working directory.
+func (spec subCommandSpec) validateArgs(subcommand SubCommand, args []string) error {
+	positional := 0
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional++
+
+			if spec.positionalPaths && !filepath.IsLocal(arg) {
+				return fmt.Errorf("path %s of %s needs to be relative and inside the working directory", arg, subcommand)
+			}
+
+			continue
+		}
+
+		// flags are not parsed after the first positional argument
+		if positional > 0 {
+			return fmt.Errorf("flag %s of %s needs to be placed before positional arguments", arg, subcommand)
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		name = "-" + strings.TrimLeft(name, "-") // '--flag' and '-flag' are equal
+
+		kind, ok := spec.flags[name]
+		if !ok {
+			return fmt.Errorf("flag %s is not allowed for %s", name, subcommand)
+		}
+
+		if kind != flagBool && (!hasValue || value == "") {
+			return fmt.Errorf("flag %s of %s requires a value in the format %s=<value>", name, subcommand, name)
+		}
+
+		// filepath.IsLocal rejects absolute paths and paths containing '..' that leave the directory
+		if kind == flagPath && !filepath.IsLocal(value) {
+			return fmt.Errorf("path of flag %s of %s needs to be relative and inside the working directory", name,
+				subcommand)
+		}
+
+		if kind == flagBool && hasValue && value != "true" && value != "false" {
+			return fmt.Errorf("flag %s of %s only accepts true or false", name, subcommand)
+		}
+	}
+
+	if spec.maxPositional != unlimited && positional > spec.maxPositional {
+		return fmt.Errorf("%s accepts at most %d positional arguments, got %d", subcommand, spec.maxPositional, positional)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package provisioning
+
+import (
+	"testing"
+)
+
+func Test_validateArgs(t *testing.T) {
+	tests := []struct {
+		subcommand SubCommand
+		args       []string
+		valid      bool
+	}{
+		{SubCommandPlan, []string{"-input=false", "-out=run.tfplan", "-target=module.vm"}, true},
+		{SubCommandPlan, []string{"--refresh-only"}, true},
+		{SubCommandPlan, []string{"-var-file=secrets.tfvars"}, false}, // not allowed
+		{SubCommandPlan, []string{"-out"}, false},                     // value missing
+		{SubCommandPlan, []string{"-out=/etc/cron.d/x"}, false},       // absolute path
+		{SubCommandPlan, []string{"-out=../other/run.tfplan"}, false}, // leaves the working directory
+		{SubCommandPlan, []string{"-out=plans/run.tfplan"}, true},
+		{SubCommandInit, []string{"-lockfile=/tmp/lock.hcl"}, false},
+		{SubCommandPlan, []string{"-input=maybe"}, false},               // invalid bool
+		{SubCommandApply, []string{"-input=false", "run.tfplan"}, true}, // saved plan
+		{SubCommandApply, []string{"run.tfplan", "-input=false"}, false},
+		{SubCommandApply, []string{"a.tfplan", "b.tfplan"}, false},
+		{SubCommandApply, []string{"/tmp/run.tfplan"}, false},
+		{SubCommandShow, []string{"../run.tfplan"}, false},
+		{SubCommandStateRm, []string{"-dry-run", "module.a", "module.b", "module.c"}, true},
+		{SubCommandStateMv, []string{"a", "b", "c"}, false},
+		{SubCommandImport, []string{"proxmox_vm_qemu.vm", "pve/qemu/100"}, true},
+		{SubCommandStatePull, []string{"pull"}, false},
+		{SubCommandProvidersLock, []string{"-platform=linux_amd64", "-platform=darwin_arm64"}, true},
+	}
+
+	specs := subCommandSpecs()
+
+	for _, tt := range tests {
+		err := specs[tt.subcommand].validateArgs(tt.subcommand, tt.args)
+		if (err == nil) != tt.valid {
+			t.Fatalf("wrong validation result for %s %v: %v", tt.subcommand, tt.args, err)
+		}
+	}
+}
@@ -3,94 +3,271 @@ package provisioning
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
 )
 
 type Command struct {
 	*exec.Cmd
+
+	masker    *credentials.Masker // masks the injected values inside the output of the command
+	isolation config.Isolation    // resource limits applied before the executable runs
+}
+
+// Start starts the command with the resource limits of the isolation.
+//
+// The command is killed if the limits can't be applied.
+func (c *Command) Start() error {
+	return c.start()
+}
+
+// Wait waits for the command to exit.
+//
+// Remaining processes of the process group, e.g. provider plugins, are killed afterward. They never outlive the command.
+func (c *Command) Wait() error {
+	err := c.Cmd.Wait()
+
+	c.killGroup()
+
+	return err //nolint:wrapcheck
 }
 
 type SubCommand string
 
 const (
-	SubCommandInit  SubCommand = "init"
-	SubCommandPlan  SubCommand = "plan"
-	SubCommandApply SubCommand = "apply"
+	SubCommandInit          SubCommand = "init"
+	SubCommandPlan          SubCommand = "plan"
+	SubCommandApply         SubCommand = "apply"
+	SubCommandDestroy       SubCommand = "destroy"
+	SubCommandValidate      SubCommand = "validate"
+	SubCommandShow          SubCommand = "show"
+	SubCommandOutput        SubCommand = "output"
+	SubCommandStateList     SubCommand = "state list"
+	SubCommandStateShow     SubCommand = "state show"
+	SubCommandStateRm       SubCommand = "state rm"
+	SubCommandStateMv       SubCommand = "state mv"
+	SubCommandStatePull     SubCommand = "state pull"
+	SubCommandImport        SubCommand = "import"
+	SubCommandForceUnlock   SubCommand = "force-unlock"
+	SubCommandProvidersLock SubCommand = "providers lock"
 )
 
 // GetCommandInit returns the command for `<provisioner> init`.
 //
 // The command is built with the given arguments and context.
-// Arguments are appended to the command string.
+// Arguments are validated and appended to the command string.
 func (bp *BaseProvisioner) GetCommandInit(ctx context.Context, args []string) (*Command, error) {
-	err := bp.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("invalid provisioner settings: %w", err)
-	}
-
-	return buildCommand(
-		bp.WorkingDirectory,
-		bp.ExecutablePath,
-		SubCommandInit,
-		args,
-		ctx,
-	), nil
+	return bp.command(ctx, SubCommandInit, args)
 }
 
 // GetCommandPlan returns the command for `<provisioner> plan`.
 //
 // The command is built with the given arguments and context.
-// Arguments are appended to the command string.
+// Arguments are validated and appended to the command string.
 func (bp *BaseProvisioner) GetCommandPlan(ctx context.Context, args []string) (*Command, error) {
-	err := bp.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("invalid provisioner settings: %w", err)
-	}
+	return bp.command(ctx, SubCommandPlan, args)
+}
 
-	return buildCommand(
-		bp.WorkingDirectory,
-		bp.ExecutablePath,
-		SubCommandPlan,
-		args,
-		ctx,
-	), nil
+// GetCommandPlanDestroy returns the command for `<provisioner> plan -destroy`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandPlanDestroy(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandPlan, append([]string{"-destroy"}, args...))
+}
+
+// GetCommandPlanRefreshOnly returns the command for `<provisioner> plan -refresh-only`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandPlanRefreshOnly(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandPlan, append([]string{"-refresh-only"}, args...))
 }
 
 // GetCommandApply returns the command for `<provisioner> apply`.
 //
 // The command is built with the given arguments and context.
-// Arguments are appended to the command string.
+// Arguments are validated and appended to the command string.
 func (bp *BaseProvisioner) GetCommandApply(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandApply, args)
+}
+
+// GetCommandDestroy returns the command for `<provisioner> destroy`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandDestroy(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandDestroy, args)
+}
+
+// GetCommandValidate returns the command for `<provisioner> validate`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandValidate(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandValidate, args)
+}
+
+// GetCommandShow returns the command for `<provisioner> show`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandShow(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandShow, args)
+}
+
+// GetCommandOutput returns the command for `<provisioner> output`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandOutput(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandOutput, args)
+}
+
+// GetCommandStateList returns the command for `<provisioner> state list`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandStateList(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandStateList, args)
+}
+
+// GetCommandStateShow returns the command for `<provisioner> state show`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandStateShow(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandStateShow, args)
+}
+
+// GetCommandStateRm returns the command for `<provisioner> state rm`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandStateRm(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandStateRm, args)
+}
+
+// GetCommandStateMv returns the command for `<provisioner> state mv`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandStateMv(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandStateMv, args)
+}
+
+// GetCommandStatePull returns the command for `<provisioner> state pull`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandStatePull(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandStatePull, args)
+}
+
+// GetCommandImport returns the command for `<provisioner> import`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandImport(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandImport, args)
+}
+
+// GetCommandForceUnlock returns the command for `<provisioner> force-unlock`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandForceUnlock(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandForceUnlock, args)
+}
+
+// GetCommandProvidersLock returns the command for `<provisioner> providers lock`.
+//
+// The command is built with the given arguments and context.
+// Arguments are validated and appended to the command string.
+func (bp *BaseProvisioner) GetCommandProvidersLock(ctx context.Context, args []string) (*Command, error) {
+	return bp.command(ctx, SubCommandProvidersLock, args)
+}
+
+// command returns the command for the subcommand.
+//
+// The provisioner settings and the arguments are validated before the command is built.
+// Only arguments that are allowed by the spec of the subcommand are accepted.
+func (bp *BaseProvisioner) command(ctx context.Context, subcommand SubCommand, args []string) (*Command, error) {
 	err := bp.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("invalid provisioner settings: %w", err)
 	}
 
-	return buildCommand(
+	spec, ok := subCommandSpecs()[subcommand]
+	if !ok {
+		return nil, fmt.Errorf("unsupported subcommand: %s", subcommand)
+	}
+
+	err = spec.validateArgs(subcommand, args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	cmd := buildCommand(
 		bp.WorkingDirectory,
 		bp.ExecutablePath,
-		SubCommandApply,
+		subcommand,
 		args,
+		bp.Environment,
+		bp.RunDirectory,
 		ctx,
-	), nil
+	)
+
+	return bp.isolate(cmd)
+}
+
+// isolate applies the isolation and the cancel grace period of the provisioner settings to the command.
+func (bp *BaseProvisioner) isolate(cmd *Command) (*Command, error) {
+	err := isolate(cmd, bp.ProvisionerConfig.Isolation)
+	if err != nil {
+		return nil, fmt.Errorf("cant isolate command: %w", err)
+	}
+
+	// the command is interrupted once ctx is done. if it does not exit within the grace period, it is killed
+	cmd.WaitDelay = bp.ProvisionerConfig.CancelGracePeriod
+
+	return cmd, nil
 }
 
 // buildCommand returns a new Command.
 //
 // workdir, executable and subcommand are used to build the command string.
+// The command gets a minimal environment with the injected variables of env. The environment of resource-nexus-core
+// is not inherited. The values of env are masked inside the output of the command.
+// If runDirectory is set, HOME and TMPDIR point to the private directories of the run.
 // ctx that will be used for the Command.
 //
 // Ensure the provided executable, subcommand and args are validated and no injection attacks are possible!
+// Use BaseProvisioner.command to validate the arguments.
 func buildCommand(
-	workdir string, executable string, subcommand SubCommand, args []string, ctx context.Context,
+	workdir string, executable string, subcommand SubCommand, args []string, env map[string]string, runDirectory string,
+	ctx context.Context,
 ) *Command {
-	command := Command{}
+	command := Command{
+		masker: credentials.NewMasker(slices.Collect(maps.Values(env))),
+	}
+
+	// subcommands can consist of multiple words. e.g. 'state list'
+	arguments := strings.Fields(string(subcommand))
 
-	// add subcommand and custom arguments to the string array
-	arguments := append([]string{string(subcommand)}, args...)
-	// append json argument
-	arguments = append(arguments, "--json")
+	// add json argument if supported. flags need to be placed before positional arguments like a plan file
+	if jsonFlag := subCommandSpecs()[subcommand].jsonFlag; jsonFlag != "" {
+		arguments = append(arguments, jsonFlag)
+	}
+
+	// add custom arguments
+	arguments = append(arguments, args...)
 
 	// builds command with context
 	// The allowed exec commands are defined inside the config files.
@@ -100,6 +277,9 @@ func buildCommand(
 	// specify work directory
 	command.Dir = workdir
 
+	// never inherit the environment of resource-nexus-core
+	command.Env = buildEnvironment(env, runDirectory)
+
 	// define the command that will be triggered once the context is reached / canceled
 	command.Cancel = func() error {
 		return command.Process.Signal(os.Interrupt)
@@ -2,26 +2,48 @@ package provisioning
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
 )
 
 func Test_buildCommand(t *testing.T) {
 	var (
-		sub  SubCommand = "bar"
+		sub  SubCommand = "plan"
 		args            = []string{"--arg1", "--arg2"}
 	)
 
-	c := buildCommand("/dummy/dir", "./foo", sub, args, context.TODO())
+	c := buildCommand("/dummy/dir", "./foo", sub, args, nil, "", context.TODO())
 
 	if c.Cmd.Dir != "/dummy/dir" {
 		t.Fatalf("wrong working directory: %s", c.Cmd.Path)
 	}
 
-	if c.Cmd.String() != "./foo bar --arg1 --arg2 --json" {
+	if c.Cmd.String() != "./foo plan --json --arg1 --arg2" {
 		t.Fatalf("wrong command: %s", c.Cmd.String())
 	}
+
+	// subcommands without machine-readable output stream
+	c = buildCommand("/dummy/dir", "./foo", SubCommandStatePull, nil, nil, "", context.TODO())
+
+	if c.Cmd.String() != "./foo state pull" {
+		t.Fatalf("wrong command: %s", c.Cmd.String())
+	}
+
+	// injected variables are part of the environment and masked inside the output
+	c = buildCommand(
+		"/dummy/dir", "./foo", SubCommandPlan, nil, map[string]string{"TF_VAR_token": "s3cr3t"}, "", context.TODO(),
+	)
+
+	if !slices.Contains(c.Env, "TF_VAR_token=s3cr3t") {
+		t.Fatalf("injected variable is missing: %v", c.Env)
+	}
+
+	if c.masker.Mask("token s3cr3t") != "token "+credentials.MaskPlaceholder {
+		t.Fatal("injected value should be masked")
+	}
 }
 
 func Test_GetCommandInit(t *testing.T) {
@@ -80,3 +102,80 @@ func Test_GetCommandApply(t *testing.T) {
 		t.Fatalf("wrong command: %s", c.Cmd.String())
 	}
 }
+
+func Test_GetCommandShow(t *testing.T) {
+	bp := BaseProvisioner{
+		ExecutablePath:   "/usr/local/bin/terraform",
+		WorkingDirectory: "bar",
+		ProvisionerConfig: config.Provisioner{
+			AllowedExecutables: "/usr/local/bin/terraform",
+		},
+	}
+
+	c, err := bp.GetCommandShow(context.TODO(), []string{"run.tfplan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cmd.String() != "/usr/local/bin/terraform show -json run.tfplan" {
+		t.Fatalf("wrong command: %s", c.Cmd.String())
+	}
+}
+
+func Test_GetCommandStateMv(t *testing.T) {
+	bp := BaseProvisioner{
+		ExecutablePath:   "/usr/local/bin/terraform",
+		WorkingDirectory: "bar",
+		ProvisionerConfig: config.Provisioner{
+			AllowedExecutables: "/usr/local/bin/terraform",
+		},
+	}
+
+	c, err := bp.GetCommandStateMv(context.TODO(), []string{"-dry-run", "module.a", "module.b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cmd.String() != "/usr/local/bin/terraform state mv -dry-run module.a module.b" {
+		t.Fatalf("wrong command: %s", c.Cmd.String())
+	}
+}
+
+func Test_GetCommandPlanDestroy(t *testing.T) {
+	bp := BaseProvisioner{
+		ExecutablePath:   "/usr/local/bin/terraform",
+		WorkingDirectory: "bar",
+		ProvisionerConfig: config.Provisioner{
+			AllowedExecutables: "/usr/local/bin/terraform",
+		},
+	}
+
+	c, err := bp.GetCommandPlanDestroy(context.TODO(), []string{"-input=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cmd.String() != "/usr/local/bin/terraform plan --json -destroy -input=false" {
+		t.Fatalf("wrong command: %s", c.Cmd.String())
+	}
+}
+
+func Test_GetCommandInvalidArgs(t *testing.T) {
+	bp := BaseProvisioner{
+		ExecutablePath:   "/usr/local/bin/terraform",
+		WorkingDirectory: "bar",
+		ProvisionerConfig: config.Provisioner{
+			AllowedExecutables: "/usr/local/bin/terraform",
+		},
+	}
+
+	_, err := bp.GetCommandPlan(context.TODO(), []string{"-var-file=/etc/shadow"})
+	if err == nil {
+		t.Fatal("flag that is not allowed should return an error")
+	}
+
+	_, err = bp.GetCommandStatePull(context.TODO(), []string{"-json"})
+	if err == nil {
+		t.Fatal("state pull does not accept any arguments")
+	}
+}
@@ -0,0 +1,49 @@
+package provisioning
+
+import (
+	"maps"
+	"os"
+	"slices"
+)
+
+// passthroughVariables returns the environment variables of resource-nexus-core that are passed to the commands.
+// Everything else of the environment of resource-nexus-core is not visible to the provisioner.
+func passthroughVariables() []string {
+	return []string{
+		"PATH", "HOME", "LANG",
+		"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+		"SSL_CERT_FILE", "SSL_CERT_DIR",
+	}
+}
+
+// buildEnvironment returns the minimal environment of a command.
+//
+// It consists of the passthrough variables, the settings for non-interactive execution and the injected variables.
+// Injected variables are sorted by name, so the environment is stable.
+// If runDirectory is set, HOME and TMPDIR point to the private directories inside of it.
+func buildEnvironment(injected map[string]string, runDirectory string) []string {
+	env := make([]string, 0, len(passthroughVariables())+len(injected)+4)
+
+	for _, name := range passthroughVariables() {
+		if name == "HOME" && runDirectory != "" {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	if runDirectory != "" {
+		env = append(env, "HOME="+runHome(runDirectory), "TMPDIR="+runTemp(runDirectory))
+	}
+
+	// disable interactive prompts and hints for manual usage
+	env = append(env, "TF_IN_AUTOMATION=1", "TF_INPUT=0")
+
+	for _, name := range slices.Sorted(maps.Keys(injected)) {
+		env = append(env, name+"="+injected[name])
+	}
+
+	return env
+}
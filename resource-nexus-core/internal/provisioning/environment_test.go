@@ -0,0 +1,42 @@
+package provisioning
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_buildEnvironment(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("RESOURCE_NEXUS_SECRET", "server-only")
+
+	env := buildEnvironment(map[string]string{
+		"TF_VAR_token":        "s3cr3t",
+		"PM_API_TOKEN_SECRET": "other",
+	}, "")
+
+	for _, expected := range []string{
+		"PATH=/usr/bin", "TF_IN_AUTOMATION=1", "TF_INPUT=0", "TF_VAR_token=s3cr3t", "PM_API_TOKEN_SECRET=other",
+	} {
+		if !slices.Contains(env, expected) {
+			t.Fatalf("environment is missing %s: %v", expected, env)
+		}
+	}
+
+	if slices.Contains(env, "RESOURCE_NEXUS_SECRET=server-only") {
+		t.Fatal("environment of the server should not be inherited")
+	}
+}
+
+func Test_buildEnvironmentRunDirectory(t *testing.T) {
+	t.Setenv("HOME", "/root")
+
+	env := buildEnvironment(nil, "/tmp/run")
+
+	if slices.Contains(env, "HOME=/root") {
+		t.Fatal("HOME of the server should not be passed")
+	}
+
+	if !slices.Contains(env, "HOME=/tmp/run/home") || !slices.Contains(env, "TMPDIR=/tmp/run/tmp") {
+		t.Fatalf("private HOME and TMPDIR are missing: %v", env)
+	}
+}
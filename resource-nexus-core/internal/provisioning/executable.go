@@ -0,0 +1,27 @@
+package provisioning
+
+import (
+	"errors"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/common/semver"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+// ErrNoExecutable is returned if no installed executable matches the requirements of a workspace.
+var ErrNoExecutable = errors.New("no matching executable installed")
+
+// Executable is an installed executable of a provisioner.
+type Executable struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Path    string         `json:"path"`
+	Version semver.Version `json:"version"` // detected version of the executable
+}
+
+// Resolver resolves the executable that is used for the runs of a workspace.
+//
+// The executable needs to match the provisioner type, the pinned executable and the required version of the workspace.
+// Returns an error wrapping ErrNoExecutable if no executable matches.
+type Resolver interface {
+	Resolve(workspace database.Workspace) (Executable, error)
+}
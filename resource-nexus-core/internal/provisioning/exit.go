@@ -0,0 +1,85 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+// ExitStatus is the classified result of an executed provisioning command.
+type ExitStatus string
+
+const (
+	ExitStatusSuccess  ExitStatus = "success"   // exit code 0
+	ExitStatusChanges  ExitStatus = "changes"   // exit code 2. only returned by ClassifyDetailedExit
+	ExitStatusFailed   ExitStatus = "failed"    // any other exit code or the command could not be started
+	ExitStatusCanceled ExitStatus = "canceled"  // context canceled
+	ExitStatusTimedOut ExitStatus = "timed_out" // context deadline exceeded
+)
+
+// exitCodeChanges is returned by `plan -detailed-exitcode` if the plan contains changes.
+const exitCodeChanges = 2
+
+// Succeeded returns true if the command finished without errors. Changes are only reported with `-detailed-exitcode`.
+func (s ExitStatus) Succeeded() bool {
+	return s == ExitStatusSuccess || s == ExitStatusChanges
+}
+
+// ClassifyExit classifies the error returned by exec.Cmd.Wait.
+//
+// The context of the command is checked first. A command that was interrupted because of a canceled context
+// often exits with a regular error code, but should be treated as canceled or timed out.
+// A process killed by a signal while the context is alive failed. e.g. killed by a resource limit or the OOM killer.
+// Returns the ExitStatus and the exit code of the process. The exit code is -1 if the process did not exit regularly.
+// Exit code 2 is a failure. Use ClassifyDetailedExit for commands started with `-detailed-exitcode`.
+func ClassifyExit(ctx context.Context, err error) (ExitStatus, int) {
+	exitCode := 0
+
+	if err != nil {
+		exitCode = -1
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	// context reached. the process has been interrupted
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return ExitStatusTimedOut, exitCode
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ExitStatusCanceled, exitCode
+	}
+
+	if exitCode == 0 {
+		return ExitStatusSuccess, exitCode
+	}
+
+	return ExitStatusFailed, exitCode
+}
+
+// ClassifyDetailedExit is like ClassifyExit, but for commands started with `-detailed-exitcode`.
+// Exit code 2 reports changes instead of a failure.
+func ClassifyDetailedExit(ctx context.Context, err error) (ExitStatus, int) {
+	status, exitCode := ClassifyExit(ctx, err)
+	if status == ExitStatusFailed && exitCode == exitCodeChanges {
+		return ExitStatusChanges, exitCode
+	}
+
+	return status, exitCode
+}
+
+// runStatusForExit returns the final run status for a phase that did not succeed.
+func runStatusForExit(status ExitStatus) database.RunStatus {
+	switch status { //nolint:exhaustive
+	case ExitStatusCanceled:
+		return database.RunStatusCanceled
+	case ExitStatusTimedOut:
+		return database.RunStatusTimedOut
+	default:
+		return database.RunStatusErrored
+	}
+}
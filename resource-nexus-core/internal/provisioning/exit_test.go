@@ -0,0 +1,109 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestClassifyExit(t *testing.T) {
+	tests := []struct {
+		script   string
+		status   ExitStatus
+		exitCode int
+	}{
+		{"exit 0", ExitStatusSuccess, 0},
+		{"exit 1", ExitStatusFailed, 1},
+		{"exit 2", ExitStatusFailed, 2},
+		{"kill -9 $$", ExitStatusFailed, -1},
+	}
+
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+
+		status, code := ClassifyExit(context.TODO(), err)
+		if status != tt.status || code != tt.exitCode {
+			t.Fatalf("%s: expected %s (%d), got %s (%d)", tt.script, tt.status, tt.exitCode, status, code)
+		}
+	}
+}
+
+func TestClassifyDetailedExit(t *testing.T) {
+	tests := []struct {
+		script   string
+		status   ExitStatus
+		exitCode int
+	}{
+		{"exit 0", ExitStatusSuccess, 0},
+		{"exit 1", ExitStatusFailed, 1},
+		{"exit 2", ExitStatusChanges, 2},
+	}
+
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+
+		status, code := ClassifyDetailedExit(context.TODO(), err)
+		if status != tt.status || code != tt.exitCode {
+			t.Fatalf("%s: expected %s (%d), got %s (%d)", tt.script, tt.status, tt.exitCode, status, code)
+		}
+	}
+}
+
+func TestClassifyExitSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sleep", "5")
+
+	err := cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// killed by a resource limit while the run is not canceled
+	_ = cmd.Process.Signal(syscall.SIGXCPU)
+
+	status, code := ClassifyExit(ctx, cmd.Wait())
+	if status != ExitStatusFailed || code != -1 {
+		t.Fatalf("expected %s (-1), got %s (%d)", ExitStatusFailed, status, code)
+	}
+
+	if runStatusForExit(status) != database.RunStatusErrored {
+		t.Fatal("killed command should result in errored run")
+	}
+}
+
+func TestClassifyExitContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+
+	err := exec.CommandContext(ctx, "sleep", "5").Run()
+
+	status, _ := ClassifyExit(ctx, err)
+	if status != ExitStatusTimedOut {
+		t.Fatalf("expected %s, got %s", ExitStatusTimedOut, status)
+	}
+
+	ctx, cancel = context.WithCancel(context.TODO())
+	cancel()
+
+	status, _ = ClassifyExit(ctx, errors.New("boom"))
+	if status != ExitStatusCanceled {
+		t.Fatalf("expected %s, got %s", ExitStatusCanceled, status)
+	}
+}
+
+func TestRunStatusForExit(t *testing.T) {
+	if runStatusForExit(ExitStatusFailed) != database.RunStatusErrored {
+		t.Fatal("failed exit should result in errored run")
+	}
+
+	if runStatusForExit(ExitStatusTimedOut) != database.RunStatusTimedOut {
+		t.Fatal("timed out exit should result in timed out run")
+	}
+}
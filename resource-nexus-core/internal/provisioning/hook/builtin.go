@@ -0,0 +1,59 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Builtins returns the built-in hooks by their name.
+func Builtins() map[string]func(ctx context.Context, input Input) (Result, error) {
+	return map[string]func(ctx context.Context, input Input) (Result, error){
+		"deny-delete": denyDelete,
+	}
+}
+
+// plannedChanges is the part of the plan rendered by `show -json` that is read by the built-in hooks.
+type plannedChanges struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// denyDelete vetoes plans that delete resources. Replaced resources are deleted as well.
+//
+// Points without plan are not vetoed.
+func denyDelete(_ context.Context, input Input) (Result, error) {
+	if len(input.Plan) == 0 {
+		return Result{}, nil
+	}
+
+	var plan plannedChanges
+
+	err := json.Unmarshal(input.Plan, &plan)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to decode plan: %w", err)
+	}
+
+	var deleted []string
+
+	for _, change := range plan.ResourceChanges {
+		if slices.Contains(change.Change.Actions, "delete") {
+			deleted = append(deleted, change.Address)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return Result{}, nil
+	}
+
+	return Result{
+		Veto:   true,
+		Reason: fmt.Sprintf("plan deletes resources: %s", strings.Join(deleted, ", ")),
+	}, nil
+}
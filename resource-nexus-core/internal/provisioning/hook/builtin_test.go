@@ -0,0 +1,34 @@
+package hook
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_denyDelete(t *testing.T) {
+	result, err := denyDelete(context.TODO(), Input{Point: PointPreApply, Plan: []byte(`{"resource_changes":[
+		{"address":"null_resource.a","change":{"actions":["create"]}},
+		{"address":"null_resource.b","change":{"actions":["delete","create"]}}
+	]}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Veto || !strings.Contains(result.Reason, "null_resource.b") {
+		t.Fatalf("replaced resource should be vetoed: %+v", result)
+	}
+
+	result, err = denyDelete(context.TODO(), Input{Point: PointPreApply, Plan: []byte(`{"resource_changes":[
+		{"address":"null_resource.a","change":{"actions":["update"]}}
+	]}`)})
+	if err != nil || result.Veto {
+		t.Fatal("plan without delete should not be vetoed")
+	}
+
+	// points without plan
+	result, err = denyDelete(context.TODO(), Input{Point: PointPreInit})
+	if err != nil || result.Veto {
+		t.Fatal("points without plan should not be vetoed")
+	}
+}
@@ -0,0 +1,128 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultTimeout is the timeout of executable hooks without a configured timeout.
+const defaultTimeout = 30 * time.Second
+
+// maxOutputSize is the maximum number of bytes of stderr that is added to the error of a failed hook.
+const maxOutputSize = 4 * 1024
+
+// waitDelay is the time an executable hook gets to exit after it has been interrupted, and to close its output after
+// it has exited. It is killed afterward.
+const waitDelay = 5 * time.Second
+
+// Command is the started command of an executable hook.
+type Command interface {
+	Start() error
+	Wait() error
+}
+
+// Isolator prepares the command of an executable hook before it is started.
+//
+// The environment of the command only contains the variables of the hook. The Isolator can add variables, e.g. the
+// passthrough variables of the provisioner, and isolate the command. The returned Command is started instead of cmd.
+type Isolator func(cmd *exec.Cmd) (Command, error)
+
+// Executable is a hook that executes an external executable.
+//
+// The Input is written as JSON to stdin. The point and the id of the run are set as RESOURCE_NEXUS_HOOK_POINT and
+// RESOURCE_NEXUS_RUN_ID. The environment of resource-nexus-core is not inherited.
+// A Result can be printed as JSON to stdout. Empty stdout is a Result without veto and annotations.
+// Exit codes other than 0 are failures of the hook.
+type Executable struct {
+	name    string
+	path    string
+	timeout time.Duration
+	isolate Isolator
+}
+
+// NewExecutable returns a new Executable. A timeout of 0 uses the default timeout.
+// A nil isolate executes the hook with the variables of the hook only.
+func NewExecutable(name string, path string, timeout time.Duration, isolate Isolator) Executable {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return Executable{name: name, path: path, timeout: timeout, isolate: isolate}
+}
+
+func (e Executable) Name() string {
+	return e.name
+}
+
+func (e Executable) Run(ctx context.Context, input Input) (Result, error) {
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to encode input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	// the path is configured by the administrator
+	cmd := exec.CommandContext(ctx, e.path) //nolint:gosec
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = []string{
+		"RESOURCE_NEXUS_HOOK_POINT=" + string(input.Point),
+		fmt.Sprintf("RESOURCE_NEXUS_RUN_ID=%d", input.Run.ID),
+	}
+	// processes that keep the output open, e.g. started in the background, can't block the hook past its timeout
+	cmd.WaitDelay = waitDelay
+
+	err = e.run(cmd)
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > maxOutputSize {
+			message = message[:maxOutputSize]
+		}
+
+		return Result{}, fmt.Errorf("%w: %s", err, message)
+	}
+
+	var result Result
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return result, nil
+	}
+
+	err = json.Unmarshal(stdout.Bytes(), &result)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to decode result: %w", err)
+	}
+
+	return result, nil
+}
+
+// run starts the command through the Isolator and waits for it to exit.
+func (e Executable) run(cmd *exec.Cmd) error {
+	var command Command = cmd
+
+	if e.isolate != nil {
+		var err error
+
+		command, err = e.isolate(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := command.Start()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return command.Wait() //nolint:wrapcheck
+}
@@ -0,0 +1,107 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+// writeHook writes an executable hook script into a temporary directory.
+func writeHook(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hook")
+
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestExecutableRun(t *testing.T) {
+	// the environment of resource-nexus-core is not inherited
+	t.Setenv("RESOURCE_NEXUS_SECRET", "s3cret")
+
+	// the hook echoes the point of the input and the environment
+	path := writeHook(t, `input=$(cat)
+case "$input" in *'"point":"post-plan"'*) ;; *) exit 1;; esac
+annotations="\"point\":\"$RESOURCE_NEXUS_HOOK_POINT\",\"secret\":\"$RESOURCE_NEXUS_SECRET\""
+echo "{\"veto\":true,\"reason\":\"run $RESOURCE_NEXUS_RUN_ID\",\"annotations\":{$annotations}}"
+`)
+
+	result, err := NewExecutable("check", path, 0, nil).Run(context.TODO(), Input{
+		Point: PointPostPlan,
+		Run:   database.Run{ID: 9},
+		Plan:  []byte(`{"resource_changes":[]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Veto || result.Reason != "run 9" || result.Annotations["point"] != "post-plan" ||
+		result.Annotations["secret"] != "" {
+		t.Fatalf("wrong result: %+v", result)
+	}
+
+	// empty stdout
+	result, err = NewExecutable("noop", writeHook(t, "exit 0\n"), 0, nil).Run(context.TODO(), Input{Point: PointPreInit})
+	if err != nil || result.Veto {
+		t.Fatalf("empty output should not veto: %v", err)
+	}
+}
+
+func TestExecutableRunFailure(t *testing.T) {
+	_, err := NewExecutable("broken", writeHook(t, "echo 'cmdb unreachable' >&2; exit 3\n"), 0, nil).
+		Run(context.TODO(), Input{Point: PointPreInit})
+	if err == nil || !strings.Contains(err.Error(), "cmdb unreachable") {
+		t.Fatalf("failure should contain stderr: %v", err)
+	}
+
+	_, err = NewExecutable("invalid", writeHook(t, "echo 'not json'\n"), 0, nil).
+		Run(context.TODO(), Input{Point: PointPreInit})
+	if err == nil {
+		t.Fatal("invalid output should return an error")
+	}
+
+	start := time.Now()
+
+	_, err = NewExecutable("slow", writeHook(t, "exec sleep 10\n"), 100*time.Millisecond, nil).
+		Run(context.TODO(), Input{Point: PointPreInit})
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Fatal("hook should be killed after the timeout")
+	}
+}
+
+func TestExecutableRunIsolate(t *testing.T) {
+	var isolated bool
+
+	isolate := func(cmd *exec.Cmd) (Command, error) {
+		isolated = true
+		cmd.Env = append(cmd.Env, "RESOURCE_NEXUS_ISOLATED=1")
+
+		return cmd, nil
+	}
+
+	path := writeHook(t, `echo "{\"annotations\":{\"isolated\":\"$RESOURCE_NEXUS_ISOLATED\"}}"`)
+
+	result, err := NewExecutable("check", path, 0, isolate).Run(context.TODO(), Input{Point: PointPreInit})
+	if err != nil || !isolated || result.Annotations["isolated"] != "1" {
+		t.Fatalf("hook should be prepared by the isolator: %+v, %v", result, err)
+	}
+
+	_, err = NewExecutable("broken", writeHook(t, "exit 0\n"), 0, func(_ *exec.Cmd) (Command, error) {
+		return nil, errors.New("boom")
+	}).Run(context.TODO(), Input{Point: PointPreInit})
+	if err == nil {
+		t.Fatal("failed isolation should return an error")
+	}
+}
@@ -0,0 +1,202 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// Point is a point of a run at which hooks are executed.
+type Point string
+
+const (
+	PointPreInit     Point = "pre-init"     // before the first init of each stage
+	PointPostPlan    Point = "post-plan"    // after the plan has been saved. before the run waits for approval
+	PointPreApply    Point = "pre-apply"    // before the approved plan is applied
+	PointPostApply   Point = "post-apply"   // after the plan has been applied
+	PointPostDestroy Point = "post-destroy" // after a destroy plan has been applied
+)
+
+// Points returns all points at which hooks can be executed.
+func Points() []Point {
+	return []Point{PointPreInit, PointPostPlan, PointPreApply, PointPostApply, PointPostDestroy}
+}
+
+// Input is passed to each hook. Executable hooks receive it as JSON on stdin.
+type Input struct {
+	Point Point           `json:"point"`
+	Run   database.Run    `json:"run"`
+	Plan  json.RawMessage `json:"plan,omitempty"` // rendering of the saved plan by `show -json`. empty at pre-init
+}
+
+// Result is returned by a hook.
+type Result struct {
+	Veto        bool              `json:"veto"`        // stops the run
+	Reason      string            `json:"reason"`      // reason of the veto
+	Annotations map[string]string `json:"annotations"` // values that are added to the run
+}
+
+// Hook is executed at a point of a run.
+type Hook interface {
+	Name() string
+	Run(ctx context.Context, input Input) (Result, error)
+}
+
+// Func is a built-in hook implemented in Go.
+type Func struct {
+	name string
+	fn   func(ctx context.Context, input Input) (Result, error)
+}
+
+// NewFunc returns a new built-in hook that executes fn.
+func NewFunc(name string, fn func(ctx context.Context, input Input) (Result, error)) Func {
+	return Func{name: name, fn: fn}
+}
+
+func (f Func) Name() string {
+	return f.name
+}
+
+func (f Func) Run(ctx context.Context, input Input) (Result, error) {
+	return f.fn(ctx, input)
+}
+
+// VetoError is returned by Hooks.Run if a hook vetoed the run.
+type VetoError struct {
+	Hook   string
+	Reason string
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("vetoed by hook '%s': %s", e.Hook, e.Reason)
+}
+
+// registered is a hook registered for a point.
+type registered struct {
+	hook         Hook
+	ignoreErrors bool
+}
+
+// Hooks holds the registered hooks of each point.
+//
+// The hooks of a point are executed in the order they have been registered. A nil *Hooks executes no hooks.
+type Hooks struct {
+	hooks  map[Point][]registered
+	logger *logging.Logger
+}
+
+// New returns a new Hooks without registered hooks.
+func New(logger *logging.Logger) *Hooks {
+	return &Hooks{
+		hooks:  make(map[Point][]registered),
+		logger: logger,
+	}
+}
+
+// Load returns a new Hooks with the hooks of the provisioner settings.
+//
+// Built-in hooks are referenced by their name. Executable hooks are prepared by isolate.
+// Returns an error if a hook is invalid.
+func Load(conf []config.Hook, isolate Isolator, logger *logging.Logger) (*Hooks, error) {
+	hooks := New(logger)
+	builtins := Builtins()
+	names := make([]string, 0, len(conf))
+
+	for _, c := range conf {
+		if c.Name == "" || slices.Contains(names, c.Name) {
+			return nil, fmt.Errorf("hook names need to be unique and not empty: '%s'", c.Name)
+		}
+
+		names = append(names, c.Name)
+
+		var hook Hook
+
+		switch {
+		case c.Path != "" && c.Builtin != "":
+			return nil, fmt.Errorf("hook '%s' can't have a path and a builtin", c.Name)
+		case c.Path != "":
+			if !filepath.IsAbs(c.Path) {
+				return nil, fmt.Errorf("path of hook '%s' needs to be absolute", c.Name)
+			}
+
+			hook = NewExecutable(c.Name, c.Path, c.Timeout, isolate)
+		case c.Builtin != "":
+			fn, ok := builtins[c.Builtin]
+			if !ok {
+				return nil, fmt.Errorf("unknown builtin of hook '%s': %s", c.Name, c.Builtin)
+			}
+
+			hook = NewFunc(c.Name, fn)
+		default:
+			return nil, fmt.Errorf("hook '%s' needs a path or a builtin", c.Name)
+		}
+
+		err := hooks.Register(Point(c.Point), hook, c.IgnoreErrors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hooks, nil
+}
+
+// Register registers the hook for the point.
+//
+// If ignoreErrors is set, failures of the hook are logged instead of failing the run. Vetoes are never ignored.
+func (h *Hooks) Register(point Point, hook Hook, ignoreErrors bool) error {
+	if !slices.Contains(Points(), point) {
+		return fmt.Errorf("unknown point of hook '%s': '%s'", hook.Name(), point)
+	}
+
+	h.hooks[point] = append(h.hooks[point], registered{hook: hook, ignoreErrors: ignoreErrors})
+
+	return nil
+}
+
+// Run executes the hooks of the point of input.
+//
+// Returns the annotations of all executed hooks. The execution stops at the first veto or failure.
+// A veto is returned as *VetoError.
+func (h *Hooks) Run(ctx context.Context, input Input) ([]database.RunAnnotation, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	var annotations []database.RunAnnotation
+
+	for _, r := range h.hooks[input.Point] {
+		result, err := r.hook.Run(ctx, input)
+		if err != nil && r.ignoreErrors {
+			h.logger.Warn("hook failed. error ignored",
+				"hook", r.hook.Name(), "point", input.Point, "run", input.Run.ID, "error", err)
+
+			continue
+		}
+
+		if err != nil {
+			return annotations, fmt.Errorf("hook '%s' failed: %w", r.hook.Name(), err)
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(result.Annotations)) {
+			annotations = append(annotations, database.RunAnnotation{
+				RunID: input.Run.ID,
+				Hook:  r.hook.Name(),
+				Name:  name,
+				Value: result.Annotations[name],
+			})
+		}
+
+		if result.Veto {
+			return annotations, &VetoError{Hook: r.hook.Name(), Reason: result.Reason}
+		}
+	}
+
+	return annotations, nil
+}
@@ -0,0 +1,106 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestLoad(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+
+	hooks, err := Load([]config.Hook{
+		{Name: "cmdb", Point: "post-apply", Path: "/usr/local/bin/cmdb-register"},
+		{Name: "no-delete", Point: "pre-apply", Builtin: "deny-delete"},
+	}, nil, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hooks.hooks[PointPostApply]) != 1 || len(hooks.hooks[PointPreApply]) != 1 {
+		t.Fatal("hooks should be registered for their points")
+	}
+
+	for _, invalid := range [][]config.Hook{
+		{{Name: "", Point: "pre-apply", Builtin: "deny-delete"}},
+		{{Name: "a", Point: "pre-apply", Builtin: "deny-delete"}, {Name: "a", Point: "post-plan", Builtin: "deny-delete"}},
+		{{Name: "a", Point: "pre-apply"}},
+		{{Name: "a", Point: "pre-apply", Path: "/bin/true", Builtin: "deny-delete"}},
+		{{Name: "a", Point: "pre-apply", Path: "relative"}},
+		{{Name: "a", Point: "pre-apply", Builtin: "unknown"}},
+		{{Name: "a", Point: "pre-everything", Builtin: "deny-delete"}},
+	} {
+		_, err = Load(invalid, nil, l)
+		if err == nil {
+			t.Fatalf("invalid hooks should return an error: %+v", invalid)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	hooks := New(l)
+
+	var executed []string
+
+	hook := func(name string, result Result, err error) Func {
+		return NewFunc(name, func(_ context.Context, _ Input) (Result, error) {
+			executed = append(executed, name)
+
+			return result, err
+		})
+	}
+
+	_ = hooks.Register(PointPreApply, hook("broken", Result{}, errors.New("boom")), true)
+	_ = hooks.Register(PointPreApply, hook("cmdb", Result{Annotations: map[string]string{"id": "CI1", "env": "prod"}}, nil), false)
+	_ = hooks.Register(PointPreApply, hook("check", Result{Veto: true, Reason: "change freeze"}, nil), false)
+	_ = hooks.Register(PointPreApply, hook("never", Result{}, nil), false)
+
+	annotations, err := hooks.Run(context.TODO(), Input{Point: PointPreApply, Run: database.Run{ID: 3}})
+
+	var veto *VetoError
+	if !errors.As(err, &veto) || veto.Hook != "check" || veto.Reason != "change freeze" {
+		t.Fatalf("run should be vetoed: %v", err)
+	}
+
+	if len(executed) != 3 {
+		t.Fatalf("execution should stop at the veto: %v", executed)
+	}
+
+	if len(annotations) != 2 || annotations[0].Name != "env" || annotations[1].RunID != 3 || annotations[1].Hook != "cmdb" {
+		t.Fatalf("wrong annotations: %+v", annotations)
+	}
+
+	// hooks of other points are not executed
+	_, err = hooks.Run(context.TODO(), Input{Point: PointPostPlan})
+	if err != nil || len(executed) != 3 {
+		t.Fatal("no hooks should be executed")
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	hooks := New(logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"}))
+
+	_ = hooks.Register(PointPreInit, NewFunc("broken", func(_ context.Context, _ Input) (Result, error) {
+		return Result{}, errors.New("boom")
+	}), false)
+
+	_, err := hooks.Run(context.TODO(), Input{Point: PointPreInit})
+
+	var veto *VetoError
+	if err == nil || errors.As(err, &veto) {
+		t.Fatalf("failure should be returned as error: %v", err)
+	}
+
+	// nil hooks execute nothing
+	var none *Hooks
+
+	_, err = none.Run(context.TODO(), Input{Point: PointPreInit})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package provisioning
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+)
+
+// HookIsolator returns the hook.Isolator of executable hooks.
+//
+// Hooks get the passthrough variables and are isolated like the commands of the provisioner. They are started in
+// their own process group, which is killed once the timeout of the hook is exceeded or the hook has exited.
+func HookIsolator(conf config.Isolation) hook.Isolator {
+	return func(cmd *exec.Cmd) (hook.Command, error) {
+		env := make([]string, 0, len(passthroughVariables())+len(cmd.Env))
+
+		for _, name := range passthroughVariables() {
+			if value, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+value)
+			}
+		}
+
+		cmd.Env = append(env, cmd.Env...)
+		command := &Command{Cmd: cmd}
+
+		err := isolate(command, conf)
+		if err != nil {
+			return nil, fmt.Errorf("cant isolate hook: %w", err)
+		}
+
+		// hooks are not interrupted like the provisioner. they don't need to clean up
+		command.Cancel = func() error {
+			command.killGroup()
+
+			return command.Process.Kill() //nolint:wrapcheck
+		}
+
+		return command, nil
+	}
+}
+
+// newRunDirectory creates the private directory of a run.
+//
+// It contains the HOME and TMPDIR of the executed commands. The directory is created inside the configured run
+// directory, or the default directory for temporary files if none is configured. If a uid or gid is configured, the
+// directories are owned by them. The directory needs to be removed once the run has finished.
+func newRunDirectory(conf config.Isolation, runID int) (string, error) {
+	dir, err := os.MkdirTemp(conf.RunDirectory, fmt.Sprintf("resource-nexus-run-%d-", runID))
+	if err != nil {
+		return "", fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	for _, path := range []string{dir, runHome(dir), runTemp(dir)} {
+		// dir already exists. MkdirTemp creates it with 0700
+		if path != dir {
+			err = os.Mkdir(path, 0o700)
+			if err != nil {
+				_ = os.RemoveAll(dir)
+
+				return "", fmt.Errorf("failed to create run directory: %w", err)
+			}
+		}
+
+		err = chownIsolated(path, conf)
+		if err != nil {
+			_ = os.RemoveAll(dir)
+
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// chownIsolated changes the owner of path to the configured uid and gid. Nothing is changed if none is configured.
+func chownIsolated(path string, conf config.Isolation) error {
+	if conf.Uid == 0 && conf.Gid == 0 {
+		return nil
+	}
+
+	// -1 keeps the current id
+	uid, gid := -1, -1
+
+	if conf.Uid != 0 {
+		uid = int(conf.Uid)
+	}
+
+	if conf.Gid != 0 {
+		gid = int(conf.Gid)
+	}
+
+	err := os.Chown(path, uid, gid)
+	if err != nil {
+		return fmt.Errorf("failed to change owner of %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// runHome returns the private HOME inside the run directory.
+func runHome(dir string) string {
+	return filepath.Join(dir, "home")
+}
+
+// runTemp returns the private TMPDIR inside the run directory.
+func runTemp(dir string) string {
+	return filepath.Join(dir, "tmp")
+}
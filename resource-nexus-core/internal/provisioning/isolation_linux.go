@@ -0,0 +1,169 @@
+//go:build linux
+
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// errCommandNotStopped is returned if a traced command exited before its resource limits were applied.
+var errCommandNotStopped = errors.New("command exited before the resource limits were applied")
+
+// isolate configures the command to be executed isolated from resource-nexus-core.
+//
+// The command is started in its own process group and is killed if resource-nexus-core dies. If a uid or gid is
+// configured, the command is executed as this user and all supplementary groups are dropped.
+// A cancellation interrupts the whole process group, so provider plugins are interrupted as well.
+// If resource limits are configured, the command is traced until the limits have been applied.
+func isolate(cmd *Command, conf config.Isolation) error {
+	cmd.isolation = conf
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+		Ptrace:    conf.MaxCPUTime > 0 || conf.MaxAddressSpace > 0 || conf.MaxOpenFiles > 0,
+	}
+
+	if conf.Uid != 0 || conf.Gid != 0 {
+		uid, gid := conf.Uid, conf.Gid
+
+		if uid == 0 {
+			uid = uint32(os.Getuid()) //nolint:gosec
+		}
+
+		if gid == 0 {
+			gid = uint32(os.Getgid()) //nolint:gosec
+		}
+
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid}
+	}
+
+	cmd.Cancel = func() error {
+		return cmd.signalGroup(syscall.SIGINT)
+	}
+
+	return nil
+}
+
+// start starts the command.
+//
+// A traced command stops right after the executable has been executed, before any of its instructions run. The
+// resource limits are applied while it is stopped, so neither the command nor the processes started by it, e.g.
+// provider plugins, run without them. The command is killed if the limits can't be applied.
+func (c *Command) start() error {
+	if c.SysProcAttr == nil || !c.SysProcAttr.Ptrace {
+		return c.Cmd.Start() //nolint:wrapcheck
+	}
+
+	// the tracer is the thread that started the command. ptrace requests are only accepted from this thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	err := c.Cmd.Start()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	err = c.limit()
+	if err != nil {
+		_ = c.Process.Kill()
+		c.killGroup()
+		_ = c.Cmd.Wait()
+
+		return fmt.Errorf("failed to apply resource limits: %w", err)
+	}
+
+	return nil
+}
+
+// limit waits for the traced command to stop after its executable has been executed, applies the resource limits
+// and lets the command continue untraced.
+func (c *Command) limit() error {
+	var (
+		status syscall.WaitStatus
+		err    error
+	)
+
+	for {
+		_, err = syscall.Wait4(c.Process.Pid, &status, 0, nil)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to wait for the command: %w", err)
+	}
+
+	if !status.Stopped() {
+		return errCommandNotStopped
+	}
+
+	err = applyLimits(c.Process.Pid, c.isolation)
+	if err != nil {
+		return err
+	}
+
+	err = syscall.PtraceDetach(c.Process.Pid)
+	if err != nil {
+		return fmt.Errorf("failed to detach from the command: %w", err)
+	}
+
+	return nil
+}
+
+// applyLimits applies the configured resource limits to the process.
+// Processes started by the process inherit the limits.
+func applyLimits(pid int, conf config.Isolation) error {
+	limits := map[int]uint64{
+		unix.RLIMIT_CPU:    uint64(conf.MaxCPUTime.Seconds()),
+		unix.RLIMIT_AS:     conf.MaxAddressSpace,
+		unix.RLIMIT_NOFILE: conf.MaxOpenFiles,
+	}
+
+	for resource, limit := range limits {
+		if limit == 0 {
+			continue
+		}
+
+		err := unix.Prlimit(pid, resource, &unix.Rlimit{Cur: limit, Max: limit}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to set limit %d: %w", resource, err)
+		}
+	}
+
+	return nil
+}
+
+// signalGroup sends sig to the process group of the command. Falls back to the process without own process group.
+func (c *Command) signalGroup(sig syscall.Signal) error {
+	if c.Process == nil {
+		return nil
+	}
+
+	if c.SysProcAttr == nil || !c.SysProcAttr.Setpgid {
+		return c.Process.Signal(sig) //nolint:wrapcheck
+	}
+
+	err := syscall.Kill(-c.Process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// killGroup kills all remaining processes of the process group of the command.
+func (c *Command) killGroup() {
+	if c.SysProcAttr == nil || !c.SysProcAttr.Setpgid {
+		return
+	}
+
+	_ = c.signalGroup(syscall.SIGKILL)
+}
@@ -0,0 +1,241 @@
+//go:build linux
+
+package provisioning
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+)
+
+// processGone returns true if the process does not exist anymore or is a zombie.
+func processGone(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+
+	// format: pid (comm) state ...
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func Test_isolateKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	// the background process ignores the interrupt, like a hanging provider plugin
+	cmd := &Command{Cmd: exec.CommandContext(ctx, "sh", "-c", "(trap '' INT; exec sleep 30) & echo $!; wait")}
+	cmd.WaitDelay = 100 * time.Millisecond
+
+	err := isolate(cmd, config.Isolation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	_ = cmd.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(child) {
+		if time.Now().After(deadline) {
+			t.Fatal("child process has outlived the command")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHookIsolator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hook")
+
+	// the background process keeps the output open after the hook has been interrupted
+	err := os.WriteFile(path, []byte("#!/bin/sh\nsleep 30 & echo \"child $!\" >&2; exec sleep 30\n"), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	_, err = hook.NewExecutable("slow", path, 200*time.Millisecond, HookIsolator(config.Isolation{})).
+		Run(context.TODO(), hook.Input{Point: hook.PointPreInit})
+	if err == nil || time.Since(start) > 3*time.Second {
+		t.Fatalf("hook should be killed after the timeout: %v", err)
+	}
+
+	match := regexp.MustCompile(`child (\d+)`).FindStringSubmatch(err.Error())
+	if match == nil {
+		t.Fatalf("error should contain the pid of the child: %v", err)
+	}
+
+	child, _ := strconv.Atoi(match[1])
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !processGone(child) {
+		if time.Now().After(deadline) {
+			t.Fatal("child process has outlived the hook")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHookIsolatorEnvironment(t *testing.T) {
+	t.Setenv("RESOURCE_NEXUS_SECRET", "s3cret")
+
+	path := filepath.Join(t.TempDir(), "hook")
+	script := `echo "{\"annotations\":{\"path\":\"$PATH\",\"secret\":\"$RESOURCE_NEXUS_SECRET\"}}"`
+
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := hook.NewExecutable("env", path, 0, HookIsolator(config.Isolation{})).
+		Run(context.TODO(), hook.Input{Point: hook.PointPreInit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Annotations["path"] != os.Getenv("PATH") || result.Annotations["secret"] != "" {
+		t.Fatalf("hook should only get the passthrough variables: %+v", result.Annotations)
+	}
+}
+
+func Test_applyLimits(t *testing.T) {
+	cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "sleep", "5")}
+
+	err := isolate(cmd, config.Isolation{MaxOpenFiles: 64, MaxCPUTime: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	limits, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/limits")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(string(limits), "\n") {
+		fields := strings.Fields(line)
+
+		switch {
+		case strings.HasPrefix(line, "Max open files"):
+			if fields[3] != "64" || fields[4] != "64" {
+				t.Fatalf("wrong limit of open files: %s", line)
+			}
+		case strings.HasPrefix(line, "Max cpu time"):
+			if fields[3] != "10" {
+				t.Fatalf("wrong limit of cpu time: %s", line)
+			}
+		}
+	}
+}
+
+func Test_applyLimitsChildren(t *testing.T) {
+	// the limits are inherited by processes started by the command, even if they are started immediately
+	cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "sh", "-c", "cat /proc/self/limits")}
+
+	err := isolate(cmd, config.Isolation{MaxOpenFiles: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limits, err := io.ReadAll(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(string(limits), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		if fields := strings.Fields(line); fields[3] != "64" || fields[4] != "64" {
+			t.Fatalf("wrong limit of open files: %s", line)
+		}
+
+		return
+	}
+
+	t.Fatalf("limit of open files is missing: %s", limits)
+}
+
+func Test_isolateCredential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the uid requires root")
+	}
+
+	cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "id", "-u")}
+
+	err := isolate(cmd, config.Isolation{Uid: 65534, Gid: 65534})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(string(out)) != "65534" {
+		t.Fatalf("command should be executed as uid 65534: %s", out)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package provisioning
+
+import (
+	"errors"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+)
+
+// errIsolationUnsupported is returned if an isolation is configured on a platform other than linux.
+var errIsolationUnsupported = errors.New("isolation of commands is only supported on linux")
+
+// isolate returns an error if a uid, gid or resource limit is configured. They are only supported on linux.
+func isolate(cmd *Command, conf config.Isolation) error {
+	if conf.Uid != 0 || conf.Gid != 0 || conf.MaxCPUTime > 0 || conf.MaxAddressSpace > 0 || conf.MaxOpenFiles > 0 {
+		return errIsolationUnsupported
+	}
+
+	cmd.isolation = conf
+
+	return nil
+}
+
+// start starts the command. Resource limits are only supported on linux.
+func (c *Command) start() error {
+	return c.Cmd.Start() //nolint:wrapcheck
+}
+
+// killGroup does nothing. Commands are not started in their own process group.
+func (c *Command) killGroup() {}
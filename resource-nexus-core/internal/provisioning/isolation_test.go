@@ -0,0 +1,26 @@
+package provisioning
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+)
+
+func Test_newRunDirectory(t *testing.T) {
+	dir, err := newRunDirectory(config.Isolation{RunDirectory: t.TempDir()}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{dir, runHome(dir), runTemp(dir)} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != 0o700 {
+			t.Fatalf("run directory %s should only be accessible by its owner: %s", path, info.Mode())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package provisioning
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+// maxAddresses is the maximum number of addresses of each run option.
+const maxAddresses = 100
+
+// Patterns of the parts of addresses.
+const (
+	addressName     = `[A-Za-z_][A-Za-z0-9_-]*`
+	addressKey      = `(\[(\d+|"[^"]+")\])?`
+	addressModule   = `module\.` + addressName + addressKey
+	addressResource = `(data\.)?` + addressName + `\.` + addressName + addressKey
+)
+
+var (
+	// modulePattern matches addresses of modules. e.g. 'module.web[0]' or 'module.web.module.dns'.
+	modulePattern = regexp.MustCompile(`^` + addressModule + `(\.` + addressModule + `)*$`) //nolint:gochecknoglobals
+
+	// resourcePattern matches addresses of resources and resource instances. e.g. 'proxmox_vm_qemu.web' or
+	// 'module.dns.data.dns_a_record_set.web["www"]'.
+	resourcePattern = regexp.MustCompile( //nolint:gochecknoglobals
+		`^(` + addressModule + `\.)*` + addressResource + `$`,
+	)
+)
+
+// ErrUnknownAddress is returned if an address of the run options does not exist inside the state.
+var ErrUnknownAddress = errors.New("address not found in state")
+
+// ValidateRunOptions validates the syntax of the addresses and the combination of the run options.
+//
+// The addresses are not checked against the state. See ValidateAddresses.
+func ValidateRunOptions(options database.RunOptions) error {
+	if options.RefreshOnly && options.Destroy {
+		return errors.New("refresh_only and destroy can't be combined")
+	}
+
+	if len(options.Replace) > 0 && (options.RefreshOnly || options.Destroy) {
+		return errors.New("replace can't be combined with refresh_only or destroy")
+	}
+
+	if len(options.Targets) > maxAddresses || len(options.Replace) > maxAddresses {
+		return fmt.Errorf("targets and replace accept at most %d addresses each", maxAddresses)
+	}
+
+	for _, address := range options.Targets {
+		if !modulePattern.MatchString(address) && !resourcePattern.MatchString(address) {
+			return fmt.Errorf("invalid target address '%s'", address)
+		}
+	}
+
+	// only resource instances can be replaced
+	for _, address := range options.Replace {
+		// 'module.web' is the address of a module, even if it matches the pattern of resources
+		if !resourcePattern.MatchString(address) || modulePattern.MatchString(address) {
+			return fmt.Errorf("invalid replace address '%s'. the address of a resource instance is required", address)
+		}
+	}
+
+	return nil
+}
+
+// RunOptionArgs returns the flags of `plan` for the run options.
+func RunOptionArgs(options database.RunOptions) []string {
+	var args []string
+
+	if options.RefreshOnly {
+		args = append(args, "-refresh-only")
+	}
+
+	if options.Destroy {
+		args = append(args, "-destroy")
+	}
+
+	for _, address := range options.Targets {
+		args = append(args, "-target="+address)
+	}
+
+	for _, address := range options.Replace {
+		args = append(args, "-replace="+address)
+	}
+
+	return args
+}
+
+// StateAddresses returns the addresses of all resource instances inside the state printed by `state pull`.
+//
+// An empty output means the workspace has no state yet. No addresses are returned in this case.
+func StateAddresses(state []byte) ([]string, error) {
+	if len(bytes.TrimSpace(state)) == 0 {
+		return nil, nil
+	}
+
+	var parsed struct {
+		Resources []struct {
+			Module    string `json:"module"`
+			Mode      string `json:"mode"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Instances []struct {
+				IndexKey any `json:"index_key"`
+			} `json:"instances"`
+		} `json:"resources"`
+	}
+
+	err := json.Unmarshal(state, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	var addresses []string
+
+	for _, resource := range parsed.Resources {
+		address := resource.Type + "." + resource.Name
+
+		if resource.Mode == "data" {
+			address = "data." + address
+		}
+
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+
+		for _, instance := range resource.Instances {
+			switch key := instance.IndexKey.(type) {
+			case float64:
+				addresses = append(addresses, address+"["+strconv.FormatFloat(key, 'f', -1, 64)+"]")
+			case string:
+				addresses = append(addresses, address+"["+strconv.Quote(key)+"]")
+			default:
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// ValidateAddresses checks the addresses of the run options against the addresses of the state.
+//
+// A target needs to match a resource instance or contain at least one. e.g. 'module.web' contains
+// 'module.web.proxmox_vm_qemu.web[0]' and 'proxmox_vm_qemu.web' contains 'proxmox_vm_qemu.web[1]'.
+// An address to replace needs to match a resource instance exactly.
+// Returns an error wrapping ErrUnknownAddress for the first address that does not exist.
+func ValidateAddresses(options database.RunOptions, state []string) error {
+	for _, target := range options.Targets {
+		if !slices.ContainsFunc(state, func(address string) bool { return containsAddress(target, address) }) {
+			return fmt.Errorf("%w: target '%s'", ErrUnknownAddress, target)
+		}
+	}
+
+	for _, replace := range options.Replace {
+		if !slices.Contains(state, replace) {
+			return fmt.Errorf("%w: replace '%s'", ErrUnknownAddress, replace)
+		}
+	}
+
+	return nil
+}
+
+// containsAddress returns true if the address is the target or one of the instances or resources inside it.
+func containsAddress(target string, address string) bool {
+	return address == target || strings.HasPrefix(address, target+".") || strings.HasPrefix(address, target+"[")
+}
@@ -0,0 +1,114 @@
+package provisioning
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestValidateRunOptions(t *testing.T) {
+	for _, options := range []database.RunOptions{
+		{},
+		{Targets: []string{"module.web", "module.web[0].module.dns", `proxmox_vm_qemu.web["a b"]`}},
+		{Targets: []string{"module.dns.data.dns_a_record_set.web"}, Destroy: true},
+		{Replace: []string{"proxmox_vm_qemu.web[1]", "module.dns.dns_a_record_set.lb"}},
+		{RefreshOnly: true},
+	} {
+		if err := ValidateRunOptions(options); err != nil {
+			t.Fatalf("options %+v should be valid: %s", options, err)
+		}
+	}
+
+	for _, options := range []database.RunOptions{
+		{RefreshOnly: true, Destroy: true},
+		{Replace: []string{"proxmox_vm_qemu.web[1]"}, Destroy: true},
+		{Targets: []string{"-lock=false"}},
+		{Targets: []string{"proxmox_vm_qemu"}},
+		{Targets: []string{"module.web.proxmox_vm_qemu"}},
+		{Replace: []string{"module.web"}},
+		{Replace: []string{"proxmox_vm_qemu.web[1] -destroy"}},
+		{Targets: make([]string, maxAddresses+1)},
+	} {
+		if err := ValidateRunOptions(options); err == nil {
+			t.Fatalf("options %+v should be invalid", options)
+		}
+	}
+}
+
+func TestRunOptionArgs(t *testing.T) {
+	args := RunOptionArgs(database.RunOptions{
+		Targets: []string{"module.web"},
+		Replace: []string{"proxmox_vm_qemu.web[1]"},
+		Destroy: true,
+	})
+
+	expected := []string{"-destroy", "-target=module.web", "-replace=proxmox_vm_qemu.web[1]"}
+	if !slices.Equal(args, expected) {
+		t.Fatalf("expected %v, got %v", expected, args)
+	}
+
+	err := subCommandSpecs()[SubCommandPlan].validateArgs(SubCommandPlan, args)
+	if err != nil {
+		t.Fatalf("arguments should be allowed for plan: %s", err)
+	}
+}
+
+func TestStateAddresses(t *testing.T) {
+	state, err := os.ReadFile("../../test/testdata/terraform/state.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses, err := StateAddresses(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"proxmox_vm_qemu.web[0]",
+		"proxmox_vm_qemu.web[1]",
+		"proxmox_vm_qemu.web[2]",
+		`module.dns.data.dns_a_record_set.web["www"]`,
+		"module.dns.dns_a_record_set.lb",
+	}
+	if !slices.Equal(addresses, expected) {
+		t.Fatalf("expected %v, got %v", expected, addresses)
+	}
+
+	addresses, err = StateAddresses(nil)
+	if err != nil || len(addresses) != 0 {
+		t.Fatalf("empty state should have no addresses: %v (%v)", addresses, err)
+	}
+
+	_, err = StateAddresses([]byte("no state"))
+	if err == nil {
+		t.Fatal("invalid state should fail")
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	state := []string{"proxmox_vm_qemu.web[0]", "proxmox_vm_qemu.web[1]", "module.dns.dns_a_record_set.lb"}
+
+	for _, options := range []database.RunOptions{
+		{Targets: []string{"proxmox_vm_qemu.web", "module.dns", "module.dns.dns_a_record_set.lb"}},
+		{Replace: []string{"proxmox_vm_qemu.web[1]"}},
+	} {
+		if err := ValidateAddresses(options, state); err != nil {
+			t.Fatalf("addresses of %+v should exist: %s", options, err)
+		}
+	}
+
+	for _, options := range []database.RunOptions{
+		{Targets: []string{"proxmox_vm_qemu.db"}},
+		{Targets: []string{"module.dn"}},
+		{Replace: []string{"proxmox_vm_qemu.web"}},
+		{Replace: []string{"proxmox_vm_qemu.web[2]"}},
+	} {
+		if err := ValidateAddresses(options, state); !errors.Is(err, ErrUnknownAddress) {
+			t.Fatalf("addresses of %+v should not exist, got %v", options, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package provisioning
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// Names of the artifacts that are stored for a run.
+const (
+	ArtifactPlan     = "plan"      // binary plan file written by `plan -out`
+	ArtifactPlanJson = "plan.json" // rendering of the plan file by `show -json`
+	ArtifactState    = "state"     // StateVersion of the state the plan has been created for
+)
+
+// StateVersion identifies a version of the state.
+//
+// The serial is incremented on each change of the state. The lineage is set once the state is created.
+// If one of both changed, a saved plan can't be applied anymore.
+type StateVersion struct {
+	Serial  int    `json:"serial"`
+	Lineage string `json:"lineage"`
+}
+
+// String returns the state version in the format 'lineage/serial'.
+func (v StateVersion) String() string {
+	return fmt.Sprintf("%s/%d", v.Lineage, v.Serial)
+}
+
+// ParseStateVersion returns the StateVersion of the state printed by `state pull`.
+//
+// An empty output means the workspace has no state yet. The zero StateVersion is returned in this case.
+func ParseStateVersion(state []byte) (StateVersion, error) {
+	var version StateVersion
+
+	if len(bytes.TrimSpace(state)) == 0 {
+		return version, nil
+	}
+
+	err := json.Unmarshal(state, &version)
+	if err != nil {
+		return StateVersion{}, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	return version, nil
+}
+
+// planFileName returns the name of the saved plan file of the run. Commands are executed inside the working directory,
+// so the name is passed to them instead of the absolute path.
+func planFileName(runID int) string {
+	return fmt.Sprintf(".resource-nexus-run-%d.tfplan", runID)
+}
+
+// planFilePath returns the path of the saved plan file of the run inside the working directory.
+func planFilePath(workingDirectory string, runID int) string {
+	return filepath.Join(workingDirectory, planFileName(runID))
+}
+
+// isDestroyPlan returns true if the plan rendered by `show -json` deletes all resources it contains.
+func isDestroyPlan(rendered []byte) bool {
+	var plan struct {
+		ResourceChanges []struct {
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+
+	if json.Unmarshal(rendered, &plan) != nil || len(plan.ResourceChanges) == 0 {
+		return false
+	}
+
+	for _, change := range plan.ResourceChanges {
+		if !slices.Equal(change.Change.Actions, []string{"delete"}) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,46 @@
+package provisioning
+
+import (
+	"testing"
+)
+
+func TestParseStateVersion(t *testing.T) {
+	v, err := ParseStateVersion([]byte(`{"version":4,"terraform_version":"1.9.5","serial":12,"lineage":"a1b2","outputs":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Serial != 12 || v.Lineage != "a1b2" {
+		t.Fatalf("wrong state version: %s", v)
+	}
+
+	// no state yet
+	v, err = ParseStateVersion([]byte("\n"))
+	if err != nil || v != (StateVersion{}) {
+		t.Fatalf("empty state should return zero version: %s", v)
+	}
+
+	_, err = ParseStateVersion([]byte("not json"))
+	if err == nil {
+		t.Fatal("invalid state should return an error")
+	}
+}
+
+func Test_isDestroyPlan(t *testing.T) {
+	tests := []struct {
+		rendered string
+		expected bool
+	}{
+		{`{"resource_changes":[{"change":{"actions":["delete"]}},{"change":{"actions":["delete"]}}]}`, true},
+		{`{"resource_changes":[{"change":{"actions":["delete"]}},{"change":{"actions":["no-op"]}}]}`, false},
+		{`{"resource_changes":[{"change":{"actions":["delete","create"]}}]}`, false},
+		{`{"resource_changes":[]}`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		if isDestroyPlan([]byte(tt.rendered)) != tt.expected {
+			t.Fatalf("wrong result for %s", tt.rendered)
+		}
+	}
+}
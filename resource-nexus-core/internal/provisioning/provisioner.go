@@ -18,16 +18,38 @@ type BaseProvisioner struct {
 	ProvisionerConfig config.Provisioner
 	ExecutablePath    string
 	WorkingDirectory  string
+	Environment       map[string]string // injected variables. e.g. TF_VAR_token. values are treated as secrets
+	RunDirectory      string            // private directory of the run. contains HOME and TMPDIR of the commands
+	Playbook          string            // ansible playbook of the post-apply stage. relative to the working directory
+	Timeouts          config.Timeouts   // timeouts of the phases. see ResolveTimeouts
 }
 
 // Validate takes the defined provisioner settings and validates them.
 // Returns an error if the executable path is not allowed.
 //
-// allowedExecutables is a list of allowed executable paths.
+// Allowed are the paths of the configured executables and the deprecated allowedExecutables.
 func (bp *BaseProvisioner) Validate() error {
-	if !slices.Contains(strings.Split(bp.ProvisionerConfig.AllowedExecutables, ","), bp.ExecutablePath) {
+	if !slices.Contains(AllowedPaths(bp.ProvisionerConfig), bp.ExecutablePath) {
 		return fmt.Errorf("invalid executable path provided: %s. contact your administrator for help", bp.ExecutablePath)
 	}
 
 	return nil
 }
+
+// AllowedPaths returns the paths of all allowed executables.
+//
+// If executables are configured, only their paths are allowed. Otherwise, the deprecated comma separated
+// allowedExecutables are used.
+func AllowedPaths(conf config.Provisioner) []string {
+	if len(conf.Executables) == 0 {
+		return strings.Split(conf.AllowedExecutables, ",")
+	}
+
+	paths := make([]string, 0, len(conf.Executables))
+
+	for _, executable := range conf.Executables {
+		paths = append(paths, executable.Path)
+	}
+
+	return paths
+}
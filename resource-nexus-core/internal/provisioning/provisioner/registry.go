@@ -0,0 +1,230 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/common/semver"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+)
+
+// probeTimeout is the maximum duration of `version -json` for a single executable.
+const probeTimeout = 10 * time.Second
+
+// ErrNoUsableExecutable is returned by Registry.Probe if none of the configured executables can be used.
+var ErrNoUsableExecutable = errors.New("no usable executable configured")
+
+// versionOutput is the output of `<executable> version -json`. terraform and opentofu use the same format.
+type versionOutput struct {
+	TerraformVersion string `json:"terraform_version"`
+	Platform         string `json:"platform"`
+}
+
+// provisioners returns all known provisioners.
+func provisioners() []provisioning.Provisioner {
+	return []provisioning.Provisioner{
+		&Terraform{},
+		&OpenTofu{},
+	}
+}
+
+// IsKnown returns true if a provisioner with the given name exists.
+func IsKnown(name string) bool {
+	for _, p := range provisioners() {
+		if p.GetProvisionerName() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Registry holds the installed executables of all provisioners.
+//
+// The version of each executable is detected with `version -json`.
+// The registry implements provisioning.Resolver and resolves the executable of a workspace by its requirements.
+type Registry struct {
+	config config.Provisioner
+	logger *logging.Logger
+
+	mu          sync.RWMutex
+	executables []provisioning.Executable
+}
+
+// NewRegistry returns a new Registry for the configured executables.
+//
+// Executables are usable once they have been probed with Probe.
+func NewRegistry(conf config.Provisioner, logger *logging.Logger) *Registry {
+	return &Registry{
+		config: conf,
+		logger: logger,
+	}
+}
+
+// Probe detects the version of all configured executables.
+//
+// Executables that can't be probed are skipped and logged.
+// Returns ErrNoUsableExecutable if none of the executables can be used.
+func (r *Registry) Probe(ctx context.Context) error {
+	var executables []provisioning.Executable
+
+	for _, conf := range configuredExecutables(r.config) {
+		if !IsKnown(conf.Type) {
+			r.logger.Warn("skipping executable with unknown provisioner type", "name", conf.Name, "type", conf.Type)
+
+			continue
+		}
+
+		version, err := probe(ctx, conf.Path)
+		if err != nil {
+			r.logger.Warn("skipping executable. cant detect version", "name", conf.Name, "path", conf.Path, "error", err)
+
+			continue
+		}
+
+		r.logger.Info("executable detected", "name", conf.Name, "type", conf.Type, "version", version)
+
+		executables = append(executables, provisioning.Executable{
+			Name:    conf.Name,
+			Type:    conf.Type,
+			Path:    conf.Path,
+			Version: version,
+		})
+	}
+
+	r.mu.Lock()
+	r.executables = executables
+	r.mu.Unlock()
+
+	if len(executables) == 0 {
+		return ErrNoUsableExecutable
+	}
+
+	return nil
+}
+
+// Executables returns all usable executables.
+func (r *Registry) Executables() []provisioning.Executable {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]provisioning.Executable(nil), r.executables...)
+}
+
+// Resolve returns the executable for the workspace.
+//
+// The executable needs to match the provisioner type, the pinned executable path and the required version constraint
+// of the workspace. Empty requirements match every executable. If multiple executables match, the highest version wins.
+func (r *Registry) Resolve(workspace database.Workspace) (provisioning.Executable, error) {
+	constraint, err := semver.ParseConstraint(workspace.RequiredVersion)
+	if err != nil {
+		return provisioning.Executable{}, fmt.Errorf("invalid required version of workspace: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		best  provisioning.Executable
+		found bool
+	)
+
+	for _, executable := range r.executables {
+		if workspace.Provisioner != "" && executable.Type != workspace.Provisioner {
+			continue
+		}
+
+		if workspace.ExecutablePath != "" && executable.Path != workspace.ExecutablePath {
+			continue
+		}
+
+		if !constraint.Check(executable.Version) {
+			continue
+		}
+
+		if !found || executable.Version.Compare(best.Version) > 0 {
+			best = executable
+			found = true
+		}
+	}
+
+	if !found {
+		return provisioning.Executable{}, fmt.Errorf(
+			"%w: provisioner '%s', required version '%s', executable '%s'",
+			provisioning.ErrNoExecutable,
+			workspace.Provisioner,
+			constraint,
+			workspace.ExecutablePath,
+		)
+	}
+
+	return best, nil
+}
+
+// configuredExecutables returns the configured executables.
+//
+// If no executables are configured, the deprecated allowedExecutables are used. The type is derived from the
+// file name in this case.
+func configuredExecutables(conf config.Provisioner) []config.Executable {
+	if len(conf.Executables) > 0 {
+		return conf.Executables
+	}
+
+	var executables []config.Executable
+
+	for _, path := range strings.Split(conf.AllowedExecutables, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		provisionerType := (&Terraform{}).GetProvisionerName()
+		if strings.Contains(filepath.Base(path), "tofu") {
+			provisionerType = (&OpenTofu{}).GetProvisionerName()
+		}
+
+		executables = append(executables, config.Executable{
+			Name: path,
+			Type: provisionerType,
+			Path: path,
+		})
+	}
+
+	return executables
+}
+
+// probe returns the version of the executable reported by `version -json`.
+func probe(ctx context.Context, path string) (semver.Version, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	// the path is part of the configuration. it is not provided by users
+	out, err := exec.CommandContext(ctx, path, "version", "-json").Output() //nolint:gosec
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to execute version command: %w", err)
+	}
+
+	var output versionOutput
+
+	err = json.Unmarshal(out, &output)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to decode version output: %w", err)
+	}
+
+	version, err := semver.Parse(output.TerraformVersion)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("failed to parse version: %w", err)
+	}
+
+	return version, nil
+}
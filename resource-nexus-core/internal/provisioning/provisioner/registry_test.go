@@ -0,0 +1,90 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+)
+
+// fakeExecutable writes a script that prints the given version with `version -json`.
+func fakeExecutable(t *testing.T, name string, version string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, []byte(`#!/bin/sh
+echo '{"terraform_version":"`+version+`","platform":"linux_amd64"}'
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestRegistryResolve(t *testing.T) {
+	tf19 := fakeExecutable(t, "terraform", "1.9.5")
+	tf15 := fakeExecutable(t, "terraform", "1.5.7")
+	tofu := fakeExecutable(t, "tofu", "1.8.0")
+
+	r := NewRegistry(config.Provisioner{
+		Executables: []config.Executable{
+			{Name: "terraform-1.9", Type: "terraform", Path: tf19},
+			{Name: "terraform-1.5", Type: "terraform", Path: tf15},
+			{Name: "tofu-1.8", Type: "opentofu", Path: tofu},
+			{Name: "broken", Type: "terraform", Path: "/does/not/exist"},
+		},
+	}, logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "error"}))
+
+	err := r.Probe(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Executables()) != 3 {
+		t.Fatal("broken executable should be skipped")
+	}
+
+	tests := []struct {
+		workspace database.Workspace
+		expected  string
+	}{
+		{database.Workspace{}, "terraform-1.9"}, // highest version
+		{database.Workspace{RequiredVersion: "~> 1.5.0"}, "terraform-1.5"},
+		{database.Workspace{Provisioner: "opentofu"}, "tofu-1.8"},
+		{database.Workspace{ExecutablePath: tf15}, "terraform-1.5"},
+	}
+
+	for _, tt := range tests {
+		executable, err := r.Resolve(tt.workspace)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if executable.Name != tt.expected {
+			t.Fatalf("wrong executable resolved for %+v: %s", tt.workspace, executable.Name)
+		}
+	}
+
+	_, err = r.Resolve(database.Workspace{RequiredVersion: ">= 2.0.0"})
+	if !errors.Is(err, provisioning.ErrNoExecutable) {
+		t.Fatalf("expected ErrNoExecutable, got %v", err)
+	}
+}
+
+func TestConfiguredExecutablesFallback(t *testing.T) {
+	executables := configuredExecutables(config.Provisioner{
+		AllowedExecutables: "/usr/local/bin/terraform,/usr/bin/tofu",
+	})
+
+	if len(executables) != 2 || executables[0].Type != "terraform" || executables[1].Type != "opentofu" {
+		t.Fatalf("wrong executables derived from allowedExecutables: %+v", executables)
+	}
+}
@@ -20,3 +20,26 @@ func Test_Validate(t *testing.T) {
 		t.Fatalf("validation failed: %s", err.Error())
 	}
 }
+
+func Test_ValidateExecutables(t *testing.T) {
+	bp := BaseProvisioner{
+		ExecutablePath: "/usr/local/bin/terraform",
+		ProvisionerConfig: config.Provisioner{
+			Executables: []config.Executable{
+				{Name: "tofu-1.8", Type: "opentofu", Path: "/usr/local/bin/tofu"},
+			},
+			AllowedExecutables: "/usr/local/bin/terraform",
+		},
+	}
+
+	// allowedExecutables is ignored once executables are configured
+	if err := bp.Validate(); err == nil {
+		t.Fatal("executable should not be allowed")
+	}
+
+	bp.ExecutablePath = "/usr/local/bin/tofu"
+
+	if err := bp.Validate(); err != nil {
+		t.Fatalf("validation failed: %s", err.Error())
+	}
+}
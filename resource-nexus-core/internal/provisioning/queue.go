@@ -0,0 +1,572 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+var (
+	// ErrShutdown is the cause of an interrupted run if the queue has been stopped.
+	ErrShutdown = errors.New("resource-nexus-core is shutting down")
+	// ErrCanceled is the cause of an interrupted run if the run has been canceled.
+	ErrCanceled = errors.New("run has been canceled")
+)
+
+// RunConflictError is returned if a run is enqueued for a workspace that already has an active run.
+type RunConflictError struct {
+	Workspace string
+	RunID     int // id of the blocking run. 0 if unknown
+}
+
+func (e *RunConflictError) Error() string {
+	return fmt.Sprintf("workspace '%s' already has an active run %d", e.Workspace, e.RunID)
+}
+
+func (e *RunConflictError) Unwrap() error {
+	return database.ErrActiveRun
+}
+
+// staleHeartbeats is the number of missed heartbeats after which a running run is treated as stale.
+const staleHeartbeats = 3
+
+// Queue is a persistent queue for provisioning runs.
+//
+// Queued runs are stored inside the database and survive restarts.
+// Workers claim queued runs with 'FOR UPDATE SKIP LOCKED', so multiple instances can share the same queue.
+// Claimed runs are executed with the Runner.
+type Queue struct {
+	db       database.Database
+	runner   *Runner
+	resolver Resolver        // resolves the executable of a workspace. nil uses the executable path of the workspace
+	recorder *audit.Recorder // records the changes of the status of runs. nil records nothing
+	config   config.Provisioner
+	logger   *logging.Logger
+	instance string
+
+	wg    sync.WaitGroup
+	stop  context.CancelFunc      // stops claiming new runs
+	abort context.CancelCauseFunc // interrupts executing runs
+
+	mu      sync.Mutex
+	running map[int]context.CancelCauseFunc // interrupts a single run executed by this instance
+}
+
+// NewQueue returns a new Queue.
+//
+// Unset or invalid settings inside conf are replaced by the defaults.
+// If resolver is nil, runs are executed with the executable path of the workspace.
+// recorder can be nil. Changes of the status of runs are not recorded in this case.
+func NewQueue(
+	db database.Database,
+	runner *Runner,
+	resolver Resolver,
+	recorder *audit.Recorder,
+	conf config.Provisioner,
+	logger *logging.Logger,
+) *Queue {
+	defaults := config.LoadDefaults().Provisioner
+
+	if conf.Workers < 0 {
+		conf.Workers = defaults.Workers
+	}
+
+	if conf.PollInterval <= 0 {
+		conf.PollInterval = defaults.PollInterval
+	}
+
+	if conf.HeartbeatInterval <= 0 {
+		conf.HeartbeatInterval = defaults.HeartbeatInterval
+	}
+
+	if conf.CancelGracePeriod <= 0 {
+		conf.CancelGracePeriod = defaults.CancelGracePeriod
+	}
+
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if conf.Ansible.InventoryOutput == "" {
+		conf.Ansible.InventoryOutput = defaults.Ansible.InventoryOutput
+	}
+
+	if conf.Ansible.FailurePolicy != FailurePolicyFail && conf.Ansible.FailurePolicy != FailurePolicyContinue {
+		conf.Ansible.FailurePolicy = defaults.Ansible.FailurePolicy
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "resource-nexus-core"
+	}
+
+	return &Queue{
+		db:       db,
+		runner:   runner,
+		resolver: resolver,
+		recorder: recorder,
+		config:   conf,
+		logger:   logger,
+		instance: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		running:  make(map[int]context.CancelCauseFunc),
+	}
+}
+
+// Enqueue adds a new run of the given kind with the options for the workspace to the queue.
+//
+// The options need to be validated with ValidateRunOptions beforehand.
+// Returns the id of the new run. The run is executed by the next free worker.
+// Returns a *RunConflictError if the workspace already has an active run.
+// Returns an error wrapping ErrNoExecutable if no installed executable matches the workspace.
+func (q *Queue) Enqueue(
+	ctx context.Context, workspace database.Workspace, kind database.RunKind, options database.RunOptions,
+) (int, error) {
+	_, err := q.executable(workspace)
+	if err != nil {
+		return 0, err
+	}
+
+	active, err := q.db.GetRuns(database.ActiveRunFilter(workspace.Name), ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for active runs: %w", err)
+	}
+
+	if len(active) > 0 {
+		return 0, &RunConflictError{Workspace: workspace.Name, RunID: active[0].ID}
+	}
+
+	id, err := q.db.InsertRun(ctx, database.Run{
+		Workspace: workspace.Name,
+		Kind:      kind,
+		Status:    database.RunStatusQueued,
+		Options:   options,
+	})
+	if errors.Is(err, database.ErrActiveRun) {
+		// another run has been added in the meantime
+		conflict := &RunConflictError{Workspace: workspace.Name}
+
+		active, err = q.db.GetRuns(database.ActiveRunFilter(workspace.Name), ctx)
+		if err == nil && len(active) > 0 {
+			conflict.RunID = active[0].ID
+		}
+
+		return 0, conflict
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue run: %w", err)
+	}
+
+	q.logger.Info("run queued", "run", id, "workspace", workspace.Name, "kind", kind)
+
+	q.recorder.RecordRun(ctx, database.Run{
+		ID:        id,
+		Workspace: workspace.Name,
+		Kind:      kind,
+		Status:    database.RunStatusQueued,
+		Options:   options,
+	}, "")
+
+	return id, nil
+}
+
+// Cancel cancels the run on behalf of the given user.
+//
+// Queued and planned runs are canceled immediately. Running runs are interrupted. If the command does not exit
+// within the configured grace period, it is killed. Runs of this instance are interrupted immediately.
+// Runs of other instances are interrupted on their next heartbeat.
+//
+// Returns the status of the run after the cancellation and false if the run can't be canceled.
+func (q *Queue) Cancel(ctx context.Context, id int, user string) (database.RunStatus, bool, error) {
+	status, ok, err := q.db.CancelRun(ctx, id, user)
+	if err != nil || !ok {
+		return status, ok, err //nolint:wrapcheck
+	}
+
+	q.logger.Info("run canceled", "run", id, "user", user, "status", status)
+
+	if status == database.RunStatusRunning {
+		// the status changes once the command has exited. it is recorded by the runner
+		q.interrupt(id)
+	} else {
+		// queued or planned before
+		q.recordRun(ctx, id, "")
+	}
+
+	return status, true, nil
+}
+
+// Approve approves the saved plan of a planned run. The run is put back into the queue to apply the plan.
+//
+// Returns false if the run is not waiting for approval.
+func (q *Queue) Approve(ctx context.Context, id int) (bool, error) {
+	result, err := q.db.ApproveRun(ctx, id)
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+
+	if approved, _ := result.RowsAffected(); approved == 0 {
+		return false, nil
+	}
+
+	q.logger.Info("run approved", "run", id)
+
+	q.recordRun(ctx, id, database.RunStatusPlanned)
+
+	return true, nil
+}
+
+// recordRun records the change of the status of the run with the id. The run is loaded to record its current state.
+// from is the status before the change. Empty if unknown.
+func (q *Queue) recordRun(ctx context.Context, id int, from database.RunStatus) {
+	if q.recorder == nil {
+		return
+	}
+
+	run, err := q.db.GetRun(database.Filter{Key: "id", Operator: "=", Value: id}, context.WithoutCancel(ctx))
+	if err != nil {
+		q.logger.Error("failed to load run for the audit log", "run", id, "error", err)
+
+		return
+	}
+
+	q.recorder.RecordRun(ctx, run, from)
+}
+
+// interrupt interrupts the run if it is executed by this instance.
+func (q *Queue) interrupt(id int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cancelRun, ok := q.running[id]; ok {
+		cancelRun(ErrCanceled)
+	}
+}
+
+// Start starts the workers in the background.
+//
+// Workers claim and execute queued runs until Stop is called.
+func (q *Queue) Start() {
+	claimCtx, stop := context.WithCancel(context.Background())
+	runCtx, abort := context.WithCancelCause(context.Background())
+
+	q.stop = stop
+	q.abort = abort
+
+	q.logger.Info("starting provisioning workers", "workers", q.config.Workers, "instance", q.instance)
+
+	q.wg.Go(func() {
+		q.recoverStaleRuns(claimCtx)
+	})
+
+	for i := range q.config.Workers {
+		worker := fmt.Sprintf("%s-%d", q.instance, i)
+
+		q.wg.Go(func() {
+			q.work(claimCtx, runCtx, worker)
+		})
+	}
+}
+
+// Stop stops claiming new runs and waits for the workers to finish their current runs.
+//
+// If ctx is done before all runs are finished, the executing runs are interrupted.
+// Interrupted runs are put back into the queue, if they have not reached the apply phase.
+func (q *Queue) Stop(ctx context.Context) {
+	if q.stop == nil {
+		return
+	}
+
+	q.stop()
+
+	done := make(chan struct{})
+
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		q.logger.Warn("interrupting running runs. shutdown timeout reached")
+		q.abort(ErrShutdown)
+		<-done
+	}
+
+	q.abort(nil)
+
+	q.logger.Debug("provisioning workers stopped")
+}
+
+// work is the loop of a single worker.
+//
+// Queued runs are executed one after another until the queue is empty.
+// Then the worker waits for the poll interval and checks again.
+func (q *Queue) work(claimCtx context.Context, runCtx context.Context, worker string) {
+	ticker := time.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for q.next(claimCtx, runCtx, worker) {
+		}
+
+		select {
+		case <-claimCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// next claims the next queued run and executes it.
+//
+// Returns false if no run has been claimed.
+func (q *Queue) next(claimCtx context.Context, runCtx context.Context, worker string) bool {
+	if claimCtx.Err() != nil {
+		return false
+	}
+
+	run, ok, err := q.db.ClaimRun(claimCtx, worker)
+	if err != nil {
+		if claimCtx.Err() == nil {
+			q.logger.Error("failed to claim run", "worker", worker, "error", err)
+		}
+
+		return false
+	}
+
+	if !ok {
+		return false
+	}
+
+	q.logger.Debug("run claimed", "run", run.ID, "worker", worker, "attempt", run.Attempts)
+
+	q.recorder.RecordRun(claimCtx, run, database.RunStatusQueued)
+
+	q.process(runCtx, run, worker)
+
+	return true
+}
+
+// process executes the claimed run.
+//
+// The run is interrupted if it is canceled.
+// The workspace is locked for the whole run. If the workspace is locked by another run, the run is put back into
+// the queue. A heartbeat is sent while the run is executed, so other instances do not treat the run as stale.
+// Failed runs are retried if it is safe to do so.
+func (q *Queue) process(ctx context.Context, run database.Run, worker string) {
+	workspace, err := q.db.GetWorkspace(database.Filter{Key: "name", Operator: "=", Value: run.Workspace}, ctx)
+	if err != nil {
+		_, err = q.runner.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("cant load workspace: %s", err))
+		if err != nil {
+			q.logger.Error("failed to update run", "run", run.ID, "error", err)
+		}
+
+		return
+	}
+
+	executable, err := q.executable(workspace)
+	if err != nil {
+		_, err = q.runner.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("cant resolve executable: %s", err))
+		if err != nil {
+			q.logger.Error("failed to update run", "run", run.ID, "error", err)
+		}
+
+		return
+	}
+
+	lock, err := q.db.LockWorkspace(ctx, workspace, run.ID, worker)
+	if errors.Is(err, database.ErrWorkspaceLocked) {
+		// the run has not been executed. waiting for the lock doesn't use up its attempts
+		q.requeue(run, time.Now().Add(q.config.PollInterval), err.Error(), false)
+
+		return
+	}
+
+	if err != nil {
+		_, err = q.runner.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("cant lock workspace: %s", err))
+		if err != nil {
+			q.logger.Error("failed to update run", "run", run.ID, "error", err)
+		}
+
+		return
+	}
+
+	defer func() {
+		err := lock.Release(context.WithoutCancel(ctx))
+		if err != nil {
+			q.logger.Error("failed to release workspace lock", "run", run.ID, "workspace", workspace.Name, "error", err)
+		}
+	}()
+
+	bp := &BaseProvisioner{
+		ProvisionerConfig: q.config,
+		ExecutablePath:    executable,
+		WorkingDirectory:  workspace.WorkingDirectory,
+		Playbook:          workspace.Playbook,
+		Timeouts:          ResolveTimeouts(q.config.Timeouts, workspace),
+	}
+
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	defer cancelRun(nil)
+
+	q.mu.Lock()
+	q.running[run.ID] = cancelRun
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, run.ID)
+		q.mu.Unlock()
+	}()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopHeartbeat()
+
+	go q.heartbeat(heartbeatCtx, run.ID, worker, cancelRun)
+
+	result, err := q.runner.Execute(runCtx, run, bp)
+	if err != nil {
+		q.logger.Error("failed to execute run", "run", run.ID, "error", err)
+
+		return
+	}
+
+	switch {
+	case errors.Is(context.Cause(ctx), ErrShutdown) && isRetrySafe(result):
+		q.requeue(result, time.Now(), "run was interrupted by a shutdown. requeued", true)
+	case shouldRetry(result, q.config.MaxAttempts):
+		q.requeue(result, time.Now().Add(q.config.RetryBackoff), fmt.Sprintf(
+			"attempt %d of %d failed: %s. retrying", result.Attempts, q.config.MaxAttempts, result.Message,
+		), true)
+	}
+}
+
+// requeue puts the run back into the queue. If countAttempt is false, the claim of the run is not counted as attempt.
+func (q *Queue) requeue(run database.Run, nextAttempt time.Time, message string, countAttempt bool) {
+	result, err := q.db.RequeueRun(context.Background(), run.ID, nextAttempt, message, countAttempt)
+	if err != nil {
+		q.logger.Error("failed to requeue run", "run", run.ID, "error", err)
+
+		return
+	}
+
+	q.logger.Info("run requeued", "run", run.ID, "next_attempt", nextAttempt, "message", message)
+
+	// canceled runs are not requeued
+	if requeued, _ := result.RowsAffected(); requeued > 0 {
+		from := run.Status
+
+		run.Status = database.RunStatusQueued
+		run.Phase = ""
+		run.Message = message
+		run.FinishedAt = nil
+
+		if !countAttempt {
+			run.Attempts--
+		}
+
+		q.recorder.RecordRun(context.Background(), run, from)
+	}
+}
+
+// heartbeat marks the run as alive until ctx is done.
+//
+// If the cancellation of the run has been requested (e.g. over another instance), the run is interrupted.
+func (q *Queue) heartbeat(ctx context.Context, id int, worker string, cancelRun context.CancelCauseFunc) {
+	ticker := time.NewTicker(q.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			canceled, err := q.db.HeartbeatRun(ctx, id, worker)
+			if err != nil && ctx.Err() == nil {
+				q.logger.Error("failed to send run heartbeat", "run", id, "error", err)
+			}
+
+			if canceled {
+				cancelRun(ErrCanceled)
+			}
+		}
+	}
+}
+
+// recoverStaleRuns periodically recovers runs of workers that stopped sending heartbeats.
+func (q *Queue) recoverStaleRuns(ctx context.Context) {
+	ticker := time.NewTicker(q.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		staleBefore := time.Now().Add(-staleHeartbeats * q.config.HeartbeatInterval)
+
+		recovered, err := q.db.RecoverStaleRuns(ctx, staleBefore)
+		if err != nil && ctx.Err() == nil {
+			q.logger.Error("failed to recover stale runs", "error", err)
+		}
+
+		if recovered > 0 {
+			q.logger.Warn("recovered stale runs", "count", recovered)
+
+			q.recorder.Record(ctx, database.AuditEntry{
+				Action: audit.ActionRunRecover,
+				Target: "runs",
+				After:  audit.Snapshot(map[string]int64{"recovered": recovered}),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isRetrySafe returns true if the run can be executed again without side effects.
+//
+// init, state pull, plan and show do not change any infrastructure. A run that stopped in apply may have changed
+// infrastructure and is never executed again automatically.
+func isRetrySafe(run database.Run) bool {
+	switch SubCommand(run.Phase) {
+	case SubCommandInit, SubCommandStatePull, SubCommandPlan, SubCommandShow:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry returns true if the failed run should be attempted again.
+//
+// Only errored runs are retried. Canceled and timed out runs are final.
+func shouldRetry(run database.Run, maxAttempts int) bool {
+	return run.Status == database.RunStatusErrored && isRetrySafe(run) && run.Attempts < maxAttempts
+}
+
+// executable returns the path of the executable used for the runs of the workspace.
+func (q *Queue) executable(workspace database.Workspace) (string, error) {
+	if q.resolver == nil {
+		return workspace.ExecutablePath, nil
+	}
+
+	executable, err := q.resolver.Resolve(workspace)
+	if err != nil {
+		return "", fmt.Errorf("cant resolve executable of workspace '%s': %w", workspace.Name, err)
+	}
+
+	q.logger.Debug("executable resolved", "workspace", workspace.Name, "executable", executable.Name,
+		"version", executable.Version)
+
+	return executable.Path, nil
+}
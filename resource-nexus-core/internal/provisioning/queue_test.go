@@ -0,0 +1,341 @@
+package provisioning
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+)
+
+// newRunRows returns the rows of the columns that are selected for a run.
+func newRunRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "workspace", "kind", "status", "phase", "message", "attempts", "created_at", "started_at", "finished_at",
+		"approved_at", "canceled_by", "post_apply_status", "options",
+	})
+}
+
+// updateRunArgs appends the status arguments that UpdateRun uses to map canceled runs to the given arguments.
+func updateRunArgs(args ...driver.Value) []driver.Value {
+	return append(args, database.RunStatusQueued, database.RunStatusPlanned, database.RunStatusCanceled)
+}
+
+func TestNewQueueDefaults(t *testing.T) {
+	q := NewQueue(nil, nil, nil, nil, config.Provisioner{Workers: -1}, nil)
+
+	defaults := config.LoadDefaults().Provisioner
+
+	if q.config.Workers != defaults.Workers || q.config.PollInterval != defaults.PollInterval {
+		t.Fatal("invalid settings should be replaced by defaults")
+	}
+
+	if q.config.MaxAttempts != defaults.MaxAttempts {
+		t.Fatal("unset max attempts should be replaced by the default")
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM runs WHERE \(workspace = \$1 AND`).
+		WithArgs("web", database.RunStatusQueued, database.RunStatusRunning, database.RunStatusPlanned).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO runs`).
+		WithArgs("web", database.RunKindDeploy, database.RunStatusQueued, "", "", `{"targets":["proxmox_vm_qemu.web"]}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	q := NewQueue(database.NewSqlDatabase(d, l), nil, nil, nil, config.Provisioner{}, l)
+
+	id, err := q.Enqueue(context.TODO(), database.Workspace{Name: "web"}, database.RunKindDeploy,
+		database.RunOptions{Targets: []string{"proxmox_vm_qemu.web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 42 {
+		t.Fatalf("wrong run id returned: %d", id)
+	}
+}
+
+func TestEnqueueConflict(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM runs WHERE`).
+		WillReturnRows(newRunRows().AddRow(17, "web", "deploy", "running", "plan", "", 1, time.Now(), time.Now(), nil, nil, "", "", []byte(`{}`)))
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	q := NewQueue(database.NewSqlDatabase(d, l), nil, nil, nil, config.Provisioner{}, l)
+
+	_, err := q.Enqueue(context.TODO(), database.Workspace{Name: "web"}, database.RunKindDeploy, database.RunOptions{})
+
+	var conflict *RunConflictError
+	if !errors.As(err, &conflict) || conflict.RunID != 17 {
+		t.Fatalf("expected conflict with run 17, got %v", err)
+	}
+
+	if !errors.Is(err, database.ErrActiveRun) {
+		t.Fatal("conflict should wrap ErrActiveRun")
+	}
+}
+
+func TestQueueAudit(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+	ctx := context.TODO()
+
+	recorder, err := audit.NewRecorder(db, config.Audit{}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQueue(db, nil, nil, recorder, config.Provisioner{}, l)
+
+	planned, err := db.InsertRun(ctx, database.Run{Workspace: "web", Kind: database.RunKindDeploy,
+		Status: database.RunStatusPlanned})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approved, err := q.Approve(ctx, planned)
+	if err != nil || !approved {
+		t.Fatalf("expected the run to be approved: %v", err)
+	}
+
+	// the run is not waiting for approval anymore
+	approved, err = q.Approve(ctx, planned)
+	if err != nil || approved {
+		t.Fatalf("expected the run not to be approved again: %v", err)
+	}
+
+	_, _, err = q.Cancel(ctx, planned, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := db.ListAuditEntries(
+		database.Filter{Key: "target", Operator: "=", Value: audit.Target("run", planned)}, database.Page{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries.Total != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries.Items)
+	}
+
+	if string(entries.Items[0].Before) != `{"status":"planned"}` ||
+		!strings.Contains(string(entries.Items[0].After), `"status":"queued"`) ||
+		!strings.Contains(string(entries.Items[1].After), `"status":"canceled"`) {
+		t.Fatalf("unexpected entries: %+v", entries.Items)
+	}
+}
+
+func TestProcessWorkspaceLocked(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+	ctx := context.TODO()
+
+	workspace := database.Workspace{Name: "web", WorkingDirectory: "/tmp/web"}
+
+	_, err := db.InsertWorkspace(ctx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another run holds the lock of the workspace
+	lock, err := db.LockWorkspace(ctx, workspace, 99, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release(ctx) //nolint:errcheck
+
+	_, err = db.InsertRun(ctx, database.Run{Workspace: "web", Kind: database.RunKindDeploy,
+		Status: database.RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQueue(db, nil, nil, nil, config.Provisioner{MaxAttempts: 1}, l)
+
+	// waiting for the lock several times doesn't use up the attempts of the run
+	for range 3 {
+		run, ok, err := db.ClaimRun(ctx, "worker-0")
+		if err != nil || !ok {
+			t.Fatalf("expected the run to be claimed: %v", err)
+		}
+
+		q.process(ctx, run, "worker-0")
+
+		// the next attempt is delayed by the poll interval
+		_, err = db.RequeueRun(ctx, run.ID, time.Now().Add(-time.Second), "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		run, err = db.GetRun(database.Filter{Key: "id", Operator: "=", Value: run.ID}, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if run.Status != database.RunStatusQueued || run.Attempts != 0 {
+			t.Fatalf("expected the run to be queued without attempts: %+v", run)
+		}
+	}
+}
+
+func TestProcessRetryAfterPostPlanHook(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+	ctx := context.TODO()
+	dir := t.TempDir()
+	executable := writePlanExecutable(t, dir)
+
+	_, err := db.InsertWorkspace(ctx, database.Workspace{Name: "web", WorkingDirectory: dir, ExecutablePath: executable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the hook fails after the artifacts of the first attempt have been stored
+	calls := 0
+	hooks := hook.New(l)
+	_ = hooks.Register(hook.PointPostPlan, hook.NewFunc("cmdb", func(_ context.Context, _ hook.Input) (hook.Result, error) {
+		calls++
+		if calls == 1 {
+			return hook.Result{}, errors.New("cmdb not reachable")
+		}
+
+		return hook.Result{}, nil
+	}), false)
+
+	q := NewQueue(db, NewRunner(db, nil, hooks, nil, l), nil, nil,
+		config.Provisioner{AllowedExecutables: executable, MaxAttempts: 2}, l)
+
+	id, err := db.InsertRun(ctx, database.Run{Workspace: "web", Kind: database.RunKindDeploy,
+		Status: database.RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		run, ok, err := db.ClaimRun(ctx, "worker-0")
+		if err != nil || !ok {
+			t.Fatalf("expected attempt %d to be claimed: %v", attempt, err)
+		}
+
+		q.process(ctx, run, "worker-0")
+	}
+
+	run, err := db.GetRun(database.Filter{Key: "id", Operator: "=", Value: id}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusPlanned || run.Attempts != 2 {
+		t.Fatalf("retried run should be planned. got %s after %d attempts: %s", run.Status, run.Attempts, run.Message)
+	}
+
+	// the artifacts of the first attempt are replaced
+	artifacts, err := db.GetRunArtifacts(database.Filter{Key: "run_id", Operator: "=", Value: id}, ctx)
+	if err != nil || len(artifacts) != 3 {
+		t.Fatalf("expected 3 artifacts, got %v (%v)", artifacts, err)
+	}
+}
+
+// staticResolver resolves a fixed executable or error.
+type staticResolver struct {
+	executable Executable
+	err        error
+}
+
+func (r staticResolver) Resolve(_ database.Workspace) (Executable, error) {
+	return r.executable, r.err
+}
+
+func TestEnqueueNoExecutable(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	q := NewQueue(nil, nil, staticResolver{err: ErrNoExecutable}, nil, config.Provisioner{}, l)
+
+	// fails before the database is accessed
+	_, err := q.Enqueue(context.TODO(), database.Workspace{Name: "web", RequiredVersion: ">= 2.0"}, database.RunKindDeploy,
+		database.RunOptions{})
+	if !errors.Is(err, ErrNoExecutable) {
+		t.Fatalf("expected ErrNoExecutable, got %v", err)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`UPDATE runs SET status`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	q := NewQueue(database.NewSqlDatabase(d, l), nil, nil, nil, config.Provisioner{Workers: 1}, l)
+
+	q.Start()
+
+	// give the worker time to poll the empty queue
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	q.Stop(ctx)
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		run      database.Run
+		expected bool
+	}{
+		{database.Run{Status: database.RunStatusErrored, Phase: "init", Attempts: 1}, true},
+		{database.Run{Status: database.RunStatusErrored, Phase: "plan", Attempts: 2}, true},
+		{database.Run{Status: database.RunStatusErrored, Phase: "plan", Attempts: 3}, false},
+		{database.Run{Status: database.RunStatusErrored, Phase: "apply", Attempts: 1}, false},
+		{database.Run{Status: database.RunStatusCanceled, Phase: "plan", Attempts: 1}, false},
+		{database.Run{Status: database.RunStatusTimedOut, Phase: "init", Attempts: 1}, false},
+	}
+
+	for _, tt := range tests {
+		if shouldRetry(tt.run, 3) != tt.expected {
+			t.Fatalf("wrong retry decision for %+v", tt.run)
+		}
+	}
+}
+
+func TestCancelRunning(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`UPDATE runs SET`).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(database.RunStatusRunning))
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	q := NewQueue(database.NewSqlDatabase(d, l), nil, nil, nil, config.Provisioner{}, l)
+
+	// run 5 is executed by this instance
+	ctx, cancelRun := context.WithCancelCause(context.TODO())
+	q.running[5] = cancelRun
+
+	status, ok, err := q.Cancel(context.TODO(), 5, "dummy")
+	if err != nil || !ok || status != database.RunStatusRunning {
+		t.Fatalf("run should be canceled: %v", err)
+	}
+
+	if !errors.Is(context.Cause(ctx), ErrCanceled) {
+		t.Fatal("running run should be interrupted")
+	}
+}
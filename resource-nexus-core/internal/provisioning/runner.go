@@ -0,0 +1,910 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+	"github.com/tbauriedel/resource-nexus-core/internal/tf/tfevent"
+)
+
+// maxStderrSize is the maximum number of bytes of stderr that is captured per phase.
+const maxStderrSize = 64 * 1024
+
+// Runner executes provisioning runs.
+//
+// A run is executed in two stages. The plan stage executes init and plan and saves the plan as run artifact.
+// Afterward, the run waits with the status planned for the approval of the plan.
+// The apply stage applies exactly the saved plan. If the state changed in the meantime, the plan is rejected as stale.
+//
+// The machine-readable output of each phase is decoded and stored as run events.
+// stderr is captured and stored together with the result of the phase.
+//
+// The credentials of the workspace are decrypted and injected as environment variables. Their values are masked
+// inside everything that is stored or logged.
+type Runner struct {
+	db       database.Database
+	cipher   *credentials.Cipher // decrypts the credentials of workspaces. nil if no credential key is configured
+	hooks    *hook.Hooks         // executed around the phases of runs. nil if no hooks are configured
+	recorder *audit.Recorder     // records the changes of the status of runs. nil records nothing
+	logger   *logging.Logger
+}
+
+// PhaseResult is the result of a single executed phase of a run.
+type PhaseResult struct {
+	Status     ExitStatus
+	ExitCode   int
+	Stderr     string
+	Err        error
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Events     map[tfevent.EventType]int // number of decoded events by type
+}
+
+// runPhase describes a single phase of a run.
+type runPhase struct {
+	subcommand SubCommand
+	args       []string
+	build      func(ctx context.Context, args []string) (*Command, error)
+	output     io.Writer     // captures stdout of phases without machine-readable output stream. nil to decode events
+	result     *PhaseResult  // receives the result of the phase. optional
+	optional   bool          // a failure does not finish the run. it is handled by the caller
+	timeout    time.Duration // the phase is interrupted and the run finished as timed out afterward. 0 for none
+}
+
+// NewRunner returns a new Runner.
+//
+// cipher can be nil. Runs of workspaces with credentials fail in this case.
+// hooks can be nil. No hooks are executed in this case.
+// recorder can be nil. Changes of the status of runs are not recorded in this case.
+func NewRunner(
+	db database.Database,
+	cipher *credentials.Cipher,
+	hooks *hook.Hooks,
+	recorder *audit.Recorder,
+	logger *logging.Logger,
+) *Runner {
+	return &Runner{
+		db:       db,
+		cipher:   cipher,
+		hooks:    hooks,
+		recorder: recorder,
+		logger:   logger,
+	}
+}
+
+// Execute executes the given run with the provisioner.
+//
+// Runs without approval execute the plan stage. Approved runs execute the apply stage.
+// Drift runs execute the drift stage.
+// The run is updated in the database whenever the phase or status changes.
+// Returns the run with its final status. An error is only returned if the run could not be executed or updated at all.
+// A failing phase is not an error, it is recorded inside the returned run.
+func (r *Runner) Execute(ctx context.Context, run database.Run, bp *BaseProvisioner) (database.Run, error) {
+	now := time.Now()
+
+	// the claimed run is stored as it is passed
+	if run.StoredStatus == "" {
+		run.StoredStatus = run.Status
+	}
+
+	run.Status = database.RunStatusRunning
+	run.StartedAt = &now
+	run.FinishedAt = nil
+	run.Message = ""
+
+	run, err := r.updateRun(ctx, run)
+	if err != nil {
+		return run, err
+	}
+
+	bp.Environment, err = r.environment(ctx, run.Workspace)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	// every run gets a private HOME and TMPDIR. nothing is shared between runs
+	bp.RunDirectory, err = newRunDirectory(bp.ProvisionerConfig.Isolation, run.ID)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	defer r.removeRunDirectory(bp.RunDirectory)
+
+	run, ok, err := r.runHooks(ctx, run, hook.PointPreInit, nil)
+	if !ok {
+		return run, err
+	}
+
+	if run.Kind == database.RunKindDrift {
+		r.logger.Info("run started", "run", run.ID, "workspace", run.Workspace, "stage", "drift")
+
+		return r.drift(ctx, run, bp)
+	}
+
+	if run.ApprovedAt != nil {
+		r.logger.Info("run started", "run", run.ID, "workspace", run.Workspace, "stage", "apply")
+
+		return r.apply(ctx, run, bp)
+	}
+
+	r.logger.Info("run started", "run", run.ID, "workspace", run.Workspace, "stage", "plan")
+
+	return r.plan(ctx, run, bp)
+}
+
+// plan executes the plan stage of the run.
+//
+// The plan is saved with `plan -out`. The plan file, its rendering by `show -json` and the version of the state are
+// stored as run artifacts. The version of the state is pulled before planning. If the state changes while planning,
+// the plan is rejected later on instead of applying a plan for an unknown state.
+// The addresses of the run options are checked against the pulled state before planning.
+func (r *Runner) plan(ctx context.Context, run database.Run, bp *BaseProvisioner) (database.Run, error) {
+	var (
+		ok       bool
+		err      error
+		state    = &bytes.Buffer{}
+		rendered = &bytes.Buffer{}
+		planFile = planFilePath(bp.WorkingDirectory, run.ID)
+	)
+
+	defer r.removeFile(planFile)
+
+	for _, phase := range []runPhase{
+		{subcommand: SubCommandInit, args: []string{"-input=false"}, build: bp.GetCommandInit, timeout: bp.Timeouts.Init},
+		{subcommand: SubCommandStatePull, build: bp.GetCommandStatePull, output: state, timeout: bp.Timeouts.Init},
+	} {
+		run, ok, err = r.executeStep(ctx, run, phase)
+		if !ok {
+			return run, err
+		}
+	}
+
+	addresses, err := StateAddresses(state.Bytes())
+	if err == nil {
+		err = ValidateAddresses(run.Options, addresses)
+	}
+
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	for _, phase := range []runPhase{
+		{
+			subcommand: SubCommandPlan,
+			args:       append([]string{"-input=false", "-out=" + planFileName(run.ID)}, RunOptionArgs(run.Options)...),
+			build:      bp.GetCommandPlan,
+			timeout:    bp.Timeouts.Plan,
+		},
+		{
+			subcommand: SubCommandShow,
+			args:       []string{planFileName(run.ID)},
+			build:      bp.GetCommandShow,
+			output:     rendered,
+			timeout:    bp.Timeouts.Plan,
+		},
+	} {
+		run, ok, err = r.executeStep(ctx, run, phase)
+		if !ok {
+			return run, err
+		}
+	}
+
+	version, err := ParseStateVersion(state.Bytes())
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	plan, err := os.ReadFile(planFile)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("failed to read saved plan: %s", err))
+	}
+
+	versionJson, err := json.Marshal(version)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("failed to encode state version: %s", err))
+	}
+
+	for _, artifact := range []database.RunArtifact{
+		{Name: ArtifactPlan, ContentType: "application/octet-stream", Content: plan},
+		{Name: ArtifactPlanJson, ContentType: "application/json", Content: rendered.Bytes()},
+		{Name: ArtifactState, ContentType: "application/json", Content: versionJson},
+	} {
+		artifact.RunID = run.ID
+
+		_, err = r.db.InsertRunArtifact(context.WithoutCancel(ctx), artifact)
+		if err != nil {
+			return r.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("failed to store %s: %s", artifact.Name, err))
+		}
+	}
+
+	run, ok, err = r.runHooks(ctx, run, hook.PointPostPlan, rendered.Bytes())
+	if !ok {
+		return run, err
+	}
+
+	// the run may have been interrupted after the last step. e.g. canceled while the artifacts were stored
+	if ctx.Err() != nil {
+		status, _ := ClassifyExit(ctx, ctx.Err())
+
+		return r.finish(ctx, run, runStatusForExit(status), fmt.Sprintf(
+			"%s before approval: %s", status, context.Cause(ctx),
+		))
+	}
+
+	run.Status = database.RunStatusPlanned
+	run.Message = "plan is waiting for approval"
+
+	r.logger.Info("run planned", "run", run.ID, "workspace", run.Workspace, "state", version)
+
+	return r.updateRun(ctx, run)
+}
+
+// apply executes the apply stage of the run.
+//
+// Only the saved plan of the run is applied. If the version of the state changed since the plan has been created,
+// the plan is rejected and the run is finished as stale.
+func (r *Runner) apply(ctx context.Context, run database.Run, bp *BaseProvisioner) (database.Run, error) {
+	saved, err := r.loadPlan(ctx, run.ID)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	var (
+		ok       bool
+		state    = &bytes.Buffer{}
+		planFile = planFilePath(bp.WorkingDirectory, run.ID)
+	)
+
+	for _, phase := range []runPhase{
+		{subcommand: SubCommandInit, args: []string{"-input=false"}, build: bp.GetCommandInit, timeout: bp.Timeouts.Init},
+		{subcommand: SubCommandStatePull, build: bp.GetCommandStatePull, output: state, timeout: bp.Timeouts.Init},
+	} {
+		run, ok, err = r.executeStep(ctx, run, phase)
+		if !ok {
+			return run, err
+		}
+	}
+
+	current, err := ParseStateVersion(state.Bytes())
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	if current != saved.version {
+		return r.finish(ctx, run, database.RunStatusStale, fmt.Sprintf(
+			"plan is stale. state changed from %s to %s since the plan has been created", saved.version, current,
+		))
+	}
+
+	run, ok, err = r.runHooks(ctx, run, hook.PointPreApply, saved.rendered)
+	if !ok {
+		return run, err
+	}
+
+	err = os.WriteFile(planFile, saved.plan, 0o600)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, fmt.Sprintf("failed to write saved plan: %s", err))
+	}
+
+	defer r.removeFile(planFile)
+
+	// the isolated user needs to be able to read the plan file
+	err = chownIsolated(planFile, bp.ProvisionerConfig.Isolation)
+	if err != nil {
+		return r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	// a saved plan is applied without asking for approval. -auto-approve is not necessary
+	run, ok, err = r.executeStep(ctx, run, runPhase{
+		subcommand: SubCommandApply,
+		args:       []string{"-input=false", planFileName(run.ID)},
+		build:      bp.GetCommandApply,
+		timeout:    bp.Timeouts.Apply,
+	})
+	if !ok {
+		return run, err
+	}
+
+	point := hook.PointPostApply
+	if run.Options.Destroy || isDestroyPlan(saved.rendered) {
+		point = hook.PointPostDestroy
+	}
+
+	run, ok, err = r.runHooks(ctx, run, point, saved.rendered)
+	if !ok {
+		return run, err
+	}
+
+	if bp.Playbook == "" {
+		return r.finish(ctx, run, database.RunStatusApplied, "")
+	}
+
+	return r.postApply(ctx, run, bp)
+}
+
+// postApply executes the post-apply stage of the run.
+//
+// An ansible inventory is generated from the terraform outputs and the playbook of the workspace is executed against
+// it. The stage is skipped if the outputs contain no hosts. The output of the playbook is stored as run events.
+// A failed stage is handled according to the configured failure policy.
+func (r *Runner) postApply(ctx context.Context, run database.Run, bp *BaseProvisioner) (database.Run, error) {
+	var (
+		ok            bool
+		err           error
+		outputs       = &bytes.Buffer{}
+		inventoryFile = inventoryFilePath(bp.WorkingDirectory, run.ID)
+	)
+
+	run, ok, err = r.executeStep(ctx, run, runPhase{
+		subcommand: SubCommandOutput,
+		build:      bp.GetCommandOutput,
+		output:     outputs,
+		optional:   true,
+		timeout:    bp.Timeouts.Init,
+	})
+	if !ok {
+		return r.postApplyFailed(ctx, run, bp, err)
+	}
+
+	inventory, err := BuildInventory(outputs.Bytes(), bp.ProvisionerConfig.Ansible.InventoryOutput)
+	if errors.Is(err, ErrNoHosts) {
+		run.PostApplyStatus = database.PostApplyStatusSkipped
+
+		return r.finish(ctx, run, database.RunStatusApplied, fmt.Sprintf("post-apply stage skipped: %s", err))
+	}
+
+	if err != nil {
+		run.Message = err.Error()
+
+		return r.postApplyFailed(ctx, run, bp, nil)
+	}
+
+	err = os.WriteFile(inventoryFile, inventory, 0o600)
+	if err == nil {
+		// the isolated user needs to be able to read the inventory
+		err = chownIsolated(inventoryFile, bp.ProvisionerConfig.Isolation)
+	}
+
+	defer r.removeFile(inventoryFile)
+
+	if err != nil {
+		run.Message = fmt.Sprintf("failed to write inventory: %s", err)
+
+		return r.postApplyFailed(ctx, run, bp, nil)
+	}
+
+	run, ok, err = r.executeStep(ctx, run, runPhase{
+		subcommand: SubCommandAnsiblePlaybook,
+		build: func(ctx context.Context, _ []string) (*Command, error) {
+			return bp.GetCommandAnsiblePlaybook(ctx, inventoryFile)
+		},
+		optional: true,
+	})
+	if !ok {
+		return r.postApplyFailed(ctx, run, bp, err)
+	}
+
+	run.PostApplyStatus = database.PostApplyStatusSucceeded
+
+	return r.finish(ctx, run, database.RunStatusApplied, "")
+}
+
+// postApplyFailed finishes the run after a failed post-apply stage.
+//
+// Runs that have already been finished by an interruption are returned as they are. Otherwise, the run is finished as
+// errored, or as applied if the failure policy is FailurePolicyContinue.
+func (r *Runner) postApplyFailed(
+	ctx context.Context, run database.Run, bp *BaseProvisioner, err error,
+) (database.Run, error) {
+	if run.FinishedAt != nil || err != nil {
+		return run, err
+	}
+
+	run.PostApplyStatus = database.PostApplyStatusFailed
+
+	message := fmt.Sprintf("post-apply stage failed: %s", run.Message)
+
+	if bp.ProvisionerConfig.Ansible.FailurePolicy == FailurePolicyContinue {
+		return r.finish(ctx, run, database.RunStatusApplied, message)
+	}
+
+	return r.finish(ctx, run, database.RunStatusErrored, message)
+}
+
+// environment returns the decrypted credentials of the workspace by their names.
+func (r *Runner) environment(ctx context.Context, workspace string) (map[string]string, error) {
+	stored, err := r.db.GetCredentials(database.Filter{Key: "workspace", Operator: "=", Value: workspace}, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	if len(stored) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	if r.cipher == nil {
+		return nil, fmt.Errorf("cant decrypt credentials of workspace: %w", credentials.ErrNoKey)
+	}
+
+	environment := make(map[string]string, len(stored))
+
+	for _, credential := range stored {
+		value, err := r.cipher.Decrypt(credential.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cant decrypt credential '%s': %w", credential.Name, err)
+		}
+
+		environment[credential.Name] = string(value)
+	}
+
+	return environment, nil
+}
+
+// drift executes the drift stage of the run.
+//
+// A refresh-only plan with detailed exit code is created. Exit code 2 means the infrastructure has been changed outside
+// of terraform. The changed resources are stored as resource_drift run events. The plan is never applied.
+// The result is stored as drift status of the workspace.
+func (r *Runner) drift(ctx context.Context, run database.Run, bp *BaseProvisioner) (database.Run, error) {
+	var (
+		ok     bool
+		err    error
+		result PhaseResult
+	)
+
+	for _, phase := range []runPhase{
+		{subcommand: SubCommandInit, args: []string{"-input=false"}, build: bp.GetCommandInit, timeout: bp.Timeouts.Init},
+		{
+			subcommand: SubCommandPlan,
+			args:       []string{"-input=false", "-detailed-exitcode"},
+			build:      bp.GetCommandPlanRefreshOnly,
+			result:     &result,
+			timeout:    bp.Timeouts.Plan,
+		},
+	} {
+		run, ok, err = r.executeStep(ctx, run, phase)
+		if !ok {
+			r.updateDrift(ctx, run, database.DriftStatusFailed)
+
+			return run, err
+		}
+	}
+
+	if result.Status != ExitStatusChanges {
+		r.updateDrift(ctx, run, database.DriftStatusInSync)
+
+		return r.finish(ctx, run, database.RunStatusCompleted, "no drift detected")
+	}
+
+	r.updateDrift(ctx, run, database.DriftStatusDrifted)
+
+	return r.finish(ctx, run, database.RunStatusCompleted, fmt.Sprintf(
+		"drift detected. %d resources changed outside of terraform", result.Events[tfevent.EventTypeResourceDrift],
+	))
+}
+
+// updateDrift stores the drift status of the workspace of the run.
+func (r *Runner) updateDrift(ctx context.Context, run database.Run, status database.DriftStatus) {
+	_, err := r.db.UpdateWorkspaceDrift(context.WithoutCancel(ctx), run.Workspace, status, run.ID)
+	if err != nil {
+		r.logger.Error("failed to update drift of workspace", "run", run.ID, "workspace", run.Workspace, "error", err)
+	}
+}
+
+// savedPlan is the saved plan of a run loaded from its artifacts.
+type savedPlan struct {
+	plan     []byte       // binary plan file
+	rendered []byte       // rendering of the plan file by `show -json`
+	version  StateVersion // version of the state the plan has been created for
+}
+
+// loadPlan loads the saved plan, its rendering and the version of the state it has been created for.
+func (r *Runner) loadPlan(ctx context.Context, runID int) (savedPlan, error) {
+	artifacts, err := r.db.GetRunArtifacts(database.Filter{Key: "run_id", Operator: "=", Value: runID}, ctx)
+	if err != nil {
+		return savedPlan{}, fmt.Errorf("failed to load saved plan: %w", err)
+	}
+
+	var (
+		saved   savedPlan
+		version *StateVersion
+	)
+
+	for _, artifact := range artifacts {
+		switch artifact.Name {
+		case ArtifactPlan:
+			saved.plan = artifact.Content
+		case ArtifactPlanJson:
+			saved.rendered = artifact.Content
+		case ArtifactState:
+			version = &StateVersion{}
+
+			err = json.Unmarshal(artifact.Content, version)
+			if err != nil {
+				return savedPlan{}, fmt.Errorf("failed to decode state version of saved plan: %w", err)
+			}
+		}
+	}
+
+	if saved.plan == nil || version == nil {
+		return savedPlan{}, fmt.Errorf("run has no saved plan")
+	}
+
+	saved.version = *version
+
+	return saved, nil
+}
+
+// runHooks executes the hooks of the point and stores their annotations.
+//
+// plan is the rendering of the saved plan. nil at points without plan.
+// Returns false if the run can't be continued. The run is finished as vetoed if a hook vetoed it, or as errored if a
+// hook failed.
+func (r *Runner) runHooks(
+	ctx context.Context, run database.Run, point hook.Point, plan []byte,
+) (database.Run, bool, error) {
+	annotations, err := r.hooks.Run(ctx, hook.Input{Point: point, Run: run, Plan: plan})
+
+	for _, annotation := range annotations {
+		_, aerr := r.db.InsertRunAnnotation(context.WithoutCancel(ctx), annotation)
+		if aerr != nil {
+			r.logger.Error("failed to store run annotation", "run", run.ID, "hook", annotation.Hook, "error", aerr)
+		}
+	}
+
+	if err == nil {
+		return run, true, nil
+	}
+
+	var veto *hook.VetoError
+
+	switch {
+	case errors.As(err, &veto):
+		run, err = r.finish(ctx, run, database.RunStatusVetoed, veto.Error())
+	case ctx.Err() != nil:
+		// the hook has been interrupted. e.g. cancellation or shutdown
+		status, _ := ClassifyExit(ctx, ctx.Err())
+
+		run, err = r.finish(ctx, run, runStatusForExit(status), fmt.Sprintf(
+			"%s during %s hooks: %s", status, point, context.Cause(ctx),
+		))
+	default:
+		run, err = r.finish(ctx, run, database.RunStatusErrored, err.Error())
+	}
+
+	return run, false, err
+}
+
+// executeStep executes a single phase of the run and stores its result.
+//
+// Returns false if the run can't be continued. The run is finished with the matching status in this case.
+// Failures of optional phases do not finish the run, unless it has been interrupted. The failure is returned as message
+// of the run and needs to be handled by the caller.
+// If the phase exceeds its timeout, it is interrupted and the run is finished as timed out. Events that have been
+// stored until then are kept.
+func (r *Runner) executeStep(ctx context.Context, run database.Run, phase runPhase) (database.Run, bool, error) {
+	// do not start the next phase of an interrupted run
+	if ctx.Err() != nil {
+		status, _ := ClassifyExit(ctx, ctx.Err())
+
+		run, err := r.finish(ctx, run, runStatusForExit(status), fmt.Sprintf(
+			"%s before %s: %s", status, phase.subcommand, context.Cause(ctx),
+		))
+
+		return run, false, err
+	}
+
+	run.Phase = string(phase.subcommand)
+	run.Status = database.RunStatusRunning
+
+	run, err := r.updateRun(ctx, run)
+	if err != nil {
+		return run, false, err
+	}
+
+	if phase.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeoutCause(ctx, phase.timeout,
+			fmt.Errorf("%w: %s did not finish within %s", ErrPhaseTimeout, phase.subcommand, phase.timeout))
+		defer cancel()
+	}
+
+	cmd, err := phase.build(ctx, phase.args)
+	if err != nil && phase.optional {
+		run.Message = err.Error()
+
+		return run, false, nil
+	}
+
+	if err != nil {
+		run, err = r.finish(ctx, run, database.RunStatusErrored, err.Error())
+
+		return run, false, err
+	}
+
+	result := r.executePhase(ctx, run.ID, phase.subcommand, cmd, phase.output)
+	if phase.result != nil {
+		*phase.result = result
+	}
+
+	err = r.storePhase(ctx, run.ID, phase.subcommand, result)
+	if err != nil {
+		r.logger.Error("failed to store run phase", "run", run.ID, "phase", phase.subcommand, "error", err)
+	}
+
+	if !result.Status.Succeeded() {
+		message := phaseMessage(phase.subcommand, result)
+
+		// add the reason of an interruption. e.g. cancellation or shutdown
+		if ctx.Err() != nil {
+			message = fmt.Sprintf("%s: %s", message, context.Cause(ctx))
+		}
+
+		if phase.optional && ctx.Err() == nil {
+			run.Message = message
+
+			return run, false, nil
+		}
+
+		run, err = r.finish(ctx, run, runStatusForExit(result.Status), message)
+
+		return run, false, err
+	}
+
+	return run, true, nil
+}
+
+// executePhase starts the command and waits for it to finish.
+//
+// If output is nil, stdout is decoded with the tfevent.Decoder and each event is stored as run event.
+// Otherwise, stdout is written to output once the command has finished.
+// stderr is captured and returned inside the PhaseResult.
+// Injected values are masked inside stdout and stderr.
+func (r *Runner) executePhase(
+	ctx context.Context, runID int, phase SubCommand, cmd *Command, output io.Writer,
+) PhaseResult {
+	result := PhaseResult{
+		StartedAt: time.Now(),
+	}
+
+	stderr := &cappedBuffer{limit: maxStderrSize}
+	cmd.Stderr = stderr
+
+	var (
+		stdout   io.Reader
+		captured = &bytes.Buffer{}
+		err      error
+	)
+
+	if output != nil {
+		cmd.Stdout = captured
+	} else {
+		stdout, err = cmd.StdoutPipe()
+		if err != nil {
+			return failedPhase(result, fmt.Errorf("failed to attach to stdout: %w", err))
+		}
+	}
+
+	r.logger.Debug("executing command", "run", runID, "phase", phase, "command", cmd.String())
+
+	err = cmd.Start()
+	if err != nil {
+		return failedPhase(result, fmt.Errorf("failed to start command: %w", err))
+	}
+
+	if stdout != nil {
+		result.Events = r.decodeEvents(ctx, runID, phase, cmd.masker.Reader(stdout))
+	}
+
+	err = cmd.Wait()
+
+	result.FinishedAt = time.Now()
+	result.Status, result.ExitCode = ClassifyExit(ctx, err)
+
+	// exit code 2 only reports changes of commands started with -detailed-exitcode. e.g. a crashed apply exits with 2
+	if slices.Contains(cmd.Args, "-detailed-exitcode") {
+		result.Status, result.ExitCode = ClassifyDetailedExit(ctx, err)
+	}
+
+	result.Stderr = cmd.masker.Mask(stderr.String())
+	result.Err = err
+
+	if output != nil {
+		_, werr := output.Write(cmd.masker.MaskBytes(captured.Bytes()))
+		if werr != nil {
+			return failedPhase(result, fmt.Errorf("failed to write output: %w", werr))
+		}
+	}
+
+	r.logger.Debug("command finished", "run", runID, "phase", phase, "result", result.Status, "exit", result.ExitCode)
+
+	return result
+}
+
+// decodeEvents decodes the machine-readable output until stdout is closed and stores each event as run event.
+// Returns the number of decoded events by type.
+func (r *Runner) decodeEvents(
+	ctx context.Context, runID int, phase SubCommand, stdout io.Reader,
+) map[tfevent.EventType]int {
+	decoder := tfevent.NewDecoder(stdout)
+	counts := make(map[tfevent.EventType]int)
+
+	for {
+		event, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			return counts
+		}
+
+		if err != nil {
+			r.logger.Warn("failed to decode output", "run", runID, "phase", phase, "error", err)
+
+			// drain stdout. the process would block on a full pipe otherwise
+			_, _ = io.Copy(io.Discard, stdout)
+
+			return counts
+		}
+
+		counts[event.Type]++
+
+		r.storeEvent(ctx, runID, phase, event)
+	}
+}
+
+// removeFile removes a temporary file of the run from the working directory.
+// Saved plans and inventories contain the values of the infrastructure and are not kept on disk.
+func (r *Runner) removeFile(file string) {
+	err := os.Remove(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		r.logger.Warn("failed to remove file", "file", file, "error", err)
+	}
+}
+
+// removeRunDirectory removes the private directory of the run.
+func (r *Runner) removeRunDirectory(dir string) {
+	err := os.RemoveAll(dir)
+	if err != nil {
+		r.logger.Warn("failed to remove run directory", "directory", dir, "error", err)
+	}
+}
+
+// storeEvent stores the decoded event as run event.
+//
+// The event is stored even if ctx is already canceled, so the event log of an interrupted run is kept.
+func (r *Runner) storeEvent(ctx context.Context, runID int, phase SubCommand, event tfevent.Event) {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	_, err := r.db.InsertRunEvent(context.WithoutCancel(ctx), database.RunEvent{
+		RunID:     runID,
+		Phase:     string(phase),
+		Type:      string(event.Type),
+		Level:     event.Level,
+		Message:   event.Message,
+		Payload:   string(event.Raw),
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		r.logger.Error("failed to store run event", "run", runID, "phase", phase, "error", err)
+	}
+}
+
+// storePhase stores the result of the phase.
+func (r *Runner) storePhase(ctx context.Context, runID int, phase SubCommand, result PhaseResult) error {
+	_, err := r.db.InsertRunPhase(context.WithoutCancel(ctx), database.RunPhase{
+		RunID:      runID,
+		Phase:      string(phase),
+		Result:     string(result.Status),
+		ExitCode:   result.ExitCode,
+		Stderr:     result.Stderr,
+		StartedAt:  result.StartedAt,
+		FinishedAt: result.FinishedAt,
+	})
+
+	return err //nolint:wrapcheck
+}
+
+// finish sets the final status of the run and stores it.
+func (r *Runner) finish(
+	ctx context.Context, run database.Run, status database.RunStatus, message string,
+) (database.Run, error) {
+	now := time.Now()
+
+	run.Status = status
+	run.Message = message
+	run.FinishedAt = &now
+
+	r.logger.Info("run finished", "run", run.ID, "workspace", run.Workspace, "status", status, "message", message)
+
+	return r.updateRun(ctx, run)
+}
+
+// updateRun stores the current state of the run.
+//
+// The update is done even if ctx is already canceled, so the final status of an interrupted run is stored.
+// A changed status is recorded in the audit log. Returns the run with the updated StoredStatus.
+func (r *Runner) updateRun(ctx context.Context, run database.Run) (database.Run, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	_, err := r.db.UpdateRun(ctx, run)
+	if err != nil {
+		return run, fmt.Errorf("failed to update run %d: %w", run.ID, err)
+	}
+
+	r.recorder.RecordRun(ctx, run, run.StoredStatus)
+
+	run.StoredStatus = run.Status
+
+	return run, nil
+}
+
+// failedPhase returns the given result, marked as failed with err.
+func failedPhase(result PhaseResult, err error) PhaseResult {
+	result.Status = ExitStatusFailed
+	result.ExitCode = -1
+	result.Err = err
+	result.FinishedAt = time.Now()
+
+	return result
+}
+
+// phaseMessage builds the message of a run that stopped in the given phase.
+func phaseMessage(phase SubCommand, result PhaseResult) string {
+	if result.Err != nil && result.ExitCode == -1 {
+		return fmt.Sprintf("%s %s: %s", phase, result.Status, result.Err.Error())
+	}
+
+	return fmt.Sprintf("%s %s with exit code %d", phase, result.Status, result.ExitCode)
+}
+
+// cappedBuffer is an io.Writer that keeps at most limit bytes. Everything above the limit is discarded.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write writes p into the buffer until the limit is reached.
+// Never returns an error, so the writing process is not interrupted.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = true
+
+		return len(p), nil
+	}
+
+	if len(p) > remaining {
+		b.truncated = true
+		b.buf.Write(p[:remaining])
+
+		return len(p), nil
+	}
+
+	b.buf.Write(p)
+
+	return len(p), nil
+}
+
+// String returns the captured content. A note is appended if the content has been truncated.
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[truncated]"
+	}
+
+	return b.buf.String()
+}
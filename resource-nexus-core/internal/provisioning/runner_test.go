@@ -0,0 +1,646 @@
+package provisioning
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/audit"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/credentials"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/hook"
+)
+
+func Test_executePhase(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`INSERT INTO run_events`).
+		WithArgs(1, "plan", "version", "info", "Terraform 1.9.5", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO run_events`).
+		WithArgs(1, "plan", "log", "info", "not json", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	script := `echo '{"@level":"info","@message":"Terraform 1.9.5","type":"version"}'; echo 'not json'; echo 'boom' >&2; exit 1`
+	cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "sh", "-c", script)}
+
+	result := r.executePhase(context.TODO(), 1, SubCommandPlan, cmd, nil)
+
+	if result.Status != ExitStatusFailed || result.ExitCode != 1 {
+		t.Fatalf("wrong result: %s (%d)", result.Status, result.ExitCode)
+	}
+
+	if strings.TrimSpace(result.Stderr) != "boom" {
+		t.Fatalf("wrong stderr captured: %s", result.Stderr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func Test_executePhaseExitCodeChanges(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(nil, nil, nil, nil, l)
+
+	tests := []struct {
+		phase  SubCommand
+		args   []string
+		status ExitStatus
+	}{
+		{SubCommandPlan, []string{"-input=false", "-detailed-exitcode"}, ExitStatusChanges},
+		{SubCommandApply, []string{"-input=false"}, ExitStatusFailed}, // e.g. fatal error of the go runtime
+		{SubCommandAnsiblePlaybook, []string{"site.yml"}, ExitStatusFailed},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"-c", "exit 2", "sh"}, tt.args...)
+		cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "sh", args...)}
+
+		result := r.executePhase(context.TODO(), 1, tt.phase, cmd, &strings.Builder{})
+		if result.Status != tt.status || result.ExitCode != 2 {
+			t.Fatalf("%s: expected %s, got %s (%d)", tt.phase, tt.status, result.Status, result.ExitCode)
+		}
+	}
+}
+
+func Test_executePhaseMasksSecrets(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	masked := "token " + credentials.MaskPlaceholder
+
+	mock.ExpectExec(`INSERT INTO run_events`).
+		WithArgs(1, "plan", "log", "info", masked, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// the injected value is printed to stdout and stderr
+	cmd := buildCommand(t.TempDir(), "sh", SubCommandPlan, nil, map[string]string{"TF_VAR_token": "s3cr3t"}, "",
+		context.TODO())
+	cmd.Args = []string{"sh", "-c", `echo "{\"@level\":\"info\",\"@message\":\"token $TF_VAR_token\",\"type\":\"log\"}"; echo "token $TF_VAR_token" >&2`}
+
+	result := r.executePhase(context.TODO(), 1, SubCommandPlan, cmd, nil)
+
+	if strings.TrimSpace(result.Stderr) != masked {
+		t.Fatalf("secret not masked inside stderr: %s", result.Stderr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+
+	// captured output
+	output := &strings.Builder{}
+
+	cmd = buildCommand(t.TempDir(), "sh", SubCommandShow, nil, map[string]string{"TF_VAR_token": "s3cr3t"}, "",
+		context.TODO())
+	cmd.Args = []string{"sh", "-c", `echo "token $TF_VAR_token"`}
+
+	r.executePhase(context.TODO(), 1, SubCommandShow, cmd, output)
+
+	if strings.TrimSpace(output.String()) != masked {
+		t.Fatalf("secret not masked inside output: %s", output.String())
+	}
+}
+
+func TestExecuteCredentialsWithoutKey(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}).
+			AddRow(1, "web", "TF_VAR_token", []byte{0x01}, time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusErrored, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	run, err := r.Execute(context.TODO(), database.Run{ID: 7, Workspace: "web"}, &BaseProvisioner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusErrored || !strings.Contains(run.Message, credentials.ErrNoKey.Error()) {
+		t.Fatalf("run should fail without credential key. got status %s: %s", run.Status, run.Message)
+	}
+}
+
+func TestExecuteVetoed(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+
+	hooks := hook.New(l)
+	_ = hooks.Register(hook.PointPreInit, hook.NewFunc("freeze", func(_ context.Context, _ hook.Input) (hook.Result, error) {
+		return hook.Result{Veto: true, Reason: "change freeze", Annotations: map[string]string{"ticket": "CHG-1"}}, nil
+	}), false)
+
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, hooks, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}))
+	mock.ExpectExec(`INSERT INTO run_annotations`).
+		WithArgs(7, "freeze", "ticket", "CHG-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusVetoed, "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	run, err := r.Execute(context.TODO(), database.Run{ID: 7, Workspace: "web"}, &BaseProvisioner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusVetoed || !strings.Contains(run.Message, "change freeze") {
+		t.Fatalf("run should be vetoed. got status %s: %s", run.Status, run.Message)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+// writePlanExecutable writes an executable into dir that successfully plans without changes.
+func writePlanExecutable(t *testing.T, dir string) string {
+	t.Helper()
+
+	executable := filepath.Join(dir, "terraform")
+
+	err := os.WriteFile(executable, []byte(`#!/bin/sh
+case "$1" in
+state) echo '{"serial":3,"lineage":"abc"}' ;;
+plan) for arg; do case "$arg" in -out=*) : > "${arg#-out=}" ;; esac; done ;;
+show) echo '{}' ;;
+esac
+exit 0
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return executable
+}
+
+func TestExecutePlanCanceledBeforeApproval(t *testing.T) {
+	dir := t.TempDir()
+	executable := writePlanExecutable(t, dir)
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+
+	_, err := db.InsertRun(context.TODO(), database.Run{Workspace: "web", Status: database.RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, _, err := db.ClaimRun(context.TODO(), "worker-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.TODO())
+	defer cancel(nil)
+
+	// the run is canceled after the last step of the plan, before it is stored as planned
+	hooks := hook.New(l)
+	_ = hooks.Register(hook.PointPostPlan, hook.NewFunc("cancel", func(_ context.Context, _ hook.Input) (hook.Result, error) {
+		_, _, err := db.CancelRun(context.TODO(), run.ID, "dummy")
+		cancel(ErrCanceled)
+
+		return hook.Result{}, err
+	}), false)
+
+	r := NewRunner(db, nil, hooks, nil, l)
+
+	run, err = r.Execute(ctx, run, &BaseProvisioner{
+		ProvisionerConfig: config.Provisioner{AllowedExecutables: executable},
+		ExecutablePath:    executable,
+		WorkingDirectory:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusCanceled {
+		t.Fatalf("canceled plan should not wait for approval. got status %s: %s", run.Status, run.Message)
+	}
+
+	stored, err := db.GetRun(database.Filter{Key: "id", Operator: "=", Value: run.ID}, context.TODO())
+	if err != nil || stored.Status != database.RunStatusCanceled {
+		t.Fatalf("canceled plan should be stored as canceled. got %v: %v", stored.Status, err)
+	}
+
+	result, err := db.ApproveRun(context.TODO(), run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected != 0 {
+		t.Fatal("canceled plan should not be approved")
+	}
+}
+
+func Test_executePhaseStartFailure(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(nil, nil, nil, nil, l)
+
+	cmd := &Command{Cmd: exec.CommandContext(context.TODO(), "/does/not/exist")}
+
+	result := r.executePhase(context.TODO(), 1, SubCommandInit, cmd, nil)
+	if result.Status != ExitStatusFailed || result.Err == nil {
+		t.Fatal("start failure should result in a failed phase")
+	}
+}
+
+func TestExecuteApplyStalePlan(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	// the state has been changed (serial 4) since the plan has been created (serial 3)
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "terraform")
+
+	err := os.WriteFile(executable, []byte(`#!/bin/sh
+[ "$1 $2" = "state pull" ] && echo '{"serial":4,"lineage":"abc"}'
+exit 0
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}))
+	mock.ExpectQuery(`SELECT (.+) FROM run_artifacts WHERE run_id = \$1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "run_id", "name", "content_type", "content", "created_at"}).
+			AddRow(1, 7, ArtifactPlan, "application/octet-stream", []byte("plan"), time.Now()).
+			AddRow(2, 7, ArtifactState, "application/json", []byte(`{"serial":3,"lineage":"abc"}`), time.Now()))
+	mock.ExpectExec(`UPDATE runs SET`).WithArgs(updateRunArgs(database.RunStatusRunning, "init", "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).WithArgs(updateRunArgs(database.RunStatusRunning, "state pull", "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusStale, "state pull", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	approved := time.Now()
+
+	run, err := r.Execute(context.TODO(), database.Run{ID: 7, Workspace: "web", ApprovedAt: &approved}, &BaseProvisioner{
+		ProvisionerConfig: config.Provisioner{AllowedExecutables: executable},
+		ExecutablePath:    executable,
+		WorkingDirectory:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusStale {
+		t.Fatalf("stale plan should be rejected. got status %s: %s", run.Status, run.Message)
+	}
+
+	// the saved plan must not be written, if it is not applied
+	_, err = os.Stat(planFilePath(dir, 7))
+	if !os.IsNotExist(err) {
+		t.Fatal("plan file of a stale plan should not be written")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExecuteDrift(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	// the refresh-only plan detects one changed resource
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "terraform")
+
+	err := os.WriteFile(executable, []byte(`#!/bin/sh
+[ "$1" = "plan" ] || exit 0
+echo '{"@level":"info","@message":"null_resource.web: Drift detected (update)","type":"resource_drift"}'
+exit 2
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}))
+	mock.ExpectExec(`UPDATE runs SET`).WithArgs(updateRunArgs(database.RunStatusRunning, "init", "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).WithArgs(updateRunArgs(database.RunStatusRunning, "plan", "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_events`).
+		WithArgs(7, "plan", "resource_drift", "info", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE workspaces SET drift_status`).
+		WithArgs(database.DriftStatusDrifted, 7, "web").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusCompleted, "plan", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	run, err := r.Execute(context.TODO(), database.Run{ID: 7, Workspace: "web", Kind: database.RunKindDrift},
+		&BaseProvisioner{
+			ProvisionerConfig: config.Provisioner{AllowedExecutables: executable},
+			ExecutablePath:    executable,
+			WorkingDirectory:  dir,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusCompleted || !strings.Contains(run.Message, "1 resources") {
+		t.Fatalf("drift should be detected. got status %s: %s", run.Status, run.Message)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestExecuteApplyPostApplyFailed(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	// the state has not been changed since the plan has been created. the playbook fails
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "terraform")
+	playbook := filepath.Join(dir, "ansible-playbook")
+
+	err := os.WriteFile(executable, []byte(`#!/bin/sh
+[ "$1 $2" = "state pull" ] && echo '{"serial":3,"lineage":"abc"}'
+[ "$1" = "output" ] && echo '{"ansible_hosts":{"sensitive":false,"value":["10.0.0.1"]}}'
+exit 0
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(playbook, []byte(`#!/bin/sh
+grep -q 10.0.0.1 "$2" || exit 3
+echo "PLAY [all]"
+exit 2
+`), 0o700) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM credentials WHERE workspace = \$1`).
+		WithArgs("web").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace", "name", "value", "created_at", "updated_at"}))
+	mock.ExpectQuery(`SELECT (.+) FROM run_artifacts WHERE run_id = \$1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "run_id", "name", "content_type", "content", "created_at"}).
+			AddRow(1, 7, ArtifactPlan, "application/octet-stream", []byte("plan"), time.Now()).
+			AddRow(2, 7, ArtifactState, "application/json", []byte(`{"serial":3,"lineage":"abc"}`), time.Now()))
+
+	for _, phase := range []string{"init", "state pull", "apply", "output"} {
+		mock.ExpectExec(`UPDATE runs SET`).
+			WithArgs(updateRunArgs(database.RunStatusRunning, phase, "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusRunning, "ansible-playbook", "", sqlmock.AnyArg(), nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_events`).
+		WithArgs(7, "ansible-playbook", "log", "info", "PLAY [all]", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusApplied, "ansible-playbook", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			database.PostApplyStatusFailed, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	approved := time.Now()
+
+	run, err := r.Execute(context.TODO(), database.Run{ID: 7, Workspace: "web", ApprovedAt: &approved}, &BaseProvisioner{
+		ProvisionerConfig: config.Provisioner{
+			AllowedExecutables: executable,
+			Ansible: config.Ansible{
+				ExecutablePath:  playbook,
+				InventoryOutput: "ansible_hosts",
+				FailurePolicy:   FailurePolicyContinue,
+			},
+		},
+		ExecutablePath:   executable,
+		WorkingDirectory: dir,
+		Playbook:         "site.yml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Status != database.RunStatusApplied || !strings.Contains(run.Message, "ansible-playbook failed") {
+		t.Fatalf("failed playbook should be recorded. got status %s: %s", run.Status, run.Message)
+	}
+
+	// the inventory must not be kept
+	_, err = os.Stat(inventoryFilePath(dir, 7))
+	if !os.IsNotExist(err) {
+		t.Fatal("inventory should be removed")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func Test_executePhaseKillAfterGracePeriod(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(nil, nil, nil, nil, l)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+
+	// the command ignores the interrupt
+	cmd := &Command{Cmd: exec.CommandContext(ctx, "sh", "-c", "trap '' INT; exec sleep 10")}
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 200 * time.Millisecond
+
+	start := time.Now()
+
+	result := r.executePhase(ctx, 1, SubCommandPlan, cmd, &strings.Builder{})
+
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("command should be killed after the grace period")
+	}
+
+	if result.Status != ExitStatusTimedOut {
+		t.Fatalf("wrong result: %s", result.Status)
+	}
+}
+
+func Test_executeStepTimeout(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	r := NewRunner(database.NewSqlDatabase(d, l), nil, nil, nil, l)
+
+	mock.ExpectExec(`UPDATE runs SET`).WithArgs(updateRunArgs(database.RunStatusRunning, "apply", "", nil, nil, database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO run_phases`).
+		WithArgs(7, "apply", string(ExitStatusTimedOut), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE runs SET`).
+		WithArgs(updateRunArgs(database.RunStatusTimedOut, "apply", sqlmock.AnyArg(), nil, sqlmock.AnyArg(), database.PostApplyStatusNone, 7)...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	start := time.Now()
+
+	// the hanging command is interrupted once the timeout of the phase is exceeded
+	run, ok, err := r.executeStep(context.TODO(), database.Run{ID: 7, Workspace: "web"}, runPhase{
+		subcommand: SubCommandApply,
+		build: func(ctx context.Context, _ []string) (*Command, error) {
+			cmd := &Command{Cmd: exec.CommandContext(ctx, "sh", "-c", "exec sleep 10")}
+			cmd.Cancel = func() error {
+				return cmd.Process.Signal(os.Interrupt)
+			}
+
+			return cmd, nil
+		},
+		output:  &strings.Builder{},
+		timeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok || time.Since(start) > 5*time.Second {
+		t.Fatal("phase should be interrupted after its timeout")
+	}
+
+	if run.Status != database.RunStatusTimedOut || !strings.Contains(run.Message, "apply did not finish within 100ms") {
+		t.Fatalf("run should be timed out. got status %s: %s", run.Status, run.Message)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func Test_cappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 4}
+
+	n, err := b.Write([]byte("foobar"))
+	if err != nil || n != 6 {
+		t.Fatal("write should always succeed")
+	}
+
+	if b.String() != "foob\n[truncated]" {
+		t.Fatalf("wrong content: %s", b.String())
+	}
+}
+
+func Test_updateRunAudit(t *testing.T) {
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+	ctx := context.TODO()
+
+	recorder, err := audit.NewRecorder(db, config.Audit{}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner(db, nil, nil, recorder, l)
+
+	id, err := db.InsertRun(ctx, database.Run{Workspace: "web", Kind: database.RunKindDeploy,
+		Status: database.RunStatusQueued})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, _, err := db.ClaimRun(ctx, "worker-0")
+	if err != nil || run.ID != id {
+		t.Fatalf("expected the run to be claimed: %v", err)
+	}
+
+	run.StoredStatus = run.Status
+
+	// the phase changed, but not the status
+	run.Phase = string(SubCommandPlan)
+
+	run, err = r.updateRun(ctx, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run.Status = database.RunStatusPlanned
+
+	run, err = r.updateRun(ctx, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err = r.finish(ctx, run, database.RunStatusErrored, "failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.StoredStatus != database.RunStatusErrored {
+		t.Fatalf("stored status should follow the update. got %s", run.StoredStatus)
+	}
+
+	entries, err := db.ListAuditEntries(
+		database.Filter{Key: "target", Operator: "=", Value: audit.Target("run", id)}, database.Page{}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries.Total != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries.Items)
+	}
+
+	if string(entries.Items[0].Before) != `{"status":"running"}` ||
+		string(entries.Items[1].Before) != `{"status":"planned"}` ||
+		!strings.Contains(string(entries.Items[1].After), `"status":"errored"`) {
+		t.Fatalf("unexpected entries: %+v", entries.Items)
+	}
+}
@@ -0,0 +1,112 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+// DriftScheduler enqueues drift runs for workspaces with a drift interval.
+//
+// Due workspaces are checked in the configured interval. Workspaces with an active run are skipped and checked again
+// on the next tick.
+type DriftScheduler struct {
+	db       database.Database
+	queue    *Queue
+	interval time.Duration
+	logger   *logging.Logger
+
+	wg   sync.WaitGroup
+	stop context.CancelFunc
+}
+
+// NewDriftScheduler returns a new DriftScheduler.
+//
+// An unset or invalid check interval inside conf is replaced by the default.
+func NewDriftScheduler(
+	db database.Database,
+	queue *Queue,
+	conf config.Provisioner,
+	logger *logging.Logger,
+) *DriftScheduler {
+	interval := conf.DriftCheckInterval
+	if interval <= 0 {
+		interval = config.LoadDefaults().Provisioner.DriftCheckInterval
+	}
+
+	return &DriftScheduler{
+		db:       db,
+		queue:    queue,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start starts checking for due workspaces in the background.
+func (s *DriftScheduler) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+
+	s.stop = stop
+
+	s.logger.Info("starting drift scheduler", "interval", s.interval)
+
+	s.wg.Go(func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.schedule(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Stop stops the scheduler. Already enqueued drift runs are not affected.
+func (s *DriftScheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+
+	s.stop()
+	s.wg.Wait()
+
+	s.logger.Debug("drift scheduler stopped")
+}
+
+// schedule enqueues a drift run for each due workspace.
+func (s *DriftScheduler) schedule(ctx context.Context) {
+	workspaces, err := s.db.GetDriftDueWorkspaces(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to get workspaces due for drift detection", "error", err)
+		}
+
+		return
+	}
+
+	for _, workspace := range workspaces {
+		_, err = s.queue.Enqueue(ctx, workspace, database.RunKindDrift, database.RunOptions{})
+
+		var conflict *RunConflictError
+		if errors.As(err, &conflict) {
+			s.logger.Debug("skipping drift detection. workspace has an active run",
+				"workspace", workspace.Name, "run", conflict.RunID)
+
+			continue
+		}
+
+		if err != nil {
+			s.logger.Error("failed to enqueue drift run", "workspace", workspace.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+)
+
+func TestNewDriftSchedulerDefaults(t *testing.T) {
+	s := NewDriftScheduler(nil, nil, config.Provisioner{}, nil)
+
+	if s.interval != config.LoadDefaults().Provisioner.DriftCheckInterval {
+		t.Fatal("unset check interval should be replaced by the default")
+	}
+}
+
+func TestDriftSchedulerSchedule(t *testing.T) {
+	d, mock, _ := sqlmock.New()
+	defer d.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM workspaces WHERE drift_interval > 0`).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "name", "working_directory", "executable_path", "provisioner", "required_version", "created_at",
+			"drift_interval", "drift_status", "drift_checked_at", "drift_run_id", "playbook", "init_timeout",
+			"plan_timeout", "apply_timeout",
+		}).
+			AddRow(1, "web", "/srv/web", "", "", "", time.Now(), 3600, "", nil, nil, "", 0, 0, 0).
+			AddRow(2, "db", "/srv/db", "", "", "", time.Now(), 3600, "in_sync", time.Now(), 4, "", 0, 0, 0))
+
+	// web has an active run and is skipped
+	mock.ExpectQuery(`SELECT (.+) FROM runs WHERE \(workspace = \$1 AND`).
+		WithArgs("web", database.RunStatusQueued, database.RunStatusRunning, database.RunStatusPlanned).
+		WillReturnRows(newRunRows().AddRow(3, "web", "deploy", "running", "plan", "", 1, time.Now(), time.Now(), nil, nil, "", "", []byte(`{}`)))
+	mock.ExpectQuery(`SELECT (.+) FROM runs WHERE \(workspace = \$1 AND`).
+		WithArgs("db", database.RunStatusQueued, database.RunStatusRunning, database.RunStatusPlanned).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO runs`).
+		WithArgs("db", database.RunKindDrift, database.RunStatusQueued, "", "", `{}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	l := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewSqlDatabase(d, l)
+	s := NewDriftScheduler(db, NewQueue(db, nil, nil, nil, config.Provisioner{}, l), config.Provisioner{}, l)
+
+	s.schedule(context.TODO())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("there were unfulfilled expectations: %v", err)
+	}
+}
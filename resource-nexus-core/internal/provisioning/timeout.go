@@ -0,0 +1,34 @@
+package provisioning
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+// ErrPhaseTimeout is the cause of phases that have been interrupted because their timeout has been exceeded.
+var ErrPhaseTimeout = errors.New("phase timeout exceeded")
+
+// ResolveTimeouts returns the timeouts of the phases for runs of the workspace.
+//
+// Timeouts of the workspace override the configured timeouts. Timeouts of the workspace with the value 0 are not
+// overridden.
+func ResolveTimeouts(conf config.Timeouts, workspace database.Workspace) config.Timeouts {
+	timeouts := conf
+
+	if workspace.InitTimeout > 0 {
+		timeouts.Init = time.Duration(workspace.InitTimeout) * time.Second
+	}
+
+	if workspace.PlanTimeout > 0 {
+		timeouts.Plan = time.Duration(workspace.PlanTimeout) * time.Second
+	}
+
+	if workspace.ApplyTimeout > 0 {
+		timeouts.Apply = time.Duration(workspace.ApplyTimeout) * time.Second
+	}
+
+	return timeouts
+}
@@ -0,0 +1,23 @@
+package provisioning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+)
+
+func TestResolveTimeouts(t *testing.T) {
+	conf := config.Timeouts{Init: 10 * time.Minute, Plan: 30 * time.Minute, Apply: time.Hour}
+
+	timeouts := ResolveTimeouts(conf, database.Workspace{Name: "web"})
+	if timeouts != conf {
+		t.Fatalf("configured timeouts expected, got %+v", timeouts)
+	}
+
+	timeouts = ResolveTimeouts(conf, database.Workspace{Name: "web", PlanTimeout: 60, ApplyTimeout: 7200})
+	if timeouts.Init != 10*time.Minute || timeouts.Plan != time.Minute || timeouts.Apply != 2*time.Hour {
+		t.Fatalf("timeouts of the workspace expected, got %+v", timeouts)
+	}
+}
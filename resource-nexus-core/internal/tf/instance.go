@@ -3,7 +3,6 @@ package tf
 import (
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/tbauriedel/resource-nexus-core/internal/common/fileutils"
 )
@@ -13,7 +12,6 @@ type TerraformInstance struct {
 	ExecutablePath    string
 	BaseDir           string
 	tmpWorkDir        string
-	CommandTimeout    time.Duration
 	ConfigCreated     bool
 	WorkspacePrepared bool
 }
@@ -52,7 +50,6 @@ func getDefaults() *TerraformInstance {
 		BaseDir:           "/tmp",
 		ConfigCreated:     false,
 		WorkspacePrepared: false,
-		CommandTimeout:    10 * time.Minute,
 	}
 }
 
@@ -0,0 +1,128 @@
+package tfevent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// maxLineSize is the maximum size of a single line in the machine-readable output.
+// Planned changes of large resources can produce long lines, so the default of bufio.Scanner is not enough.
+const maxLineSize = 1024 * 1024
+
+// Event is a single decoded line of the machine-readable output.
+//
+// BaseEvent holds the common fields. Payload holds the typed event (e.g. *PlannedChangeEvent) if the type is known.
+// Raw is the original line as printed by the provisioner.
+type Event struct {
+	BaseEvent
+	Payload any
+	Raw     []byte
+}
+
+// Decoder reads events from the machine-readable output (`-json`) of terraform or opentofu.
+type Decoder struct {
+	scanner *bufio.Scanner
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &Decoder{
+		scanner: scanner,
+	}
+}
+
+// Next returns the next event from the stream.
+//
+// Returns io.EOF once the stream is fully consumed.
+// Lines that are no valid JSON are returned as event of type 'log' with the line as message.
+func (d *Decoder) Next() (Event, error) {
+	for d.scanner.Scan() {
+		line := d.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		// copy the line. the scanner reuses its buffer on the next call
+		raw := make([]byte, len(line))
+		copy(raw, line)
+
+		return Decode(raw), nil
+	}
+
+	err := d.scanner.Err()
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to read event stream: %w", err)
+	}
+
+	return Event{}, io.EOF
+}
+
+// Decode decodes a single line of the machine-readable output.
+//
+// If the line can't be decoded, an event of type 'log' is returned with the line as message.
+func Decode(line []byte) Event {
+	var base BaseEvent
+
+	err := json.Unmarshal(line, &base)
+	if err != nil {
+		return Event{
+			BaseEvent: BaseEvent{
+				Level:     "info",
+				Message:   string(line),
+				Timestamp: time.Now(),
+				Type:      EventTypeLog,
+			},
+			Raw: line,
+		}
+	}
+
+	return Event{
+		BaseEvent: base,
+		Payload:   decodePayload(base.Type, line),
+		Raw:       line,
+	}
+}
+
+// decodePayload decodes the line into the typed event for the given EventType.
+// Returns nil if the type is unknown or the line does not match the typed event.
+func decodePayload(t EventType, line []byte) any {
+	var payload any
+
+	switch t {
+	case EventTypeVersion:
+		payload = &EventVersion{}
+	case EventTypeLog:
+		payload = &LogEvent{}
+	case EventTypeDiagnostic:
+		payload = &DiagnosticEvent{}
+	case EventTypeInitOutput:
+		payload = &InitOutputEvent{}
+	case EventTypePlannedChange:
+		payload = &PlannedChangeEvent{}
+	case EventTypeChangeSummary:
+		payload = &ChangeSummaryEvent{}
+	case EventTypeApplyStart:
+		payload = &ApplyStartEvent{}
+	case EventTypeApplyProgress:
+		payload = &ApplyProgressEvent{}
+	case EventTypeApplyComplete, EventTypeApplyErrored:
+		payload = &ApplyCompleteEvent{}
+	case EventTypeOutputs:
+		payload = &OutputsEvent{}
+	default:
+		return nil
+	}
+
+	err := json.Unmarshal(line, payload)
+	if err != nil {
+		return nil
+	}
+
+	return payload
+}
@@ -0,0 +1,67 @@
+package tfevent
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoderNext(t *testing.T) {
+	stream := `{"@level":"info","@message":"Terraform 1.9.5","@module":"terraform.ui","@timestamp":"2026-01-04T14:33:07.501900+01:00","terraform":"1.9.5","type":"version","ui":"1.2"}
+
+{"@level":"info","@message":"vm: Plan to create","@module":"terraform.ui","@timestamp":"2026-01-04T14:33:08.000000+01:00","change":{"resource":{"addr":"proxmox_vm_qemu.vm","resource_type":"proxmox_vm_qemu","resource_name":"vm"},"action":"create"},"type":"planned_change"}
+this is not json
+`
+
+	d := NewDecoder(strings.NewReader(stream))
+
+	// version event
+	e, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Type != EventTypeVersion {
+		t.Fatalf("wrong event type: %s", e.Type)
+	}
+
+	v, ok := e.Payload.(*EventVersion)
+	if !ok || v.Terraform != "1.9.5" {
+		t.Fatalf("wrong payload: %v", e.Payload)
+	}
+
+	// planned change event. empty line is skipped
+	e, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := e.Payload.(*PlannedChangeEvent)
+	if !ok || c.Change.Resource.Addr != "proxmox_vm_qemu.vm" || c.Change.Action != "create" {
+		t.Fatalf("wrong payload: %v", e.Payload)
+	}
+
+	// plain text line is returned as log event
+	e, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Type != EventTypeLog || e.Message != "this is not json" {
+		t.Fatalf("wrong event returned: %v", e)
+	}
+
+	_, err = d.Next()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecodeUnknownType(t *testing.T) {
+	e := Decode([]byte(`{"@level":"info","@message":"foo","type":"something_new"}`))
+
+	if e.Type != "something_new" || e.Payload != nil {
+		t.Fatalf("wrong event returned: %v", e)
+	}
+}
@@ -4,6 +4,23 @@ import "time"
 
 type EventType string
 
+const (
+	EventTypeVersion         EventType = "version"
+	EventTypeLog             EventType = "log"
+	EventTypeDiagnostic      EventType = "diagnostic"
+	EventTypeInitOutput      EventType = "init_output"
+	EventTypePlannedChange   EventType = "planned_change"
+	EventTypeChangeSummary   EventType = "change_summary"
+	EventTypeApplyStart      EventType = "apply_start"
+	EventTypeApplyProgress   EventType = "apply_progress"
+	EventTypeApplyComplete   EventType = "apply_complete"
+	EventTypeApplyErrored    EventType = "apply_errored"
+	EventTypeOutputs         EventType = "outputs"
+	EventTypeResourceDrift   EventType = "resource_drift"
+	EventTypeRefreshStart    EventType = "refresh_start"
+	EventTypeRefreshComplete EventType = "refresh_complete"
+)
+
 // BaseEvent represents the base event structure. Each EventType builds on that base.
 type BaseEvent struct {
 	Level     string    `json:"@level"`
@@ -25,6 +42,12 @@ type LogEvent struct {
 	BaseEvent
 }
 
+// DiagnosticEvent represents the event type 'diagnostic'.
+type DiagnosticEvent struct {
+	BaseEvent
+	Diagnostic Diagnostic `json:"diagnostic"`
+}
+
 // InitOutputEvent represents the event type 'init_output'.
 type InitOutputEvent struct {
 	BaseEvent
@@ -67,6 +90,13 @@ type OutputsEvent struct {
 	Outputs Outputs
 }
 
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Address  string `json:"address,omitempty"`
+}
+
 type Change struct {
 	Resource Resource `json:"resource"`
 	Action   string   `json:"action"`
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"github.com/tbauriedel/resource-nexus-core/test/faketerraform"
+)
+
+// fake-terraform is a scriptable replacement for terraform and opentofu.
+// It replays the scenario inside the working directory. See faketerraform.Scenario.
+func main() {
+	os.Exit(faketerraform.Main())
+}
@@ -0,0 +1,411 @@
+package faketerraform
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ScenarioFile is the file inside the working directory the scenario is read from.
+	ScenarioFile = "fake-terraform.json"
+	// InvocationsFile is the file inside the working directory each invocation is recorded to.
+	InvocationsFile = ".fake-terraform-invocations"
+
+	// DefaultVersion is printed by `version -json` if the scenario has no version.
+	DefaultVersion = "1.9.5"
+
+	// InterruptExit exits the command once it has been interrupted. This is the default.
+	InterruptExit = "exit"
+	// InterruptIgnore ignores interrupts. The command has to be killed.
+	InterruptIgnore = "ignore"
+)
+
+// Scenario scripts the responses of the fake executable.
+type Scenario struct {
+	Version  string                `json:"version"`  // printed by `version -json`
+	Commands map[string][]Response `json:"commands"` // responses by subcommand. e.g. "plan" or "state pull"
+}
+
+// Response is the scripted behavior of a single invocation of a subcommand.
+//
+// The n-th invocation of a subcommand gets the n-th response. The last response is repeated.
+// The events are printed first, followed by stdout and stderr. Afterward, the command waits for the delay and exits.
+type Response struct {
+	Events     []json.RawMessage `json:"events"`      // machine-readable events. each one is printed as single line
+	EventsFile string            `json:"events_file"` // recorded event stream. relative to the working directory
+	EventDelay string            `json:"event_delay"` // delay between events. e.g. "100ms"
+	Stdout     string            `json:"stdout"`
+	Stderr     string            `json:"stderr"`
+	Delay      string            `json:"delay"` // delay before exiting. e.g. "1h" for a hanging command
+	ExitCode   int               `json:"exit_code"`
+	Interrupt  string            `json:"interrupt"`      // InterruptExit or InterruptIgnore
+	Plan       string            `json:"plan,omitempty"` // content of the plan file written by `plan -out`
+}
+
+// Invocation is a recorded invocation of the fake executable.
+type Invocation struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// errInterrupted is returned if the command has been interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// WriteScenario writes the scenario into the working directory.
+func WriteScenario(dir string, scenario Scenario) error {
+	data, err := json.Marshal(scenario)
+	if err != nil {
+		return fmt.Errorf("failed to encode scenario: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, ScenarioFile), data, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write scenario: %w", err)
+	}
+
+	return nil
+}
+
+// Invocations returns the recorded invocations inside the working directory in the order of their execution.
+func Invocations(dir string) ([]Invocation, error) {
+	file, err := os.Open(filepath.Join(dir, InvocationsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open invocations: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var invocations []Invocation
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var invocation Invocation
+
+		err = json.Unmarshal(scanner.Bytes(), &invocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode invocation: %w", err)
+		}
+
+		invocations = append(invocations, invocation)
+	}
+
+	return invocations, scanner.Err() //nolint:wrapcheck
+}
+
+// Main executes the fake executable with the arguments of the process inside the current working directory.
+// Returns the exit code.
+func Main() int {
+	dir, err := os.Getwd()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "fake-terraform: %s\n", err)
+
+		return 1
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	return Run(dir, os.Args[1:], os.Stdout, os.Stderr, interrupt)
+}
+
+// Run executes the fake executable with the arguments (without the name of the executable) inside dir.
+//
+// The scenario is read from dir. Interrupts are received from interrupt. Returns the exit code.
+func Run(dir string, args []string, stdout io.Writer, stderr io.Writer, interrupt <-chan os.Signal) int {
+	command := subcommand(args)
+
+	if command == "" {
+		_, _ = fmt.Fprintln(stderr, "fake-terraform: missing subcommand")
+
+		return 1
+	}
+
+	scenario, err := loadScenario(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_, _ = fmt.Fprintf(stderr, "fake-terraform: %s\n", err)
+
+		return 1
+	}
+
+	if command == "version" && len(scenario.Commands["version"]) == 0 {
+		return printVersion(scenario, stdout)
+	}
+
+	count, err := record(dir, command, args)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "fake-terraform: %s\n", err)
+
+		return 1
+	}
+
+	responses := scenario.Commands[command]
+	if len(responses) == 0 {
+		_, _ = fmt.Fprintf(stderr, "fake-terraform: no response scripted for '%s'\n", command)
+
+		return 1
+	}
+
+	response := responses[min(count, len(responses)-1)]
+
+	err = execute(dir, command, args, response, stdout, stderr, interrupt)
+	if errors.Is(err, errInterrupted) {
+		_, _ = fmt.Fprintln(stderr, "Interrupt received. Exiting.")
+
+		return 1
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "fake-terraform: %s\n", err)
+
+		return 1
+	}
+
+	return response.ExitCode
+}
+
+// subcommand returns the subcommand of the arguments. Subcommands can consist of two words. e.g. 'state pull'.
+func subcommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	if (args[0] == "state" || args[0] == "providers") && len(args) > 1 {
+		return args[0] + " " + args[1]
+	}
+
+	return args[0]
+}
+
+// loadScenario reads the scenario from dir.
+func loadScenario(dir string) (Scenario, error) {
+	var scenario Scenario
+
+	data, err := os.ReadFile(filepath.Join(dir, ScenarioFile))
+	if err != nil {
+		return scenario, err //nolint:wrapcheck
+	}
+
+	err = json.Unmarshal(data, &scenario)
+	if err != nil {
+		return scenario, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	return scenario, nil
+}
+
+// printVersion prints the version like `version -json`.
+func printVersion(scenario Scenario, stdout io.Writer) int {
+	version := scenario.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	_, _ = fmt.Fprintf(stdout, `{"terraform_version":%q,"platform":"linux_amd64"}`+"\n", version)
+
+	return 0
+}
+
+// record appends the invocation to the invocations of dir.
+// Returns the number of previous invocations of the same command.
+func record(dir string, command string, args []string) (int, error) {
+	invocations, err := Invocations(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, invocation := range invocations {
+		if invocation.Command == command {
+			count++
+		}
+	}
+
+	line, err := json.Marshal(Invocation{Command: command, Args: args})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode invocation: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, InvocationsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record invocation: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record invocation: %w", err)
+	}
+
+	return count, nil
+}
+
+// execute replays the response.
+//
+// `plan -out=<file>` writes the plan file. `apply <file>` fails like terraform if the plan file does not exist.
+func execute(
+	dir string, command string, args []string, response Response, stdout io.Writer, stderr io.Writer,
+	interrupt <-chan os.Signal,
+) error {
+	err := handleFiles(dir, command, args, response)
+	if err != nil {
+		return err
+	}
+
+	events, err := loadEvents(dir, response)
+	if err != nil {
+		return err
+	}
+
+	eventDelay, err := parseDelay(response.EventDelay)
+	if err != nil {
+		return err
+	}
+
+	delay, err := parseDelay(response.Delay)
+	if err != nil {
+		return err
+	}
+
+	ignore := response.Interrupt == InterruptIgnore
+
+	for i, event := range events {
+		if i > 0 {
+			err = wait(eventDelay, interrupt, ignore)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, _ = fmt.Fprintln(stdout, event)
+	}
+
+	_, _ = io.WriteString(stdout, response.Stdout)
+	_, _ = io.WriteString(stderr, response.Stderr)
+
+	return wait(delay, interrupt, ignore)
+}
+
+// handleFiles writes and checks the plan files of the invocation.
+func handleFiles(dir string, command string, args []string, response Response) error {
+	switch command {
+	case "plan":
+		for _, arg := range args {
+			file, ok := strings.CutPrefix(arg, "-out=")
+			if !ok {
+				continue
+			}
+
+			plan := response.Plan
+			if plan == "" {
+				plan = "fake plan"
+			}
+
+			err := os.WriteFile(resolve(dir, file), []byte(plan), 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to write plan file: %w", err)
+			}
+		}
+	case "apply":
+		if len(args) < 2 || strings.HasPrefix(args[len(args)-1], "-") {
+			return nil
+		}
+
+		last := args[len(args)-1]
+
+		_, err := os.Stat(resolve(dir, last))
+		if err != nil {
+			return fmt.Errorf("failed to load plan file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadEvents returns the events of the response. Recorded events are read line by line. Empty lines are skipped.
+func loadEvents(dir string, response Response) ([]string, error) {
+	events := make([]string, 0, len(response.Events))
+
+	for _, event := range response.Events {
+		events = append(events, string(event))
+	}
+
+	if response.EventsFile == "" {
+		return events, nil
+	}
+
+	data, err := os.ReadFile(resolve(dir, response.EventsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recorded events: %w", err)
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimSpace(line); line != "" {
+			events = append(events, line)
+		}
+	}
+
+	return events, nil
+}
+
+// parseDelay parses a delay of the response. An empty delay is 0.
+func parseDelay(delay string) (time.Duration, error) {
+	if delay == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(delay)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay: %w", err)
+	}
+
+	return d, nil
+}
+
+// wait waits for the delay. Returns errInterrupted if an interrupt is received, unless interrupts are ignored.
+func wait(delay time.Duration, interrupt <-chan os.Signal, ignore bool) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case <-interrupt:
+			if !ignore {
+				return errInterrupted
+			}
+		}
+	}
+}
+
+// resolve returns the path relative to dir. Absolute paths are returned as they are.
+func resolve(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,124 @@
+package faketerraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "plan.jsonl"), []byte(`{"type":"version"}`+"\n\n"+`{"type":"change_summary"}`+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WriteScenario(dir, Scenario{
+		Version: "1.5.7",
+		Commands: map[string][]Response{
+			"plan": {
+				{EventsFile: "plan.jsonl", Plan: "saved", ExitCode: 2},
+			},
+			"state pull": {
+				{Stdout: `{"serial":1}`},
+				{Stdout: `{"serial":2}`, Stderr: "changed"},
+			},
+			"apply": {
+				{Events: []json.RawMessage{json.RawMessage(`{"type":"apply_complete"}`)}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	// version is answered without recording
+	if Run(dir, []string{"version", "-json"}, &stdout, &stderr, nil) != 0 || !strings.Contains(stdout.String(), "1.5.7") {
+		t.Fatalf("wrong version output: %s", stdout.String())
+	}
+
+	stdout.Reset()
+
+	if code := Run(dir, []string{"plan", "--json", "-out=run.plan"}, &stdout, &stderr, nil); code != 2 {
+		t.Fatalf("wrong exit code: %d", code)
+	}
+
+	if stdout.String() != "{\"type\":\"version\"}\n{\"type\":\"change_summary\"}\n" {
+		t.Fatalf("recorded events not replayed: %s", stdout.String())
+	}
+
+	plan, err := os.ReadFile(filepath.Join(dir, "run.plan"))
+	if err != nil || string(plan) != "saved" {
+		t.Fatalf("plan file not written: %s (%v)", plan, err)
+	}
+
+	// the last response is repeated
+	for _, expected := range []string{`{"serial":1}`, `{"serial":2}`, `{"serial":2}`} {
+		stdout.Reset()
+		Run(dir, []string{"state", "pull"}, &stdout, &stderr, nil)
+
+		if stdout.String() != expected {
+			t.Fatalf("wrong response: %s. expected %s", stdout.String(), expected)
+		}
+	}
+
+	if Run(dir, []string{"apply", "--json", "missing.plan"}, &stdout, &stderr, nil) != 1 {
+		t.Fatal("apply of a missing plan file should fail")
+	}
+
+	if Run(dir, []string{"destroy"}, &stdout, &stderr, nil) != 1 {
+		t.Fatal("command without scripted response should fail")
+	}
+
+	invocations, err := Invocations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invocations) != 6 || invocations[1].Command != "state pull" || invocations[0].Args[2] != "-out=run.plan" {
+		t.Fatalf("wrong invocations recorded: %v", invocations)
+	}
+}
+
+func TestRunInterrupt(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteScenario(dir, Scenario{
+		Commands: map[string][]Response{
+			"apply":   {{Delay: "1h"}},
+			"refresh": {{Delay: "200ms", Interrupt: InterruptIgnore}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	interrupt <- syscall.SIGINT
+
+	var stderr bytes.Buffer
+
+	start := time.Now()
+
+	if Run(dir, []string{"apply"}, &bytes.Buffer{}, &stderr, interrupt) != 1 || time.Since(start) > time.Minute {
+		t.Fatal("interrupted command should exit with 1")
+	}
+
+	if !strings.Contains(stderr.String(), "Interrupt received") {
+		t.Fatalf("interrupt should be reported: %s", stderr.String())
+	}
+
+	interrupt <- syscall.SIGINT
+
+	if Run(dir, []string{"refresh"}, &bytes.Buffer{}, &bytes.Buffer{}, interrupt) != 0 {
+		t.Fatal("ignored interrupt should not stop the command")
+	}
+}
@@ -0,0 +1,283 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/config"
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/internal/logging"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning"
+	"github.com/tbauriedel/resource-nexus-core/internal/provisioning/provisioner"
+	"github.com/tbauriedel/resource-nexus-core/test/faketerraform"
+)
+
+// fakeName is the name of the fake executable. The test binary acts as fake executable if it is executed by this name.
+const fakeName = "terraform"
+
+// waitTimeout is the maximum time to wait for a run to reach a status.
+const waitTimeout = 20 * time.Second
+
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == fakeName {
+		os.Exit(faketerraform.Main())
+	}
+
+	os.Exit(m.Run())
+}
+
+// environment is a running provisioning pipeline against an in-memory database.
+type environment struct {
+	db        *database.MemoryDatabase
+	queue     *provisioning.Queue
+	workspace database.Workspace
+}
+
+// newEnvironment starts a queue with a single worker that executes the runs of a workspace with the fake executable.
+//
+// The scenario is written into the working directory of the workspace. The queue is stopped once the test finished.
+func newEnvironment(t *testing.T, scenario faketerraform.Scenario) *environment {
+	t.Helper()
+
+	return newEnvironmentWithWorkspace(t, scenario, database.Workspace{})
+}
+
+// newEnvironmentWithWorkspace is like newEnvironment, but the workspace is created with the settings of workspace.
+// Name, working directory and provisioner are always set by the environment.
+func newEnvironmentWithWorkspace(
+	t *testing.T, scenario faketerraform.Scenario, workspace database.Workspace,
+) *environment {
+	t.Helper()
+
+	ctx := context.TODO()
+	logger := logging.NewLoggerStdout(config.Logger{Type: "stdout", Level: "warn"})
+	db := database.NewMemoryDatabase()
+	dir := t.TempDir()
+
+	err := faketerraform.WriteScenario(dir, scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := config.Provisioner{
+		Executables:       []config.Executable{{Name: "terraform-fake", Type: "terraform", Path: fakeExecutable(t)}},
+		Workers:           1,
+		PollInterval:      20 * time.Millisecond,
+		MaxAttempts:       1,
+		HeartbeatInterval: 50 * time.Millisecond,
+		CancelGracePeriod: 200 * time.Millisecond,
+	}
+
+	registry := provisioner.NewRegistry(conf, logger)
+
+	err = registry.Probe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspace.Name = "web"
+	workspace.WorkingDirectory = dir
+	workspace.Provisioner = "terraform"
+
+	_, err = db.InsertWorkspace(ctx, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workspace, err = db.GetWorkspace(database.Filter{Key: "name", Operator: "=", Value: "web"}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue := provisioning.NewQueue(db, provisioning.NewRunner(db, nil, nil, nil, logger), registry, nil, conf, logger)
+	queue.Start()
+
+	t.Cleanup(func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+		defer cancel()
+
+		queue.Stop(stopCtx)
+	})
+
+	return &environment{db: db, queue: queue, workspace: workspace}
+}
+
+// fakeExecutable returns the path of the fake executable. It is a symlink to the test binary.
+func fakeExecutable(t *testing.T) string {
+	t.Helper()
+
+	binary, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), fakeName)
+
+	err = os.Symlink(binary, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// enqueue adds a run of the given kind for the workspace.
+func (e *environment) enqueue(t *testing.T, kind database.RunKind) int {
+	t.Helper()
+
+	return e.enqueueWithOptions(t, kind, database.RunOptions{})
+}
+
+// enqueueWithOptions adds a run of the given kind with the options for the workspace.
+func (e *environment) enqueueWithOptions(t *testing.T, kind database.RunKind, options database.RunOptions) int {
+	t.Helper()
+
+	id, err := e.queue.Enqueue(context.TODO(), e.workspace, kind, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// waitForStatus waits until the run reaches one of the given statuses and returns it.
+func (e *environment) waitForStatus(t *testing.T, id int, statuses ...database.RunStatus) database.Run {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for {
+		run, err := e.db.GetRun(database.Filter{Key: "id", Operator: "=", Value: id}, context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if slices.Contains(statuses, run.Status) {
+			return run
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("run %d did not reach %v. status %s: %s", id, statuses, run.Status, run.Message)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForEvents waits until events of the run and phase have been stored.
+func (e *environment) waitForEvents(t *testing.T, id int, phase string) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for len(e.events(t, id, phase)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no events of phase %s have been stored for run %d", phase, id)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForUnlock waits until the workspace is not locked anymore.
+func (e *environment) waitForUnlock(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(waitTimeout)
+
+	for {
+		locks, err := e.db.GetWorkspaceLocks(database.Filter{Key: "workspace", Operator: "=", Value: "web"}, context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(locks) == 0 {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("workspace is still locked by run %d", locks[0].RunID)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// events returns the stored events of the run and phase by their type.
+func (e *environment) events(t *testing.T, id int, phase string) map[string]int {
+	t.Helper()
+
+	events, err := e.db.GetRunEvents(database.LogicalFilter{
+		Operator: "AND",
+		Filters: []database.FilterExpr{
+			database.Filter{Key: "run_id", Operator: "=", Value: id},
+			database.Filter{Key: "phase", Operator: "=", Value: phase},
+		},
+	}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := make(map[string]int)
+
+	for _, event := range events {
+		types[event.Type]++
+	}
+
+	return types
+}
+
+// invocations returns the recorded subcommands executed inside the working directory of the workspace.
+func (e *environment) invocations(t *testing.T) []string {
+	t.Helper()
+
+	invocations, err := faketerraform.Invocations(e.workspace.WorkingDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := make([]string, 0, len(invocations))
+
+	for _, invocation := range invocations {
+		commands = append(commands, invocation.Command)
+	}
+
+	return commands
+}
+
+// testdata returns the path of a file inside test/testdata/terraform.
+func testdata(t *testing.T, name string) string {
+	t.Helper()
+
+	path, err := filepath.Abs(filepath.Join("..", "testdata", "terraform", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// readTestdata returns the content of a file inside test/testdata/terraform.
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(testdata(t, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// state returns the output of `state pull` for the given serial.
+func state(serial int) faketerraform.Response {
+	return faketerraform.Response{
+		Stdout: `{"version":4,"terraform_version":"1.9.5","serial":` + strconv.Itoa(serial) +
+			`,"lineage":"3c2bfa4e-8a61-4b51-a4fb-6f0f1b0bd2a4","outputs":{},"resources":[]}`,
+	}
+}
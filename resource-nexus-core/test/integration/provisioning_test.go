@@ -0,0 +1,281 @@
+package integration
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tbauriedel/resource-nexus-core/internal/database"
+	"github.com/tbauriedel/resource-nexus-core/test/faketerraform"
+)
+
+func TestDeploy(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{EventsFile: testdata(t, "init.jsonl")}},
+			"state pull": {state(3)},
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+			"show":       {{Stdout: readTestdata(t, "plan.json")}},
+			"apply":      {{EventsFile: testdata(t, "apply.jsonl"), EventDelay: "10ms"}},
+		},
+	})
+
+	id := env.enqueue(t, database.RunKindDeploy)
+
+	run := env.waitForStatus(t, id, database.RunStatusPlanned, database.RunStatusErrored)
+	if run.Status != database.RunStatusPlanned {
+		t.Fatalf("run should be planned: %s", run.Message)
+	}
+
+	if planned := env.events(t, id, "plan"); planned["planned_change"] != 2 || planned["change_summary"] != 1 {
+		t.Fatalf("events of the plan should be stored: %v", planned)
+	}
+
+	artifacts, err := env.db.GetRunArtifacts(database.Filter{Key: "run_id", Operator: "=", Value: id}, context.TODO())
+	if err != nil || len(artifacts) != 3 {
+		t.Fatalf("saved plan should be stored: %v (%v)", artifacts, err)
+	}
+
+	_, err = env.db.ApproveRun(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run = env.waitForStatus(t, id, database.RunStatusApplied, database.RunStatusErrored)
+	if run.Status != database.RunStatusApplied {
+		t.Fatalf("run should be applied: %s", run.Message)
+	}
+
+	if applied := env.events(t, id, "apply"); applied["apply_complete"] != 2 {
+		t.Fatalf("events of the apply should be stored: %v", applied)
+	}
+
+	expected := []string{"init", "state pull", "plan", "show", "init", "state pull", "apply"}
+	if commands := env.invocations(t); !slices.Equal(commands, expected) {
+		t.Fatalf("wrong commands executed: %v", commands)
+	}
+
+	// the lock is released once the run has been finished
+	env.waitForUnlock(t)
+}
+
+func TestPlanFailed(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{EventsFile: testdata(t, "init.jsonl")}},
+			"state pull": {state(3)},
+			"plan": {{
+				Stderr:   "Error: No value for required variable\n",
+				ExitCode: 1,
+			}},
+		},
+	})
+
+	id := env.enqueue(t, database.RunKindDeploy)
+
+	run := env.waitForStatus(t, id, database.RunStatusErrored, database.RunStatusPlanned)
+	if run.Status != database.RunStatusErrored || run.Message != "plan failed with exit code 1" {
+		t.Fatalf("run should fail in plan. got %s: %s", run.Status, run.Message)
+	}
+
+	phases, err := env.db.GetRunPhases(database.Filter{Key: "phase", Operator: "=", Value: "plan"}, context.TODO())
+	if err != nil || len(phases) != 1 || !strings.Contains(phases[0].Stderr, "required variable") {
+		t.Fatalf("stderr of the plan should be stored: %v (%v)", phases, err)
+	}
+}
+
+func TestStalePlan(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{}},
+			"state pull": {state(3), state(4)}, // the state changed between plan and apply
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+			"show":       {{Stdout: readTestdata(t, "plan.json")}},
+			"apply":      {{}},
+		},
+	})
+
+	id := env.enqueue(t, database.RunKindDeploy)
+	env.waitForStatus(t, id, database.RunStatusPlanned)
+
+	_, err := env.db.ApproveRun(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := env.waitForStatus(t, id, database.RunStatusStale, database.RunStatusApplied, database.RunStatusErrored)
+	if run.Status != database.RunStatusStale {
+		t.Fatalf("run should be stale. got %s: %s", run.Status, run.Message)
+	}
+
+	if slices.Contains(env.invocations(t), "apply") {
+		t.Fatal("stale plan should never be applied")
+	}
+}
+
+func TestCancelHangingApply(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{}},
+			"state pull": {state(3)},
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+			"show":       {{Stdout: readTestdata(t, "plan.json")}},
+			// the clone hangs and terraform does not react to the interrupt
+			"apply": {{EventsFile: testdata(t, "apply.jsonl"), Delay: "1h", Interrupt: faketerraform.InterruptIgnore}},
+		},
+	})
+
+	id := env.enqueue(t, database.RunKindDeploy)
+	env.waitForStatus(t, id, database.RunStatusPlanned)
+
+	_, err := env.db.ApproveRun(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the apply to hang
+	env.waitForEvents(t, id, "apply")
+
+	_, ok, err := env.queue.Cancel(context.TODO(), id, "dummy")
+	if err != nil || !ok {
+		t.Fatalf("running run should be canceled: %v", err)
+	}
+
+	run := env.waitForStatus(t, id, database.RunStatusCanceled, database.RunStatusApplied, database.RunStatusErrored)
+	if run.Status != database.RunStatusCanceled || run.Phase != "apply" {
+		t.Fatalf("run should be canceled during apply. got %s: %s", run.Status, run.Message)
+	}
+
+	// the event log until the interruption is kept
+	if applied := env.events(t, id, "apply"); applied["apply_complete"] != 2 {
+		t.Fatalf("events of the interrupted apply should be kept: %v", applied)
+	}
+}
+
+func TestApplyTimeout(t *testing.T) {
+	env := newEnvironmentWithWorkspace(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{}},
+			"state pull": {state(3)},
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+			"show":       {{Stdout: readTestdata(t, "plan.json")}},
+			// the clone hangs. terraform exits once it is interrupted
+			"apply": {{EventsFile: testdata(t, "apply.jsonl"), Delay: "1h"}},
+		},
+	}, database.Workspace{ApplyTimeout: 1})
+
+	id := env.enqueue(t, database.RunKindDeploy)
+	env.waitForStatus(t, id, database.RunStatusPlanned)
+
+	_, err := env.db.ApproveRun(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := env.waitForStatus(t, id, database.RunStatusTimedOut, database.RunStatusApplied, database.RunStatusErrored)
+	if run.Status != database.RunStatusTimedOut || run.Phase != "apply" {
+		t.Fatalf("run should time out during apply. got %s: %s", run.Status, run.Message)
+	}
+
+	if !strings.Contains(run.Message, "apply did not finish within 1s") {
+		t.Fatalf("run message should contain the timeout: %s", run.Message)
+	}
+
+	// the event log until the timeout is kept and the workspace is unlocked
+	if applied := env.events(t, id, "apply"); applied["apply_complete"] != 2 {
+		t.Fatalf("events of the timed out apply should be kept: %v", applied)
+	}
+
+	env.waitForUnlock(t)
+}
+
+func TestDriftDetection(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init": {{}},
+			"plan": {{EventsFile: testdata(t, "drift.jsonl"), ExitCode: 2}},
+		},
+	})
+
+	id := env.enqueue(t, database.RunKindDrift)
+
+	run := env.waitForStatus(t, id, database.RunStatusCompleted, database.RunStatusErrored)
+	if run.Status != database.RunStatusCompleted || !strings.Contains(run.Message, "1 resources changed") {
+		t.Fatalf("drift should be detected. got %s: %s", run.Status, run.Message)
+	}
+
+	workspace, err := env.db.GetWorkspace(database.Filter{Key: "name", Operator: "=", Value: "web"}, context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if workspace.DriftStatus != database.DriftStatusDrifted || *workspace.DriftRunID != id {
+		t.Fatalf("drift status of the workspace should be stored: %v", workspace)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{}},
+			"state pull": {{Stdout: readTestdata(t, "state.json")}},
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+			"show":       {{Stdout: readTestdata(t, "plan.json")}},
+		},
+	})
+
+	// only the broken vm is rebuilt
+	id := env.enqueueWithOptions(t, database.RunKindDeploy, database.RunOptions{
+		Targets: []string{"proxmox_vm_qemu.web"},
+		Replace: []string{"proxmox_vm_qemu.web[1]"},
+	})
+
+	run := env.waitForStatus(t, id, database.RunStatusPlanned, database.RunStatusErrored)
+	if run.Status != database.RunStatusPlanned {
+		t.Fatalf("run should be planned: %s", run.Message)
+	}
+
+	if !slices.Equal(run.Options.Replace, []string{"proxmox_vm_qemu.web[1]"}) {
+		t.Fatalf("options of the run should be stored: %+v", run.Options)
+	}
+
+	invocations, err := faketerraform.Invocations(env.workspace.WorkingDirectory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := invocations[slices.IndexFunc(invocations, func(i faketerraform.Invocation) bool {
+		return i.Command == "plan"
+	})]
+
+	if !slices.Contains(plan.Args, "-target=proxmox_vm_qemu.web") ||
+		!slices.Contains(plan.Args, "-replace=proxmox_vm_qemu.web[1]") {
+		t.Fatalf("options should be passed to plan: %v", plan.Args)
+	}
+}
+
+func TestUnknownAddress(t *testing.T) {
+	env := newEnvironment(t, faketerraform.Scenario{
+		Commands: map[string][]faketerraform.Response{
+			"init":       {{}},
+			"state pull": {{Stdout: readTestdata(t, "state.json")}},
+			"plan":       {{EventsFile: testdata(t, "plan.jsonl")}},
+		},
+	})
+
+	id := env.enqueueWithOptions(t, database.RunKindDeploy, database.RunOptions{
+		Replace: []string{"proxmox_vm_qemu.web[5]"},
+	})
+
+	run := env.waitForStatus(t, id, database.RunStatusPlanned, database.RunStatusErrored)
+	if run.Status != database.RunStatusErrored || !strings.Contains(run.Message, "proxmox_vm_qemu.web[5]") {
+		t.Fatalf("run should fail with the unknown address. got %s: %s", run.Status, run.Message)
+	}
+
+	// nothing is planned for an unknown address
+	expected := []string{"init", "state pull"}
+	if commands := env.invocations(t); !slices.Equal(commands, expected) {
+		t.Fatalf("wrong commands executed: %v", commands)
+	}
+}